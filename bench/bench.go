@@ -0,0 +1,116 @@
+// Package bench measures one workload against the mock MySQL server and a
+// MySQL container alike, to quantify how far the latency of the mock is from
+// the one of a real server before relying on it, e.g. for timeouts.
+package bench
+
+import (
+	"context"
+	"github.com/dennis2006/mtest/container"
+	"github.com/dennis2006/mtest/mysql"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// EnvBackends enables the container backend of CompareBackends: "both" or
+// "container". Only the mock runs by default, so benchmarks don't need docker.
+const EnvBackends = "MTEST_BENCH"
+
+type options struct {
+	schema        []string
+	containerOpts []container.Option
+	configure     func(b *mysql.MockBuilder)
+}
+
+// Option configures CompareBackends
+type Option func(*options)
+
+// WithSchema runs the statements on each backend before the workload, e.g.
+// the CREATE TABLE and seed rows it needs
+func WithSchema(stmts ...string) Option {
+	return func(o *options) {
+		o.schema = append(o.schema, stmts...)
+	}
+}
+
+// WithContainerOptions configures the MySQL container, e.g. its image
+func WithContainerOptions(opts ...container.Option) Option {
+	return func(o *options) {
+		o.containerOpts = append(o.containerOpts, opts...)
+	}
+}
+
+// WithMockBuilder configures the builder of the mock, e.g. to add functions
+func WithMockBuilder(configure func(b *mysql.MockBuilder)) Option {
+	return func(o *options) {
+		o.configure = configure
+	}
+}
+
+// CompareBackends runs body b.N times as the sub-benchmarks "mock" and
+// "container", the latter only when EnvBackends enables it, each reporting
+// the latency per op of its backend. When both ran, their ratio is logged.
+// The backends are started once and shared by the runs of a sub-benchmark
+// with a growing b.N, so body must leave the data as it found it.
+func CompareBackends(b *testing.B, body func(db *sqlx.DB), opts ...Option) {
+	b.Helper()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv(EnvBackends)))
+
+	// a benchmark running sub-benchmarks runs once, unlike them
+	var mockOp, containerOp time.Duration
+	if mode != "container" {
+		db := mockDB(b, o)
+		b.Run("mock", func(b *testing.B) {
+			mockOp = run(b, db, body)
+		})
+	}
+	if mode == "container" || mode == "both" {
+		db := containerDB(b, o)
+		b.Run("container", func(b *testing.B) {
+			containerOp = run(b, db, body)
+		})
+	}
+	if mockOp > 0 && containerOp > 0 {
+		b.Logf("mock %s/op, container %s/op, the mock runs %.1fx as fast",
+			mockOp, containerOp, float64(containerOp)/float64(mockOp))
+	}
+}
+
+// run runs body b.N times and returns its latency per op
+func run(b *testing.B, db *sqlx.DB, body func(db *sqlx.DB)) time.Duration {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body(db)
+	}
+	b.StopTimer()
+	return b.Elapsed() / time.Duration(b.N)
+}
+
+func mockDB(b *testing.B, o *options) *sqlx.DB {
+	builder := mysql.Builder().Logf(b.Logf)
+	if o.configure != nil {
+		o.configure(builder)
+	}
+	db, _, shutdown, err := builder.SQLStmts(o.schema...).Build()
+	if err != nil {
+		b.Fatalf("failed to start mock: %v", err)
+	}
+	b.Cleanup(shutdown)
+	return db
+}
+
+func containerDB(b *testing.B, o *options) *sqlx.DB {
+	c := container.CreateMySQLContainerT(b, o.containerOpts...)
+	for _, stmt := range o.schema {
+		if _, err := c.Db.ExecContext(context.Background(), stmt); err != nil {
+			b.Fatalf("failed to run schema statement '%s': %v", stmt, err)
+		}
+	}
+	return c.Db
+}
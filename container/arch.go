@@ -0,0 +1,122 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"strings"
+)
+
+// ArchError is returned by the helpers when their image doesn't run on the
+// architecture of the docker daemon, instead of a container crashing under
+// emulation with a cryptic error
+type ArchError struct {
+	Service Service
+	Image   string
+	// ImageArch is the architecture of the image, empty when the helper has
+	// no image for the daemon
+	ImageArch  string
+	DaemonArch string
+}
+
+func (e *ArchError) Error() string {
+	var sb strings.Builder
+	if e.ImageArch == "" {
+		fmt.Fprintf(&sb, "%s has no known image for the %s docker daemon", e.Service, e.DaemonArch)
+	} else {
+		fmt.Fprintf(&sb, "the %s image %s is built for %s, the docker daemon runs on %s", e.Service, e.Image, e.ImageArch, e.DaemonArch)
+	}
+	fmt.Fprintf(&sb, ": pass an image built for %s with WithImageOverride(%q, image), "+
+		"or skip the test with MTEST_SKIP=%s", e.DaemonArch, e.DaemonArch, e.Service)
+	return sb.String()
+}
+
+// dorisImages are the images of the Doris helper by daemon architecture, the
+// StarRocks allin1 image is published for both
+var dorisImages = map[string]string{
+	"amd64": DorisImage,
+	"arm64": DorisImage,
+}
+
+// WithImageOverride runs image when the docker daemon runs on arch, "amd64"
+// or "arm64", e.g. a locally built arm64 image on Apple Silicon. Unlike
+// WithImage, the image is not checked against the architecture of the daemon,
+// so an amd64 image can be forced under emulation.
+func WithImageOverride(arch, image string) Option {
+	return func(o *options) {
+		if o.archImages == nil {
+			o.archImages = make(map[string]string)
+		}
+		o.archImages[normalizeArch(arch)] = image
+	}
+}
+
+// normalizeArch returns the GOARCH name of an architecture reported by the
+// daemon or an image, e.g. amd64 for x86_64
+func normalizeArch(arch string) string {
+	switch arch = strings.ToLower(arch); arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64", "arm64/v8":
+		return "arm64"
+	}
+	return arch
+}
+
+// daemonArch returns the architecture of the docker daemon, which differs
+// from the one of the process when the daemon is remote
+func daemonArch(ctx context.Context) (string, error) {
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return "", fmt.Errorf("%w\n%s", err, Doctor(ctx))
+	}
+	defer func() { _ = provider.Close() }()
+
+	info, err := provider.Client().Info(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get docker info: %w", err)
+	}
+	return normalizeArch(info.Architecture), nil
+}
+
+// archImage returns the image of the helper for the daemon architecture, of
+// WithImageOverride, WithImage or the helper's images by architecture, and
+// whether the image must be checked with checkImageArch once pulled
+func (o *options) archImage(ctx context.Context, service Service, images map[string]string) (string, bool, error) {
+	arch, err := daemonArch(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if img, ok := o.archImages[arch]; ok {
+		return img, false, nil
+	}
+	if o.image != "" {
+		return o.image, true, nil
+	}
+	if img, ok := images[arch]; ok {
+		return img, true, nil
+	}
+	return "", false, &ArchError{Service: service, DaemonArch: arch}
+}
+
+// checkImageArch checks the pulled image runs on the daemon architecture
+func checkImageArch(ctx context.Context, service Service, img string) error {
+	arch, err := daemonArch(ctx)
+	if err != nil {
+		return err
+	}
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = provider.Close() }()
+
+	inspect, err := provider.Client().ImageInspect(ctx, img)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image %s: %w", img, err)
+	}
+	if imageArch := normalizeArch(inspect.Architecture); imageArch != "" && imageArch != arch {
+		return &ArchError{Service: service, Image: img, ImageArch: imageArch, DaemonArch: arch}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/client"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DockerHost is a docker daemon endpoint found by Doctor
+type DockerHost struct {
+	// Host is the daemon address, e.g. "unix:///var/run/docker.sock"
+	Host string
+	// Source tells where the host was found, e.g. "DOCKER_HOST" or "podman rootless socket"
+	Source        string
+	Reachable     bool
+	Err           error
+	ServerVersion string
+	Podman        bool
+	Rootless      bool
+}
+
+// DoctorReport is the result of Doctor
+type DoctorReport struct {
+	// Hosts are the candidate daemons in the order they are tried,
+	// the first reachable one is Selected.
+	Hosts    []DockerHost
+	Selected *DockerHost
+	Hints    []string
+}
+
+// OK reports whether a reachable daemon was found
+func (r *DoctorReport) OK() bool {
+	return r.Selected != nil
+}
+
+func (r *DoctorReport) String() string {
+	var sb strings.Builder
+	for _, h := range r.Hosts {
+		switch {
+		case h.Reachable:
+			engine := "docker"
+			if h.Podman {
+				engine = "podman"
+			}
+			if h.Rootless {
+				engine += " rootless"
+			}
+			fmt.Fprintf(&sb, "[ok]   %s (%s): %s %s\n", h.Host, h.Source, engine, h.ServerVersion)
+		default:
+			fmt.Fprintf(&sb, "[fail] %s (%s): %v\n", h.Host, h.Source, h.Err)
+		}
+	}
+	if len(r.Hosts) == 0 {
+		sb.WriteString("[fail] no docker or podman socket found\n")
+	}
+	for _, hint := range r.Hints {
+		sb.WriteString("hint: " + hint + "\n")
+	}
+	return sb.String()
+}
+
+// Doctor looks for a docker compatible daemon (DOCKER_HOST, the docker socket,
+// rootless and rootful podman sockets, docker desktop, colima and rancher
+// desktop), checks every candidate is reachable, and
+// returns a report with hints to fix the setup. Print it when a helper fails
+// with an opaque testcontainers error.
+func Doctor(ctx context.Context) *DoctorReport {
+	report := &DoctorReport{}
+	for _, candidate := range dockerHostCandidates() {
+		h := pingDockerHost(ctx, candidate.Host, candidate.Source)
+		report.Hosts = append(report.Hosts, h)
+		if h.Reachable && report.Selected == nil {
+			report.Selected = &report.Hosts[len(report.Hosts)-1]
+		}
+	}
+	report.Hints = doctorHints(report)
+	return report
+}
+
+// UseDetectedDockerHost runs Doctor and points testcontainers at the selected
+// daemon by setting DOCKER_HOST when it is not set, and for podman also the
+// settings its reaper needs. testcontainers reads its configuration once, so
+// call it before any container is created, e.g. in TestMain.
+func UseDetectedDockerHost(ctx context.Context) (*DoctorReport, error) {
+	report := Doctor(ctx)
+	if !report.OK() {
+		return report, fmt.Errorf("no reachable docker daemon:\n%s", report)
+	}
+
+	selected := report.Selected
+	if os.Getenv("DOCKER_HOST") == "" {
+		if err := os.Setenv("DOCKER_HOST", selected.Host); err != nil {
+			return report, fmt.Errorf("failed to set DOCKER_HOST: %w", err)
+		}
+	}
+	if selected.Podman && os.Getenv("TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED") == "" {
+		if err := os.Setenv("TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED", "true"); err != nil {
+			return report, fmt.Errorf("failed to set TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED: %w", err)
+		}
+	}
+	if selected.Podman && strings.HasPrefix(selected.Host, "unix://") &&
+		os.Getenv("TESTCONTAINERS_DOCKER_SOCKET_OVERRIDE") == "" {
+		// the reaper mounts the socket, which is the podman socket path
+		// inside the podman machine as well
+		if err := os.Setenv("TESTCONTAINERS_DOCKER_SOCKET_OVERRIDE", strings.TrimPrefix(selected.Host, "unix://")); err != nil {
+			return report, fmt.Errorf("failed to set TESTCONTAINERS_DOCKER_SOCKET_OVERRIDE: %w", err)
+		}
+	}
+	return report, nil
+}
+
+type dockerHostCandidate struct {
+	Host   string
+	Source string
+}
+
+func dockerHostCandidates() []dockerHostCandidate {
+	var candidates []dockerHostCandidate
+	seen := make(map[string]bool)
+	add := func(host, source string) {
+		if host == "" || seen[host] {
+			return
+		}
+		seen[host] = true
+		candidates = append(candidates, dockerHostCandidate{Host: host, Source: source})
+	}
+	addSocket := func(path, source string) {
+		if _, err := os.Stat(path); err == nil {
+			add("unix://"+path, source)
+		}
+	}
+
+	add(os.Getenv("DOCKER_HOST"), "DOCKER_HOST")
+	addSocket("/var/run/docker.sock", "docker socket")
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		addSocket(filepath.Join(runtimeDir, "podman", "podman.sock"), "podman rootless socket")
+		addSocket(filepath.Join(runtimeDir, "docker.sock"), "docker rootless socket")
+	}
+	addSocket(fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()), "podman rootless socket")
+	addSocket(fmt.Sprintf("/run/user/%d/docker.sock", os.Getuid()), "docker rootless socket")
+	addSocket("/run/podman/podman.sock", "podman socket")
+	if home, err := os.UserHomeDir(); err == nil {
+		addSocket(filepath.Join(home, ".docker", "run", "docker.sock"), "docker desktop socket")
+		addSocket(filepath.Join(home, ".colima", "default", "docker.sock"), "colima socket")
+		addSocket(filepath.Join(home, ".rd", "docker.sock"), "rancher desktop socket")
+		addSocket(filepath.Join(home, ".local", "share", "containers", "podman", "machine", "podman.sock"), "podman machine socket")
+	}
+	return candidates
+}
+
+func pingDockerHost(ctx context.Context, host, source string) DockerHost {
+	h := DockerHost{Host: host, Source: source}
+
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		h.Err = fmt.Errorf("invalid docker host: %w", err)
+		return h
+	}
+	defer func() { _ = cli.Close() }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		h.Err = err
+		return h
+	}
+	h.Reachable = true
+	h.ServerVersion = version.Version
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			h.Podman = true
+		}
+	}
+
+	if info, err := cli.Info(ctx); err == nil {
+		for _, opt := range info.SecurityOptions {
+			if strings.Contains(opt, "rootless") {
+				h.Rootless = true
+			}
+		}
+	}
+	return h
+}
+
+func doctorHints(report *DoctorReport) []string {
+	var hints []string
+	if !report.OK() {
+		hints = append(hints,
+			"start docker, or start the podman socket with 'systemctl --user enable --now podman.socket'",
+			"for a remote daemon set DOCKER_HOST, e.g. tcp://host:2375")
+		for _, h := range report.Hosts {
+			if h.Err != nil && strings.Contains(h.Err.Error(), "permission denied") {
+				hints = append(hints, fmt.Sprintf("no permission on %s, add the user to the docker group", h.Host))
+				break
+			}
+		}
+		return hints
+	}
+
+	selected := report.Selected
+	if os.Getenv("DOCKER_HOST") == "" && selected.Source != "docker socket" {
+		hints = append(hints, fmt.Sprintf("testcontainers may not find %s by itself, set DOCKER_HOST=%s "+
+			"or call UseDetectedDockerHost", selected.Host, selected.Host))
+	}
+	if selected.Podman && os.Getenv("TESTCONTAINERS_RYUK_DISABLED") == "" &&
+		os.Getenv("TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED") == "" {
+		hints = append(hints, "the reaper container needs TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED=true on podman, "+
+			"or disable it with TESTCONTAINERS_RYUK_DISABLED=true")
+	}
+	return hints
+}
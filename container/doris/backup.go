@@ -0,0 +1,237 @@
+package doris
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Repository is an S3 compatible location holding the snapshots of
+// Backup, e.g. a MinIO bucket
+type S3Repository struct {
+	// Name is the name of the repository in Doris
+	Name string
+	// Location is the prefix of the snapshots, e.g. "s3://bucket/doris",
+	// StarRocks gets it as s3a://
+	Location string
+	// Endpoint is the URL of the S3 API as reachable from the container,
+	// e.g. "http://172.17.0.3:9000"
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	// Region is "us-east-1" if empty
+	Region string
+}
+
+// CreateRepository creates the repository unless it exists. A repository
+// created on a location already holding snapshots, e.g. of an earlier run,
+// lists them, so they can be restored into a fresh container.
+func (c *Container) CreateRepository(ctx context.Context, repo S3Repository) error {
+	db, err := connectRoot(ctx, c, c.password, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	repos, err := showRows(ctx, db, "SHOW REPOSITORIES")
+	if err != nil {
+		return fmt.Errorf("failed to show repositories: %w", err)
+	}
+	for _, r := range repos {
+		if r["RepoName"] == repo.Name {
+			return nil
+		}
+	}
+
+	region := repo.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	var stmt string
+	if c.flavor == Doris {
+		props := []string{
+			quoteProperty("s3.endpoint") + " = " + quoteProperty(repo.Endpoint),
+			quoteProperty("s3.region") + " = " + quoteProperty(region),
+			quoteProperty("s3.access_key") + " = " + quoteProperty(repo.AccessKey),
+			quoteProperty("s3.secret_key") + " = " + quoteProperty(repo.SecretKey),
+			quoteProperty("use_path_style") + " = " + quoteProperty("true"),
+		}
+		stmt = "CREATE REPOSITORY " + quoteIdent(repo.Name) + " WITH S3 ON LOCATION " + quoteProperty(repo.Location) +
+			" PROPERTIES (" + strings.Join(props, ", ") + ")"
+	} else {
+		// StarRocks reads S3 through its broker-less s3a file system
+		props := []string{
+			quoteProperty("aws.s3.endpoint") + " = " + quoteProperty(repo.Endpoint),
+			quoteProperty("aws.s3.region") + " = " + quoteProperty(region),
+			quoteProperty("aws.s3.access_key") + " = " + quoteProperty(repo.AccessKey),
+			quoteProperty("aws.s3.secret_key") + " = " + quoteProperty(repo.SecretKey),
+			quoteProperty("aws.s3.enable_path_style_access") + " = " + quoteProperty("true"),
+			quoteProperty("aws.s3.enable_ssl") + " = " + quoteProperty(strconv.FormatBool(strings.HasPrefix(repo.Endpoint, "https://"))),
+		}
+		location := repo.Location
+		if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+			location = "s3a://" + rest
+		}
+		stmt = "CREATE REPOSITORY " + quoteIdent(repo.Name) + " WITH BROKER ON LOCATION " + quoteProperty(location) +
+			" PROPERTIES (" + strings.Join(props, ", ") + ")"
+	}
+	if _, err = db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create repository %s: %w", repo.Name, err)
+	}
+	return nil
+}
+
+// HasSnapshot reports whether the repository holds the snapshot label
+func (c *Container) HasSnapshot(ctx context.Context, repo, label string) (bool, error) {
+	db, err := connectRoot(ctx, c, c.password, "")
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	_, ok, err := snapshotTimestamp(ctx, db, repo, label)
+	return ok, err
+}
+
+// Backup snapshots the tables of the database of WithDatabase into the
+// repository as label and waits until the backup finished or the timeout
+// expires, e.g. once the database is seeded so later runs Restore it
+// instead of seeding again. A label is written once per repository.
+func (c *Container) Backup(ctx context.Context, repo, label string, timeout time.Duration) error {
+	if c.database == "" {
+		return fmt.Errorf("no database to back up, see WithDatabase")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := connectRoot(ctx, c, c.password, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	stmt := "BACKUP SNAPSHOT " + quoteIdent(c.database) + "." + quoteIdent(label) + " TO " + quoteIdent(repo)
+	if _, err = db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", label, err)
+	}
+	return waitJob(ctx, db, "SHOW BACKUP FROM "+quoteIdent(c.database), "SnapshotName", label, timeout)
+}
+
+// Restore restores the snapshot label of the repository into the database
+// of WithDatabase and waits until the restore finished or the timeout
+// expires. The tables of the snapshot must not exist yet or have the same
+// schema. The tables get a single replica, the container has one backend.
+func (c *Container) Restore(ctx context.Context, repo, label string, timeout time.Duration) error {
+	if c.database == "" {
+		return fmt.Errorf("no database to restore into, see WithDatabase")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := connectRoot(ctx, c, c.password, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ts, ok, err := snapshotTimestamp(ctx, db, repo, label)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no snapshot %s in repository %s", label, repo)
+	}
+	stmt := "RESTORE SNAPSHOT " + quoteIdent(c.database) + "." + quoteIdent(label) + " FROM " + quoteIdent(repo) +
+		" PROPERTIES (" + quoteProperty("backup_timestamp") + " = " + quoteProperty(ts) + ", " +
+		quoteProperty("replication_num") + " = " + quoteProperty("1") + ")"
+	if _, err = db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", label, err)
+	}
+	return waitJob(ctx, db, "SHOW RESTORE FROM "+quoteIdent(c.database), "Label", label, timeout)
+}
+
+// snapshotTimestamp returns the timestamp of the snapshot label in the
+// repository, false if it has none
+func snapshotTimestamp(ctx context.Context, db *sqlx.DB, repo, label string) (string, bool, error) {
+	snapshots, err := showRows(ctx, db, "SHOW SNAPSHOT ON "+quoteIdent(repo)+" WHERE SNAPSHOT = "+quoteProperty(label))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to show snapshots of %s: %w", repo, err)
+	}
+	for _, s := range snapshots {
+		if s["Snapshot"] == label && s["Status"] == "OK" {
+			return s["Timestamp"], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// waitJob polls the statement listing the backup or restore jobs until the
+// last job of the label finished, it fails if the job was cancelled
+func waitJob(ctx context.Context, db *sqlx.DB, show, labelColumn, label string, timeout time.Duration) error {
+	ticker := time.NewTicker(visiblePollInterval)
+	defer ticker.Stop()
+	for {
+		jobs, err := showRows(ctx, db, show)
+		var job map[string]string
+		for _, j := range jobs {
+			if j[labelColumn] == label {
+				job = j
+			}
+		}
+		switch job["State"] {
+		case "FINISHED":
+			return nil
+		case "CANCELLED":
+			return fmt.Errorf("job %s was cancelled: %s", label, job["Status"])
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("job %s not finished after %s: %w", label, timeout, err)
+			}
+			return fmt.Errorf("job %s not finished after %s, state %q", label, timeout, job["State"])
+		case <-ticker.C:
+		}
+	}
+}
+
+// quoteIdent quotes a database, label or repository name
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// showRows returns the rows of a SHOW statement keyed by column name
+func showRows(ctx context.Context, db *sqlx.DB, stmt string) ([]map[string]string, error) {
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, col := range cols {
+			row[col] = values[i].String
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
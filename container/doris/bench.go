@@ -0,0 +1,178 @@
+package doris
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	defaultBenchIterations = 10
+	defaultBenchTolerance  = 0.2
+)
+
+// BenchOptions configures Bench
+type BenchOptions struct {
+	// Args are the query arguments.
+	Args []any
+	// Warmup is the number of iterations run before measuring.
+	Warmup int
+	// Iterations is the number of measured iterations, 10 if zero.
+	Iterations int
+	// BaselineFile is a JSON file holding a previous BenchResult. When set, the
+	// result is compared against it; a missing file is created from the result.
+	BaselineFile string
+	// Tolerance is the allowed relative slowdown of P50/P95 against the baseline, 0.2 if zero.
+	Tolerance float64
+	// UpdateBaseline overwrites BaselineFile with the result instead of comparing.
+	UpdateBaseline bool
+}
+
+// BenchResult holds the latency distribution of a benchmarked query.
+type BenchResult struct {
+	Query      string        `json:"query"`
+	Iterations int           `json:"iterations"`
+	Min        time.Duration `json:"min"`
+	Max        time.Duration `json:"max"`
+	Mean       time.Duration `json:"mean"`
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P95        time.Duration `json:"p95"`
+	P99        time.Duration `json:"p99"`
+}
+
+// Bench runs the query repeatedly against db (reading all rows every time)
+// and measures its latency percentiles. If a baseline file is configured,
+// it returns an error when P50 or P95 regressed beyond the tolerance.
+func Bench(ctx context.Context, db *sqlx.DB, query string, opts BenchOptions) (*BenchResult, error) {
+	if opts.Iterations <= 0 {
+		opts.Iterations = defaultBenchIterations
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = defaultBenchTolerance
+	}
+
+	for i := 0; i < opts.Warmup; i++ {
+		if _, err := runBenchQuery(ctx, db, query, opts.Args); err != nil {
+			return nil, fmt.Errorf("warmup iteration %d: %w", i, err)
+		}
+	}
+
+	latencies := make([]time.Duration, 0, opts.Iterations)
+	for i := 0; i < opts.Iterations; i++ {
+		d, err := runBenchQuery(ctx, db, query, opts.Args)
+		if err != nil {
+			return nil, fmt.Errorf("iteration %d: %w", i, err)
+		}
+		latencies = append(latencies, d)
+	}
+
+	result := newBenchResult(query, latencies)
+	if opts.BaselineFile == "" {
+		return result, nil
+	}
+
+	if opts.UpdateBaseline {
+		return result, writeBenchBaseline(opts.BaselineFile, result)
+	}
+	baseline, err := readBenchBaseline(opts.BaselineFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, writeBenchBaseline(opts.BaselineFile, result)
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, result.Compare(baseline, opts.Tolerance)
+}
+
+// Compare returns an error if P50 or P95 of r is slower than the baseline by more than tolerance.
+func (r *BenchResult) Compare(baseline *BenchResult, tolerance float64) error {
+	var errs []error
+	check := func(name string, got, base time.Duration) {
+		limit := time.Duration(float64(base) * (1 + tolerance))
+		if got > limit {
+			errs = append(errs, fmt.Errorf("%s regressed: %s, baseline %s, tolerance %.0f%%",
+				name, got, base, tolerance*100))
+		}
+	}
+	check("p50", r.P50, baseline.P50)
+	check("p95", r.P95, baseline.P95)
+	if len(errs) > 0 {
+		return fmt.Errorf("bench query '%s': %w", r.Query, errors.Join(errs...))
+	}
+	return nil
+}
+
+func runBenchQuery(ctx context.Context, db *sqlx.DB, query string, args []any) (time.Duration, error) {
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func newBenchResult(query string, latencies []time.Duration) *BenchResult {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+
+	percentile := func(p float64) time.Duration {
+		idx := int(math.Ceil(p*float64(len(latencies)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return latencies[idx]
+	}
+
+	return &BenchResult{
+		Query:      query,
+		Iterations: len(latencies),
+		Min:        latencies[0],
+		Max:        latencies[len(latencies)-1],
+		Mean:       total / time.Duration(len(latencies)),
+		P50:        percentile(0.50),
+		P90:        percentile(0.90),
+		P95:        percentile(0.95),
+		P99:        percentile(0.99),
+	}
+}
+
+func readBenchBaseline(file string) (*BenchResult, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var baseline BenchResult
+	if err = json.Unmarshal(bs, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to decode bench baseline '%s': %w", file, err)
+	}
+	return &baseline, nil
+}
+
+func writeBenchBaseline(file string, result *BenchResult) error {
+	bs, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(file, bs, 0o644); err != nil {
+		return fmt.Errorf("failed to write bench baseline '%s': %w", file, err)
+	}
+	return nil
+}
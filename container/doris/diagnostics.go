@@ -0,0 +1,205 @@
+package doris
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// feLogLines is how many lines of the FE log the diagnostics keep
+	feLogLines = 100
+
+	diagnosticsTimeout = 30 * time.Second
+)
+
+// StartupError is returned by Run when the container or its init scripts
+// fail, with the state of the cluster at the time of the failure.
+type StartupError struct {
+	Err error
+	// Diagnostics holds the output of SHOW PROC '/frontends' and
+	// SHOW PROC '/backends' and the tail of the FE log
+	Diagnostics string
+}
+
+func (e *StartupError) Error() string {
+	return fmt.Sprintf("%v\n%s", e.Err, e.Diagnostics)
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics returns the output of SHOW PROC '/frontends' and
+// SHOW PROC '/backends' and the tail of the FE log, e.g. to log when a test
+// fails because of the cluster.
+func (c *Container) Diagnostics(ctx context.Context) string {
+	return collectDiagnostics(ctx, c.Container, c.password, c.flavor)
+}
+
+// collectDiagnostics runs the diagnostics commands in the container, a
+// failing command reports its error in place of its output
+func collectDiagnostics(ctx context.Context, ctr testcontainers.Container, password string, flavor Flavor) string {
+	// the failed startup may have used up the context
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsTimeout)
+	defer cancel()
+
+	var sb strings.Builder
+	for _, proc := range []string{"/frontends", "/backends"} {
+		stmt := fmt.Sprintf("SHOW PROC '%s'", proc)
+		query := execSQL
+		if flavor == Doris {
+			query = querySQL
+		}
+		out, err := query(ctx, ctr, password, stmt)
+		writeDiagnostic(&sb, stmt, out, err)
+	}
+	out, err := execOutput(ctx, ctr, []string{"tail", "-n", fmt.Sprint(feLogLines), flavor.feLogPath()})
+	writeDiagnostic(&sb, flavor.feLogPath(), out, err)
+	return sb.String()
+}
+
+func writeDiagnostic(sb *strings.Builder, title, out string, err error) {
+	fmt.Fprintf(sb, "=== %s ===\n", title)
+	if err != nil {
+		fmt.Fprintf(sb, "unavailable: %v\n", err)
+		return
+	}
+	sb.WriteString(strings.TrimRight(out, "\n") + "\n")
+}
+
+// execSQL runs the statement with the mysql client of the container as
+// root, with the password and then without it, as the init script may not
+// have set it yet
+func execSQL(ctx context.Context, ctr testcontainers.Container, password, stmt string) (string, error) {
+	cmd := []string{"mysql", "-P9030", "-h127.0.0.1", "-uroot", "-e", stmt}
+	out, err := execOutput(ctx, ctr, cmd, tcexec.WithEnv([]string{"MYSQL_PWD=" + password}))
+	if err != nil && password != "" {
+		out, err = execOutput(ctx, ctr, cmd)
+	}
+	return out, err
+}
+
+// querySQL runs the statement through the mapped query port, for the
+// images without a mysql client, and returns its rows tab separated
+func querySQL(ctx context.Context, ctr testcontainers.Container, password, stmt string) (string, error) {
+	db, err := connectRoot(ctx, ctr, password, "")
+	if err != nil && password != "" {
+		db, err = connectRoot(ctx, ctr, "", "")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(columns, "\t") + "\n")
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		fields := make([]string, len(values))
+		for i, v := range values {
+			fields[i] = v.String
+			if !v.Valid {
+				fields[i] = "NULL"
+			}
+		}
+		sb.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	return sb.String(), rows.Err()
+}
+
+// execOutput runs the command in the container and returns its output,
+// failing on a non-zero exit code
+func execOutput(ctx context.Context, ctr testcontainers.Container, cmd []string, opts ...tcexec.ProcessOption) (string, error) {
+	code, reader, err := ctr.Exec(ctx, cmd, append(opts, tcexec.Multiplexed())...)
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// withInitScript sources the script with the mysql client of the container
+// once it is ready, Doris images run it through the query port. Unlike
+// testcontainers.WithAfterReadyCommand a failing script fails the startup.
+func withInitScript(flavor Flavor, path, password, database string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostReadies: []testcontainers.ContainerHook{
+				func(ctx context.Context, c testcontainers.Container) error {
+					return runInitScript(ctx, c, flavor, path, password, database)
+				},
+			},
+		})
+		return nil
+	}
+}
+
+// runInitScript runs the script of withInitScript
+func runInitScript(ctx context.Context, c testcontainers.Container, flavor Flavor, path, password, database string) error {
+	if flavor == Doris {
+		if err := runScriptSQL(ctx, c, path, password, database); err != nil {
+			return fmt.Errorf("init script %s failed: %w", path, err)
+		}
+		return nil
+	}
+	cmd := []string{"mysql", "-P9030", "-h127.0.0.1", "-uroot"}
+	if database != "" {
+		cmd = append(cmd, database)
+	}
+	cmd = append(cmd, "-e", "source "+path)
+	if _, err := execOutput(ctx, c, cmd, tcexec.WithEnv([]string{"MYSQL_PWD=" + password})); err != nil {
+		return fmt.Errorf("init script %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// withInitCommandStep runs the command file of WithInitCommand with sh
+func withInitCommandStep(path string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostReadies: []testcontainers.ContainerHook{
+				func(ctx context.Context, c testcontainers.Container) error {
+					return runInitCommand(ctx, c, path)
+				},
+			},
+		})
+		return nil
+	}
+}
+
+// runInitCommand runs the command file of withInitCommandStep
+func runInitCommand(ctx context.Context, c testcontainers.Container, path string) error {
+	if _, err := execOutput(ctx, c, []string{"sh", path}); err != nil {
+		return fmt.Errorf("init command %s failed: %w", path, err)
+	}
+	return nil
+}
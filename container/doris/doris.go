@@ -5,14 +5,15 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"github.com/dennis2006/mtest/dsn"
 	_ "github.com/go-sql-driver/mysql"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
 //go:embed mounts/init.sql.tpl
@@ -29,6 +30,11 @@ type Container struct {
 	testcontainers.Container
 	password string
 	database string
+	flavor   Flavor
+	// javaUDFs is set by WithJavaUDFs
+	javaUDFs bool
+	// steps are the init scripts and commands, in order
+	steps []string
 }
 
 // Deprecated: use Run instead
@@ -37,13 +43,15 @@ func RunContainer(ctx context.Context, opts ...testcontainers.ContainerCustomize
 	return Run(ctx, "starrocks/allin1-ubuntu:3.4.3", opts...)
 }
 
-// Run creates an instance of the StarRocks container type
+// Run creates an instance of the StarRocks container type, or of Apache
+// Doris for Doris images, see WithFlavor
 func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	readiness := ForSQLReady()
 	req := testcontainers.ContainerRequest{
 		Image:        img,
 		Env:          make(map[string]string),
-		ExposedPorts: []string{"9030/tcp"},
-		WaitingFor:   wait.ForLog("Enjoy the journey to StarRocks blazing-fast lake-house engine!"),
+		ExposedPorts: []string{"9030/tcp", feHTTPPort, beHTTPPort},
+		WaitingFor:   readiness,
 	}
 
 	genericContainerReq := testcontainers.GenericContainerRequest{
@@ -61,9 +69,19 @@ func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustom
 	}
 	database := genericContainerReq.Env["DORIS_DATABASE"]
 	password := genericContainerReq.Env["DORIS_PASSWORD"]
+	timezone := genericContainerReq.Env["DORIS_TIMEZONE"]
+	readiness.password = password
+	flavor := Flavor(genericContainerReq.Env["DORIS_FLAVOR"])
+	switch flavor {
+	case "":
+		flavor = detectFlavor(img)
+	case StarRocks, Doris:
+	default:
+		return nil, fmt.Errorf("unknown flavor '%s'", flavor)
+	}
 
 	// 根据参数及模板生成初始化脚本文件
-	initScriptBytes, err := renderEmbedDorisConfig(database, password)
+	initScriptBytes, err := renderEmbedDorisConfig(database, password, timezone)
 	if err != nil {
 		return nil, fmt.Errorf("render config: %w", err)
 	}
@@ -81,24 +99,27 @@ func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustom
 		ContainerFilePath: defaultDorisInitContainerPath,
 		FileMode:          0o644,
 	})
-	initCmd := testcontainers.NewRawCommand(
-		[]string{"/bin/sh", "-c", fmt.Sprintf("mysql -P9030 -h127.0.0.1 -uroot -e 'source %s'", defaultDorisInitContainerPath)})
-	postOpts = append(postOpts, dorisInitScript, testcontainers.WithAfterReadyCommand(initCmd))
-
-	// 挂载其它文件 && 执行其它脚本
-	var execs []testcontainers.Executable
-	for _, opt := range genericContainerReq.Files {
-		if opt.ContainerFilePath == defaultDorisInitContainerPath {
-			// skip
+	postOpts = append(postOpts, dorisInitScript, withInitScript(flavor, defaultDorisInitContainerPath, "", ""))
+	javaUDFs := genericContainerReq.Env["DORIS_JAVA_UDF"] == "true"
+	if javaUDFs && flavor == StarRocks {
+		postOpts = append(postOpts, withEnableUDF())
+	}
+
+	// 按传入顺序执行其它脚本及命令
+	var steps []string
+	for _, f := range genericContainerReq.Files {
+		if strings.HasPrefix(f.ContainerFilePath, initStepDir+"/") {
+			steps = append(steps, f.ContainerFilePath)
+		}
+	}
+	sort.Strings(steps)
+	for _, step := range steps {
+		if strings.HasSuffix(step, ".sh") {
+			postOpts = append(postOpts, withInitCommandStep(step))
 		} else {
-			execs = append(execs, testcontainers.NewRawCommand([]string{
-				"/bin/sh",
-				"-c",
-				fmt.Sprintf("mysql -P9030 -h127.0.0.1 -uroot -p%s %s -e 'source %s'", password, database, opt.ContainerFilePath),
-			}))
+			postOpts = append(postOpts, withInitScript(flavor, step, password, database))
 		}
 	}
-	postOpts = append(postOpts, dorisInitScript, testcontainers.WithAfterReadyCommand(execs...))
 
 	for _, opt := range postOpts {
 		if err = opt.Customize(&genericContainerReq); err != nil {
@@ -113,16 +134,59 @@ func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustom
 			Container: container,
 			database:  database,
 			password:  password,
+			flavor:    flavor,
+			javaUDFs:  javaUDFs,
+			steps:     steps,
 		}
 	}
 
 	if err != nil {
-		return c, fmt.Errorf("generic container: %w", err)
+		err = fmt.Errorf("generic container: %w", err)
+		if container != nil {
+			err = &StartupError{Err: err, Diagnostics: collectDiagnostics(ctx, container, password, flavor)}
+		}
+		return c, err
 	}
 
 	return c, nil
 }
 
+// ResetDatabase drops and recreates the database of WithDatabase and runs
+// the scripts and commands of WithSQLScripts, WithSQLTemplates and
+// WithInitCommand again, so suites sharing a container start from the same
+// state without restarting it, which takes minutes for the all-in-one image.
+// Connections using the database must be reopened.
+func (c *Container) ResetDatabase(ctx context.Context) error {
+	if c.database == "" {
+		return fmt.Errorf("no database to reset, see WithDatabase")
+	}
+	db, err := connectRoot(ctx, c, c.password, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	quoted := "`" + strings.ReplaceAll(c.database, "`", "``") + "`"
+	// FORCE skips the recycle bin, which would keep the dropped tablets around
+	if _, err = db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoted+" FORCE"); err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", c.database, err)
+	}
+	if _, err = db.ExecContext(ctx, "CREATE DATABASE "+quoted); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", c.database, err)
+	}
+	for _, step := range c.steps {
+		if strings.HasSuffix(step, ".sh") {
+			err = runInitCommand(ctx, c.Container, step)
+		} else {
+			err = runInitScript(ctx, c.Container, c.flavor, step, c.password, c.database)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MustConnectionString panics if the address cannot be determined.
 func (c *Container) MustConnectionString(ctx context.Context, args ...string) string {
 	addr, err := c.ConnectionString(ctx, args...)
@@ -143,16 +207,7 @@ func (c *Container) ConnectionString(ctx context.Context, args ...string) (strin
 		return "", err
 	}
 
-	extraArgs := ""
-	if len(args) > 0 {
-		extraArgs = strings.Join(args, "&")
-	}
-	if extraArgs != "" {
-		extraArgs = "?" + extraArgs
-	}
-
-	connectionString := fmt.Sprintf("root:%s@tcp(%s:%s)/%s%s", c.password, host, containerPort.Port(), c.database, extraArgs)
-	return connectionString, nil
+	return dsn.NewDoris(host, containerPort.Int()).Credentials("root", c.password).Database(c.database).Params(args...).String(), nil
 }
 
 func defaultOptions(ctx context.Context) []testcontainers.ContainerCustomizer {
@@ -178,38 +233,153 @@ func WithDatabase(database string) testcontainers.CustomizeRequestOption {
 	}
 }
 
-func WithSQLScripts(scripts ...string) testcontainers.CustomizeRequestOption {
+// WithTimezone sets the timezone of the container, e.g. "Asia/Shanghai", both
+// the TZ of its processes and the global time_zone variable of the FE, so date
+// functions don't depend on the timezone of the CI host.
+func WithTimezone(tz string) testcontainers.CustomizeRequestOption {
 	return func(req *testcontainers.GenericContainerRequest) error {
-		var initScripts []testcontainers.ContainerFile
-		for _, script := range scripts {
-			if !strings.EqualFold(".sql", filepath.Ext(script)) {
-				return fmt.Errorf("file %s is not a sql file", script)
-			}
+		req.Env["TZ"] = tz
+		req.Env["DORIS_TIMEZONE"] = tz
 
-			cf := testcontainers.ContainerFile{
+		return nil
+	}
+}
+
+// WithSQLScripts runs the SQL scripts once the container is ready, in the
+// given order and after the scripts and commands of earlier options. A
+// directory runs its *.sql files sorted by name.
+func WithSQLScripts(scripts ...string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		files, err := expandSQLScripts(scripts)
+		if err != nil {
+			return err
+		}
+		for _, script := range files {
+			req.Files = append(req.Files, testcontainers.ContainerFile{
 				HostFilePath:      script,
-				ContainerFilePath: "/tmp/" + filepath.Base(script),
+				ContainerFilePath: nextInitStep(req, filepath.Base(script)),
 				FileMode:          0o644,
+			})
+		}
+
+		return nil
+	}
+}
+
+// WithSQLTemplates is like WithSQLScripts, but renders each script as a Go template
+// with the given variables first, e.g. "INSERT INTO t VALUES ({{.TenantID}}, {{quote .Name}})",
+// so one fixture set can serve many parameterized test cases.
+func WithSQLTemplates(vars map[string]any, scripts ...string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		files, err := expandSQLScripts(scripts)
+		if err != nil {
+			return err
+		}
+		for _, script := range files {
+			content, err := os.ReadFile(script)
+			if err != nil {
+				return fmt.Errorf("failed to read sql template %s: %w", script, err)
+			}
+			tpl, err := template.New(filepath.Base(script)).Funcs(sqlTemplateFuncs).Option("missingkey=error").Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("failed to parse sql template %s: %w", script, err)
 			}
-			initScripts = append(initScripts, cf)
+			var rendered bytes.Buffer
+			if err = tpl.Execute(&rendered, vars); err != nil {
+				return fmt.Errorf("failed to render sql template %s: %w", script, err)
+			}
+
+			req.Files = append(req.Files, testcontainers.ContainerFile{
+				Reader:            &rendered,
+				ContainerFilePath: nextInitStep(req, filepath.Base(script)),
+				FileMode:          0o644,
+			})
 		}
-		req.Files = append(req.Files, initScripts...)
 
 		return nil
 	}
 }
 
+// WithInitCommand runs the shell command in the container once it is ready,
+// in order with the scripts of WithSQLScripts, e.g. to copy data files or
+// to wait for a BE. A non-zero exit code fails the startup.
+func WithInitCommand(command string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Files = append(req.Files, testcontainers.ContainerFile{
+			Reader:            strings.NewReader(command + "\n"),
+			ContainerFilePath: nextInitStep(req, "command.sh"),
+			FileMode:          0o755,
+		})
+
+		return nil
+	}
+}
+
+// initStepDir holds the scripts and commands run once the container is
+// ready, named by their position so sorting them restores the given order
+const initStepDir = "/tmp/mtest-init"
+
+// nextInitStep returns the container path of the next init step
+func nextInitStep(req *testcontainers.GenericContainerRequest, name string) string {
+	n := 0
+	for _, f := range req.Files {
+		if strings.HasPrefix(f.ContainerFilePath, initStepDir+"/") {
+			n++
+		}
+	}
+	return fmt.Sprintf("%s/%04d-%s", initStepDir, n, name)
+}
+
+// expandSQLScripts replaces the directories by their sorted *.sql files
+// and checks all files are SQL scripts
+func expandSQLScripts(scripts []string) ([]string, error) {
+	var files []string
+	for _, script := range scripts {
+		info, err := os.Stat(script)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat sql script %s: %w", script, err)
+		}
+		if info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(script, "*.sql"))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(matches)
+			files = append(files, matches...)
+			continue
+		}
+		if !strings.EqualFold(".sql", filepath.Ext(script)) {
+			return nil, fmt.Errorf("file %s is not a sql file", script)
+		}
+		files = append(files, script)
+	}
+	return files, nil
+}
+
+// sqlTemplateFuncs are the functions available to the templates of WithSQLTemplates
+var sqlTemplateFuncs = template.FuncMap{
+	// quote renders a value as a single quoted SQL string literal
+	"quote": func(v any) string {
+		s := fmt.Sprint(v)
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `'`, `''`)
+		return "'" + s + "'"
+	},
+}
+
 type embedDorisConfigTplParams struct {
 	Database string
 	Password string
+	Timezone string
 }
 
-// renderEmbedDorisConfig renders the embed etcd config template with the given database/password
+// renderEmbedDorisConfig renders the embed etcd config template with the given database/password/timezone
 // and returns it as []byte.
-func renderEmbedDorisConfig(database, password string) ([]byte, error) {
+func renderEmbedDorisConfig(database, password, timezone string) ([]byte, error) {
 	tplParams := embedDorisConfigTplParams{
 		Database: database,
 		Password: password,
+		Timezone: timezone,
 	}
 
 	dorisCfgTpl, err := template.New("init.sql").Parse(embedDorisConfigTpl)
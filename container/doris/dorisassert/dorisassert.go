@@ -0,0 +1,230 @@
+// Package dorisassert compares query results of the Doris/StarRocks container
+// against expected fixtures, tolerating the formatting quirks of the engine.
+package dorisassert
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Null is how NULL values are written in fixtures
+const Null = "NULL"
+
+var (
+	decimalRegex  = regexp.MustCompile(`^-?\d+\.\d+$`)
+	datetimeRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[ T](\d{2}:\d{2}:\d{2})(\.\d+)?$`)
+)
+
+type config struct {
+	args            []any
+	unordered       bool
+	ignoreNullOrder bool
+}
+
+// Option configures the comparison
+type Option func(*config)
+
+// WithArgs sets the query arguments
+func WithArgs(args ...any) Option {
+	return func(c *config) {
+		c.args = args
+	}
+}
+
+// Unordered compares the rows as a multiset, for queries without ORDER BY
+func Unordered() Option {
+	return func(c *config) {
+		c.unordered = true
+	}
+}
+
+// IgnoreNullOrder lets rows holding a NULL appear anywhere, as the engine may sort
+// NULLs first or last; the other rows are still compared in order
+func IgnoreNullOrder() Option {
+	return func(c *config) {
+		c.ignoreNullOrder = true
+	}
+}
+
+// AssertQuery runs the query and asserts the result equals the expected rows.
+func AssertQuery(t testing.TB, db *sqlx.DB, query string, expected [][]string, opts ...Option) bool {
+	t.Helper()
+
+	cfg := newConfig(opts...)
+	actual, err := QueryRows(db, query, cfg.args...)
+	if err != nil {
+		t.Errorf("failed to query '%s': %v", query, err)
+		return false
+	}
+	if err = compare(actual, expected, cfg); err != nil {
+		t.Errorf("query '%s': %v", query, err)
+		return false
+	}
+	return true
+}
+
+// AssertQueryFile is AssertQuery with the expected rows loaded from a CSV fixture file.
+func AssertQueryFile(t testing.TB, db *sqlx.DB, query string, file string, opts ...Option) bool {
+	t.Helper()
+
+	expected, err := LoadCSV(file)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return AssertQuery(t, db, query, expected, opts...)
+}
+
+// Compare returns an error describing the first difference of the rows after normalization.
+func Compare(actual, expected [][]string, opts ...Option) error {
+	return compare(actual, expected, newConfig(opts...))
+}
+
+// QueryRows runs the query and returns all values as strings, NULL as Null.
+func QueryRows(db *sqlx.DB, query string, args ...any) ([][]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result [][]string
+	for rows.Next() {
+		values := make([]sql.RawBytes, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(columns))
+		for i, v := range values {
+			if v == nil {
+				row[i] = Null
+			} else {
+				row[i] = string(v)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// LoadCSV loads a fixture file, one row per line without header, NULL written as Null.
+func LoadCSV(file string) ([][]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixture '%s': %w", file, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture '%s': %w", file, err)
+	}
+	return rows, nil
+}
+
+// Normalize returns the canonical form of a value: trailing zeros of decimals
+// and zero fractional seconds of datetimes are dropped.
+func Normalize(v string) string {
+	if decimalRegex.MatchString(v) {
+		v = strings.TrimRight(v, "0")
+		return strings.TrimSuffix(v, ".")
+	}
+	if m := datetimeRegex.FindStringSubmatch(v); m != nil {
+		frac := strings.TrimRight(m[3], "0")
+		if frac == "." {
+			frac = ""
+		}
+		return m[1] + " " + m[2] + frac
+	}
+	return v
+}
+
+func newConfig(opts ...Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func compare(actual, expected [][]string, cfg *config) error {
+	actual, expected = normalizeRows(actual), normalizeRows(expected)
+	if len(actual) != len(expected) {
+		return fmt.Errorf("got %d rows, want %d\ngot:  %v\nwant: %v", len(actual), len(expected), actual, expected)
+	}
+
+	switch {
+	case cfg.unordered:
+		sortRows(actual)
+		sortRows(expected)
+	case cfg.ignoreNullOrder:
+		var actualNulls, expectedNulls [][]string
+		actual, actualNulls = splitNullRows(actual)
+		expected, expectedNulls = splitNullRows(expected)
+		sortRows(actualNulls)
+		sortRows(expectedNulls)
+		actual = append(actual, actualNulls...)
+		expected = append(expected, expectedNulls...)
+	}
+
+	for i := range expected {
+		if strings.Join(actual[i], "\x00") != strings.Join(expected[i], "\x00") {
+			return fmt.Errorf("row %d differs\ngot:  %v\nwant: %v", i, actual[i], expected[i])
+		}
+	}
+	return nil
+}
+
+func normalizeRows(rows [][]string) [][]string {
+	normalized := make([][]string, len(rows))
+	for i, row := range rows {
+		normalized[i] = make([]string, len(row))
+		for j, v := range row {
+			normalized[i][j] = Normalize(v)
+		}
+	}
+	return normalized
+}
+
+func splitNullRows(rows [][]string) (nonNull, withNull [][]string) {
+	for _, row := range rows {
+		hasNull := false
+		for _, v := range row {
+			if v == Null {
+				hasNull = true
+				break
+			}
+		}
+		if hasNull {
+			withNull = append(withNull, row)
+		} else {
+			nonNull = append(nonNull, row)
+		}
+	}
+	return nonNull, withNull
+}
+
+func sortRows(rows [][]string) {
+	sort.Slice(rows, func(i, j int) bool {
+		return strings.Join(rows[i], "\x00") < strings.Join(rows[j], "\x00")
+	})
+}
@@ -0,0 +1,83 @@
+package dorisassert
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"1.500":                      "1.5",
+		"10.00":                      "10",
+		"0.0":                        "0",
+		"-0.250":                     "-0.25",
+		"100":                        "100",
+		"2024-01-02 03:04:05":        "2024-01-02 03:04:05",
+		"2024-01-02 03:04:05.000000": "2024-01-02 03:04:05",
+		"2024-01-02T03:04:05.120":    "2024-01-02 03:04:05.12",
+		"2024-01-02":                 "2024-01-02",
+		"1.0.0":                      "1.0.0",
+		Null:                         Null,
+		"":                           "",
+	}
+	for v, want := range tests {
+		if got := Normalize(v); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   [][]string
+		expected [][]string
+		opts     []Option
+		ok       bool
+	}{
+		{
+			name:     "normalized values equal",
+			actual:   [][]string{{"1", "2.50", "2024-01-02 03:04:05.000"}},
+			expected: [][]string{{"1", "2.5", "2024-01-02 03:04:05"}},
+			ok:       true,
+		},
+		{
+			name:     "order matters by default",
+			actual:   [][]string{{"2"}, {"1"}},
+			expected: [][]string{{"1"}, {"2"}},
+		},
+		{
+			name:     "unordered",
+			actual:   [][]string{{"2"}, {"1"}},
+			expected: [][]string{{"1"}, {"2"}},
+			opts:     []Option{Unordered()},
+			ok:       true,
+		},
+		{
+			name:     "nulls first or last",
+			actual:   [][]string{{Null}, {"1"}, {"2"}},
+			expected: [][]string{{"1"}, {"2"}, {Null}},
+			opts:     []Option{IgnoreNullOrder()},
+			ok:       true,
+		},
+		{
+			name:     "other rows still ordered",
+			actual:   [][]string{{Null}, {"2"}, {"1"}},
+			expected: [][]string{{"1"}, {"2"}, {Null}},
+			opts:     []Option{IgnoreNullOrder()},
+		},
+		{
+			name:     "row count differs",
+			actual:   [][]string{{"1"}},
+			expected: [][]string{{"1"}, {"1"}},
+			opts:     []Option{Unordered()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compare(tt.actual, tt.expected, newConfig(tt.opts...))
+			if (err == nil) != tt.ok {
+				t.Errorf("compare() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
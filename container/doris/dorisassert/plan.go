@@ -0,0 +1,46 @@
+package dorisassert
+
+import (
+	"github.com/dennis2006/mtest/container/doris"
+	"testing"
+)
+
+// AssertExchanges asserts the plan shuffles data exactly n times, e.g. to
+// lock in a colocate or bucket shuffle join of a critical query.
+func AssertExchanges(t testing.TB, plan *doris.Plan, n int) bool {
+	t.Helper()
+
+	if got := plan.Exchanges(); got != n {
+		t.Errorf("plan of query '%s' has %d exchanges, want %d\n%s", plan.Query, got, n, plan.Text)
+		return false
+	}
+	return true
+}
+
+// AssertScanRanges asserts the plan reads at most max scan ranges of the
+// table, e.g. to check partition and bucket pruning still applies.
+func AssertScanRanges(t testing.TB, plan *doris.Plan, table string, max int) bool {
+	t.Helper()
+
+	got, ok := plan.ScanRanges(table)
+	if !ok {
+		t.Errorf("plan of query '%s' has no scan ranges of table '%s'\n%s", plan.Query, table, plan.Text)
+		return false
+	}
+	if got > max {
+		t.Errorf("plan of query '%s' reads %d scan ranges of table '%s', want at most %d\n%s", plan.Query, got, table, max, plan.Text)
+		return false
+	}
+	return true
+}
+
+// AssertNodes asserts the plan has exactly n nodes of the kind, see doris.NodeKind
+func AssertNodes(t testing.TB, plan *doris.Plan, kind string, n int) bool {
+	t.Helper()
+
+	if got := len(plan.Nodes(kind)); got != n {
+		t.Errorf("plan of query '%s' has %d %s nodes, want %d\n%s", plan.Query, got, doris.NodeKind(kind), n, plan.Text)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+// Package dorissql wraps sqlx with bounded retries of the transient errors
+// of Doris/StarRocks, e.g. a backend not alive yet right after startup.
+package dorissql
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+// TransientErrors are the lowercase fragments of the error messages retried by default
+var TransientErrors = []string{
+	"backend not alive",
+	"is not alive",
+	"backend node not found",
+	"tablet not ready",
+	"no queryable replica",
+	"publish version timeout",
+	"timeout on publish version",
+	"publish timeout",
+	"too many versions",
+}
+
+type config struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	transient      []string
+}
+
+// Option configures the retries of a DB
+type Option func(*config)
+
+// WithMaxAttempts sets how many times a statement is run at most, 5 by default
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the wait before the first retry, doubled on every
+// following one up to maxBackoff; 200ms and 3s by default.
+func WithBackoff(initial, maxBackoff time.Duration) Option {
+	return func(c *config) {
+		c.initialBackoff = initial
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithRetryOn retries errors containing any of the fragments too, compared case-insensitively
+func WithRetryOn(fragments ...string) Option {
+	return func(c *config) {
+		for _, fragment := range fragments {
+			c.transient = append(c.transient, strings.ToLower(fragment))
+		}
+	}
+}
+
+// DB is a sqlx.DB retrying statements failing with a transient error.
+// Errors of Rows returned by Queryx happen while iterating and are not retried.
+type DB struct {
+	*sqlx.DB
+	cfg config
+}
+
+// New wraps db
+func New(db *sqlx.DB, opts ...Option) *DB {
+	cfg := config{
+		maxAttempts:    5,
+		initialBackoff: 200 * time.Millisecond,
+		maxBackoff:     3 * time.Second,
+		transient:      append([]string(nil), TransientErrors...),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &DB{DB: db, cfg: cfg}
+}
+
+// Open connects to the dsn with the mysql driver and wraps the connection
+func Open(dsn string, opts ...Option) (*DB, error) {
+	db, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, opts...), nil
+}
+
+// IsTransient reports whether err is one of TransientErrors
+func IsTransient(err error) bool {
+	return isTransient(err, TransientErrors)
+}
+
+func isTransient(err error, transient []string) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transient {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry runs fn until it succeeds, fails with a non transient error,
+// runs out of attempts or ctx is done.
+func (db *DB) retry(ctx context.Context, fn func() error) error {
+	backoff := db.cfg.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= db.cfg.maxAttempts || !isTransient(err, db.cfg.transient) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, db.cfg.maxBackoff)
+	}
+}
+
+// ExecContext executes the statement, retrying transient errors
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	err = db.retry(ctx, func() error {
+		res, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// Exec executes the statement, retrying transient errors
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// QueryxContext runs the query, retrying transient errors
+func (db *DB) QueryxContext(ctx context.Context, query string, args ...any) (rows *sqlx.Rows, err error) {
+	err = db.retry(ctx, func() error {
+		rows, err = db.DB.QueryxContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// Queryx runs the query, retrying transient errors
+func (db *DB) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	return db.QueryxContext(context.Background(), query, args...)
+}
+
+// SelectContext scans the rows into dest, retrying transient errors
+func (db *DB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.retry(ctx, func() error {
+		return db.DB.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+// Select scans the rows into dest, retrying transient errors
+func (db *DB) Select(dest any, query string, args ...any) error {
+	return db.SelectContext(context.Background(), dest, query, args...)
+}
+
+// GetContext scans a single row into dest, retrying transient errors
+func (db *DB) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.retry(ctx, func() error {
+		return db.DB.GetContext(ctx, dest, query, args...)
+	})
+}
+
+// Get scans a single row into dest, retrying transient errors
+func (db *DB) Get(dest any, query string, args ...any) error {
+	return db.GetContext(context.Background(), dest, query, args...)
+}
@@ -0,0 +1,209 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	fragmentRegex = regexp.MustCompile(`^(?:PLAN FRAGMENT|Fragment) (\d+)`)
+	// "0:OlapScanNode" of EXPLAIN and "OLAP_SCAN (id=0)" of EXPLAIN ANALYZE
+	explainNodeRegex = regexp.MustCompile(`^(\d+):([A-Za-z_ ]+?)\s*$`)
+	analyzeNodeRegex = regexp.MustCompile(`^([A-Z_ ]+?) \(id=(\d+)\)`)
+	explainPropRegex = regexp.MustCompile(`^([\w.]+)=(.*)$`)
+)
+
+// Plan is a parsed EXPLAIN or EXPLAIN ANALYZE output
+type Plan struct {
+	Query string
+	// Text is the raw output, one line per row
+	Text      string
+	Fragments []Fragment
+}
+
+// Fragment is a plan fragment, the unit the plan is distributed in
+type Fragment struct {
+	ID    int
+	Nodes []PlanNode
+}
+
+// PlanNode is an operator of the plan, e.g. a scan, join or exchange
+type PlanNode struct {
+	ID int
+	// Kind is the normalized operator name, e.g. "OLAPSCAN" for both
+	// "OlapScanNode" and "OLAP_SCAN", see NodeKind
+	Kind string
+	// Props are the "key: value" and "key=value" lines under the node,
+	// e.g. "TABLE", "partitions", "tabletRatio" or the runtime metrics
+	Props map[string]string
+}
+
+// NodeKind normalizes an operator name, so "OlapScanNode", "OLAP_SCAN" and
+// "olap scan" are all "OLAPSCAN"
+func NodeKind(name string) string {
+	kind := strings.ToUpper(strings.NewReplacer(" ", "", "_", "").Replace(name))
+	if kind != "NODE" {
+		kind = strings.TrimSuffix(kind, "NODE")
+	}
+	return kind
+}
+
+// Prop returns the property of the node by case-insensitive name
+func (n PlanNode) Prop(name string) (string, bool) {
+	for k, v := range n.Props {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Table returns the table a scan node reads, empty for other nodes
+func (n PlanNode) Table() string {
+	table, _ := n.Prop("TABLE")
+	// EXPLAIN ANALYZE appends e.g. " (Partitions: 1/1)"
+	table, _, _ = strings.Cut(table, " ")
+	return table
+}
+
+// ScanRanges returns how many scan ranges (tablets) a scan node reads, from
+// the ScanRanges metric of EXPLAIN ANALYZE or the tabletRatio of EXPLAIN.
+func (n PlanNode) ScanRanges() (int, bool) {
+	if v, ok := n.Prop("ScanRanges"); ok {
+		if ranges, err := strconv.Atoi(strings.Fields(v + " ")[0]); err == nil {
+			return ranges, true
+		}
+	}
+	if v, ok := n.Prop("tabletRatio"); ok {
+		scanned, _, _ := strings.Cut(v, "/")
+		if ranges, err := strconv.Atoi(scanned); err == nil {
+			return ranges, true
+		}
+	}
+	return 0, false
+}
+
+// Nodes returns the nodes of all fragments of the kind, see NodeKind
+func (p *Plan) Nodes(kind string) []PlanNode {
+	kind = NodeKind(kind)
+	var nodes []PlanNode
+	for _, f := range p.Fragments {
+		for _, n := range f.Nodes {
+			if n.Kind == kind {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// Exchanges returns the number of exchange nodes, i.e. data shuffles
+func (p *Plan) Exchanges() int {
+	return len(p.Nodes("EXCHANGE"))
+}
+
+// ScanRanges returns the scan ranges read from the table summed over its scan
+// nodes, false if the plan scans no such table or tells no ranges for it.
+func (p *Plan) ScanRanges(table string) (int, bool) {
+	var total int
+	var found bool
+	for _, f := range p.Fragments {
+		for _, n := range f.Nodes {
+			if !strings.EqualFold(n.Table(), table) {
+				continue
+			}
+			if ranges, ok := n.ScanRanges(); ok {
+				total += ranges
+				found = true
+			}
+		}
+	}
+	return total, found
+}
+
+// Explain returns the plan of the query without running it
+func Explain(ctx context.Context, db *sqlx.DB, query string, args ...any) (*Plan, error) {
+	return explain(ctx, db, "EXPLAIN ", query, args)
+}
+
+// ExplainAnalyze runs the query and returns its plan with the runtime
+// profile of every node, e.g. the rows and scan ranges it actually read.
+func ExplainAnalyze(ctx context.Context, db *sqlx.DB, query string, args ...any) (*Plan, error) {
+	return explain(ctx, db, "EXPLAIN ANALYZE ", query, args)
+}
+
+func explain(ctx context.Context, db *sqlx.DB, prefix, query string, args []any) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, prefix+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query '%s': %w", query, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to read plan of query '%s': %w", query, err)
+		}
+		lines = append(lines, strings.Split(line, "\n")...)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read plan of query '%s': %w", query, err)
+	}
+	return ParsePlan(query, strings.Join(lines, "\n")), nil
+}
+
+// ParsePlan parses the text of an EXPLAIN or EXPLAIN ANALYZE output, lines
+// it doesn't understand are skipped.
+func ParsePlan(query, text string) *Plan {
+	plan := &Plan{Query: query, Text: text}
+	var fragment *Fragment
+	var node *PlanNode
+	for _, line := range strings.Split(text, "\n") {
+		// drop the tree drawing of EXPLAIN ANALYZE
+		line = strings.TrimSpace(strings.TrimLeft(line, " │└├─|-<"))
+		if line == "" {
+			continue
+		}
+
+		if m := fragmentRegex.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			plan.Fragments = append(plan.Fragments, Fragment{ID: id})
+			fragment = &plan.Fragments[len(plan.Fragments)-1]
+			node = nil
+			continue
+		}
+		if fragment == nil {
+			continue
+		}
+
+		var id int
+		var name string
+		if m := explainNodeRegex.FindStringSubmatch(line); m != nil {
+			id, _ = strconv.Atoi(m[1])
+			name = m[2]
+		} else if m = analyzeNodeRegex.FindStringSubmatch(line); m != nil {
+			id, _ = strconv.Atoi(m[2])
+			name = m[1]
+		}
+		if name != "" {
+			fragment.Nodes = append(fragment.Nodes, PlanNode{ID: id, Kind: NodeKind(name), Props: make(map[string]string)})
+			node = &fragment.Nodes[len(fragment.Nodes)-1]
+			continue
+		}
+		if node == nil {
+			continue
+		}
+
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			node.Props[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		} else if m := explainPropRegex.FindStringSubmatch(line); m != nil {
+			node.Props[m[1]] = strings.TrimSpace(m[2])
+		}
+	}
+	return plan
+}
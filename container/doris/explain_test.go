@@ -0,0 +1,110 @@
+package doris
+
+import (
+	"testing"
+)
+
+const explainText = `PLAN FRAGMENT 0
+ OUTPUT EXPRS:1: id | 2: amount
+  PARTITION: UNPARTITIONED
+
+  RESULT SINK
+
+  2:EXCHANGE
+
+PLAN FRAGMENT 1
+ OUTPUT EXPRS:
+  PARTITION: RANDOM
+
+  STREAM DATA SINK
+    EXCHANGE ID: 02
+    UNPARTITIONED
+
+  1:Project
+  |  <slot 1> : 1: id
+
+  0:OlapScanNode
+     TABLE: orders
+     PREAGGREGATION: ON
+     partitions=1/3
+     rollup: orders
+     tabletRatio=4/12
+     cardinality=1`
+
+const explainAnalyzeText = `Summary
+    QueryId: 6f3c5b0e-1d2a-11ef-9a3b-0242ac110002
+    Version: 3.4.3
+Fragment 0
+│   BackendNum: 1
+└──RESULT_SINK
+   └──EXCHANGE (id=2)
+         Estimates: [row: 1, cpu: ?, memory: ?, network: ?, cost: 0.0]
+         TotalTime: 1.2ms (1.50%)
+Fragment 1
+│   BackendNum: 1
+└──DATA_STREAM_SINK (id=2)
+   └──OLAP_SCAN (id=0)
+          Estimates: [row: 1, cpu: ?, memory: ?, network: ?, cost: 0.0]
+          TotalTime: 10.3ms (80.12%)
+          ScanRanges: 3
+          Table: orders (Partitions: 1/3)`
+
+func TestParsePlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		fragments  int
+		exchanges  int
+		scanRanges int
+	}{
+		{name: "explain", text: explainText, fragments: 2, exchanges: 1, scanRanges: 4},
+		{name: "explain analyze", text: explainAnalyzeText, fragments: 2, exchanges: 1, scanRanges: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := ParsePlan("SELECT id, amount FROM orders", tt.text)
+			if len(plan.Fragments) != tt.fragments {
+				t.Fatalf("got %d fragments, want %d", len(plan.Fragments), tt.fragments)
+			}
+			if got := plan.Exchanges(); got != tt.exchanges {
+				t.Errorf("Exchanges() = %d, want %d", got, tt.exchanges)
+			}
+			scans := plan.Nodes("OlapScanNode")
+			if len(scans) != 1 {
+				t.Fatalf("got %d scan nodes, want 1", len(scans))
+			}
+			if scans[0].ID != 0 || scans[0].Table() != "orders" {
+				t.Errorf("scan node = %d on %q, want 0 on %q", scans[0].ID, scans[0].Table(), "orders")
+			}
+			if got, ok := plan.ScanRanges("ORDERS"); !ok || got != tt.scanRanges {
+				t.Errorf("ScanRanges() = %d, %v, want %d, true", got, ok, tt.scanRanges)
+			}
+			if _, ok := plan.ScanRanges("customers"); ok {
+				t.Errorf("ScanRanges() of an unscanned table succeeded")
+			}
+		})
+	}
+}
+
+func TestParsePlanSkipsUnknownLines(t *testing.T) {
+	plan := ParsePlan("SELECT 1", "0:OlapScanNode\nnot a plan\n\nPLAN FRAGMENT 0\n  garbage=\n")
+	if len(plan.Fragments) != 1 || len(plan.Fragments[0].Nodes) != 0 {
+		t.Errorf("got fragments %+v, want one without nodes", plan.Fragments)
+	}
+}
+
+func TestNodeKind(t *testing.T) {
+	tests := map[string]string{
+		"OlapScanNode": "OLAPSCAN",
+		"OLAP_SCAN":    "OLAPSCAN",
+		"olap scan":    "OLAPSCAN",
+		"EXCHANGE":     "EXCHANGE",
+		"HASH JOIN":    "HASHJOIN",
+		"Node":         "NODE",
+	}
+	for name, want := range tests {
+		if got := NodeKind(name); got != want {
+			t.Errorf("NodeKind(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/go-connections/nat"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	"io"
+	"strings"
+	"time"
+)
+
+// Flavor is the distribution an image runs, the module supports the
+// StarRocks all-in-one image and the Apache Doris all-in-one image
+type Flavor string
+
+const (
+	// StarRocks runs images like starrocks/allin1-ubuntu, the default
+	StarRocks Flavor = "starrocks"
+	// Doris runs images like apache/doris all-in-one. Their init scripts are
+	// run through the mapped query port, the image lacks a mysql client.
+	Doris Flavor = "doris"
+)
+
+// WithFlavor sets the flavor of the image, detected from the image name by
+// default: images named like apache/doris are Doris, all others StarRocks.
+func WithFlavor(flavor Flavor) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Env["DORIS_FLAVOR"] = string(flavor)
+
+		return nil
+	}
+}
+
+// detectFlavor returns the flavor of the image
+func detectFlavor(img string) Flavor {
+	name := strings.ToLower(img)
+	if strings.Contains(name, "starrocks") || !strings.Contains(name, "doris") {
+		return StarRocks
+	}
+	return Doris
+}
+
+// feLogPath returns the path of the FE log in the container
+func (f Flavor) feLogPath() string {
+	if f == Doris {
+		return "/opt/apache-doris/fe/log/fe.log"
+	}
+	return "/data/deploy/starrocks/fe/log/fe.log"
+}
+
+// Flavor returns the flavor the container runs
+func (c *Container) Flavor() Flavor {
+	return c.flavor
+}
+
+// hostPortTarget is what connectRoot needs of a container or wait target
+type hostPortTarget interface {
+	Host(context.Context) (string, error)
+	MappedPort(context.Context, nat.Port) (nat.Port, error)
+}
+
+// connectRoot connects as root to the mapped query port of the container
+func connectRoot(ctx context.Context, target hostPortTarget, password, database string) (*sqlx.DB, error) {
+	host, err := target.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	port, err := target.MappedPort(ctx, nat.Port("9030/tcp"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := gomysql.NewConfig()
+	cfg.User = "root"
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = host + ":" + port.Port()
+	cfg.DBName = database
+	cfg.Timeout = 5 * time.Second
+	return sqlx.ConnectContext(ctx, "mysql", cfg.FormatDSN())
+}
+
+// runScriptSQL runs the statements of a script copied into the container
+// through the mapped query port
+func runScriptSQL(ctx context.Context, c testcontainers.Container, path, password, database string) error {
+	rc, err := c.CopyFileFromContainer(ctx, path)
+	if err != nil {
+		return err
+	}
+	script, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		return err
+	}
+
+	db, err := connectRoot(ctx, c, password, database)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	// the init script changes the password, keep the session that set it
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, stmt := range splitScript(string(script)) {
+		if _, err = conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("statement %q failed: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// splitScript splits a script into statements at the semicolons and the
+// GO lines of the mysql client outside quotes and comments
+func splitScript(script string) []string {
+	var stmts []string
+	var sb strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(sb.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		sb.Reset()
+	}
+
+	var quote byte
+	lineStart := true
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case quote != 0:
+			sb.WriteByte(ch)
+			if ch == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				sb.WriteByte(script[i])
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			sb.WriteByte(ch)
+		case ch == '-' && strings.HasPrefix(script[i:], "-- "), ch == '#':
+			// skip the comment up to the end of the line
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+		case ch == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case ch == ';':
+			flush()
+		case lineStart && isGoLine(script[i:]):
+			flush()
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		default:
+			sb.WriteByte(ch)
+		}
+		lineStart = i < len(script) && script[i] == '\n'
+	}
+	flush()
+	return stmts
+}
+
+// isGoLine reports whether the line starting the text is a GO delimiter
+func isGoLine(text string) bool {
+	line, _, _ := strings.Cut(text, "\n")
+	return strings.EqualFold(strings.TrimSpace(line), "go")
+}
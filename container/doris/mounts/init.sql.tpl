@@ -1,3 +1,8 @@
+{{- if .Timezone -}}
+SET GLOBAL time_zone = '{{ .Timezone }}';
+GO
+
+{{ end -}}
 SET PASSWORD = PASSWORD('{{ .Password }}');
 GO
 
@@ -0,0 +1,170 @@
+package doris
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	feHTTPPort = "8030/tcp"
+	beHTTPPort = "8040/tcp"
+)
+
+// LoadFormat is the format of the data of a Stream Load
+type LoadFormat string
+
+const (
+	// LoadCSV loads comma separated rows, override the separator with
+	// StreamLoadHeader("column_separator", ...)
+	LoadCSV LoadFormat = "csv"
+	// LoadJSON loads a JSON array of objects whose keys are the columns
+	LoadJSON LoadFormat = "json"
+)
+
+// StreamLoadOption sets a header of a Stream Load request, see the
+// StarRocks Stream Load docs for the supported headers
+type StreamLoadOption func(http.Header)
+
+// StreamLoadHeader sets a Stream Load header, e.g. "columns" or "where"
+func StreamLoadHeader(key, value string) StreamLoadOption {
+	return func(h http.Header) {
+		h.Set(key, value)
+	}
+}
+
+// StreamLoadResult is the response of a Stream Load
+type StreamLoadResult struct {
+	TxnID                int64  `json:"TxnId"`
+	Label                string `json:"Label"`
+	Status               string `json:"Status"`
+	Message              string `json:"Message"`
+	NumberTotalRows      int64  `json:"NumberTotalRows"`
+	NumberLoadedRows     int64  `json:"NumberLoadedRows"`
+	NumberFilteredRows   int64  `json:"NumberFilteredRows"`
+	NumberUnselectedRows int64  `json:"NumberUnselectedRows"`
+	LoadBytes            int64  `json:"LoadBytes"`
+	LoadTimeMs           int64  `json:"LoadTimeMs"`
+	// ErrorURL lists the filtered rows, it is relative to the BE inside the container
+	ErrorURL string `json:"ErrorURL"`
+}
+
+// HTTPConnectionString returns the base URL of the FE HTTP port, e.g.
+// "http://localhost:32768"
+func (c *Container) HTTPConnectionString(ctx context.Context) (string, error) {
+	return c.httpEndpoint(ctx, feHTTPPort)
+}
+
+func (c *Container) httpEndpoint(ctx context.Context, port nat.Port) (string, error) {
+	containerPort, err := c.MappedPort(ctx, port)
+	if err != nil {
+		return "", err
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("http://%s:%s", host, containerPort.Port()), nil
+}
+
+// StreamLoad loads the data into the table with a Stream Load as root,
+// under a random label unless set with StreamLoadHeader("label", ...).
+// The FE redirects the load to the BE, whose address is rewritten to the
+// mapped BE HTTP port. A load whose status is not Success fails.
+func (c *Container) StreamLoad(ctx context.Context, db, table string, format LoadFormat, r io.Reader, opts ...StreamLoadOption) (*StreamLoadResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream load data: %w", err)
+	}
+	fe, err := c.HTTPConnectionString(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fe http endpoint: %w", err)
+	}
+
+	header := make(http.Header)
+	header.Set("label", "mtest_"+uuid.NewString())
+	header.Set("format", string(format))
+	switch format {
+	case LoadCSV:
+		header.Set("column_separator", ",")
+	case LoadJSON:
+		header.Set("strip_outer_array", "true")
+	}
+	for _, opt := range opts {
+		opt(header)
+	}
+
+	// redirects are followed by hand, the BE address is internal to the container
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	target := fmt.Sprintf("%s/api/%s/%s/_stream_load", fe, url.PathEscape(db), url.PathEscape(table))
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+		req.Header.Set("Expect", "100-continue")
+		req.SetBasicAuth("root", c.password)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stream load into %s.%s: %w", db, table, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream load response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTemporaryRedirect && redirects < 3 {
+			if target, err = c.beLocation(ctx, resp.Header.Get("Location")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, fmt.Errorf("unexpected status %d from stream load: %s", resp.StatusCode, body)
+		}
+
+		var result StreamLoadResult
+		if err = json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode stream load response %s: %w", body, err)
+		}
+		if result.Status != "Success" {
+			return &result, fmt.Errorf("stream load into %s.%s %s: %s", db, table, result.Status, result.Message)
+		}
+		return &result, nil
+	}
+}
+
+// beLocation rewrites the address of a redirect to the BE to its mapped port
+func (c *Container) beLocation(ctx context.Context, location string) (string, error) {
+	if location == "" {
+		return "", errors.New("stream load redirect without location")
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream load redirect '%s': %w", location, err)
+	}
+	be, err := c.httpEndpoint(ctx, beHTTPPort)
+	if err != nil {
+		return "", fmt.Errorf("failed to get be http endpoint: %w", err)
+	}
+	beURL, _ := url.Parse(be)
+	u.Scheme = beURL.Scheme
+	u.Host = beURL.Host
+	// the BE checks the credentials itself, they are sent again
+	u.User = nil
+	return u.String(), nil
+}
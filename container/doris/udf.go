@@ -0,0 +1,202 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// udfDir holds the jars of RegisterJavaUDF in the container
+const udfDir = "/tmp/mtest-udf"
+
+const (
+	// feConfPath is the config of the StarRocks FE in the container
+	feConfPath = "/data/deploy/starrocks/fe/conf/fe.conf"
+	// udfHTTPAddr serves udfDir in StarRocks containers, which only load
+	// jars over HTTP
+	udfHTTPAddr = "127.0.0.1:18090"
+)
+
+// WithJavaUDFs enables the Java UDFs of RegisterJavaUDF. StarRocks only
+// loads them with enable_udf set in fe.conf before the FE starts, Doris
+// always does.
+func WithJavaUDFs() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Env["DORIS_JAVA_UDF"] = "true"
+
+		return nil
+	}
+}
+
+// withEnableUDF sets enable_udf of WithJavaUDFs in StarRocks containers
+func withEnableUDF() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostCreates: []testcontainers.ContainerHook{enableUDF},
+		})
+		return nil
+	}
+}
+
+// enableUDF appends enable_udf to the fe.conf of a created StarRocks
+// container, before it starts
+func enableUDF(ctx context.Context, c testcontainers.Container) error {
+	r, err := c.CopyFileFromContainer(ctx, feConfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", feConfPath, err)
+	}
+	conf, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", feConfPath, err)
+	}
+	conf = append(conf, "\nenable_udf = true\n"...)
+	if err = c.CopyToContainer(ctx, conf, feConfPath, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", feConfPath, err)
+	}
+	return nil
+}
+
+// JavaUDF is a Java scalar function of RegisterJavaUDF
+type JavaUDF struct {
+	// Name is the name of the function, e.g. "add_one"
+	Name string
+	// Args are the SQL types of the arguments, e.g. []string{"INT"}
+	Args []string
+	// Returns is the SQL type of the result
+	Returns string
+	// Symbol is the class implementing evaluate, e.g. "com.example.AddOne"
+	Symbol string
+	// Properties are extra properties of CREATE FUNCTION, "always_nullable"
+	// is "true" unless set
+	Properties map[string]string
+}
+
+// RegisterJavaUDF copies the jar into the container and creates the function
+// in the database of WithDatabase, replacing a function of the same
+// signature, e.g. registered with an older jar. It then polls SHOW FUNCTIONS
+// until the function is listed or the timeout expires. ResetDatabase drops
+// the function with the database. StarRocks containers need WithJavaUDFs,
+// they load the jar over HTTP from a server started in the container.
+func (c *Container) RegisterJavaUDF(ctx context.Context, jar string, fn JavaUDF, timeout time.Duration) error {
+	if c.flavor != Doris && !c.javaUDFs {
+		return fmt.Errorf("java udfs are disabled in %s, create the container WithJavaUDFs", c.flavor)
+	}
+	if fn.Name == "" || fn.Returns == "" || fn.Symbol == "" {
+		return fmt.Errorf("java udf needs a name, a return type and a symbol")
+	}
+
+	path := udfDir + "/" + filepath.Base(jar)
+	if err := c.CopyFileToContainer(ctx, jar, path, 0o644); err != nil {
+		return fmt.Errorf("failed to copy udf jar %s: %w", jar, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	file := "file://" + path
+	if c.flavor != Doris {
+		if err := serveUDFs(ctx, c); err != nil {
+			return err
+		}
+		file = "http://" + udfHTTPAddr + "/" + filepath.Base(jar)
+	}
+
+	db, err := connectRoot(ctx, c, c.password, c.database)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	signature := "`" + strings.ReplaceAll(fn.Name, "`", "``") + "`(" + strings.Join(fn.Args, ", ") + ")"
+	props := make(map[string]string)
+	if c.flavor == Doris {
+		if _, err = db.ExecContext(ctx, "DROP FUNCTION IF EXISTS "+signature); err != nil {
+			return fmt.Errorf("failed to drop function %s: %w", fn.Name, err)
+		}
+		props["always_nullable"] = "true"
+		props["type"] = "JAVA_UDF"
+	} else {
+		// StarRocks has no DROP FUNCTION IF EXISTS, a function of another
+		// signature fails the drop but not the create
+		var names []string
+		if err = db.SelectContext(ctx, &names, "SHOW FUNCTIONS LIKE '"+strings.ReplaceAll(fn.Name, "'", "''")+"'"); err != nil {
+			return fmt.Errorf("failed to show functions: %w", err)
+		}
+		if containsFold(names, fn.Name) {
+			_, _ = db.ExecContext(ctx, "DROP FUNCTION "+signature)
+		}
+		props["type"] = "StarrocksJar"
+	}
+	for k, v := range fn.Properties {
+		props[k] = v
+	}
+	props["file"] = file
+	props["symbol"] = fn.Symbol
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = quoteProperty(k) + " = " + quoteProperty(props[k])
+	}
+	create := "CREATE FUNCTION " + signature + " RETURNS " + fn.Returns + " PROPERTIES (" + strings.Join(pairs, ", ") + ")"
+	if _, err = db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create function %s: %w", fn.Name, err)
+	}
+
+	ticker := time.NewTicker(visiblePollInterval)
+	defer ticker.Stop()
+	for {
+		var names []string
+		err = db.SelectContext(ctx, &names, "SHOW FUNCTIONS LIKE '"+strings.ReplaceAll(fn.Name, "'", "''")+"'")
+		if err == nil && containsFold(names, fn.Name) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("function %s not available after %s: %w", fn.Name, timeout, err)
+			}
+			return fmt.Errorf("function %s not available after %s", fn.Name, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveUDFs serves udfDir over HTTP in the container unless it is served
+// already, with the python3 of the image running its supervisord
+func serveUDFs(ctx context.Context, c *Container) error {
+	host, port, _ := strings.Cut(udfHTTPAddr, ":")
+	probe := "python3 -c 'import urllib.request; urllib.request.urlopen(\"http://" + udfHTTPAddr + "/\")' 2>/dev/null"
+	script := probe + " && exit 0\n" +
+		"nohup python3 -m http.server " + port + " --bind " + host + " --directory " + udfDir + " >/dev/null 2>&1 &\n" +
+		"for i in $(seq 50); do " + probe + " && exit 0; sleep 0.1; done\n" +
+		"exit 1\n"
+	if _, err := execOutput(ctx, c, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to serve udf jars: %w", err)
+	}
+	return nil
+}
+
+// quoteProperty quotes a key or value of PROPERTIES
+func quoteProperty(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
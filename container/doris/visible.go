@@ -0,0 +1,50 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const visiblePollInterval = 200 * time.Millisecond
+
+// WaitRowsVisible polls SELECT COUNT(*) of the table, "t" or "db.t", until
+// it holds the expected number of rows, since loaded data, e.g. of a Stream
+// Load, becomes visible only once its version is published. It fails with
+// the last count once the timeout is reached.
+func (c *Container) WaitRowsVisible(ctx context.Context, table string, expected int64, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := connectRoot(ctx, c, c.password, c.database)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	query := "SELECT COUNT(*) FROM " + strings.Join(parts, ".")
+
+	ticker := time.NewTicker(visiblePollInterval)
+	defer ticker.Stop()
+	var count int64
+	for {
+		err = db.GetContext(ctx, &count, query)
+		if err == nil && count == expected {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("rows of '%s' not visible after %s: %w", table, timeout, err)
+			}
+			return fmt.Errorf("rows of '%s' not visible after %s: got %d, want %d", table, timeout, count, expected)
+		case <-ticker.C:
+		}
+	}
+}
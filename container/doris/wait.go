@@ -0,0 +1,186 @@
+package doris
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStartupTimeout = 5 * time.Minute
+	defaultPollInterval   = time.Second
+)
+
+// ReadinessStrategy waits until the FE accepts queries: it connects on port
+// 9030 until all frontends and at least one backend report alive, then runs
+// the readiness query. Unlike the startup log line it does not depend on the
+// image version, and the FE no longer rejects the first queries.
+type ReadinessStrategy struct {
+	timeout  time.Duration
+	interval time.Duration
+	query    string
+	// password is tried once the init script changed the root password
+	password string
+}
+
+var _ wait.Strategy = (*ReadinessStrategy)(nil)
+
+// ForSQLReady returns the readiness strategy Run uses by default, running
+// "SELECT 1" as readiness query.
+func ForSQLReady() *ReadinessStrategy {
+	return &ReadinessStrategy{
+		timeout:  defaultStartupTimeout,
+		interval: defaultPollInterval,
+		query:    "SELECT 1",
+	}
+}
+
+// WithStartupTimeout bounds how long to wait, 5 minutes by default
+func (s *ReadinessStrategy) WithStartupTimeout(timeout time.Duration) *ReadinessStrategy {
+	s.timeout = timeout
+	return s
+}
+
+// WithPollInterval sets how often to check, every second by default
+func (s *ReadinessStrategy) WithPollInterval(interval time.Duration) *ReadinessStrategy {
+	s.interval = interval
+	return s
+}
+
+// WithQuery sets the query that must succeed once the nodes are alive
+func (s *ReadinessStrategy) WithQuery(query string) *ReadinessStrategy {
+	s.query = query
+	return s
+}
+
+// Timeout returns the startup timeout, see wait.StrategyTimeout
+func (s *ReadinessStrategy) Timeout() *time.Duration {
+	return &s.timeout
+}
+
+func (s *ReadinessStrategy) String() string {
+	return "alive frontends and backends on port 9030"
+}
+
+func (s *ReadinessStrategy) WaitUntilReady(ctx context.Context, target wait.StrategyTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		state, err := target.State(ctx)
+		if err == nil && !state.Running {
+			return fmt.Errorf("container exited with code %d while waiting for the FE", state.ExitCode)
+		}
+		if lastErr = s.check(ctx, target); lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("FE not ready: %w: %w", ctx.Err(), lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// check connects to the FE and returns why it is not ready yet
+func (s *ReadinessStrategy) check(ctx context.Context, target wait.StrategyTarget) error {
+	db, err := connectRoot(ctx, target, "", "")
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1045 && s.password != "" {
+		db, err = connectRoot(ctx, target, s.password, "")
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	frontends, err := aliveNodes(ctx, db, "SHOW FRONTENDS")
+	if err != nil {
+		return err
+	}
+	if frontends.alive < frontends.total || frontends.total == 0 {
+		return fmt.Errorf("%d/%d frontends alive", frontends.alive, frontends.total)
+	}
+	backends, err := aliveNodes(ctx, db, "SHOW BACKENDS")
+	if err != nil {
+		return err
+	}
+	if backends.alive == 0 {
+		return fmt.Errorf("0/%d backends alive", backends.total)
+	}
+
+	if s.query == "" {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx, s.query)
+	if err != nil {
+		return fmt.Errorf("readiness query failed: %w", err)
+	}
+	return rows.Close()
+}
+
+type nodeCount struct {
+	alive, total int
+}
+
+// aliveNodes counts the rows of a SHOW FRONTENDS/BACKENDS statement and
+// the ones whose Alive column is true
+func aliveNodes(ctx context.Context, db *sqlx.DB, query string) (nodeCount, error) {
+	var count nodeCount
+	rows, err := db.QueryxContext(ctx, query)
+	if err != nil {
+		return count, fmt.Errorf("failed to %s: %w", strings.ToLower(query), err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		row := make(map[string]any)
+		if err = rows.MapScan(row); err != nil {
+			return count, err
+		}
+		count.total++
+		if alive, ok := row["Alive"].([]byte); ok && strings.EqualFold(string(alive), "true") {
+			count.alive++
+		}
+	}
+	return count, rows.Err()
+}
+
+// WithWaitStrategy replaces the readiness strategy of Run, e.g. with
+// ForSQLReady().WithQuery(...) or a wait.ForLog of another image version.
+// Several strategies must all be satisfied.
+func WithWaitStrategy(strategies ...wait.Strategy) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		if len(strategies) == 1 {
+			req.WaitingFor = strategies[0]
+		} else {
+			req.WaitingFor = wait.ForAll(strategies...).WithStartupTimeoutDefault(defaultStartupTimeout)
+		}
+		return nil
+	}
+}
+
+// WithStartupTimeout bounds how long Run waits for the container to be ready
+func WithStartupTimeout(timeout time.Duration) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		switch s := req.WaitingFor.(type) {
+		case *ReadinessStrategy:
+			s.WithStartupTimeout(timeout)
+		case *wait.MultiStrategy:
+			s.WithDeadline(timeout)
+		default:
+			req.WaitingFor = wait.ForAll(s).WithDeadline(timeout)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/dennis2006/mtest/container/doris"
+	dcontainer "github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MinIOImage is the image of the backup repository of StartBackupRepository
+const MinIOImage = "minio/minio:RELEASE.2025-04-22T22-12-26Z"
+
+// BackupRepositoryName is the name of the repository of
+// StartBackupRepository in Doris
+const BackupRepositoryName = "mtest_backups"
+
+const (
+	minioPort     = "9000/tcp"
+	minioUser     = "mtest"
+	minioPassword = "mtest-backups"
+	backupBucket  = "doris-backups"
+)
+
+// BackupRepository is a MinIO container storing the Doris snapshots in a
+// directory of the host, so they outlive the containers
+type BackupRepository struct {
+	testcontainers.Container
+	// Dir is the host directory holding the data of MinIO
+	Dir string
+}
+
+// StartBackupRepository starts a MinIO container on the docker network of
+// the Doris container, storing its data in dir, and creates the repository
+// BackupRepositoryName on it in Doris, available as DorisContainer.Backups.
+// Keep dir across CI runs, e.g. in the cache of the CI, so a snapshot
+// written once by Backup is restored into the fresh containers of the later
+// runs, see RestoreOrSeed.
+func (c *DorisContainer) StartBackupRepository(ctx context.Context, dir string) (*BackupRepository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	// MinIO joins the first network of Doris, like ProxySQL does for MySQL
+	inspect, err := c.Inspect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect doris container: %w", err)
+	}
+	var networks []string
+	for name := range inspect.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("doris container is not attached to any network")
+	}
+	sort.Strings(networks)
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        MinIOImage,
+			ExposedPorts: []string{minioPort},
+			Networks:     []string{networks[0]},
+			Cmd:          []string{"server", "/data"},
+			Env:          map[string]string{"MINIO_ROOT_USER": minioUser, "MINIO_ROOT_PASSWORD": minioPassword},
+			HostConfigModifier: func(hc *dcontainer.HostConfig) {
+				hc.Binds = append(hc.Binds, dir+":/data")
+			},
+			WaitingFor: wait.ForHTTP("/minio/health/live").WithPort(minioPort).WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		// the container is returned with the error when it started but isn't ready
+		_ = testcontainers.TerminateContainer(ctr)
+		return nil, fmt.Errorf("failed to start minio container: %w", err)
+	}
+	repo := &BackupRepository{Container: ctr, Dir: dir}
+
+	cmd := "mc alias set local http://127.0.0.1:9000 " + minioUser + " " + minioPassword +
+		" && mc mb --ignore-existing local/" + backupBucket
+	code, out, err := ctr.Exec(ctx, []string{"sh", "-c", cmd}, tcexec.Multiplexed())
+	if err == nil && code != 0 {
+		output, _ := io.ReadAll(out)
+		err = fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		_ = ctr.Terminate(ctx)
+		return nil, fmt.Errorf("failed to create bucket %s: %w", backupBucket, err)
+	}
+
+	host, err := sharedNetworkHost(ctx, c, ctr)
+	if err == nil {
+		err = c.CreateRepository(ctx, doris.S3Repository{
+			Name:      BackupRepositoryName,
+			Location:  "s3://" + backupBucket + "/snapshots",
+			Endpoint:  "http://" + host + ":9000",
+			AccessKey: minioUser,
+			SecretKey: minioPassword,
+		})
+	}
+	if err != nil {
+		_ = ctr.Terminate(ctx)
+		return nil, err
+	}
+	c.Backups = repo
+	return repo, nil
+}
+
+// RestoreOrSeed restores the snapshot label of the backup repository into
+// the database if the repository holds it, else it runs seed and backs the
+// database up as label, so only the first run pays for the seeding. Change
+// the label when the seed changes, e.g. to a hash of the seed files.
+func (c *DorisContainer) RestoreOrSeed(ctx context.Context, label string, seed func(ctx context.Context) error, timeout time.Duration) error {
+	if c.Backups == nil {
+		return fmt.Errorf("no backup repository, see StartBackupRepository")
+	}
+	ok, err := c.HasSnapshot(ctx, BackupRepositoryName, label)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return c.Restore(ctx, BackupRepositoryName, label, timeout)
+	}
+	if err = seed(ctx); err != nil {
+		return fmt.Errorf("failed to seed: %w", err)
+	}
+	return c.Backup(ctx, BackupRepositoryName, label, timeout)
+}
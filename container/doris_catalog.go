@@ -0,0 +1,110 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
+	"sort"
+	"strings"
+)
+
+// DefaultMySQLJDBCDriverURL is the MySQL JDBC driver downloaded by the FE
+// when a catalog is created without a driver url.
+const DefaultMySQLJDBCDriverURL = "https://repo1.maven.org/maven2/mysql/mysql-connector-java/8.0.28/mysql-connector-java-8.0.28.jar"
+
+// AddMySQLCatalog creates a JDBC catalog named name in Doris pointing at the
+// MySQL container, so federated queries like "SELECT * FROM name.foo.t" can be
+// tested. Both containers must share a docker network, e.g. by creating them
+// with WithNetwork; the default bridge network works as well. driverURL is
+// where the FE downloads the JDBC driver from, DefaultMySQLJDBCDriverURL if empty.
+func (c *DorisContainer) AddMySQLCatalog(ctx context.Context, name string, m *MySQLContainer, driverURL string) error {
+	if driverURL == "" {
+		driverURL = DefaultMySQLJDBCDriverURL
+	}
+
+	dsn, err := m.ConnectionString(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get mysql connection string: %w", err)
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("failed to parse mysql connection string: %w", err)
+	}
+
+	host, err := sharedNetworkHost(ctx, c, m)
+	if err != nil {
+		return err
+	}
+
+	stmt := fmt.Sprintf("CREATE EXTERNAL CATALOG %s PROPERTIES ("+
+		"\"type\" = \"jdbc\", "+
+		"\"user\" = %s, "+
+		"\"password\" = %s, "+
+		"\"jdbc_uri\" = %s, "+
+		"\"driver_url\" = %s, "+
+		"\"driver_class\" = \"com.mysql.cj.jdbc.Driver\")",
+		quoteDorisIdent(name), quoteDorisProperty(cfg.User), quoteDorisProperty(cfg.Passwd),
+		quoteDorisProperty("jdbc:mysql://"+host+":3306"), quoteDorisProperty(driverURL))
+	if _, err = c.Db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create catalog '%s': %w", name, err)
+	}
+
+	// the catalog is only connected on first use, check it right away
+	// so a wrong address or driver fails here instead of in the test
+	if _, err = c.Db.ExecContext(ctx, "SHOW DATABASES FROM "+quoteDorisIdent(name)); err != nil {
+		return fmt.Errorf("failed to connect catalog '%s' to mysql at %s: %w", name, host, err)
+	}
+	return nil
+}
+
+// DropCatalog drops the external catalog if it exists
+func (c *DorisContainer) DropCatalog(ctx context.Context, name string) error {
+	if _, err := c.Db.ExecContext(ctx, "DROP CATALOG IF EXISTS "+quoteDorisIdent(name)); err != nil {
+		return fmt.Errorf("failed to drop catalog '%s': %w", name, err)
+	}
+	return nil
+}
+
+// quoteDorisIdent backtick quotes an identifier, doubling its backticks
+func quoteDorisIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteDorisProperty double quotes the value of a PROPERTIES entry,
+// escaping its backslashes and double quotes
+func quoteDorisProperty(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sharedNetworkHost returns the address target is reachable at from source,
+// its network alias if it has one on a shared network, otherwise its IP there.
+func sharedNetworkHost(ctx context.Context, source, target testcontainers.Container) (string, error) {
+	sourceNetworks, err := source.Networks(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get networks: %w", err)
+	}
+	aliases, err := target.NetworkAliases(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get network aliases: %w", err)
+	}
+	inspect, err := target.Inspect(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	sort.Strings(sourceNetworks)
+	for _, nw := range sourceNetworks {
+		settings, ok := inspect.NetworkSettings.Networks[nw]
+		if !ok {
+			continue
+		}
+		if len(aliases[nw]) > 0 {
+			return aliases[nw][0], nil
+		}
+		if settings.IPAddress != "" {
+			return settings.IPAddress, nil
+		}
+	}
+	return "", fmt.Errorf("containers share no network, create them with WithNetwork")
+}
@@ -0,0 +1,14 @@
+package container
+
+import (
+	"github.com/dennis2006/mtest/container/doris"
+)
+
+// WithDorisJavaUDFs enables the Java UDFs of DorisContainer.RegisterJavaUDF.
+// The default StarRocks image needs it before it starts, Doris images
+// always load them.
+func WithDorisJavaUDFs() Option {
+	return func(o *options) {
+		o.customizers = append(o.customizers, doris.WithJavaUDFs())
+	}
+}
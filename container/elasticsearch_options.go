@@ -0,0 +1,298 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/testcontainers/testcontainers-go"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ElasticsearchImage is the image of the Elasticsearch helper
+	ElasticsearchImage = "docker.elastic.co/elasticsearch/elasticsearch:8.15.3"
+	// OpenSearchImage is the image of the Elasticsearch helper run by WithOpenSearch
+	OpenSearchImage = "opensearchproject/opensearch:2.17.1"
+)
+
+// esOptions holds the flavor, memory and bootstrap settings of the Elasticsearch helper
+type esOptions struct {
+	heap       string
+	memory     int64
+	noSecurity bool
+	indexes    []esIndex
+	documents  []esDocuments
+}
+
+type esIndex struct {
+	name string
+	file string
+}
+
+type esDocuments struct {
+	index string
+	file  string
+}
+
+var esNeed = resourceNeed{
+	service:           ServiceElasticsearch,
+	minMemory:         1 << 30,
+	recommendedMemory: 4 << 30,
+	minCPUs:           1,
+	// the default heap of 2G doesn't fit below the recommended memory, give
+	// the JVM half of the container like the ES docs advise
+	adjust: func(memory int64) testcontainers.ContainerCustomizer {
+		return esMemory(memory, fmt.Sprintf("%dm", memory/2>>20))
+	},
+}
+
+// WithElasticsearchVersion runs the Elasticsearch image of the version, e.g. "7.17.24"
+func WithElasticsearchVersion(version string) Option {
+	return func(o *options) {
+		o.image = "docker.elastic.co/elasticsearch/elasticsearch:" + version
+	}
+}
+
+// WithOpenSearch runs OpenSearch of the version instead of Elasticsearch,
+// OpenSearchImage if version is empty. The security plugin is disabled.
+func WithOpenSearch(version string) Option {
+	return func(o *options) {
+		o.image = OpenSearchImage
+		if version != "" {
+			o.image = "opensearchproject/opensearch:" + version
+		}
+	}
+}
+
+// WithESHeapSize sets the JVM heap, e.g. "512m", 2g by default
+func WithESHeapSize(size string) Option {
+	return func(o *options) {
+		o.es.heap = size
+	}
+}
+
+// WithESMemoryLimit limits the memory of the container to bytes, set the heap
+// to about half of it with WithESHeapSize
+func WithESMemoryLimit(bytes int64) Option {
+	return func(o *options) {
+		o.es.memory = bytes
+	}
+}
+
+// WithESSecurityDisabled disables the authentication and TLS Elasticsearch 8
+// enables by default, so plain HTTP clients connect without credentials
+func WithESSecurityDisabled() Option {
+	return func(o *options) {
+		o.es.noSecurity = true
+	}
+}
+
+// WithESIndex creates the index once the node is ready, with the body of the
+// create index request in file, e.g. {"settings": ..., "mappings": ...}
+func WithESIndex(name, file string) Option {
+	return func(o *options) {
+		o.es.indexes = append(o.es.indexes, esIndex{name: name, file: file})
+	}
+}
+
+// WithESDocuments indexes the documents of file into index once the indexes
+// of WithESIndex are created: a JSON array or one document per line (.ndjson).
+// An "_id" field sets the id of its document. The index is refreshed, so the
+// documents are searchable when the helper returns.
+func WithESDocuments(index, file string) Option {
+	return func(o *options) {
+		o.es.documents = append(o.es.documents, esDocuments{index: index, file: file})
+	}
+}
+
+// isOpenSearch reports whether the image is an OpenSearch one
+func isOpenSearch(img string) bool {
+	return strings.Contains(img, "opensearch")
+}
+
+// esMemory limits the memory of the container and sets the heap
+func esMemory(memory int64, heap string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		if memory > 0 {
+			if err := testcontainers.WithHostConfigModifier(func(hc *dockercontainer.HostConfig) {
+				hc.Memory = memory
+			})(req); err != nil {
+				return err
+			}
+		}
+		if heap != "" {
+			// ES_JAVA_OPTS takes precedence over the jvm.options files
+			opts := fmt.Sprintf("-Xms%s -Xmx%s", heap, heap)
+			return testcontainers.WithEnv(map[string]string{"ES_JAVA_OPTS": opts, "OPENSEARCH_JAVA_OPTS": opts})(req)
+		}
+		return nil
+	}
+}
+
+// moduleOpts returns the customizers of the Elasticsearch settings
+func (o *esOptions) moduleOpts(img string, configFile string) []testcontainers.ContainerCustomizer {
+	var opts []testcontainers.ContainerCustomizer
+	env := map[string]string{}
+	configDir := "/usr/share/elasticsearch/config"
+	if isOpenSearch(img) {
+		configDir = "/usr/share/opensearch/config"
+		env["DISABLE_SECURITY_PLUGIN"] = "true"
+		env["DISABLE_INSTALL_DEMO_CONFIG"] = "true"
+	} else if o.noSecurity {
+		env["xpack.security.enabled"] = "false"
+	}
+	opts = append(opts, testcontainers.WithEnv(env))
+	if o.heap != "" || o.memory > 0 {
+		opts = append(opts, esMemory(o.memory, o.heap))
+	}
+	if configFile != "" {
+		name := "elasticsearch.yml"
+		if isOpenSearch(img) {
+			name = "opensearch.yml"
+		}
+		opts = append(opts, testcontainers.WithFiles(testcontainers.ContainerFile{
+			HostFilePath:      configFile,
+			ContainerFilePath: configDir + "/" + name,
+			FileMode:          0o644,
+		}))
+	}
+	return opts
+}
+
+// bootstrap creates the indexes and loads the documents of the options
+func (o *esOptions) bootstrap(ctx context.Context, cli *elasticsearch.Client) error {
+	for _, index := range o.indexes {
+		body, err := os.ReadFile(index.file)
+		if err != nil {
+			return fmt.Errorf("failed to read index file: %w", err)
+		}
+		res, err := cli.Indices.Create(index.name, cli.Indices.Create.WithBody(bytes.NewReader(body)), cli.Indices.Create.WithContext(ctx))
+		if err = esResponseError(res, err); err != nil {
+			return fmt.Errorf("failed to create index '%s': %w", index.name, err)
+		}
+		_ = res.Body.Close()
+	}
+
+	for _, docs := range o.documents {
+		body, err := esBulkBody(docs.file)
+		if err != nil {
+			return fmt.Errorf("failed to read documents of '%s': %w", docs.index, err)
+		}
+		res, err := cli.Bulk(bytes.NewReader(body), cli.Bulk.WithIndex(docs.index),
+			cli.Bulk.WithRefresh("true"), cli.Bulk.WithContext(ctx))
+		if err = esResponseError(res, err); err != nil {
+			return fmt.Errorf("failed to index documents into '%s': %w", docs.index, err)
+		}
+		var result struct {
+			Errors bool `json:"errors"`
+			Items  []map[string]struct {
+				Error json.RawMessage `json:"error"`
+			} `json:"items"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		_ = res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode bulk response: %w", err)
+		}
+		if result.Errors {
+			for i, item := range result.Items {
+				for _, action := range item {
+					if len(action.Error) > 0 {
+						return fmt.Errorf("failed to index document %d of %s: %s", i, filepath.Base(docs.file), action.Error)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// esBulkBody builds the body of a bulk request indexing the documents of a
+// JSON array or NDJSON file
+func esBulkBody(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the numbers as written, float64 would round large ids
+	unmarshal := func(data []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		return dec.Decode(v)
+	}
+	var docs []map[string]any
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err = unmarshal(trimmed, &docs); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var doc map[string]any
+			if err = unmarshal(line, &doc); err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, doc := range docs {
+		action := map[string]any{}
+		if id, ok := doc["_id"]; ok {
+			action["_id"] = fmt.Sprint(id)
+			delete(doc, "_id")
+		}
+		if err = enc.Encode(map[string]any{"index": action}); err != nil {
+			return nil, err
+		}
+		if err = enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// esResponseError returns the error of a request, or of its response
+func esResponseError(res *esapi.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		defer func() { _ = res.Body.Close() }()
+		return fmt.Errorf("%s", res.String())
+	}
+	return nil
+}
+
+// openSearchTransport marks the responses of OpenSearch as coming from
+// Elasticsearch, which the Elasticsearch client refuses to talk to otherwise
+type openSearchTransport struct {
+	next http.RoundTripper
+}
+
+func (t *openSearchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if res != nil {
+		res.Header.Set("X-Elastic-Product", "Elasticsearch")
+	}
+	return res, err
+}
@@ -0,0 +1,554 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service names a dependency started by an Environment
+type Service string
+
+const (
+	ServiceMySQL         Service = "mysql"
+	ServiceRedis         Service = "redis"
+	ServiceMongoDB       Service = "mongodb"
+	ServiceDoris         Service = "doris"
+	ServiceGreptimeDB    Service = "greptimedb"
+	ServiceSpanner       Service = "spanner"
+	ServiceRabbitMQ      Service = "rabbitmq"
+	ServiceKafka         Service = "kafka"
+	ServiceElasticsearch Service = "elasticsearch"
+	ServicePostgres      Service = "postgres"
+)
+
+// envHandle is a helper container managed by an Environment
+type envHandle interface {
+	Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error
+	// envVars returns the endpoints and DSNs of the container, keyed by the
+	// environment variable name without prefix, e.g. "MYSQL_DSN".
+	envVars(ctx context.Context) (map[string]string, error)
+}
+
+type serviceSpec struct {
+	name  Service
+	start func(ctx context.Context) (envHandle, error)
+}
+
+type envOptions struct {
+	services   []serviceSpec
+	lazy       bool
+	reportPath string
+}
+
+// EnvOption configures the services of an Environment
+type EnvOption func(*envOptions)
+
+func withService(name Service, start func(ctx context.Context) (envHandle, error)) EnvOption {
+	return func(o *envOptions) {
+		o.services = append(o.services, serviceSpec{name: name, start: start})
+	}
+}
+
+// Lazy declares the services without starting them, each one is started
+// when its accessor is first called. Concurrent callers share the same start,
+// and a failed start is retried by the next caller.
+func Lazy() EnvOption {
+	return func(o *envOptions) {
+		o.lazy = true
+	}
+}
+
+// WithMySQL adds a MySQL container created by CreateMySQLContainer
+func WithMySQL(opts ...Option) EnvOption {
+	return withService(ServiceMySQL, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateMySQLContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithRedis adds a Redis container created by CreateRedisContainer
+func WithRedis(opts ...Option) EnvOption {
+	return withService(ServiceRedis, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateRedisContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithMongo adds a MongoDB container created by CreateMongoDBContainer
+func WithMongo(opts ...Option) EnvOption {
+	return withService(ServiceMongoDB, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateMongoDBContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithDoris adds a Doris container created by CreateDorisContainer
+func WithDoris(opts ...Option) EnvOption {
+	return withService(ServiceDoris, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateDorisContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithGreptimeDB adds a GreptimeDB container created by CreateGreptimeDBContainer
+func WithGreptimeDB(opts ...Option) EnvOption {
+	return withService(ServiceGreptimeDB, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateGreptimeDBContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithSpanner adds a Spanner emulator container created by CreateSpannerContainer
+func WithSpanner(opts ...Option) EnvOption {
+	return withService(ServiceSpanner, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateSpannerContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithRabbitMQ adds a RabbitMQ container created by CreateRabbitMQContainer
+func WithRabbitMQ(opts ...Option) EnvOption {
+	return withService(ServiceRabbitMQ, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateRabbitMQContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithKafka adds a Kafka container created by CreateKafkaContainer
+func WithKafka(opts ...Option) EnvOption {
+	return withService(ServiceKafka, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateKafkaContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithElasticsearch adds an Elasticsearch container created by CreateElasticsearchContainer
+func WithElasticsearch(opts ...Option) EnvOption {
+	return withService(ServiceElasticsearch, func(ctx context.Context) (envHandle, error) {
+		c, err := CreateElasticsearchContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WithPostgres adds a PostgreSQL container created by CreatePostgresContainer
+func WithPostgres(opts ...Option) EnvOption {
+	return withService(ServicePostgres, func(ctx context.Context) (envHandle, error) {
+		c, err := CreatePostgresContainer(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// Environment groups the containers a test run depends on
+type Environment struct {
+	mu      sync.Mutex
+	order   []Service
+	handles map[Service]envHandle
+	vars    map[Service]map[string]string
+
+	lazy  map[Service]serviceSpec
+	group singleflight.Group
+
+	reports    []ServiceReport
+	reportPath string
+	reportMu   sync.Mutex
+}
+
+// NewEnvironment starts the services of the options in parallel. If any of
+// them fails to start, the others are canceled and the ones already started
+// are terminated. With Lazy, the services
+// are only declared and started by their accessors.
+func NewEnvironment(ctx context.Context, opts ...EnvOption) (*Environment, error) {
+	o := &envOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	e := &Environment{
+		handles: make(map[Service]envHandle),
+		vars:    make(map[Service]map[string]string),
+		lazy:    make(map[Service]serviceSpec),
+
+		reportPath: o.reportPath,
+	}
+	if o.lazy {
+		for _, spec := range o.services {
+			if _, ok := e.lazy[spec.name]; ok {
+				return nil, fmt.Errorf("service '%s' added twice", spec.name)
+			}
+			e.lazy[spec.name] = spec
+		}
+		return e, nil
+	}
+
+	seen := make(map[Service]bool, len(o.services))
+	for _, spec := range o.services {
+		if seen[spec.name] {
+			return nil, fmt.Errorf("service '%s' added twice", spec.name)
+		}
+		seen[spec.name] = true
+	}
+
+	// The services don't depend on each other, start them all at once so
+	// the startup takes as long as the slowest one instead of the sum.
+	g, gctx := errgroup.WithContext(ctx)
+	for _, spec := range o.services {
+		g.Go(func() error {
+			return e.start(gctx, spec)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, errors.Join(err, e.Terminate(context.WithoutCancel(ctx)))
+	}
+	return e, nil
+}
+
+func (e *Environment) start(ctx context.Context, spec serviceSpec) error {
+	begin := time.Now()
+	err := e.startService(ctx, spec)
+	report := ServiceReport{Service: spec.name, Duration: time.Since(begin)}
+	e.mu.Lock()
+	h, ok := e.handles[spec.name]
+	e.mu.Unlock()
+	if ok {
+		describeService(context.WithoutCancel(ctx), h, begin, &report)
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	e.record(report)
+	return err
+}
+
+func (e *Environment) startService(ctx context.Context, spec serviceSpec) error {
+	h, err := spec.start(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", spec.name, err)
+	}
+	e.mu.Lock()
+	e.order = append(e.order, spec.name)
+	e.handles[spec.name] = h
+	e.mu.Unlock()
+
+	vars, err := h.envVars(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get endpoints of %s: %w", spec.name, err)
+	}
+	e.mu.Lock()
+	e.vars[spec.name] = vars
+	e.mu.Unlock()
+	return nil
+}
+
+// MySQL returns the MySQL container of the environment
+func (e *Environment) MySQL(ctx context.Context) (*MySQLContainer, error) {
+	return envService[*MySQLContainer](ctx, e, ServiceMySQL)
+}
+
+// Redis returns the Redis container of the environment
+func (e *Environment) Redis(ctx context.Context) (*RedisContainer, error) {
+	return envService[*RedisContainer](ctx, e, ServiceRedis)
+}
+
+// Mongo returns the MongoDB container of the environment
+func (e *Environment) Mongo(ctx context.Context) (*MongoDBContainer, error) {
+	return envService[*MongoDBContainer](ctx, e, ServiceMongoDB)
+}
+
+// Doris returns the Doris container of the environment
+func (e *Environment) Doris(ctx context.Context) (*DorisContainer, error) {
+	return envService[*DorisContainer](ctx, e, ServiceDoris)
+}
+
+// GreptimeDB returns the GreptimeDB container of the environment
+func (e *Environment) GreptimeDB(ctx context.Context) (*GreptimeDBContainer, error) {
+	return envService[*GreptimeDBContainer](ctx, e, ServiceGreptimeDB)
+}
+
+// Spanner returns the Spanner emulator container of the environment
+func (e *Environment) Spanner(ctx context.Context) (*SpannerContainer, error) {
+	return envService[*SpannerContainer](ctx, e, ServiceSpanner)
+}
+
+// RabbitMQ returns the RabbitMQ container of the environment
+func (e *Environment) RabbitMQ(ctx context.Context) (*RabbitMQContainer, error) {
+	return envService[*RabbitMQContainer](ctx, e, ServiceRabbitMQ)
+}
+
+// Kafka returns the Kafka container of the environment
+func (e *Environment) Kafka(ctx context.Context) (*KafkaContainer, error) {
+	return envService[*KafkaContainer](ctx, e, ServiceKafka)
+}
+
+// Elasticsearch returns the Elasticsearch container of the environment
+func (e *Environment) Elasticsearch(ctx context.Context) (*ElasticsearchContainer, error) {
+	return envService[*ElasticsearchContainer](ctx, e, ServiceElasticsearch)
+}
+
+// Postgres returns the PostgreSQL container of the environment
+func (e *Environment) Postgres(ctx context.Context) (*PostgresContainer, error) {
+	return envService[*PostgresContainer](ctx, e, ServicePostgres)
+}
+
+func envService[T envHandle](ctx context.Context, e *Environment, name Service) (T, error) {
+	var zero T
+	e.mu.Lock()
+	h, ok := e.handles[name]
+	spec, lazy := e.lazy[name]
+	e.mu.Unlock()
+	if ok {
+		return h.(T), nil
+	}
+	if !lazy {
+		return zero, fmt.Errorf("service '%s' is not part of the environment", name)
+	}
+
+	// The start is shared by all callers, so it must not be canceled
+	// when the caller that happens to run it gives up.
+	startCtx := context.WithoutCancel(ctx)
+	ch := e.group.DoChan(string(name), func() (any, error) {
+		e.mu.Lock()
+		h, ok := e.handles[name]
+		e.mu.Unlock()
+		if ok {
+			return h, nil
+		}
+		if err := e.start(startCtx, spec); err != nil {
+			return nil, err
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.handles[name], nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// Vars returns the endpoints and DSNs of all started services keyed by the
+// environment variable name prefixed with prefix, e.g. "MTEST_MYSQL_DSN".
+// Lazy services not started yet are left out.
+func (e *Environment) Vars(prefix string) map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	all := make(map[string]string)
+	for _, name := range e.order {
+		for k, v := range e.vars[name] {
+			all[prefix+k] = v
+		}
+	}
+	return all
+}
+
+// ExportEnv sets the endpoints and DSNs of all started services as environment
+// variables of the current process, so spawned subprocesses inherit them.
+// Use t.Setenv instead with Vars if the variables must be restored after the test.
+func (e *Environment) ExportEnv(prefix string) error {
+	for k, v := range e.Vars(prefix) {
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("failed to set env '%s': %w", k, err)
+		}
+	}
+	return nil
+}
+
+// ExportDotenv writes the endpoints and DSNs of all started services
+// to a .env file at path, sorted by name.
+func (e *Environment) ExportDotenv(path string) error {
+	vars := e.Vars("")
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.WriteString(k + "=" + strconv.Quote(vars[k]) + "\n")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write dotenv file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// Terminate terminates all started services in reverse start order
+func (e *Environment) Terminate(ctx context.Context) error {
+	e.mu.Lock()
+	order := e.order
+	handles := e.handles
+	e.order = nil
+	e.handles = make(map[Service]envHandle)
+	e.vars = make(map[Service]map[string]string)
+	e.mu.Unlock()
+
+	var errs []error
+	for i := len(order) - 1; i >= 0; i-- {
+		if err := handles[order[i]].Terminate(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to terminate %s: %w", order[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *MySQLContainer) envVars(ctx context.Context) (map[string]string, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "3306/tcp", "MYSQL", map[string]string{"MYSQL_DSN": dsn})
+}
+
+func (c *RedisContainer) envVars(ctx context.Context) (map[string]string, error) {
+	url, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := dsn.ParseRedis(url)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "6379/tcp", "REDIS", map[string]string{
+		"REDIS_URL":  url,
+		"REDIS_ADDR": parsed.Addr(),
+	})
+}
+
+func (c *MongoDBContainer) envVars(ctx context.Context) (map[string]string, error) {
+	uri, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "27017/tcp", "MONGODB", map[string]string{"MONGODB_URI": uri})
+}
+
+func (c *DorisContainer) envVars(ctx context.Context) (map[string]string, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "9030/tcp", "DORIS", map[string]string{"DORIS_DSN": dsn})
+}
+
+func (c *GreptimeDBContainer) envVars(ctx context.Context) (map[string]string, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := c.HTTPEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"GREPTIMEDB_DSN":           dsn,
+		"GREPTIMEDB_HTTP_ENDPOINT": endpoint,
+	}, nil
+}
+
+func (c *SpannerContainer) envVars(ctx context.Context) (map[string]string, error) {
+	endpoint, err := c.GRPCEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// SPANNER_EMULATOR_HOST is the variable the Spanner client libraries
+	// read to connect to the emulator.
+	return map[string]string{
+		"SPANNER_EMULATOR_HOST": endpoint,
+		"SPANNER_DATABASE":      c.DatabaseName(),
+	}, nil
+}
+
+func (c *RabbitMQContainer) envVars(ctx context.Context) (map[string]string, error) {
+	url, err := c.AmqpURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "5672/tcp", "RABBITMQ", map[string]string{
+		"RABBITMQ_URL": url,
+	})
+}
+
+func (c *KafkaContainer) envVars(ctx context.Context) (map[string]string, error) {
+	return withHostPort(ctx, c, "9093/tcp", "KAFKA", map[string]string{
+		"KAFKA_BROKERS": strings.Join(c.Brokers, ","),
+	})
+}
+
+func (c *ElasticsearchContainer) envVars(ctx context.Context) (map[string]string, error) {
+	return withHostPort(ctx, c, "9200/tcp", "ELASTICSEARCH", map[string]string{
+		"ELASTICSEARCH_URL": c.Address,
+	})
+}
+
+func (c *PostgresContainer) envVars(ctx context.Context) (map[string]string, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withHostPort(ctx, c, "5432/tcp", "POSTGRES", map[string]string{"POSTGRES_DSN": dsn})
+}
+
+// withHostPort adds <name>_HOST and <name>_PORT of the mapped port to vars
+func withHostPort(ctx context.Context, c testcontainers.Container, port, name string, vars map[string]string) (map[string]string, error) {
+	host, err := c.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := c.MappedPort(ctx, nat.Port(port))
+	if err != nil {
+		return nil, err
+	}
+	vars[name+"_HOST"] = host
+	vars[name+"_PORT"] = mapped.Port()
+	return vars, nil
+}
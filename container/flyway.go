@@ -0,0 +1,138 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FlywayImage is the image of RunFlywayMigrations
+const FlywayImage = "flyway/flyway:10.17.0"
+
+// LiquibaseImage is the image of RunLiquibaseMigrations
+const LiquibaseImage = "liquibase/liquibase:4.29.1"
+
+// migrationToolTimeout bounds a run of a migration tool, including the pull
+const migrationToolTimeout = 5 * time.Minute
+
+// RunFlywayMigrations runs Flyway migrate with the SQL migrations of
+// migrationsDir against the database of jdbcURL, from a container on the
+// network, e.g. with the MySQL container created WithNetwork(nw, "mysql"):
+//
+//	RunFlywayMigrations(ctx, nw, "jdbc:mysql://mysql:3306/app?user=root&password=secret", "testdata/sql")
+//
+// The user and password of the URL are passed as the credentials of Flyway.
+// A nil network runs it on the default bridge network, where the database is
+// only reachable by its IP. The error of a failed run holds the output.
+func RunFlywayMigrations(ctx context.Context, nw *testcontainers.DockerNetwork, jdbcURL, migrationsDir string) error {
+	dir := mountedDir("/flyway", migrationsDir)
+	cmd := []string{"-url=" + jdbcURL, "-locations=filesystem:" + dir, "-connectRetries=10"}
+	if user, password, ok := jdbcCredentials(jdbcURL); ok {
+		cmd = append(cmd, "-user="+user, "-password="+password)
+	}
+	return runMigrationTool(ctx, nw, "flyway", testcontainers.ContainerRequest{
+		Image: FlywayImage,
+		Cmd:   append(cmd, "migrate"),
+	}, migrationsDir, dir)
+}
+
+// RunLiquibaseMigrations runs Liquibase update with the changelog file of
+// changelogDir, relative to it, against the database of jdbcURL, from a
+// container on the network like RunFlywayMigrations. The MySQL driver,
+// which the image doesn't ship, is installed for jdbc:mysql URLs.
+func RunLiquibaseMigrations(ctx context.Context, nw *testcontainers.DockerNetwork, jdbcURL, changelogDir, changelogFile string) error {
+	dir := mountedDir("/liquibase", changelogDir)
+	cmd := []string{"--url=" + jdbcURL, "--search-path=" + dir, "--changelog-file=" + path.Clean(changelogFile)}
+	if user, password, ok := jdbcCredentials(jdbcURL); ok {
+		cmd = append(cmd, "--username="+user, "--password="+password)
+	}
+	req := testcontainers.ContainerRequest{
+		Image: LiquibaseImage,
+		Cmd:   append(cmd, "update"),
+	}
+	if strings.HasPrefix(jdbcURL, "jdbc:mysql:") {
+		req.Env = map[string]string{"INSTALL_MYSQL": "true"}
+	}
+	return runMigrationTool(ctx, nw, "liquibase", req, changelogDir, dir)
+}
+
+// runMigrationTool runs the request with dir copied to containerDir until it
+// exits, and fails with its output unless it exits with code 0
+func runMigrationTool(ctx context.Context, nw *testcontainers.DockerNetwork, tool string, req testcontainers.ContainerRequest, dir, containerDir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s migrations dir %s: %w", tool, dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s migrations dir %s is not a directory", tool, dir)
+	}
+	if nw != nil {
+		req.Networks = []string{nw.Name}
+	}
+	req.Files = []testcontainers.ContainerFile{{
+		HostFilePath:      dir,
+		ContainerFilePath: containerDir,
+		FileMode:          0o755,
+	}}
+	_, err = runToolContainer(ctx, tool, req)
+	return err
+}
+
+// runToolContainer runs the request until it exits and returns its output,
+// it fails with the output unless the tool exits with code 0
+func runToolContainer(ctx context.Context, tool string, req testcontainers.ContainerRequest) (string, error) {
+	req.WaitingFor = wait.ForExit().WithExitTimeout(migrationToolTimeout)
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if ctr != nil {
+		defer func() { _ = ctr.Terminate(context.WithoutCancel(ctx)) }()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s container: %w", tool, err)
+	}
+
+	state, err := ctr.State(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s container state: %w", tool, err)
+	}
+	var output []byte
+	if logs, err := ctr.Logs(ctx); err == nil {
+		output, _ = io.ReadAll(logs)
+		_ = logs.Close()
+	}
+	if state.ExitCode != 0 {
+		return string(output), fmt.Errorf("%s exited with code %d: %s", tool, state.ExitCode, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// mountedDir returns the path dir is copied to under parent, which keeps its
+// base name
+func mountedDir(parent, dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return path.Join(parent, filepath.Base(dir))
+}
+
+// jdbcCredentials returns the user and password query parameters of the URL
+func jdbcCredentials(jdbcURL string) (user, password string, ok bool) {
+	u, err := url.Parse(strings.TrimPrefix(jdbcURL, "jdbc:"))
+	if err != nil {
+		return "", "", false
+	}
+	query := u.Query()
+	user = query.Get("user")
+	return user, query.Get("password"), user != ""
+}
@@ -0,0 +1,116 @@
+package greptimedb
+
+import (
+	"context"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	_ "github.com/go-sql-driver/mysql"
+	"net/http"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	httpPort  = "4000/tcp"
+	mysqlPort = "4002/tcp"
+
+	defaultDatabaseName = "public"
+)
+
+// Container represents the GreptimeDB container type used in the module
+type Container struct {
+	testcontainers.Container
+	database string
+}
+
+// Run creates an instance of the GreptimeDB container type running in standalone mode
+func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        img,
+		ExposedPorts: []string{httpPort, mysqlPort},
+		Cmd: []string{
+			"standalone", "start",
+			"--http-addr", "0.0.0.0:4000",
+			"--rpc-addr", "0.0.0.0:4001",
+			"--mysql-addr", "0.0.0.0:4002",
+			"--postgres-addr", "0.0.0.0:4003",
+		},
+		WaitingFor: wait.ForAll(
+			wait.ForHTTP("/health").WithPort(httpPort).WithStatusCodeMatcher(func(status int) bool {
+				return status == http.StatusOK
+			}),
+			wait.ForListeningPort(mysqlPort),
+		),
+	}
+
+	genericContainerReq := testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	}
+
+	for _, opt := range opts {
+		if err := opt.Customize(&genericContainerReq); err != nil {
+			return nil, err
+		}
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, genericContainerReq)
+	var c *Container
+	if container != nil {
+		c = &Container{
+			Container: container,
+			database:  defaultDatabaseName,
+		}
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("generic container: %w", err)
+	}
+
+	return c, nil
+}
+
+// Database returns the database the connection strings point at.
+func (c *Container) Database() string {
+	return c.database
+}
+
+// ConnectionString returns the MySQL protocol DSN of the container.
+func (c *Container) ConnectionString(ctx context.Context, args ...string) (string, error) {
+	containerPort, err := c.MappedPort(ctx, mysqlPort)
+	if err != nil {
+		return "", err
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return dsn.NewMySQL(host, containerPort.Int()).Credentials("root", "").Database(c.database).Params(args...).String(), nil
+}
+
+// HTTPEndpoint returns the base URL of the HTTP API of the container.
+func (c *Container) HTTPEndpoint(ctx context.Context) (string, error) {
+	containerPort, err := c.MappedPort(ctx, httpPort)
+	if err != nil {
+		return "", err
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("http://%s:%s", host, containerPort.Port()), nil
+}
+
+// HTTPClient returns a client of the HTTP API of the container.
+func (c *Container) HTTPClient(ctx context.Context) (*HTTPClient, error) {
+	endpoint, err := c.HTTPEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPClient(endpoint, c.database), nil
+}
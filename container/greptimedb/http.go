@@ -0,0 +1,117 @@
+package greptimedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPClient talks to the HTTP API of GreptimeDB.
+type HTTPClient struct {
+	endpoint string
+	database string
+	client   *http.Client
+}
+
+// NewHTTPClient creates a client of the HTTP API at endpoint using the given database.
+func NewHTTPClient(endpoint, database string) *HTTPClient {
+	return &HTTPClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		database: database,
+		client:   http.DefaultClient,
+	}
+}
+
+// SQLOutput is a single statement result of the SQL API.
+type SQLOutput struct {
+	AffectedRows *int `json:"affectedrows,omitempty"`
+	Records      *struct {
+		Schema struct {
+			ColumnSchemas []struct {
+				Name     string `json:"name"`
+				DataType string `json:"data_type"`
+			} `json:"column_schemas"`
+		} `json:"schema"`
+		Rows [][]any `json:"rows"`
+	} `json:"records,omitempty"`
+}
+
+// SQLResponse is the response of the SQL API.
+type SQLResponse struct {
+	Output          []SQLOutput `json:"output"`
+	ExecutionTimeMS int64       `json:"execution_time_ms"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// Health checks the health endpoint of the server.
+func (c *HTTPClient) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/health", nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req)
+	return err
+}
+
+// SQL executes the statements with the SQL API.
+func (c *HTTPClient) SQL(ctx context.Context, query string) (*SQLResponse, error) {
+	form := url.Values{"sql": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.endpoint+"/v1/sql?db="+url.QueryEscape(c.database), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SQLResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode sql response: %w", err)
+	}
+	if resp.Error != "" {
+		return &resp, fmt.Errorf("failed to exec sql '%s': %s", query, resp.Error)
+	}
+	return &resp, nil
+}
+
+// WriteInfluxDB writes points in InfluxDB line protocol, precision is one of ns, us, ms, s.
+func (c *HTTPClient) WriteInfluxDB(ctx context.Context, lines string, precision string) error {
+	params := url.Values{"db": {c.database}}
+	if precision != "" {
+		params.Set("precision", precision)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.endpoint+"/v1/influxdb/write?"+params.Encode(), strings.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	_, err = c.do(req)
+	return err
+}
+
+func (c *HTTPClient) do(req *http.Request) ([]byte, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return body, fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, req.URL.Path, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,84 @@
+package greptimedb
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"strings"
+)
+
+// Column is a field column of a time-series table.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table describes a time-series table.
+type Table struct {
+	Name string
+	// TimeIndex is the timestamp column, "ts" if empty.
+	TimeIndex string
+	// Tags are the primary key columns, created as STRING.
+	Tags []string
+	// Fields are the value columns.
+	Fields []Column
+	// Options are appended as WITH (k = 'v', ...).
+	Options map[string]string
+}
+
+// CreateTableSQL renders the CREATE TABLE statement of the table.
+func (t Table) CreateTableSQL() string {
+	timeIndex := t.TimeIndex
+	if timeIndex == "" {
+		timeIndex = "ts"
+	}
+
+	columns := make([]string, 0, len(t.Tags)+len(t.Fields)+2)
+	for _, tag := range t.Tags {
+		columns = append(columns, fmt.Sprintf("`%s` STRING", tag))
+	}
+	for _, field := range t.Fields {
+		columns = append(columns, fmt.Sprintf("`%s` %s", field.Name, field.Type))
+	}
+	columns = append(columns, fmt.Sprintf("`%s` TIMESTAMP TIME INDEX", timeIndex))
+	if len(t.Tags) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (`%s`)", strings.Join(t.Tags, "`, `")))
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s)", t.Name, strings.Join(columns, ", "))
+	if len(t.Options) > 0 {
+		keys := make([]string, 0, len(t.Options))
+		for k := range t.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		opts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			opts = append(opts, fmt.Sprintf("'%s' = '%s'", k, t.Options[k]))
+		}
+		stmt += " WITH (" + strings.Join(opts, ", ") + ")"
+	}
+	return stmt
+}
+
+// CreateTables creates the given tables through the MySQL protocol.
+func CreateTables(ctx context.Context, db *sqlx.DB, tables ...Table) error {
+	for _, t := range tables {
+		if _, err := db.ExecContext(ctx, t.CreateTableSQL()); err != nil {
+			return fmt.Errorf("failed to create table '%s': %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// DropTables drops the given tables if they exist.
+func DropTables(ctx context.Context, db *sqlx.DB, names ...string) error {
+	for _, name := range names {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS `%s`", name)); err != nil {
+			return fmt.Errorf("failed to drop table '%s': %w", name, err)
+		}
+	}
+	return nil
+}
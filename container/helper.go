@@ -1,175 +1,1214 @@
 package container
 
 import (
+	gspanner "cloud.google.com/go/spanner"
 	"context"
 	"fmt"
+	"github.com/dennis2006/mtest/container/doris"
+	"github.com/dennis2006/mtest/container/greptimedb"
+	"github.com/dennis2006/mtest/container/kafka"
+	"github.com/dennis2006/mtest/container/postgres"
+	"github.com/dennis2006/mtest/container/spanner"
+	"github.com/dennis2006/mtest/dsn"
+	"github.com/dennis2006/mtest/migrate"
+	"github.com/elastic/go-elasticsearch/v8"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/qiniu/qmgo"
 	qnOpts "github.com/qiniu/qmgo/options"
+	amqp "github.com/rabbitmq/amqp091-go"
 	r "github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	tces "github.com/testcontainers/testcontainers-go/modules/elasticsearch"
+	tckafka "github.com/testcontainers/testcontainers-go/modules/kafka"
 	"github.com/testcontainers/testcontainers-go/modules/mongodb"
 	"github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/modules/rabbitmq"
 	"github.com/testcontainers/testcontainers-go/modules/redis"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"mutils/mtest/container/doris"
-	"os"
-	"path/filepath"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"net/http"
+	"time"
+)
+
+// The default images of the helpers, see WithImage
+const (
+	RedisImage      = "redis:6.2.6"
+	MySQLImage      = "mysql:8.4.5"
+	MongoDBImage    = "mongo:6.0.19"
+	DorisImage      = "starrocks/allin1-ubuntu:3.4.3"
+	GreptimeDBImage = "greptime/greptimedb:v0.14.4"
+	SpannerImage    = "gcr.io/cloud-spanner-emulator/emulator:1.5.28"
+	RabbitMQImage   = "rabbitmq:3.13.7-management-alpine"
+	KafkaImage      = "confluentinc/confluent-local:7.5.0"
 )
 
 type RedisContainer struct {
 	*redis.RedisContainer
 	RedisCli *r.Client
+
+	acl      aclClients
+	scripts  luaScripts
+	tracking *redisTracking
 }
 
 type MySQLContainer struct {
 	*mysql.MySQLContainer
 	Db *sqlx.DB
+	// Proxy is the ProxySQL container in front of MySQL, set by WithProxySQL
+	Proxy *ProxySQLContainer
+
+	migrations *migrations
 }
 
 type MongoDBContainer struct {
 	*mongodb.MongoDBContainer
 	MongoCli *qmgo.Client
+	// Client is a client of the official driver with the settings of
+	// MongoCli, for the libraries taking a *mongo.Client
+	Client *mongo.Client
+
+	slowOp time.Duration
+	// connStr is the connection string of MongoCli
+	connStr string
+	// database is the database of WithDatabase, test by default
+	database    string
+	secondaries []mongoSecondary
 }
 
 type DorisContainer struct {
 	*doris.Container
 	Db *sqlx.DB
+	// Backups is the repository of the snapshots, set by StartBackupRepository
+	Backups *BackupRepository
+
+	migrations *migrations
 }
 
-func CreateRedisContainer(ctx context.Context) (*RedisContainer, error) {
-	c, err := redis.Run(ctx, "redis:6.2.6")
-	if err != nil {
+type GreptimeDBContainer struct {
+	*greptimedb.Container
+	Db      *sqlx.DB
+	HTTPCli *greptimedb.HTTPClient
+}
+
+type SpannerContainer struct {
+	*spanner.Container
+	Client *gspanner.Client
+}
+
+type RabbitMQContainer struct {
+	*rabbitmq.RabbitMQContainer
+	// Conn is an AMQP connection as the admin user, Channel a channel on it
+	Conn    *amqp.Connection
+	Channel *amqp.Channel
+}
+
+// KafkaContainer is a single Kafka broker in KRaft mode with clients
+// connected to it, see KafkaCluster for several brokers
+type KafkaContainer struct {
+	*tckafka.KafkaContainer
+	// Brokers are the addresses of the brokers reachable from the host
+	Brokers []string
+	// Producer is a client producing to any topic
+	Producer *kgo.Client
+	// Consumer consumes the topics of WithKafkaTopics from the start, add
+	// more with Consumer.AddConsumeTopics
+	Consumer *kgo.Client
+	Admin    *kafka.Admin
+
+	partitions int32
+}
+
+type ElasticsearchContainer struct {
+	*tces.ElasticsearchContainer
+	Client *elasticsearch.Client
+	// Address is the base URL of the HTTP API, https unless the security is disabled
+	Address string
+}
+
+type PostgresContainer struct {
+	*postgres.Container
+	Db *sqlx.DB
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *RedisContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.tracking != nil {
+		_ = c.tracking.close()
+	}
+	return terminate(ctx, c, c.RedisContainer, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *MySQLContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.Proxy != nil {
+		_ = c.Proxy.Terminate(ctx, opts...)
+	}
+	return terminate(ctx, c, c.MySQLContainer, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *MongoDBContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.Client != nil {
+		_ = c.Client.Disconnect(ctx)
+	}
+	return terminate(ctx, c, c.MongoDBContainer, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *DorisContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.Backups != nil {
+		_ = c.Backups.Terminate(ctx, opts...)
+	}
+	return terminate(ctx, c, c.Container, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *GreptimeDBContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	return terminate(ctx, c, c.Container, opts...)
+}
+
+// Terminate closes the channel and the connection, runs the terminate hooks
+// and terminates the container
+func (c *RabbitMQContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.Channel != nil {
+		_ = c.Channel.Close()
+	}
+	if c.Conn != nil {
+		_ = c.Conn.Close()
+	}
+	return terminate(ctx, c, c.RabbitMQContainer, opts...)
+}
+
+// Terminate closes the clients, runs the terminate hooks and terminates the container
+func (c *KafkaContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	c.closeClients()
+	return terminate(ctx, c, c.KafkaContainer, opts...)
+}
+
+// closeClients closes the clients created so far
+func (c *KafkaContainer) closeClients() {
+	if c.Producer != nil {
+		c.Producer.Close()
+	}
+	if c.Consumer != nil {
+		c.Consumer.Close()
+	}
+	if c.Admin != nil {
+		c.Admin.Close()
+	}
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *ElasticsearchContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	return terminate(ctx, c, c.ElasticsearchContainer, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *SpannerContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	return terminate(ctx, c, c.Container, opts...)
+}
+
+// Terminate runs the terminate hooks and terminates the container
+func (c *PostgresContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	return terminate(ctx, c, c.Container, opts...)
+}
+
+func CreateRedisContainer(ctx context.Context, opts ...Option) (*RedisContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(RedisImage)
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
 		return nil, err
 	}
 
-	connStr, err := c.ConnectionString(ctx)
+	var (
+		c        *redis.RedisContainer
+		cli      *r.Client
+		tracking *redisTracking
+		ready    bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if tracking != nil {
+			_ = tracking.close()
+		}
+		if cli != nil {
+			_ = cli.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		customizers, err := o.containerCustomizers(ctx, o.redisModuleOpts(img)...)
+		if err != nil {
+			return err
+		}
+		c, err = redis.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
 	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
 		return nil, err
 	}
 
-	options, err := r.ParseURL(connStr)
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		connStr, err := c.ConnectionString(ctx)
+		if err != nil {
+			return err
+		}
+
+		if connStr, err = o.withRedisParams(connStr); err != nil {
+			return err
+		}
+		redisOpts, err := r.ParseURL(connStr)
+		if err != nil {
+			return err
+		}
+		if o.password != "" {
+			redisOpts.Password = o.password
+		}
+		switch o.redis.protocol {
+		case 0:
+		case 2, 3:
+			redisOpts.Protocol = o.redis.protocol
+		default:
+			return fmt.Errorf("unknown redis protocol %d, use 2 or 3", o.redis.protocol)
+		}
+		if o.redis.tracking {
+			if tracking, err = newRedisTracking(ctx, redisOpts, o.redis.trackingPrefixes); err != nil {
+				return err
+			}
+		}
+
+		cli = r.NewClient(redisOpts)
+		return cli.Ping(ctx).Err()
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Unable to connect to Redis: %v\n", err)
+		o.logf("Unable to connect to Redis: %v", err)
 		return nil, err
 	}
 
-	cli := r.NewClient(options)
-
-	return &RedisContainer{
+	hc := &RedisContainer{
 		RedisContainer: c,
 		RedisCli:       cli,
-	}, nil
+		tracking:       tracking,
+	}
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		if err := o.resetReused(ctx, hc); err != nil {
+			return err
+		}
+		if o.redis.keyspaceEvents != "" {
+			if err := cli.ConfigSet(ctx, "notify-keyspace-events", o.redis.keyspaceEvents).Err(); err != nil {
+				return fmt.Errorf("failed to enable keyspace notifications: %w", err)
+			}
+		}
+		for _, user := range o.aclUsers {
+			if _, err := hc.CreateACLUser(ctx, user); err != nil {
+				return err
+			}
+		}
+		for _, scripts := range o.luaScripts {
+			if err := hc.LoadScripts(ctx, scripts.fsys, scripts.glob); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		o.logf("failed to init redis: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
 }
 
-func CreateMySQLContainer(ctx context.Context) (*MySQLContainer, error) {
-	c, err := mysql.Run(ctx,
-		"mysql:8.4.5",
-		mysql.WithConfigFile(filepath.Join("..", "mounts", "mysql", "my_8.cnf")),
-		mysql.WithDatabase("foo"),
-		mysql.WithUsername("root"),
-		mysql.WithPassword("password"),
+func CreateMySQLContainer(ctx context.Context, opts ...Option) (*MySQLContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(MySQLImage)
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	var (
+		c     *mysql.MySQLContainer
+		db    *sqlx.DB
+		proxy *ProxySQLContainer
+		ready bool
 	)
+	defer func() {
+		if ready {
+			return
+		}
+		if proxy != nil {
+			_ = proxy.Terminate(context.WithoutCancel(ctx))
+		}
+		if db != nil {
+			_ = db.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		moduleOpts := []testcontainers.ContainerCustomizer{
+			o.mysqlConfigFile(),
+			mysql.WithDatabase(orDefault(o.database, defaultMySQLDatabase)),
+			mysql.WithUsername(orDefault(o.user, defaultMySQLUser)),
+			mysql.WithPassword(orDefault(o.password, defaultMySQLPassword)),
+			o.initScriptFiles("/docker-entrypoint-initdb.d"),
+		}
+		if o.binlogFormat != "" {
+			moduleOpts = append(moduleOpts, o.binlogArgs())
+		}
+		customizers, err := o.containerCustomizers(ctx, moduleOpts...)
+		if err != nil {
+			return err
+		}
+		c, err = mysql.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
 	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
 		return nil, err
 	}
 
-	connStr, err := c.ConnectionString(ctx)
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		connStr, err := c.ConnectionString(ctx, o.connParams...)
+		if err != nil {
+			return err
+		}
+
+		db, err = sqlx.ConnectContext(ctx, "mysql", connStr)
+		if err != nil {
+			return err
+		}
+		return db.PingContext(ctx)
+	})
 	if err != nil {
+		o.logf("Unable to connect to mysql: %v", err)
 		return nil, err
 	}
 
-	db, err := sqlx.Connect("mysql", connStr)
+	hc := &MySQLContainer{
+		MySQLContainer: c,
+		Db:             db,
+		migrations:     o.migrations,
+	}
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		if err := o.resetReused(ctx, hc); err != nil {
+			return err
+		}
+		return o.migrations.up(ctx, db.DB, migrate.MySQL)
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Unable to connect to mysql: %v\n", err)
+		o.logf("failed to init mysql: %v", err)
 		return nil, err
 	}
+	if o.proxySQL {
+		err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) (err error) {
+			proxy, err = hc.StartProxySQL(ctx, o.proxySQLRules...)
+			hc.Proxy = proxy
+			return err
+		})
+		if err != nil {
+			o.logf("failed to start proxysql: %v", err)
+			return nil, err
+		}
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
 
-	if err = db.Ping(); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to ping MySQL: %v\n", err)
+func CreateMongoDBContainer(ctx context.Context, opts ...Option) (*MongoDBContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(MongoDBImage)
+	if o.reuseName != "" && o.mongo.secondaries > 0 {
+		o.logf("replica sets with secondaries can't be reused, starting a new one")
+		o.reuseName = ""
+	}
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
 		return nil, err
 	}
 
-	return &MySQLContainer{
-		MySQLContainer: c,
-		Db:             db,
-	}, nil
+	var (
+		c        *mongodb.MongoDBContainer
+		mongoCli *qmgo.Client
+		client   *mongo.Client
+		connStr  string
+		ready    bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if mongoCli != nil {
+			_ = mongoCli.Close(context.WithoutCancel(ctx))
+		}
+		if client != nil {
+			_ = client.Disconnect(context.WithoutCancel(ctx))
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		moduleOpts := o.mongo.moduleOpts()
+		if o.user != "" || o.password != "" {
+			moduleOpts = append(moduleOpts, mongodb.WithUsername(o.user), mongodb.WithPassword(o.password))
+		}
+		if o.database != "" {
+			moduleOpts = append(moduleOpts, testcontainers.WithEnv(map[string]string{"MONGO_INITDB_DATABASE": o.database}))
+		}
+		if o.configFile != "" {
+			moduleOpts = append(moduleOpts, testcontainers.WithFiles(testcontainers.ContainerFile{
+				HostFilePath:      o.configFile,
+				ContainerFilePath: "/etc/mongod.conf",
+				FileMode:          0o644,
+			}), testcontainers.WithCmdArgs("--config", "/etc/mongod.conf"))
+		}
+		moduleOpts = append(moduleOpts, o.initScriptFiles("/docker-entrypoint-initdb.d"))
+		customizers, err := o.containerCustomizers(ctx, moduleOpts...)
+		if err != nil {
+			return err
+		}
+		c, err = mongodb.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		var err error
+		connStr, err = c.ConnectionString(ctx)
+		if err != nil {
+			return err
+		}
+		if connStr, err = o.withMongoParams(connStr); err != nil {
+			return err
+		}
+		if o.mongo.replicaSet != "" {
+			// the member is advertised with its container IP, don't discover it
+			u, err := dsn.ParseMongo(connStr)
+			if err != nil {
+				return err
+			}
+			connStr = u.Param("directConnection", "true").String()
+		}
+
+		timeout := o.mongo.connectTimeout.Milliseconds()
+		opts := qnOpts.ClientOptions{
+			ClientOptions: mongoOpts.Client().ApplyURI(connStr),
+		}
+		if o.mongo.writeConcern != nil {
+			opts.ClientOptions.SetWriteConcern(o.mongo.writeConcern)
+		}
+		cfg := qmgo.Config{
+			Uri:              connStr,
+			ConnectTimeoutMS: &timeout,
+			MaxPoolSize:      &o.mongo.maxPoolSize,
+			MinPoolSize:      &o.mongo.minPoolSize,
+			ReadPreference:   o.mongo.readPreference,
+		}
+
+		mongoCli, err = qmgo.NewClient(ctx, &cfg, opts)
+		if err != nil {
+			return err
+		}
+		if err = mongoCli.Ping(5); err != nil {
+			return err
+		}
+		clientOpts := opts.ClientOptions.SetMaxPoolSize(o.mongo.maxPoolSize).SetMinPoolSize(o.mongo.minPoolSize)
+		if timeout > 0 {
+			clientOpts.SetConnectTimeout(o.mongo.connectTimeout)
+		}
+		if pref := o.mongo.readPreference; pref != nil {
+			var rpOpts []readpref.Option
+			if pref.MaxStalenessMS > 0 {
+				rpOpts = append(rpOpts, readpref.WithMaxStaleness(time.Duration(pref.MaxStalenessMS)*time.Millisecond))
+			}
+			rp, err := readpref.New(pref.Mode, rpOpts...)
+			if err != nil {
+				return err
+			}
+			clientOpts.SetReadPreference(rp)
+		}
+		if client, err = mongo.Connect(ctx, clientOpts); err != nil {
+			return err
+		}
+		if o.mongo.replicaSet != "" {
+			return waitMongoPrimary(ctx, mongoCli, 30*time.Second)
+		}
+		return nil
+	})
+	if err != nil {
+		o.logf("Unable to connect to mongodb: %v", err)
+		return nil, err
+	}
+
+	hc := &MongoDBContainer{
+		MongoDBContainer: c,
+		MongoCli:         mongoCli,
+		Client:           client,
+		slowOp:           o.mongo.slowOp,
+		connStr:          connStr,
+		database:         orDefault(o.database, "test"),
+	}
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		if o.mongo.secondaries > 0 {
+			if err := hc.startSecondaries(ctx, o); err != nil {
+				return err
+			}
+		}
+		if err := o.resetReused(ctx, hc); err != nil {
+			return err
+		}
+		for _, f := range o.mongo.fixtures {
+			if err := hc.LoadFixture(ctx, f.db, f.coll, f.file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		o.logf("failed to load mongodb fixtures: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
 }
 
-func CreateMongoDBContainer(ctx context.Context) (*MongoDBContainer, error) {
-	c, err := mongodb.Run(ctx,
-		"mongo:6.0.19",
+// CreateDorisContainer starts the StarRocks all-in-one image, or Apache Doris
+// for Doris images, and connects to its database. Some features depend on the
+// flavor: RegisterJavaUDF needs WithDorisJavaUDFs on StarRocks.
+func CreateDorisContainer(ctx context.Context, opts ...Option) (*DorisContainer, error) {
+	o := newOptions(opts...)
+	img, checkArch, err := o.archImage(ctx, ServiceDoris, dorisImages)
+	if err != nil {
+		o.logf("no doris image: %v", err)
+		return nil, err
+	}
+	// check before pulling the large image that the machine can run it
+	adjust, err := o.preflight(ctx, dorisNeed)
+	if err != nil {
+		o.logf("preflight check failed: %v", err)
+		return nil, err
+	}
+	runner := newPhaseRunner(o)
+	if err = runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+	if checkArch {
+		if err = checkImageArch(ctx, ServiceDoris, img); err != nil {
+			o.logf("doris image can't run: %v", err)
+			return nil, err
+		}
+	}
+
+	var (
+		c     *doris.Container
+		db    *sqlx.DB
+		ready bool
 	)
+	defer func() {
+		if ready {
+			return
+		}
+		if db != nil {
+			_ = db.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err = runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		var moduleOpts []testcontainers.ContainerCustomizer
+		if o.password != "" {
+			moduleOpts = append(moduleOpts, doris.WithPassword(o.password))
+		}
+		if o.database != "" {
+			moduleOpts = append(moduleOpts, doris.WithDatabase(o.database))
+		}
+		if len(o.initScripts) > 0 {
+			moduleOpts = append(moduleOpts, doris.WithSQLScripts(o.initScripts...))
+		}
+		if adjust != nil {
+			moduleOpts = append(moduleOpts, adjust)
+		}
+		customizers, err := o.containerCustomizers(ctx, moduleOpts...)
+		if err != nil {
+			return err
+		}
+		c, err = doris.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to start container: %v\n", err)
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
 		return nil, err
 	}
 
-	connStr, err := c.ConnectionString(ctx)
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		connStr, err := c.ConnectionString(ctx, append([]string{"charset=utf8mb4", "parseTime=True"}, o.connParams...)...)
+		if err != nil {
+			return err
+		}
+
+		db, err = sqlx.ConnectContext(ctx, "mysql", connStr)
+		if err != nil {
+			return err
+		}
+		return db.PingContext(ctx)
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to get connection string: %v\n", err)
+		o.logf("Unable to connect to mysql: %v", err)
+		return nil, err
+	}
+
+	hc := &DorisContainer{
+		Container:  c,
+		Db:         db,
+		migrations: o.migrations,
+	}
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		if err := o.resetReused(ctx, hc); err != nil {
+			return err
+		}
+		return o.migrations.up(ctx, db.DB, dorisDialect(c.Flavor()))
+	})
+	if err != nil {
+		o.logf("failed to init doris: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
+
+func CreateGreptimeDBContainer(ctx context.Context, opts ...Option) (*GreptimeDBContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(GreptimeDBImage)
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
 		return nil, err
 	}
 
 	var (
-		timeout     int64  = 2000
-		maxPoolSize uint64 = 100
-		minPoolSize uint64 = 0
+		c       *greptimedb.Container
+		db      *sqlx.DB
+		httpCli *greptimedb.HTTPClient
+		ready   bool
 	)
-	opts := qnOpts.ClientOptions{
-		ClientOptions: options.Client().ApplyURI(connStr),
+	defer func() {
+		if ready {
+			return
+		}
+		if db != nil {
+			_ = db.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		customizers, err := o.containerCustomizers(ctx)
+		if err != nil {
+			return err
+		}
+		c, err = greptimedb.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
 	}
-	cfg := qmgo.Config{
-		Uri:              connStr,
-		ConnectTimeoutMS: &timeout,
-		MaxPoolSize:      &maxPoolSize,
-		MinPoolSize:      &minPoolSize,
+	if err = runHooks(ctx, EventStart, c); err != nil {
+		return nil, err
 	}
 
-	mongoCli, err := qmgo.NewClient(ctx, &cfg, opts)
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		connStr, err := c.ConnectionString(ctx, "parseTime=True")
+		if err != nil {
+			return err
+		}
+
+		db, err = sqlx.ConnectContext(ctx, "mysql", connStr)
+		if err != nil {
+			return err
+		}
+		if err = db.PingContext(ctx); err != nil {
+			return err
+		}
+
+		httpCli, err = c.HTTPClient(ctx)
+		if err != nil {
+			return err
+		}
+		return httpCli.Health(ctx)
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Unable to connect to mongodb: %v\n", err)
+		o.logf("Unable to connect to greptimedb: %v", err)
+		return nil, err
+	}
+
+	hc := &GreptimeDBContainer{
+		Container: c,
+		Db:        db,
+		HTTPCli:   httpCli,
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
 		return nil, err
 	}
+	ready = true
+	return hc, nil
+}
 
-	err = mongoCli.Ping(5)
+func CreateSpannerContainer(ctx context.Context, opts ...Option) (*SpannerContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(SpannerImage)
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	var (
+		c     *spanner.Container
+		cli   *gspanner.Client
+		ready bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if cli != nil {
+			cli.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		customizers, err := o.containerCustomizers(ctx)
+		if err != nil {
+			return err
+		}
+		c, err = spanner.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "MongoClient ping failed:  %v\n", err)
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
 		return nil, err
 	}
 
-	return &MongoDBContainer{
-		MongoDBContainer: c,
-		MongoCli:         mongoCli,
-	}, nil
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) (err error) {
+		cli, err = c.Client(ctx)
+		return err
+	})
+	if err != nil {
+		o.logf("Unable to connect to spanner: %v", err)
+		return nil, err
+	}
+
+	hc := &SpannerContainer{
+		Container: c,
+		Client:    cli,
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
 }
 
-func CreateDorisContainer(ctx context.Context) (*DorisContainer, error) {
-	c, err := doris.Run(ctx, "starrocks/allin1-ubuntu:3.4.3")
+// CreateRabbitMQContainer starts RabbitMQ with the management plugin, opens
+// a connection and a channel, and declares the exchanges and queues of
+// WithDeadLetter and WithDelayedExchange. WithDelayedMessagePlugin runs an
+// image with the delayed message exchange plugin.
+func CreateRabbitMQContainer(ctx context.Context, opts ...Option) (*RabbitMQContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(orDefault(o.rabbit.delayedImage, RabbitMQImage))
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	var (
+		c     *rabbitmq.RabbitMQContainer
+		conn  *amqp.Connection
+		ch    *amqp.Channel
+		ready bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if ch != nil {
+			_ = ch.Close()
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		customizers, err := o.containerCustomizers(ctx)
+		if err != nil {
+			return err
+		}
+		c, err = rabbitmq.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to start container: %v\n", err)
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
 		return nil, err
 	}
 
-	connStr, err := c.ConnectionString(ctx, "charset=utf8mb4", "parseTime=True")
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		url, err := c.AmqpURL(ctx)
+		if err != nil {
+			return err
+		}
+		if conn, err = amqp.Dial(url); err != nil {
+			return err
+		}
+		ch, err = conn.Channel()
+		return err
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to get connection string: %v\n", err)
+		o.logf("Unable to connect to rabbitmq: %v", err)
 		return nil, err
 	}
 
-	db, err := sqlx.Connect("mysql", connStr)
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		if o.rabbit.delayedImage != "" {
+			code, _, err := c.Exec(ctx, []string{"rabbitmq-plugins", "enable", "rabbitmq_delayed_message_exchange"})
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return fmt.Errorf("failed to enable delayed message plugin, exit code %d", code)
+			}
+		}
+		for _, declare := range o.rabbit.topology {
+			if err := declare(ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Unable to connect to mysql: %v\n", err)
+		o.logf("failed to declare rabbitmq topology: %v", err)
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to ping MySQL: %v\n", err)
+	hc := &RabbitMQContainer{
+		RabbitMQContainer: c,
+		Conn:              conn,
+		Channel:           ch,
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
+
+// CreateKafkaContainer starts a single broker, connects a producer, a
+// consumer and an admin client to it and creates the topics of
+// WithKafkaTopics with the partitions of WithKafkaPartitions.
+func CreateKafkaContainer(ctx context.Context, opts ...Option) (*KafkaContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(KafkaImage)
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
 		return nil, err
 	}
 
-	return &DorisContainer{
+	var (
+		c     *tckafka.KafkaContainer
+		hc    *KafkaContainer
+		ready bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if hc != nil {
+			hc.closeClients()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		customizers, err := o.containerCustomizers(ctx)
+		if err != nil {
+			return err
+		}
+		c, err = tckafka.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
+		return nil, err
+	}
+
+	hc = &KafkaContainer{KafkaContainer: c, partitions: o.kafka.partitions}
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		if hc.Brokers, err = c.Brokers(ctx); err != nil {
+			return err
+		}
+		if hc.Admin, err = kafka.NewAdmin(hc.Brokers...); err != nil {
+			return err
+		}
+		if hc.Producer, err = kgo.NewClient(kgo.SeedBrokers(hc.Brokers...)); err != nil {
+			return err
+		}
+		hc.Consumer, err = kgo.NewClient(
+			kgo.SeedBrokers(hc.Brokers...),
+			kgo.ConsumeTopics(o.kafka.topics...),
+			kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		)
+		if err != nil {
+			return err
+		}
+		// the clients connect lazily, ping to fail here if the broker is unreachable
+		return hc.Producer.Ping(ctx)
+	})
+	if err != nil {
+		o.logf("Unable to connect to kafka: %v", err)
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		return createKafkaTopics(ctx, hc.Admin.Client(), o.kafka.partitions, 1, o.kafka.topics...)
+	})
+	if err != nil {
+		o.logf("failed to create kafka topics: %v", err)
+		return nil, err
+	}
+
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
+
+// CreateElasticsearchContainer starts a single node Elasticsearch, or
+// OpenSearch with WithOpenSearch, and creates the indexes and documents of
+// WithESIndex and WithESDocuments. WithConfigFile replaces elasticsearch.yml.
+func CreateElasticsearchContainer(ctx context.Context, opts ...Option) (*ElasticsearchContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(ElasticsearchImage)
+	adjust, err := o.preflight(ctx, esNeed)
+	if err != nil {
+		o.logf("preflight check failed: %v", err)
+		return nil, err
+	}
+	runner := newPhaseRunner(o)
+	if err = runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	var (
+		c     *tces.ElasticsearchContainer
+		ready bool
+	)
+	defer func() {
+		if !ready {
+			o.abort(ctx, c)
+		}
+	}()
+	err = runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		var moduleOpts []testcontainers.ContainerCustomizer
+		if adjust != nil {
+			moduleOpts = append(moduleOpts, adjust)
+		}
+		moduleOpts = append(moduleOpts, o.es.moduleOpts(img, o.configFile)...)
+		if o.password != "" && !isOpenSearch(img) {
+			moduleOpts = append(moduleOpts, tces.WithPassword(o.password))
+		}
+		customizers, err := o.containerCustomizers(ctx, moduleOpts...)
+		if err != nil {
+			return err
+		}
+		c, err = tces.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
+		return nil, err
+	}
+
+	var cli *elasticsearch.Client
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		cfg := elasticsearch.Config{
+			Addresses: []string{c.Settings.Address},
+			CACert:    c.Settings.CACert,
+		}
+		if !o.es.noSecurity && !isOpenSearch(img) {
+			cfg.Username = c.Settings.Username
+			cfg.Password = c.Settings.Password
+		}
+		if isOpenSearch(img) {
+			cfg.Transport = &openSearchTransport{next: http.DefaultTransport}
+		}
+		if cli, err = elasticsearch.NewClient(cfg); err != nil {
+			return err
+		}
+		res, err := cli.Info(cli.Info.WithContext(ctx))
+		if err = esResponseError(res, err); err != nil {
+			return err
+		}
+		return res.Body.Close()
+	})
+	if err != nil {
+		o.logf("Unable to connect to elasticsearch: %v", err)
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		return o.es.bootstrap(ctx, cli)
+	})
+	if err != nil {
+		o.logf("failed to bootstrap elasticsearch: %v", err)
+		return nil, err
+	}
+
+	hc := &ElasticsearchContainer{ElasticsearchContainer: c, Client: cli, Address: c.Settings.Address}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
+
+// CreatePostgresContainer starts PostgreSQL with the extensions of
+// WithExtensions and the .sql and .sh init scripts of WithInitScripts, and
+// connects to its database, "test" as postgres/password by default.
+func CreatePostgresContainer(ctx context.Context, opts ...Option) (*PostgresContainer, error) {
+	o := newOptions(opts...)
+	img := o.imageOr(o.postgres.image())
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	var (
+		c     *postgres.Container
+		db    *sqlx.DB
+		ready bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if db != nil {
+			_ = db.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		moduleOpts := []testcontainers.ContainerCustomizer{
+			o.postgres.extensionsScript(),
+			o.initScriptFiles("/docker-entrypoint-initdb.d"),
+		}
+		if o.user != "" {
+			moduleOpts = append(moduleOpts, postgres.WithUsername(o.user))
+		}
+		if o.password != "" {
+			moduleOpts = append(moduleOpts, postgres.WithPassword(o.password))
+		}
+		if o.database != "" {
+			moduleOpts = append(moduleOpts, postgres.WithDatabase(o.database))
+		}
+		customizers, err := o.containerCustomizers(ctx, moduleOpts...)
+		if err != nil {
+			return err
+		}
+		c, err = postgres.Run(ctx, img, append(customizers, runner.customizer())...)
+		return err
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	if err = runHooks(ctx, EventStart, c); err != nil {
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		connStr, err := c.ConnectionString(ctx, o.connParams...)
+		if err != nil {
+			return err
+		}
+
+		db, err = sqlx.ConnectContext(ctx, "postgres", connStr)
+		if err != nil {
+			return err
+		}
+		return db.PingContext(ctx)
+	})
+	if err != nil {
+		o.logf("Unable to connect to postgres: %v", err)
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		return o.postgres.createExtensions(ctx, db, img)
+	})
+	if err != nil {
+		o.logf("failed to init postgres: %v", err)
+		return nil, err
+	}
+
+	hc := &PostgresContainer{
 		Container: c,
 		Db:        db,
-	}, nil
+	}
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
 }
@@ -0,0 +1,152 @@
+package container
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	"github.com/testcontainers/testcontainers-go"
+	"path/filepath"
+)
+
+// defaultMySQLConfig is the config file of the MySQL helper, embedded so the
+// helper works from any working directory
+//
+//go:embed mounts/mysql/my_8.cnf
+var defaultMySQLConfig []byte
+
+const (
+	defaultMySQLDatabase = "foo"
+	defaultMySQLUser     = "root"
+	defaultMySQLPassword = "password"
+)
+
+// WithImage overrides the image of the helper, e.g. "mysql:8.0.36" to test
+// against another server version.
+func WithImage(image string) Option {
+	return func(o *options) {
+		o.image = image
+	}
+}
+
+// WithCredentials sets the user and password the helper creates and connects
+// with. MySQL and MongoDB use both, root/password for MySQL and none for
+// MongoDB by default. Redis and Doris only take the password, set as the
+// password of the default and the root user.
+func WithCredentials(user, password string) Option {
+	return func(o *options) {
+		o.user = user
+		o.password = password
+	}
+}
+
+// WithDatabase sets the database the helper creates and connects to, "foo" for
+// MySQL by default. For MongoDB it is the database the init scripts run against.
+func WithDatabase(name string) Option {
+	return func(o *options) {
+		o.database = name
+	}
+}
+
+// WithConfigFile replaces the server config file of the MySQL, Redis and
+// MongoDB helpers with the file at path.
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.configFile = path
+	}
+}
+
+// WithInitScripts runs the scripts when the MySQL, MongoDB, Doris or Postgres
+// container starts, in the given order: .sql and .sh files for MySQL and
+// Postgres, .js and .sh files for MongoDB and .sql files for Doris.
+func WithInitScripts(paths ...string) Option {
+	return func(o *options) {
+		o.initScripts = append(o.initScripts, paths...)
+	}
+}
+
+// WithConnParams adds "key=value" parameters to the connection string of the
+// helper's client, e.g. "parseTime=true" for MySQL, "maxPoolSize=10" for
+// MongoDB or "protocol=3" for Redis.
+func WithConnParams(params ...string) Option {
+	return func(o *options) {
+		o.connParams = append(o.connParams, params...)
+	}
+}
+
+// imageOr returns the image set by WithImage, img if none
+func (o *options) imageOr(img string) string {
+	if o.image != "" {
+		return o.image
+	}
+	return img
+}
+
+// orDefault returns v, def if v is empty
+func orDefault(v, def string) string {
+	if v != "" {
+		return v
+	}
+	return def
+}
+
+// withRedisParams sets the parameters of WithConnParams on a Redis URL
+func (o *options) withRedisParams(connStr string) (string, error) {
+	if len(o.connParams) == 0 {
+		return connStr, nil
+	}
+	u, err := dsn.ParseRedis(connStr)
+	if err != nil {
+		return "", err
+	}
+	return u.Params(o.connParams...).String(), nil
+}
+
+// withMongoParams is withRedisParams for a MongoDB connection string
+func (o *options) withMongoParams(connStr string) (string, error) {
+	if len(o.connParams) == 0 {
+		return connStr, nil
+	}
+	u, err := dsn.ParseMongo(connStr)
+	if err != nil {
+		return "", err
+	}
+	return u.Params(o.connParams...).String(), nil
+}
+
+// initScriptFiles copies the scripts of WithInitScripts into dir, prefixed by
+// their index so they run in the given order
+func (o *options) initScriptFiles(dir string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		for i, script := range o.initScripts {
+			req.Files = append(req.Files, testcontainers.ContainerFile{
+				HostFilePath:      script,
+				ContainerFilePath: dir + "/" + indexedName(i, filepath.Base(script)),
+				FileMode:          0o755,
+			})
+		}
+		return nil
+	}
+}
+
+// mysqlConfigFile mounts the config file of WithConfigFile, the embedded
+// default config if none
+func (o *options) mysqlConfigFile() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		file := testcontainers.ContainerFile{
+			ContainerFilePath: "/etc/mysql/conf.d/my.cnf",
+			FileMode:          0o644,
+		}
+		if o.configFile != "" {
+			file.HostFilePath = o.configFile
+		} else {
+			file.Reader = bytes.NewReader(defaultMySQLConfig)
+		}
+		req.Files = append(req.Files, file)
+		return nil
+	}
+}
+
+func indexedName(i int, name string) string {
+	return fmt.Sprintf("%03d-%s", i, name)
+}
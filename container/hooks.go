@@ -0,0 +1,127 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"sync"
+)
+
+// Event is a lifecycle event of the containers created by the helpers
+type Event string
+
+const (
+	// EventStart fires once the container is running, the handle is the
+	// module container, e.g. *redis.RedisContainer or *doris.Container.
+	EventStart Event = "start"
+	// EventReady fires once the clients are connected, the handle is the
+	// helper container, e.g. *RedisContainer or *DorisContainer.
+	EventReady Event = "ready"
+	// EventTerminate fires before the helper container is terminated,
+	// the handle is the helper container.
+	EventTerminate Event = "terminate"
+)
+
+type hook struct {
+	id    uint64
+	event Event
+	fn    func(ctx context.Context, handle any) error
+}
+
+var hooks struct {
+	mu     sync.RWMutex
+	nextID uint64
+	list   []hook
+}
+
+// OnEvent registers fn to be called on the event for every handle of type T,
+// use any as T to receive all handles. Errors of start and ready hooks fail
+// the helper. The returned func unregisters the hook.
+func OnEvent[T any](event Event, fn func(ctx context.Context, handle T) error) (unregister func()) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	hooks.nextID++
+	id := hooks.nextID
+	hooks.list = append(hooks.list, hook{
+		id:    id,
+		event: event,
+		fn: func(ctx context.Context, handle any) error {
+			typed, ok := handle.(T)
+			if !ok {
+				return nil
+			}
+			return fn(ctx, typed)
+		},
+	})
+
+	return func() {
+		hooks.mu.Lock()
+		defer hooks.mu.Unlock()
+		for i, h := range hooks.list {
+			if h.id == id {
+				hooks.list = append(hooks.list[:i], hooks.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnStart registers fn for EventStart, see OnEvent
+func OnStart[T any](fn func(ctx context.Context, handle T) error) (unregister func()) {
+	return OnEvent(EventStart, fn)
+}
+
+// OnReady registers fn for EventReady, see OnEvent
+func OnReady[T any](fn func(ctx context.Context, handle T) error) (unregister func()) {
+	return OnEvent(EventReady, fn)
+}
+
+// OnTerminate registers fn for EventTerminate, see OnEvent
+func OnTerminate[T any](fn func(ctx context.Context, handle T) error) (unregister func()) {
+	return OnEvent(EventTerminate, fn)
+}
+
+// runHooks calls the hooks of the event in registration order and stops at the first error.
+func runHooks(ctx context.Context, event Event, handle any) error {
+	hooks.mu.RLock()
+	list := make([]hook, len(hooks.list))
+	copy(list, hooks.list)
+	hooks.mu.RUnlock()
+
+	for _, h := range list {
+		if h.event != event {
+			continue
+		}
+		if err := h.fn(ctx, handle); err != nil {
+			return fmt.Errorf("%s hook: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// terminate runs the terminate hooks of handle and terminates the container,
+// the container is terminated even if a hook fails. Containers of WithReuse
+// are left running.
+func terminate(ctx context.Context, handle any, c testcontainers.Container, opts ...testcontainers.TerminateOption) error {
+	hookErr := runHooks(ctx, EventTerminate, handle)
+	if isReused(ctx, c) {
+		// WithReuse keeps the container for the next run
+		return hookErr
+	}
+	return errors.Join(hookErr, c.Terminate(ctx, opts...))
+}
+
+// abort terminates the container of a helper whose startup failed, c may be
+// nil if it didn't start. Unlike terminate it runs no hooks, the handle never
+// got ready. Containers of WithReuse are left running.
+func (o *options) abort(ctx context.Context, c testcontainers.Container) {
+	ctx = context.WithoutCancel(ctx)
+	if !o.reused {
+		if err := testcontainers.TerminateContainer(c, testcontainers.StopContext(ctx)); err != nil {
+			o.logf("failed to terminate container: %v", err)
+		}
+	}
+	o.removeIPv6Network(ctx)
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"sort"
+	"testing"
+)
+
+// Admin is a Kafka admin client for assertions on topics and consumer groups
+type Admin struct {
+	brokers []string
+	client  *kgo.Client
+	adm     *kadm.Client
+}
+
+// NewAdmin creates an admin client of the brokers, e.g. "localhost:9092"
+func NewAdmin(brokers ...string) (*Admin, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	return &Admin{brokers: brokers, client: client, adm: kadm.NewClient(client)}, nil
+}
+
+// Client returns the underlying admin client
+func (a *Admin) Client() *kadm.Client {
+	return a.adm
+}
+
+// Close closes the admin client
+func (a *Admin) Close() {
+	a.client.Close()
+}
+
+// PartitionLag is the lag of a consumer group on a partition
+type PartitionLag struct {
+	Partition int32
+	// Committed is the offset committed by the group, -1 if none
+	Committed int64
+	// End is the high watermark of the partition
+	End int64
+	// Lag is the number of records the group has not consumed yet. Without
+	// a commit, it counts from the start of the partition.
+	Lag int64
+}
+
+// TopicLag is the lag of a consumer group on all partitions of a topic
+type TopicLag struct {
+	Group      string
+	Topic      string
+	Partitions []PartitionLag
+	Total      int64
+}
+
+// ConsumerLag returns the lag of the consumer group on every partition of the topic
+func (a *Admin) ConsumerLag(ctx context.Context, group, topic string) (*TopicLag, error) {
+	starts, err := a.adm.ListStartOffsets(ctx, topic)
+	if err == nil {
+		err = starts.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list start offsets of topic '%s': %w", topic, err)
+	}
+	ends, err := a.adm.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list end offsets of topic '%s': %w", topic, err)
+	}
+	if len(ends[topic]) == 0 {
+		return nil, fmt.Errorf("topic '%s' does not exist", topic)
+	}
+	commits, err := a.adm.FetchOffsetsForTopics(ctx, group, topic)
+	if err == nil {
+		err = commits.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group '%s': %w", group, err)
+	}
+
+	lag := &TopicLag{Group: group, Topic: topic}
+	for partition, end := range ends[topic] {
+		pl := PartitionLag{Partition: partition, Committed: -1, End: end.Offset}
+		from := int64(0)
+		if start, ok := starts.Lookup(topic, partition); ok {
+			from = start.Offset
+		}
+		if commit, ok := commits.Lookup(topic, partition); ok && commit.At >= 0 {
+			pl.Committed = commit.At
+			from = commit.At
+		}
+		pl.Lag = max(end.Offset-from, 0)
+		lag.Partitions = append(lag.Partitions, pl)
+		lag.Total += pl.Lag
+	}
+	sort.Slice(lag.Partitions, func(i, j int) bool {
+		return lag.Partitions[i].Partition < lag.Partitions[j].Partition
+	})
+	return lag, nil
+}
+
+// CommittedOffset returns the offset committed by the consumer group
+// on the partition, -1 if the group has not committed any.
+func (a *Admin) CommittedOffset(ctx context.Context, group, topic string, partition int32) (int64, error) {
+	commits, err := a.adm.FetchOffsetsForTopics(ctx, group, topic)
+	if err == nil {
+		err = commits.Error()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch offsets of group '%s': %w", group, err)
+	}
+	commit, ok := commits.Lookup(topic, partition)
+	if !ok {
+		return 0, fmt.Errorf("partition %d of topic '%s' does not exist", partition, topic)
+	}
+	return commit.At, nil
+}
+
+// AssertCommittedOffset asserts that the consumer group committed the offset on the partition.
+func (a *Admin) AssertCommittedOffset(t testing.TB, group, topic string, partition int32, offset int64) bool {
+	t.Helper()
+
+	committed, err := a.CommittedOffset(context.Background(), group, topic, partition)
+	if err != nil {
+		t.Errorf("failed to get committed offset: %v", err)
+		return false
+	}
+	if committed != offset {
+		t.Errorf("group '%s' committed offset %d on %s[%d], want %d", group, committed, topic, partition, offset)
+		return false
+	}
+	return true
+}
+
+// AssertLag asserts the total lag of the consumer group on the topic, e.g. 0
+// once an at-least-once consumer has processed and committed everything.
+func (a *Admin) AssertLag(t testing.TB, group, topic string, lag int64) bool {
+	t.Helper()
+
+	got, err := a.ConsumerLag(context.Background(), group, topic)
+	if err != nil {
+		t.Errorf("failed to get consumer lag: %v", err)
+		return false
+	}
+	if got.Total != lag {
+		t.Errorf("group '%s' lags %d records behind on topic '%s', want %d, partitions: %+v",
+			group, got.Total, topic, lag, got.Partitions)
+		return false
+	}
+	return true
+}
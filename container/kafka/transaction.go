@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+var (
+	txnSeq          atomic.Uint64
+	txnInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// TransactionalID returns a transactional id unique to the test run, so
+// producers of different tests, or of the same test run with -count, don't
+// fence each other.
+func TransactionalID(t testing.TB) string {
+	return "mtest-" + txnInvalidChars.ReplaceAllString(t.Name(), "_") + "-" + strconv.FormatUint(txnSeq.Add(1), 10)
+}
+
+// TransactionalProducer creates a producer with a TransactionalID, it is closed
+// when the test finishes. Use BeginTransaction and EndTransaction on it.
+func (a *Admin) TransactionalProducer(t testing.TB, opts ...kgo.Opt) *kgo.Client {
+	t.Helper()
+
+	opts = append([]kgo.Opt{kgo.SeedBrokers(a.brokers...), kgo.TransactionalID(TransactionalID(t))}, opts...)
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("failed to create transactional producer: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// ReadTopic reads all records of the topic from the start, with the
+// read_committed isolation level if committed, which skips the records of
+// aborted and still open transactions. It reads each partition up to its
+// end offset when called, the last stable offset if committed, so records
+// produced meanwhile are left out. Records are sorted by partition and offset.
+func (a *Admin) ReadTopic(ctx context.Context, topic string, committed bool) ([]*kgo.Record, error) {
+	starts, err := a.adm.ListStartOffsets(ctx, topic)
+	if err == nil {
+		err = starts.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list start offsets of topic '%s': %w", topic, err)
+	}
+	listEnds, isolation := a.adm.ListEndOffsets, kgo.ReadUncommitted()
+	if committed {
+		listEnds, isolation = a.adm.ListCommittedOffsets, kgo.ReadCommitted()
+	}
+	ends, err := listEnds(ctx, topic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list end offsets of topic '%s': %w", topic, err)
+	}
+	if len(ends[topic]) == 0 {
+		return nil, fmt.Errorf("topic '%s' does not exist", topic)
+	}
+
+	// the partitions to read, from their start to their end offset
+	pending := make(map[int32]kgo.Offset)
+	end := make(map[int32]int64)
+	for partition, o := range ends[topic] {
+		from := int64(0)
+		if start, ok := starts.Lookup(topic, partition); ok {
+			from = start.Offset
+		}
+		if o.Offset > from {
+			pending[partition] = kgo.NewOffset().At(from)
+			end[partition] = o.Offset
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(a.brokers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: pending}),
+		kgo.FetchIsolationLevel(isolation),
+		// the transaction markers take offsets too, keep them to see a
+		// partition reach its end when it ends with one
+		kgo.KeepControlRecords(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	defer client.Close()
+
+	var records []*kgo.Record
+	for len(pending) > 0 {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to read topic '%s', %d partitions not read to their end: %w", topic, len(pending), ctx.Err())
+		}
+		if err = fetches.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read topic '%s': %w", topic, err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			if r.Offset >= end[r.Partition] {
+				return
+			}
+			if r.Offset+1 >= end[r.Partition] {
+				delete(pending, r.Partition)
+			}
+			if !r.Attrs.IsControl() {
+				records = append(records, r)
+			}
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Partition != records[j].Partition {
+			return records[i].Partition < records[j].Partition
+		}
+		return records[i].Offset < records[j].Offset
+	})
+	return records, nil
+}
+
+// AbortedRecords returns the records of the topic that a read_committed
+// consumer doesn't see, i.e. those of aborted or still open transactions.
+func (a *Admin) AbortedRecords(ctx context.Context, topic string) ([]*kgo.Record, error) {
+	all, err := a.ReadTopic(ctx, topic, false)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := a.ReadTopic(ctx, topic, true)
+	if err != nil {
+		return nil, err
+	}
+
+	type position struct {
+		partition int32
+		offset    int64
+	}
+	visible := make(map[position]bool, len(committed))
+	for _, r := range committed {
+		visible[position{r.Partition, r.Offset}] = true
+	}
+	var aborted []*kgo.Record
+	for _, r := range all {
+		if !visible[position{r.Partition, r.Offset}] {
+			aborted = append(aborted, r)
+		}
+	}
+	return aborted, nil
+}
+
+// AssertCommittedValues asserts that a read_committed consumer of the topic
+// sees exactly the values, in any order, e.g. that an exactly-once pipeline
+// produced every output once and none of its aborted attempts is visible.
+func (a *Admin) AssertCommittedValues(t testing.TB, topic string, values ...string) bool {
+	t.Helper()
+
+	records, err := a.ReadTopic(context.Background(), topic, true)
+	if err != nil {
+		t.Errorf("failed to read committed records: %v", err)
+		return false
+	}
+	got := make([]string, len(records))
+	for i, r := range records {
+		got[i] = string(r.Value)
+	}
+	want := append([]string(nil), values...)
+	sort.Strings(got)
+	sort.Strings(want)
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("read_committed consumer of topic '%s' sees values %q, want %q", topic, got, want)
+		return false
+	}
+	return true
+}
+
+// AssertAborted asserts the number of records of the topic hidden from
+// read_committed consumers because their transaction was aborted.
+func (a *Admin) AssertAborted(t testing.TB, topic string, n int) bool {
+	t.Helper()
+
+	aborted, err := a.AbortedRecords(context.Background(), topic)
+	if err != nil {
+		t.Errorf("failed to read aborted records: %v", err)
+		return false
+	}
+	if len(aborted) != n {
+		values := make([]string, len(aborted))
+		for i, r := range aborted {
+			values[i] = string(r.Value)
+		}
+		t.Errorf("topic '%s' has %d aborted records, want %d, aborted values: %q", topic, len(aborted), n, values)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,356 @@
+package container
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dennis2006/mtest/container/kafka"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/network"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KafkaClusterImage is the image of the brokers of CreateKafkaCluster
+const KafkaClusterImage = "apache/kafka:3.8.0"
+
+const (
+	kafkaInternalPort   = 19092
+	kafkaControllerPort = 9093
+	kafkaExternalPort   = 9092
+	// kafkaStarter holds the advertised listeners of a broker, the broker
+	// waits for it since its host port is known once the container started
+	kafkaStarter = "/tmp/mtest-kafka-listeners"
+)
+
+// KafkaCluster is a cluster of Kafka brokers on their own network, each one
+// also being a KRaft controller. Brokers are numbered from 1.
+type KafkaCluster struct {
+	// Brokers are the addresses of the brokers reachable from the host, by id - 1
+	Brokers []string
+	// Producer is a client producing to any topic
+	Producer *kgo.Client
+	// Consumer consumes the topics of WithKafkaTopics from the start
+	Consumer *kgo.Client
+	Admin    *kafka.Admin
+
+	containers []testcontainers.Container
+	network    *testcontainers.DockerNetwork
+	partitions int32
+}
+
+// CreateKafkaCluster starts n brokers, 3 for the usual replication tests. The
+// topics of WithKafkaTopics are replicated to min(n, 3) brokers, like the
+// internal topics. Each broker publishes a host port assigned by docker, a
+// restarted broker may get another one that the clients find in the metadata.
+func CreateKafkaCluster(ctx context.Context, n int, opts ...Option) (*KafkaCluster, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("a kafka cluster needs at least 1 broker, got %d", n)
+	}
+	o := newOptions(opts...)
+	img := o.imageOr(KafkaClusterImage)
+	if o.reuseName != "" {
+		o.logf("clusters can't be reused, starting a new one")
+		o.reuseName = ""
+	}
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	kc := &KafkaCluster{partitions: o.kafka.partitions, containers: make([]testcontainers.Container, n), Brokers: make([]string, n)}
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		var err error
+		if kc.network, err = network.New(ctx); err != nil {
+			return fmt.Errorf("failed to create network: %w", err)
+		}
+		voters := make([]string, n)
+		for i := range n {
+			voters[i] = fmt.Sprintf("%d@%s:%d", i+1, kafkaBrokerAlias(i+1), kafkaControllerPort)
+		}
+		clusterID, err := kafkaClusterID()
+		if err != nil {
+			return err
+		}
+
+		// the controllers need a quorum to start, so the brokers start together
+		g, gctx := errgroup.WithContext(ctx)
+		for i := range n {
+			g.Go(func() error {
+				id := i + 1
+				port := nat.Port(strconv.Itoa(kafkaExternalPort) + "/tcp")
+				replicas := strconv.Itoa(min(n, 3))
+				req := testcontainers.GenericContainerRequest{
+					ContainerRequest: testcontainers.ContainerRequest{
+						Image:        img,
+						ExposedPorts: []string{string(port)},
+						Entrypoint: []string{"sh", "-c", "while [ ! -f " + kafkaStarter + " ]; do sleep 0.1; done; . " + kafkaStarter +
+							"; rm -f " + kafkaStarter + "; exec /etc/kafka/docker/run"},
+						Env: map[string]string{
+							"CLUSTER_ID":                                     clusterID,
+							"KAFKA_NODE_ID":                                  strconv.Itoa(id),
+							"KAFKA_PROCESS_ROLES":                            "broker,controller",
+							"KAFKA_CONTROLLER_QUORUM_VOTERS":                 strings.Join(voters, ","),
+							"KAFKA_LISTENERS":                                fmt.Sprintf("INTERNAL://:%d,CONTROLLER://:%d,EXTERNAL://:%d", kafkaInternalPort, kafkaControllerPort, kafkaExternalPort),
+							"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":           "INTERNAL:PLAINTEXT,CONTROLLER:PLAINTEXT,EXTERNAL:PLAINTEXT",
+							"KAFKA_CONTROLLER_LISTENER_NAMES":                "CONTROLLER",
+							"KAFKA_INTER_BROKER_LISTENER_NAME":               "INTERNAL",
+							"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR":         replicas,
+							"KAFKA_TRANSACTION_STATE_LOG_REPLICATION_FACTOR": replicas,
+							"KAFKA_TRANSACTION_STATE_LOG_MIN_ISR":            strconv.Itoa(min(n, 2)),
+							"KAFKA_DEFAULT_REPLICATION_FACTOR":               replicas,
+							"KAFKA_GROUP_INITIAL_REBALANCE_DELAY_MS":         "0",
+						},
+						Networks:       []string{kc.network.Name},
+						NetworkAliases: map[string][]string{kc.network.Name: {kafkaBrokerAlias(id)}},
+						LifecycleHooks: []testcontainers.ContainerLifecycleHooks{{
+							// runs on every start, docker may assign another host port on a restart
+							PostStarts: []testcontainers.ContainerHook{func(ctx context.Context, c testcontainers.Container) error {
+								return kc.advertise(ctx, c, id, port)
+							}},
+						}},
+						WaitingFor: wait.ForLog("Kafka Server started").WithStartupTimeout(3 * time.Minute),
+					},
+					Started: true,
+				}
+				customizers, err := o.containerCustomizers(gctx)
+				if err != nil {
+					return err
+				}
+				for _, customizer := range append(customizers, runner.customizer()) {
+					if err = customizer.Customize(&req); err != nil {
+						return err
+					}
+				}
+				kc.containers[i], err = testcontainers.GenericContainer(gctx, req)
+				if err != nil {
+					return fmt.Errorf("failed to start broker %d: %w", id, err)
+				}
+				return nil
+			})
+		}
+		return g.Wait()
+	})
+	if err != nil {
+		o.logf("failed to start kafka cluster: %v", err)
+		_ = kc.Terminate(context.WithoutCancel(ctx))
+		o.removeIPv6Network(context.WithoutCancel(ctx))
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		var err error
+		if kc.Admin, err = kafka.NewAdmin(kc.Brokers...); err != nil {
+			return err
+		}
+		if kc.Producer, err = kgo.NewClient(kgo.SeedBrokers(kc.Brokers...)); err != nil {
+			return err
+		}
+		kc.Consumer, err = kgo.NewClient(
+			kgo.SeedBrokers(kc.Brokers...),
+			kgo.ConsumeTopics(o.kafka.topics...),
+			kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		)
+		if err != nil {
+			return err
+		}
+		return kc.waitBrokers(ctx, n)
+	})
+	if err != nil {
+		o.logf("Unable to connect to kafka: %v", err)
+		_ = kc.Terminate(context.WithoutCancel(ctx))
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		return kc.CreateTopics(ctx, o.kafka.topics...)
+	})
+	if err != nil {
+		o.logf("failed to create kafka topics: %v", err)
+		_ = kc.Terminate(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return kc, nil
+}
+
+// CreateTopics creates the topics with the partitions of WithKafkaPartitions,
+// replicated to min(brokers, 3) brokers
+func (kc *KafkaCluster) CreateTopics(ctx context.Context, topics ...string) error {
+	return createKafkaTopics(ctx, kc.Admin.Client(), kc.partitions, int16(min(len(kc.containers), 3)), topics...)
+}
+
+// Broker returns the container of the broker
+func (kc *KafkaCluster) Broker(id int) (testcontainers.Container, error) {
+	if id < 1 || id > len(kc.containers) {
+		return nil, fmt.Errorf("no broker %d, the cluster has %d", id, len(kc.containers))
+	}
+	return kc.containers[id-1], nil
+}
+
+// KillBroker kills the broker without a clean shutdown and waits until the
+// cluster fenced it, its partitions fail over to the other replicas
+func (kc *KafkaCluster) KillBroker(ctx context.Context, id int) error {
+	ctr, err := kc.Broker(id)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(0)
+	if err = ctr.Stop(ctx, &timeout); err != nil {
+		return fmt.Errorf("failed to kill broker %d: %w", id, err)
+	}
+	return kc.waitBroker(ctx, id, false)
+}
+
+// RestartBroker starts a killed broker again and waits for it to rejoin the
+// cluster. Its host port in Brokers may change.
+func (kc *KafkaCluster) RestartBroker(ctx context.Context, id int) error {
+	ctr, err := kc.Broker(id)
+	if err != nil {
+		return err
+	}
+	if err = ctr.Start(ctx); err != nil {
+		return fmt.Errorf("failed to restart broker %d: %w", id, err)
+	}
+	return kc.waitBroker(ctx, id, true)
+}
+
+// advertise writes the advertised listeners of the broker with the host
+// port docker assigned to it, the broker starts once it reads them
+func (kc *KafkaCluster) advertise(ctx context.Context, c testcontainers.Container, id int, port nat.Port) error {
+	host, err := c.Host(ctx)
+	if err != nil {
+		return err
+	}
+	mapped, err := c.MappedPort(ctx, port)
+	if err != nil {
+		return err
+	}
+	kc.Brokers[id-1] = net.JoinHostPort(host, mapped.Port())
+	listeners := fmt.Sprintf("INTERNAL://%s:%d,EXTERNAL://%s", kafkaBrokerAlias(id), kafkaInternalPort, kc.Brokers[id-1])
+	// written as the user of the broker, so it can remove the file
+	cmd := "echo 'export KAFKA_ADVERTISED_LISTENERS=" + listeners + "' > " + kafkaStarter + ".tmp && mv " + kafkaStarter + ".tmp " + kafkaStarter
+	code, out, err := c.Exec(ctx, []string{"sh", "-c", cmd}, tcexec.Multiplexed())
+	if err == nil && code != 0 {
+		output, _ := io.ReadAll(out)
+		err = fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to advertise broker %d: %w", id, err)
+	}
+	return nil
+}
+
+// Leader returns the id of the broker leading the partition of the topic
+func (kc *KafkaCluster) Leader(ctx context.Context, topic string, partition int32) (int, error) {
+	topics, err := kc.Admin.Client().ListTopics(ctx, topic)
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe topic '%s': %w", topic, err)
+	}
+	detail, ok := topics[topic]
+	if !ok || detail.Err != nil {
+		return 0, fmt.Errorf("failed to describe topic '%s': %v", topic, detail.Err)
+	}
+	p, ok := detail.Partitions[partition]
+	if !ok {
+		return 0, fmt.Errorf("topic '%s' has no partition %d", topic, partition)
+	}
+	if p.Leader < 0 {
+		return 0, fmt.Errorf("partition %d of '%s' has no leader", partition, topic)
+	}
+	return int(p.Leader), nil
+}
+
+// KillLeader kills the broker leading the partition of the topic and returns its id
+func (kc *KafkaCluster) KillLeader(ctx context.Context, topic string, partition int32) (int, error) {
+	id, err := kc.Leader(ctx, topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	return id, kc.KillBroker(ctx, id)
+}
+
+// waitBroker waits until the broker id is registered and unfenced, or until
+// it isn't anymore unless registered
+func (kc *KafkaCluster) waitBroker(ctx context.Context, id int, registered bool) error {
+	for {
+		brokers, err := kc.Admin.Client().ListBrokers(ctx)
+		if err == nil && slices.Contains(brokers.NodeIDs(), int32(id)) == registered {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if registered {
+				return fmt.Errorf("waiting for kafka broker %d to rejoin: %w", id, errors.Join(ctx.Err(), err))
+			}
+			return fmt.Errorf("waiting for kafka broker %d to be fenced: %w", id, errors.Join(ctx.Err(), err))
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// waitBrokers waits until n brokers are registered and unfenced
+func (kc *KafkaCluster) waitBrokers(ctx context.Context, n int) error {
+	for {
+		brokers, err := kc.Admin.Client().ListBrokers(ctx)
+		if err == nil && len(brokers) >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d kafka brokers: %w", n, errors.Join(ctx.Err(), err))
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// Terminate closes the clients and terminates the brokers and their network
+func (kc *KafkaCluster) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	for _, cli := range []*kgo.Client{kc.Producer, kc.Consumer} {
+		if cli != nil {
+			cli.Close()
+		}
+	}
+	if kc.Admin != nil {
+		kc.Admin.Close()
+	}
+	var errs []error
+	for i, ctr := range kc.containers {
+		if ctr == nil {
+			continue
+		}
+		if err := ctr.Terminate(ctx, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to terminate broker %d: %w", i+1, err))
+		}
+	}
+	if kc.network != nil {
+		if err := kc.network.Remove(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove network: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func kafkaBrokerAlias(id int) string {
+	return "kafka-" + strconv.Itoa(id)
+}
+
+// kafkaClusterID returns a random KRaft cluster id, a base64 encoded UUID
+func kafkaClusterID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"testing"
+	"time"
+)
+
+// kafkaOptions holds the topic settings of the Kafka helper
+type kafkaOptions struct {
+	topics     []string
+	partitions int32
+}
+
+// WithKafkaTopics creates the topics once the broker is ready, the
+// consumer of the helper consumes them from the start
+func WithKafkaTopics(topics ...string) Option {
+	return func(o *options) {
+		o.kafka.topics = append(o.kafka.topics, topics...)
+	}
+}
+
+// WithKafkaPartitions sets the partitions of the topics the helper creates, 1 by default
+func WithKafkaPartitions(n int32) Option {
+	return func(o *options) {
+		o.kafka.partitions = n
+	}
+}
+
+// CreateTopics creates the topics with the partitions of WithKafkaPartitions
+// and a replication factor of 1. Topics that already exist are left as they are.
+func (c *KafkaContainer) CreateTopics(ctx context.Context, topics ...string) error {
+	return createKafkaTopics(ctx, c.Admin.Client(), c.partitions, 1, topics...)
+}
+
+func createKafkaTopics(ctx context.Context, adm *kadm.Client, partitions int32, replicationFactor int16, topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	if partitions <= 0 {
+		partitions = 1
+	}
+	resps, err := adm.CreateTopics(ctx, partitions, replicationFactor, nil, topics...)
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+	for _, resp := range resps.Sorted() {
+		if resp.Err != nil && !errors.Is(resp.Err, kerr.TopicAlreadyExists) {
+			return fmt.Errorf("failed to create topic '%s': %w", resp.Topic, resp.Err)
+		}
+	}
+	return nil
+}
+
+// NewConsumer creates a consumer of the topics in the consumer group, reading
+// them from the start. Close it when done.
+func (c *KafkaContainer) NewConsumer(group string, topics []string, opts ...kgo.Opt) (*kgo.Client, error) {
+	opts = append([]kgo.Opt{
+		kgo.SeedBrokers(c.Brokers...),
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(topics...),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	}, opts...)
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	return client, nil
+}
+
+// Produce produces the values to the topic with the producer of the helper
+// and waits for the broker to acknowledge them
+func (c *KafkaContainer) Produce(ctx context.Context, topic string, values ...string) error {
+	records := make([]*kgo.Record, len(values))
+	for i, value := range values {
+		records[i] = &kgo.Record{Topic: topic, Value: []byte(value)}
+	}
+	if err := c.Producer.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to '%s': %w", topic, err)
+	}
+	return nil
+}
+
+// Consume polls the consumer of the helper until it got n records or the
+// timeout elapsed, returning the records it got
+func (c *KafkaContainer) Consume(ctx context.Context, n int, timeout time.Duration) ([]*kgo.Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := c.Consumer.PollRecords(ctx, n-len(records))
+		if ctx.Err() != nil {
+			return records, fmt.Errorf("got %d of %d records within %s", len(records), n, timeout)
+		}
+		if err := fetches.Err(); err != nil {
+			return records, fmt.Errorf("failed to consume: %w", err)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records, nil
+}
+
+// AssertConsumed asserts the consumer of the helper gets the values, in order, within the timeout
+func (c *KafkaContainer) AssertConsumed(t testing.TB, timeout time.Duration, values ...string) bool {
+	t.Helper()
+	records, err := c.Consume(context.Background(), len(values), timeout)
+	if err != nil {
+		t.Errorf("failed to consume %d records: %v", len(values), err)
+		return false
+	}
+	for i, value := range values {
+		if got := string(records[i].Value); got != value {
+			t.Errorf("record %d: got %q, want %q", i, got, value)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dennis2006/mtest/container/doris"
+	"github.com/dennis2006/mtest/migrate"
+)
+
+// migrations is the migrations dir of WithMigrations
+type migrations struct {
+	dir  string
+	tool migrate.Tool
+}
+
+// WithMigrations applies the migrations of dir, laid out for the tool, once
+// the MySQL or Doris container is ready, after its init scripts. The version
+// table of the tool records them, in the dialect of the Doris flavor for
+// Doris, MigrateDown reverts them.
+func WithMigrations(dir string, tool migrate.Tool) Option {
+	return func(o *options) {
+		o.migrations = &migrations{dir: dir, tool: tool}
+	}
+}
+
+func (m *migrations) up(ctx context.Context, db *sql.DB, dialect migrate.Dialect) error {
+	if m == nil {
+		return nil
+	}
+	if err := migrate.Up(ctx, db, m.dir, m.tool, dialect); err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	return nil
+}
+
+func (m *migrations) down(ctx context.Context, db *sql.DB, dialect migrate.Dialect, steps int) error {
+	if m == nil {
+		return fmt.Errorf("no migrations, create the container WithMigrations")
+	}
+	return migrate.Down(ctx, db, m.dir, m.tool, dialect, steps)
+}
+
+// dorisDialect is the dialect of the version table in the Doris flavor
+func dorisDialect(flavor doris.Flavor) migrate.Dialect {
+	if flavor == doris.Doris {
+		return migrate.Doris
+	}
+	return migrate.StarRocks
+}
+
+// MigrateDown reverts the last steps migrations of WithMigrations, all of
+// them if steps is 0 or less, e.g. to test the down migrations
+func (c *MySQLContainer) MigrateDown(ctx context.Context, steps int) error {
+	return c.migrations.down(ctx, c.Db.DB, migrate.MySQL, steps)
+}
+
+// MigrateDown reverts the last steps migrations of WithMigrations, all of
+// them if steps is 0 or less, e.g. to test the down migrations
+func (c *DorisContainer) MigrateDown(ctx context.Context, steps int) error {
+	return c.migrations.down(ctx, c.Db.DB, dorisDialect(c.Flavor()), steps)
+}
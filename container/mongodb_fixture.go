@@ -0,0 +1,94 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LoadFixture inserts the documents of file into the collection, see
+// WithMongoFixture for the format
+func (c *MongoDBContainer) LoadFixture(ctx context.Context, db, coll, file string) error {
+	docs, err := mongoFixtureDocs(file)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture %s: %w", filepath.Base(file), err)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	if _, err = c.MongoCli.Database(db).Collection(coll).InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("failed to load fixture %s into '%s.%s': %w", filepath.Base(file), db, coll, err)
+	}
+	return nil
+}
+
+// mongoFixtureDocs reads the documents of a JSON array or NDJSON file in
+// extended JSON
+func mongoFixtureDocs(file string) ([]bson.D, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		// extended JSON only unmarshals documents, wrap the array in one
+		var wrapper struct {
+			Docs []bson.D `bson:"docs"`
+		}
+		body := append(append([]byte(`{"docs":`), trimmed...), '}')
+		if err = bson.UnmarshalExtJSON(body, false, &wrapper); err != nil {
+			return nil, err
+		}
+		return wrapper.Docs, nil
+	}
+
+	var docs []bson.D
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err = bson.UnmarshalExtJSON(text, false, &doc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, scanner.Err()
+}
+
+// waitMongoPrimary waits until the node of a replica set is primary, rs.initiate
+// returns before the election is over
+func waitMongoPrimary(ctx context.Context, cli *qmgo.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		err := cli.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+		if err == nil && hello.IsWritablePrimary {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = fmt.Errorf("node is not primary")
+			}
+			return fmt.Errorf("replica set not ready within %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
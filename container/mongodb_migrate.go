@@ -0,0 +1,205 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MongoMigrationsCollection is the collection recording the applied
+// migrations, the one of mongo-migrate so the tool sees them applied
+const MongoMigrationsCollection = "migrations"
+
+// MongoMigrationFunc applies a Go migration to the database
+type MongoMigrationFunc func(ctx context.Context, db *mongo.Database) error
+
+// mongoMigration is a version of the database, a Go migration or a file
+type mongoMigration struct {
+	version     uint64
+	description string
+	up          MongoMigrationFunc
+	// file is the .up.js or .up.json file of the migration
+	file string
+}
+
+var (
+	mongoMigrationsMu sync.Mutex
+	mongoMigrations   = make(map[uint64]mongoMigration)
+)
+
+// RegisterMongoMigration registers a Go migration ApplyMongoMigrations
+// applies in version order with the ones of the directory, like
+// migrate.Register of mongo-migrate, typically from the init function of the
+// file of the migration. It panics if the version is already registered.
+func RegisterMongoMigration(version uint64, description string, up MongoMigrationFunc) {
+	mongoMigrationsMu.Lock()
+	defer mongoMigrationsMu.Unlock()
+	if _, ok := mongoMigrations[version]; ok {
+		panic(fmt.Sprintf("mongo migration %d is already registered", version))
+	}
+	mongoMigrations[version] = mongoMigration{version: version, description: description, up: up}
+}
+
+// ApplyMongoMigrations applies to the database of WithDatabase, test by
+// default, the migrations newer than its version in version order: the Go
+// migrations of RegisterMongoMigration and the {version}_{description}
+// files of dir, .up.js scripts run with mongosh in the container and .up.json
+// arrays of commands like the ones of golang-migrate, e.g.
+//
+//	[{"createIndexes": "users", "indexes": [{"key": {"email": 1}, "name": "email_1", "unique": true}]}]
+//
+// The .down files are ignored. Each applied version is recorded in
+// MongoMigrationsCollection, dir may be empty to only apply the Go
+// migrations.
+func (c *MongoDBContainer) ApplyMongoMigrations(ctx context.Context, dir string) error {
+	migrations, err := loadMongoMigrations(dir)
+	if err != nil {
+		return err
+	}
+
+	db := c.Client.Database(c.database)
+	versions := db.Collection(MongoMigrationsCollection)
+	var last struct {
+		Version uint64 `bson:"version"`
+	}
+	err = versions.FindOne(ctx, bson.D{}, mongoOpts.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("failed to get the migrated version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= last.Version {
+			continue
+		}
+		switch {
+		case m.up != nil:
+			err = m.up(ctx, db)
+		case strings.HasSuffix(m.file, ".js"):
+			err = c.runMongoScript(ctx, m.file)
+		default:
+			err = runMongoCommands(ctx, db, m.file)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d %s: %w", m.version, m.description, err)
+		}
+		record := bson.D{
+			{Key: "version", Value: m.version},
+			{Key: "description", Value: m.description},
+			{Key: "timestamp", Value: time.Now().UTC()},
+		}
+		if _, err = versions.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// loadMongoMigrations returns the registered migrations and the ones of dir,
+// sorted by version
+func loadMongoMigrations(dir string) ([]mongoMigration, error) {
+	byVersion := make(map[uint64]mongoMigration)
+	mongoMigrationsMu.Lock()
+	for v, m := range mongoMigrations {
+		byVersion[v] = m
+	}
+	mongoMigrationsMu.Unlock()
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, ".up.js") || strings.HasSuffix(name, ".up.json")) {
+				continue
+			}
+			prefix, rest, ok := strings.Cut(name, "_")
+			version, err := strconv.ParseUint(prefix, 10, 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid migration file name %s, expected {version}_{description}", name)
+			}
+			if _, ok = byVersion[version]; ok {
+				return nil, fmt.Errorf("migration %d of %s is already defined", version, name)
+			}
+			desc := strings.TrimSuffix(strings.TrimSuffix(rest, ".js"), ".json")
+			byVersion[version] = mongoMigration{
+				version:     version,
+				description: strings.TrimSuffix(desc, ".up"),
+				file:        filepath.Join(dir, name),
+			}
+		}
+	}
+
+	migrations := make([]mongoMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// runMongoScript runs the script with mongosh in the container, with db set
+// to the database of the container
+func (c *MongoDBContainer) runMongoScript(ctx context.Context, file string) error {
+	script, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	name, _ := json.Marshal(c.database)
+	script = append([]byte("db = db.getSiblingDB("+string(name)+");\n"), script...)
+	path := "/tmp/mtest-migrations/" + filepath.Base(file)
+	if err = c.CopyToContainer(ctx, script, path, 0o644); err != nil {
+		return fmt.Errorf("failed to copy script: %w", err)
+	}
+
+	u, err := dsn.ParseMongo(c.connStr)
+	if err != nil {
+		return err
+	}
+	uri := u.Hosts("localhost:27017").Param("directConnection", "true").String()
+	code, out, err := c.Exec(ctx, []string{"mongosh", "--quiet", "--norc", uri, path}, tcexec.Multiplexed())
+	if err != nil {
+		return fmt.Errorf("failed to run mongosh: %w", err)
+	}
+	if code != 0 {
+		output, _ := io.ReadAll(out)
+		return fmt.Errorf("mongosh exit code %d: %s", code, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runMongoCommands runs the commands of the extended JSON array of file
+func runMongoCommands(ctx context.Context, db *mongo.Database, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	// extended JSON only unmarshals documents, wrap the array in one
+	var wrapper struct {
+		Commands []bson.D `bson:"commands"`
+	}
+	body := append(append([]byte(`{"commands":`), data...), '}')
+	if err = bson.UnmarshalExtJSON(body, false, &wrapper); err != nil {
+		return fmt.Errorf("failed to parse commands: %w", err)
+	}
+	for i, cmd := range wrapper.Commands {
+		if err = db.RunCommand(ctx, cmd).Err(); err != nil {
+			return fmt.Errorf("command %d failed: %w", i+1, err)
+		}
+	}
+	return nil
+}
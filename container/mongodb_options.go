@@ -0,0 +1,158 @@
+package container
+
+import (
+	"github.com/qiniu/qmgo"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"strconv"
+	"time"
+)
+
+// mongoOptions holds the qmgo client settings of the MongoDB helper
+type mongoOptions struct {
+	connectTimeout time.Duration
+	maxPoolSize    uint64
+	minPoolSize    uint64
+	readPreference *qmgo.ReadPref
+	writeConcern   *writeconcern.WriteConcern
+
+	ttlMonitorInterval time.Duration
+
+	oplogSizeMB int
+	profile     bool
+	slowOp      time.Duration
+
+	replicaSet  string
+	secondaries int
+	fixtures    []mongoFixture
+}
+
+type mongoFixture struct {
+	db   string
+	coll string
+	file string
+}
+
+func defaultMongoOptions() mongoOptions {
+	return mongoOptions{
+		connectTimeout: 2 * time.Second,
+		maxPoolSize:    100,
+		minPoolSize:    0,
+	}
+}
+
+// WithConnectTimeout sets the connect timeout of the MongoDB client, 2s by default.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.mongo.connectTimeout = timeout
+	}
+}
+
+// WithPoolSize sets the min and max connection pool size of the MongoDB client, 0 and 100 by default.
+func WithPoolSize(minSize, maxSize uint64) Option {
+	return func(o *options) {
+		o.mongo.minPoolSize = minSize
+		o.mongo.maxPoolSize = maxSize
+	}
+}
+
+// WithReadPreference sets the read preference of the MongoDB client,
+// maxStaleness is ignored when zero.
+func WithReadPreference(mode readpref.Mode, maxStaleness time.Duration) Option {
+	return func(o *options) {
+		o.mongo.readPreference = &qmgo.ReadPref{
+			Mode:           mode,
+			MaxStalenessMS: maxStaleness.Milliseconds(),
+		}
+	}
+}
+
+// WithWriteConcern sets the write concern of the MongoDB client, e.g. writeconcern.Majority().
+func WithWriteConcern(wc *writeconcern.WriteConcern) Option {
+	return func(o *options) {
+		o.mongo.writeConcern = wc
+	}
+}
+
+// WithTTLMonitorInterval sets how often the server removes expired documents
+// of TTL indexes, 60s by default. It is rounded up to whole seconds.
+func WithTTLMonitorInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.mongo.ttlMonitorInterval = interval
+	}
+}
+
+// WithOplogSize sets the size of the oplog in megabytes, the server only has
+// an oplog when it runs as a replica set, see WithMongoReplicaSet.
+func WithOplogSize(mb int) Option {
+	return func(o *options) {
+		o.mongo.oplogSizeMB = mb
+	}
+}
+
+// WithMongoReplicaSet runs the server as a single node replica set of the
+// name, "rs0" if empty, so multi-document transactions and change streams can
+// be tested. The helper initiates the replica set and returns once the node
+// is primary. The client connects directly to the node, whose advertised
+// address is only reachable from the docker network.
+func WithMongoReplicaSet(name string) Option {
+	return func(o *options) {
+		o.mongo.replicaSet = orDefault(name, "rs0")
+	}
+}
+
+// WithMongoSecondaries adds n secondaries to the replica set, run as more
+// mongod processes in the container, implying WithMongoReplicaSet. They
+// have priority 0 and no vote, so the node of the helper stays primary and
+// majority writes don't wait for them. See SecondaryClient and
+// StopReplication for testing stale reads.
+func WithMongoSecondaries(n int) Option {
+	return func(o *options) {
+		o.mongo.secondaries = n
+		o.mongo.replicaSet = orDefault(o.mongo.replicaSet, "rs0")
+	}
+}
+
+// WithMongoFixture inserts the documents of file into the collection once the
+// init scripts ran: a JSON array or one document per line (.ndjson), in
+// MongoDB extended JSON, e.g. {"_id": {"$oid": "..."}, "at": {"$date": "..."}}.
+func WithMongoFixture(db, coll, file string) Option {
+	return func(o *options) {
+		o.mongo.fixtures = append(o.mongo.fixtures, mongoFixture{db: db, coll: coll, file: file})
+	}
+}
+
+// WithProfiler enables the profiler at level 2 on startup, recording every
+// operation of every database in its system.profile collection. SlowOps
+// returns the operations that took at least slowOp, zero returns all of them.
+func WithProfiler(slowOp time.Duration) Option {
+	return func(o *options) {
+		o.mongo.profile = true
+		o.mongo.slowOp = slowOp
+	}
+}
+
+// moduleOpts returns the container customizers of the server settings
+func (m mongoOptions) moduleOpts() []testcontainers.ContainerCustomizer {
+	var opts []testcontainers.ContainerCustomizer
+	if m.ttlMonitorInterval > 0 {
+		opts = append(opts, testcontainers.WithCmdArgs("--setParameter",
+			"ttlMonitorSleepSecs="+strconv.Itoa(ttlSeconds(m.ttlMonitorInterval))))
+	}
+	if m.oplogSizeMB > 0 {
+		opts = append(opts, testcontainers.WithCmdArgs("--oplogSize", strconv.Itoa(m.oplogSizeMB)))
+	}
+	if m.profile {
+		opts = append(opts, testcontainers.WithCmdArgs("--profile", "2",
+			"--slowms", strconv.FormatInt(m.slowOp.Milliseconds(), 10)))
+	}
+	if m.replicaSet != "" {
+		opts = append(opts, mongodb.WithReplicaSet(m.replicaSet))
+	}
+	if m.secondaries > 0 {
+		opts = append(opts, testcontainers.WithExposedPorts(mongoSecondaryPorts(m.secondaries)...))
+	}
+	return opts
+}
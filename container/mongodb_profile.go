@@ -0,0 +1,73 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+	"time"
+)
+
+// ProfiledOp is an operation recorded by the profiler, see the
+// system.profile collection in the MongoDB docs.
+type ProfiledOp struct {
+	Database     string    `bson:"-"`
+	Namespace    string    `bson:"ns"`
+	Op           string    `bson:"op"`
+	Command      bson.M    `bson:"command"`
+	Millis       int64     `bson:"millis"`
+	PlanSummary  string    `bson:"planSummary"`
+	KeysExamined int64     `bson:"keysExamined"`
+	DocsExamined int64     `bson:"docsExamined"`
+	NReturned    int64     `bson:"nreturned"`
+	Timestamp    time.Time `bson:"ts"`
+}
+
+// Duration returns how long the operation took
+func (op ProfiledOp) Duration() time.Duration {
+	return time.Duration(op.Millis) * time.Millisecond
+}
+
+// CollScan reports whether the operation scanned the whole collection
+// instead of using an index
+func (op ProfiledOp) CollScan() bool {
+	return op.PlanSummary == "COLLSCAN"
+}
+
+// SlowOps returns the operations of all user databases the profiler recorded
+// as taking at least the threshold of WithProfiler, slowest first. The
+// profiler must be enabled, see WithProfiler.
+func (c *MongoDBContainer) SlowOps(ctx context.Context) ([]ProfiledOp, error) {
+	var list struct {
+		Databases []struct {
+			Name string `bson:"name"`
+		} `bson:"databases"`
+	}
+	cmd := bson.D{{Key: "listDatabases", Value: 1}, {Key: "nameOnly", Value: true}}
+	if err := c.MongoCli.Database("admin").RunCommand(ctx, cmd).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	var ops []ProfiledOp
+	for _, db := range list.Databases {
+		switch db.Name {
+		case "admin", "config", "local":
+			continue
+		}
+		var dbOps []ProfiledOp
+		filter := bson.M{"millis": bson.M{"$gte": c.slowOp.Milliseconds()}}
+		err := c.MongoCli.Database(db.Name).Collection("system.profile").Find(ctx, filter).All(&dbOps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query '%s.system.profile': %w", db.Name, err)
+		}
+		for i := range dbOps {
+			dbOps[i].Database = db.Name
+		}
+		ops = append(ops, dbOps...)
+	}
+
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Millis > ops[j].Millis
+	})
+	return ops, nil
+}
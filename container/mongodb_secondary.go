@@ -0,0 +1,248 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	"github.com/docker/go-connections/nat"
+	"github.com/qiniu/qmgo"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mongoSecondaryBasePort is the port of the first secondary in the container
+const mongoSecondaryBasePort = 27018
+
+// mongoSecondary is a secondary of WithMongoSecondaries
+type mongoSecondary struct {
+	port nat.Port
+	// host is the address of the member in the replica set config
+	host string
+}
+
+// mongoSecondaryPorts returns the container ports of n secondaries
+func mongoSecondaryPorts(n int) []string {
+	ports := make([]string, n)
+	for i := range ports {
+		ports[i] = strconv.Itoa(mongoSecondaryBasePort+i) + "/tcp"
+	}
+	return ports
+}
+
+// startSecondaries starts the mongod processes of the secondaries, adds them
+// to the replica set and waits until they are secondaries
+func (c *MongoDBContainer) startSecondaries(ctx context.Context, o *options) error {
+	ip, err := c.ContainerIP(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container ip: %w", err)
+	}
+	for i, port := range mongoSecondaryPorts(o.mongo.secondaries) {
+		p := nat.Port(port)
+		dbPath := "/data/secondary" + strconv.Itoa(i)
+		args := []string{"mongod", "--replSet", o.mongo.replicaSet, "--port", p.Port(), "--bind_ip_all",
+			"--dbpath", dbPath, "--fork", "--logpath", dbPath + ".log"}
+		if o.user != "" && o.password != "" {
+			// the keyfile of the module authenticates the members
+			args = append(args, "--keyFile", "/tmp/mongo_keyfile")
+		}
+		if o.mongo.oplogSizeMB > 0 {
+			args = append(args, "--oplogSize", strconv.Itoa(o.mongo.oplogSizeMB))
+		}
+		cmd := []string{"sh", "-c", "mkdir -p " + dbPath + " && " + strings.Join(args, " ")}
+		code, out, err := c.Exec(ctx, cmd, tcexec.Multiplexed())
+		if err != nil {
+			return fmt.Errorf("failed to start secondary %d: %w", i, err)
+		}
+		if code != 0 {
+			output, _ := io.ReadAll(out)
+			return fmt.Errorf("failed to start secondary %d, exit code %d: %s", i, code, output)
+		}
+		c.secondaries = append(c.secondaries, mongoSecondary{port: p, host: ip + ":" + p.Port()})
+	}
+
+	admin := c.MongoCli.Database("admin")
+	var res struct {
+		Config bson.M `bson:"config"`
+	}
+	if err = admin.RunCommand(ctx, bson.D{{Key: "replSetGetConfig", Value: 1}}).Decode(&res); err != nil {
+		return fmt.Errorf("failed to get replica set config: %w", err)
+	}
+	members, _ := res.Config["members"].(bson.A)
+	base := len(members)
+	for i, s := range c.secondaries {
+		members = append(members, bson.M{"_id": base + i, "host": s.host, "priority": 0, "votes": 0})
+	}
+	res.Config["members"] = members
+	res.Config["version"] = toInt64(res.Config["version"]) + 1
+	if err = admin.RunCommand(ctx, bson.D{{Key: "replSetReconfig", Value: res.Config}}).Err(); err != nil {
+		return fmt.Errorf("failed to add the secondaries to the replica set: %w", err)
+	}
+	return c.waitSecondaries(ctx, 60*time.Second)
+}
+
+// mongoMember is a member of replSetGetStatus
+type mongoMember struct {
+	Name     string `bson:"name"`
+	StateStr string `bson:"stateStr"`
+	Optime   struct {
+		TS primitive.Timestamp `bson:"ts"`
+	} `bson:"optime"`
+}
+
+func (c *MongoDBContainer) replicaSetStatus(ctx context.Context) (map[string]mongoMember, mongoMember, error) {
+	var status struct {
+		Members []mongoMember `bson:"members"`
+	}
+	cmd := bson.D{{Key: "replSetGetStatus", Value: 1}}
+	if err := c.MongoCli.Database("admin").RunCommand(ctx, cmd).Decode(&status); err != nil {
+		return nil, mongoMember{}, fmt.Errorf("failed to get replica set status: %w", err)
+	}
+	members := make(map[string]mongoMember)
+	var primary mongoMember
+	for _, m := range status.Members {
+		members[m.Name] = m
+		if m.StateStr == "PRIMARY" {
+			primary = m
+		}
+	}
+	return members, primary, nil
+}
+
+// waitSecondaries waits until all the secondaries finished their initial sync
+func (c *MongoDBContainer) waitSecondaries(ctx context.Context, timeout time.Duration) error {
+	return pollMongo(ctx, timeout, func() error {
+		members, _, err := c.replicaSetStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range c.secondaries {
+			if state := members[s.host].StateStr; state != "SECONDARY" {
+				return fmt.Errorf("member %s is %s", s.host, orDefault(state, "unknown"))
+			}
+		}
+		return nil
+	})
+}
+
+// pollMongo calls check until it succeeds or the timeout expires
+func pollMongo(ctx context.Context, timeout time.Duration, check func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready within %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *MongoDBContainer) secondary(i int) (mongoSecondary, error) {
+	if i < 0 || i >= len(c.secondaries) {
+		return mongoSecondary{}, fmt.Errorf("no secondary %d, the replica set has %d, see WithMongoSecondaries", i, len(c.secondaries))
+	}
+	return c.secondaries[i], nil
+}
+
+// Secondaries returns the number of secondaries of WithMongoSecondaries
+func (c *MongoDBContainer) Secondaries() int {
+	return len(c.secondaries)
+}
+
+// SecondaryClient connects directly to the secondary i, from 0, with the
+// secondary read preference, so its reads see what the secondary replicated
+// so far, e.g. nothing newer once StopReplication stopped it. Close it when
+// done.
+func (c *MongoDBContainer) SecondaryClient(ctx context.Context, i int) (*qmgo.Client, error) {
+	s, err := c.secondary(i)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := c.PortEndpoint(ctx, s.port, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secondary endpoint: %w", err)
+	}
+	u, err := dsn.ParseMongo(c.connStr)
+	if err != nil {
+		return nil, err
+	}
+	uri := u.Hosts(endpoint).Param("directConnection", "true").Param("readPreference", "secondary").String()
+	cli, err := qmgo.NewClient(ctx, &qmgo.Config{Uri: uri})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect secondary %d: %w", i, err)
+	}
+	return cli, nil
+}
+
+// StopReplication stops the secondary i from applying the writes of the
+// primary with fsyncLock, its reads stay served but return stale data until
+// ResumeReplication. Reads waiting for newer data, e.g. of causally
+// consistent sessions, block meanwhile.
+func (c *MongoDBContainer) StopReplication(ctx context.Context, i int) error {
+	return c.secondaryCommand(ctx, i, bson.D{{Key: "fsync", Value: 1}, {Key: "lock", Value: true}})
+}
+
+// ResumeReplication resumes the replication stopped by StopReplication
+func (c *MongoDBContainer) ResumeReplication(ctx context.Context, i int) error {
+	return c.secondaryCommand(ctx, i, bson.D{{Key: "fsyncUnlock", Value: 1}})
+}
+
+func (c *MongoDBContainer) secondaryCommand(ctx context.Context, i int, cmd bson.D) error {
+	cli, err := c.SecondaryClient(ctx, i)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cli.Close(ctx) }()
+	if err = cli.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("failed to run %s on secondary %d: %w", cmd[0].Key, i, err)
+	}
+	return nil
+}
+
+// WaitReplicated waits until the secondary i applied the writes of the
+// primary so far, e.g. after ResumeReplication
+func (c *MongoDBContainer) WaitReplicated(ctx context.Context, i int, timeout time.Duration) error {
+	s, err := c.secondary(i)
+	if err != nil {
+		return err
+	}
+	_, primary, err := c.replicaSetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return pollMongo(ctx, timeout, func() error {
+		members, _, err := c.replicaSetStatus(ctx)
+		if err != nil {
+			return err
+		}
+		if members[s.host].Optime.TS.Before(primary.Optime.TS) {
+			return fmt.Errorf("secondary %d lags behind the primary", i)
+		}
+		return nil
+	})
+}
+
+// toInt64 converts a number decoded from BSON
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	qnOpts "github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"math"
+	"time"
+)
+
+// CreateTTLIndex creates a TTL index on the date field of the collection,
+// documents expire expireAfter past the time held by the field, rounded up
+// to whole seconds. Zero expires them at the time held by the field.
+func (c *MongoDBContainer) CreateTTLIndex(ctx context.Context, db, coll, field string, expireAfter time.Duration) error {
+	err := c.MongoCli.Database(db).Collection(coll).CreateOneIndex(ctx, qnOpts.IndexModel{
+		Key:          []string{field},
+		IndexOptions: mongoOpts.Index().SetExpireAfterSeconds(int32(math.Ceil(expireAfter.Seconds()))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ttl index on '%s.%s.%s': %w", db, coll, field, err)
+	}
+	return nil
+}
+
+// SetTTLMonitorInterval changes how often the running server removes expired
+// documents, rounded up to whole seconds. Use WithTTLMonitorInterval to set it
+// on startup instead if the server does not accept the change at runtime.
+func (c *MongoDBContainer) SetTTLMonitorInterval(ctx context.Context, interval time.Duration) error {
+	cmd := bson.D{{Key: "setParameter", Value: 1}, {Key: "ttlMonitorSleepSecs", Value: ttlSeconds(interval)}}
+	if err := c.MongoCli.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("failed to set ttl monitor interval: %w", err)
+	}
+	return nil
+}
+
+// AccelerateTTL makes the server remove expired documents every second
+// instead of every minute, so expiration can be tested in seconds.
+func (c *MongoDBContainer) AccelerateTTL(ctx context.Context) error {
+	return c.SetTTLMonitorInterval(ctx, time.Second)
+}
+
+// WaitExpired waits until no document of the collection matching filter
+// is left, i.e. the TTL monitor removed all of them. A nil filter matches
+// all documents.
+func (c *MongoDBContainer) WaitExpired(ctx context.Context, db, coll string, filter any, timeout time.Duration) error {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	var left int64
+	for {
+		n, err := c.MongoCli.Database(db).Collection(coll).Find(ctx, filter).Count()
+		if err == nil {
+			if n == 0 {
+				return nil
+			}
+			left = n
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d documents of '%s.%s' not expired after %s", left, db, coll, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ttlSeconds rounds d up to whole seconds, at least one
+func ttlSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
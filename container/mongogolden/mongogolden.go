@@ -0,0 +1,119 @@
+// Package mongogolden locks down the output of MongoDB aggregation pipelines
+// with golden files holding their canonical extended JSON results.
+package mongogolden
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites the golden files with the actual results, run
+// "go test -mongogolden.update"; the flag is namespaced so it doesn't clash
+// with an -update flag of the test package
+var update = flag.Bool("mongogolden.update", false, "update the golden files of mongogolden")
+
+type config struct {
+	seed []any
+}
+
+// Option configures Run
+type Option func(*config)
+
+// WithSeed replaces the documents of the collection with docs before the pipeline runs
+func WithSeed(docs ...any) Option {
+	return func(c *config) {
+		c.seed = docs
+	}
+}
+
+// Run runs the aggregation pipeline on the collection and asserts its result
+// equals the golden file, compared as canonical extended JSON so that types
+// (e.g. int32 vs int64 vs double) are locked down too. With
+// -mongogolden.update the golden file is written from the result instead; a
+// missing golden file fails the test.
+func Run(t testing.TB, coll *qmgo.Collection, pipeline any, goldenFile string, opts ...Option) bool {
+	t.Helper()
+
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	ctx := context.Background()
+	if cfg.seed != nil {
+		if _, err := coll.RemoveAll(ctx, bson.M{}); err != nil {
+			t.Errorf("failed to clear collection: %v", err)
+			return false
+		}
+		if len(cfg.seed) > 0 {
+			if _, err := coll.InsertMany(ctx, cfg.seed); err != nil {
+				t.Errorf("failed to seed collection: %v", err)
+				return false
+			}
+		}
+	}
+
+	var docs []bson.Raw
+	if err := coll.Aggregate(ctx, pipeline).All(&docs); err != nil {
+		t.Errorf("failed to run aggregation: %v", err)
+		return false
+	}
+	actual, err := Canonical(docs)
+	if err != nil {
+		t.Errorf("failed to render aggregation result: %v", err)
+		return false
+	}
+
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(goldenFile), 0o755); err == nil {
+			err = os.WriteFile(goldenFile, actual, 0o644)
+		}
+		if err != nil {
+			t.Errorf("failed to update golden file %s: %v", goldenFile, err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Errorf("failed to read golden file %s, run with -mongogolden.update to create it: %v", goldenFile, err)
+		return false
+	}
+	if !bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(actual)) {
+		t.Errorf("aggregation result differs from golden file %s, run with -mongogolden.update to accept it\nexpected:\n%s\nactual:\n%s",
+			goldenFile, expected, actual)
+		return false
+	}
+	return true
+}
+
+// Canonical renders documents as an indented JSON array of canonical extended
+// JSON documents, keeping the field order the server returned them in.
+func Canonical(docs []bson.Raw) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, doc := range docs {
+		js, err := bson.MarshalExtJSONIndent(doc, true, false, "  ", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal document #%d: %w", i, err)
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		buf.Write(js)
+	}
+	if len(docs) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
+	"strconv"
+)
+
+// BinlogFormat is the binlog_format of the server
+type BinlogFormat string
+
+const (
+	BinlogRow       BinlogFormat = "ROW"
+	BinlogMixed     BinlogFormat = "MIXED"
+	BinlogStatement BinlogFormat = "STATEMENT"
+)
+
+// binlogServerID is the server_id of the MySQL container with WithBinlog,
+// CDC consumers must connect with a different one
+const binlogServerID = 1
+
+// WithBinlog enables the binlog of the MySQL container in the format with
+// GTIDs and full row images, so canal or debezium style CDC consumers can
+// be tested against it, see MySQLContainer.BinlogEndpoint.
+func WithBinlog(format BinlogFormat) Option {
+	return func(o *options) {
+		o.binlogFormat = format
+	}
+}
+
+// binlogArgs returns the server arguments enabling the binlog
+func (o *options) binlogArgs() testcontainers.CustomizeRequestOption {
+	return testcontainers.WithCmdArgs(
+		"--server-id="+strconv.Itoa(binlogServerID),
+		"--log-bin=mysql-bin",
+		"--binlog-format="+string(o.binlogFormat),
+		"--binlog-row-image=FULL",
+		"--gtid-mode=ON",
+		"--enforce-gtid-consistency=ON",
+	)
+}
+
+// BinlogPosition is a position in the binlog of the server
+type BinlogPosition struct {
+	File string
+	Pos  uint64
+	// GTIDSet is the set of transactions executed so far, e.g.
+	// "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+	GTIDSet string
+}
+
+// BinlogEndpoint is what a CDC consumer needs to follow the binlog
+type BinlogEndpoint struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// ServerID is the server_id of the container, the consumer must
+	// register as a replica with another one
+	ServerID uint32
+	// Position is the current end of the binlog
+	Position BinlogPosition
+}
+
+// ServerID returns the server_id of the server
+func (c *MySQLContainer) ServerID(ctx context.Context) (uint32, error) {
+	var id uint32
+	if err := c.Db.GetContext(ctx, &id, "SELECT @@server_id"); err != nil {
+		return 0, fmt.Errorf("failed to query server id: %w", err)
+	}
+	return id, nil
+}
+
+// BinlogPosition returns the current end of the binlog, events written
+// after it are the ones of statements executed from now on.
+func (c *MySQLContainer) BinlogPosition(ctx context.Context) (BinlogPosition, error) {
+	// SHOW MASTER STATUS was renamed in 8.2 and removed in 8.4
+	rows, err := c.Db.QueryxContext(ctx, "SHOW BINARY LOG STATUS")
+	if err != nil {
+		rows, err = c.Db.QueryxContext(ctx, "SHOW MASTER STATUS")
+	}
+	if err != nil {
+		return BinlogPosition{}, fmt.Errorf("failed to query binlog status: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return BinlogPosition{}, fmt.Errorf("failed to query binlog status: %w", err)
+		}
+		return BinlogPosition{}, fmt.Errorf("binlog is disabled, start the container WithBinlog")
+	}
+	status := make(map[string]any)
+	if err = rows.MapScan(status); err != nil {
+		return BinlogPosition{}, fmt.Errorf("failed to scan binlog status: %w", err)
+	}
+
+	pos := BinlogPosition{
+		File:    string(asBytes(status["File"])),
+		GTIDSet: string(asBytes(status["Executed_Gtid_Set"])),
+	}
+	pos.Pos, err = strconv.ParseUint(string(asBytes(status["Position"])), 10, 64)
+	if err != nil {
+		return BinlogPosition{}, fmt.Errorf("invalid binlog position: %w", err)
+	}
+	return pos, nil
+}
+
+// BinlogEndpoint returns the address, credentials and current binlog position
+// a CDC consumer connects with.
+func (c *MySQLContainer) BinlogEndpoint(ctx context.Context) (BinlogEndpoint, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return BinlogEndpoint{}, fmt.Errorf("failed to get mysql connection string: %w", err)
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return BinlogEndpoint{}, fmt.Errorf("failed to parse mysql connection string: %w", err)
+	}
+	host, err := c.Host(ctx)
+	if err != nil {
+		return BinlogEndpoint{}, err
+	}
+	port, err := c.MappedPort(ctx, "3306/tcp")
+	if err != nil {
+		return BinlogEndpoint{}, err
+	}
+
+	id, err := c.ServerID(ctx)
+	if err != nil {
+		return BinlogEndpoint{}, err
+	}
+	pos, err := c.BinlogPosition(ctx)
+	if err != nil {
+		return BinlogEndpoint{}, err
+	}
+	return BinlogEndpoint{
+		Host:     host,
+		Port:     port.Int(),
+		User:     cfg.User,
+		Password: cfg.Passwd,
+		ServerID: id,
+		Position: pos,
+	}, nil
+}
+
+// asBytes returns the raw value of a column scanned into a map
+func asBytes(v any) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case nil:
+		return nil
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
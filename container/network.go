@@ -0,0 +1,92 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	dcontainer "github.com/docker/docker/api/types/container"
+	dnetwork "github.com/docker/docker/api/types/network"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+)
+
+// WithDNS sets the DNS servers written to resolv.conf of the container.
+func WithDNS(servers ...string) Option {
+	return withHostConfig(func(hc *dcontainer.HostConfig) {
+		hc.DNS = append(hc.DNS, servers...)
+	})
+}
+
+// WithDNSSearch sets the DNS search domains written to resolv.conf of the container.
+func WithDNSSearch(domains ...string) Option {
+	return withHostConfig(func(hc *dcontainer.HostConfig) {
+		hc.DNSSearch = append(hc.DNSSearch, domains...)
+	})
+}
+
+// WithDNSOptions sets the options written to resolv.conf of the container, e.g. "ndots:2".
+func WithDNSOptions(opts ...string) Option {
+	return withHostConfig(func(hc *dcontainer.HostConfig) {
+		hc.DNSOptions = append(hc.DNSOptions, opts...)
+	})
+}
+
+// WithExtraHosts adds "host:ip" entries to /etc/hosts of the container.
+func WithExtraHosts(hosts ...string) Option {
+	return withHostConfig(func(hc *dcontainer.HostConfig) {
+		hc.ExtraHosts = append(hc.ExtraHosts, hosts...)
+	})
+}
+
+// WithNetwork attaches the container to an existing network with the given aliases.
+func WithNetwork(nw *testcontainers.DockerNetwork, aliases ...string) Option {
+	return func(o *options) {
+		o.customizers = append(o.customizers, network.WithNetwork(aliases, nw))
+	}
+}
+
+// WithIPv6 attaches the container to a new dual-stack network. subnet is the
+// IPv6 subnet of the network, e.g. "fd00:1::/64"; if empty the default address
+// pool of the docker daemon is used, which must then have IPv6 enabled. The
+// network is removed when the container is terminated.
+func WithIPv6(subnet string) Option {
+	return func(o *options) {
+		o.ipv6 = true
+		o.ipv6Subnet = subnet
+	}
+}
+
+// NewIPv6Network creates a dual-stack network that can be shared by several
+// containers with WithNetwork. See WithIPv6 for the meaning of subnet.
+func NewIPv6Network(ctx context.Context, subnet string) (*testcontainers.DockerNetwork, error) {
+	opts := []network.NetworkCustomizer{network.WithEnableIPv6()}
+	if subnet != "" {
+		opts = append(opts, network.WithIPAM(&dnetwork.IPAM{
+			Driver: "default",
+			Config: []dnetwork.IPAMConfig{{Subnet: subnet}},
+		}))
+	}
+
+	nw, err := network.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ipv6 network: %w", err)
+	}
+	return nw, nil
+}
+
+// withHostConfig chains the host config modifier with the ones set before,
+// as testcontainers only keeps a single modifier per request.
+func withHostConfig(modify func(hc *dcontainer.HostConfig)) Option {
+	return func(o *options) {
+		o.customizers = append(o.customizers, testcontainers.CustomizeRequestOption(
+			func(req *testcontainers.GenericContainerRequest) error {
+				prev := req.HostConfigModifier
+				req.HostConfigModifier = func(hc *dcontainer.HostConfig) {
+					if prev != nil {
+						prev(hc)
+					}
+					modify(hc)
+				}
+				return nil
+			}))
+	}
+}
@@ -0,0 +1,218 @@
+// Package network injects network faults between two containers of the
+// container package, or any testcontainers container: a partition dropping
+// their traffic, or latency and packet loss, e.g. to test the split brain
+// and timeout handling of code talking to several dependencies. The faults
+// are applied by a privileged sidecar sharing the network namespace of the
+// container, so its image needs neither iptables nor tc.
+package network
+
+import (
+	"context"
+	"fmt"
+	dcontainer "github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SidecarImage is the image applying the faults, it has iptables and tc
+const SidecarImage = "nicolaka/netshoot:v0.13"
+
+// sidecarTimeout bounds a sidecar run
+const sidecarTimeout = 2 * time.Minute
+
+type config struct {
+	image string
+}
+
+// Option configures Partition and Degrade
+type Option func(*config)
+
+// WithSidecarImage runs the sidecar of image instead of SidecarImage, it must
+// have sh, iptables and tc
+func WithSidecarImage(image string) Option {
+	return func(c *config) {
+		c.image = image
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{image: SidecarImage}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Netem is the degradation of Degrade, applied with tc netem
+type Netem struct {
+	// Delay is added to every packet, varying by up to Jitter
+	Delay  time.Duration
+	Jitter time.Duration
+	// Loss is the percentage of the packets dropped, from 0 to 100
+	Loss float64
+}
+
+func (n Netem) args() (string, error) {
+	if n.Delay <= 0 && n.Loss <= 0 {
+		return "", fmt.Errorf("netem needs a delay or a loss")
+	}
+	if n.Loss < 0 || n.Loss > 100 {
+		return "", fmt.Errorf("loss %g is not a percentage", n.Loss)
+	}
+	var args []string
+	if n.Delay > 0 {
+		args = append(args, fmt.Sprintf("delay %dus", n.Delay.Microseconds()))
+		if n.Jitter > 0 {
+			args = append(args, fmt.Sprintf("%dus", n.Jitter.Microseconds()))
+		}
+	}
+	if n.Loss > 0 {
+		args = append(args, fmt.Sprintf("loss %g%%", n.Loss))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// Fault is a fault injected into the network namespace of a container
+// until Heal
+type Fault struct {
+	target testcontainers.Container
+	cfg    *config
+	name   string
+	// heal is the script removing the fault
+	heal string
+
+	mu     sync.Mutex
+	healed bool
+}
+
+// Heal removes the fault, healing it again does nothing
+func (f *Fault) Heal(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.healed {
+		return nil
+	}
+	if err := runSidecar(ctx, f.cfg, f.target, f.heal); err != nil {
+		return fmt.Errorf("failed to heal %s: %w", f.name, err)
+	}
+	f.healed = true
+	return nil
+}
+
+// Partition drops the traffic between a and b in both directions until
+// Heal, like a network split. The connections already open stall until
+// their timeouts rather than being reset.
+func Partition(ctx context.Context, a, b testcontainers.Container, opts ...Option) (*Fault, error) {
+	ips, err := b.ContainerIPs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container ips: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("container %s has no ip", b.GetContainerID())
+	}
+
+	var apply, heal strings.Builder
+	apply.WriteString("set -e\n")
+	heal.WriteString("rc=0\n")
+	for _, ip := range ips {
+		for _, rule := range []string{"INPUT -s " + ip + " -j DROP", "OUTPUT -d " + ip + " -j DROP"} {
+			apply.WriteString("iptables -I " + rule + "\n")
+			heal.WriteString("iptables -D " + rule + " || rc=1\n")
+		}
+	}
+	heal.WriteString("exit $rc\n")
+	return inject(ctx, newConfig(opts), a, "partition", apply.String(), heal.String())
+}
+
+// Degrade applies the netem degradation to the packets a sends to b until
+// Heal, e.g. a Delay to test timeouts or a Loss to test retries. It only
+// delays one direction, degrade b to a too to delay both. A container has a
+// single degradation at a time, heal it before degrading it again.
+func Degrade(ctx context.Context, a, b testcontainers.Container, netem Netem, opts ...Option) (*Fault, error) {
+	args, err := netem.args()
+	if err != nil {
+		return nil, err
+	}
+	ips, err := b.ContainerIPs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container ips: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("container %s has no ip", b.GetContainerID())
+	}
+
+	// the traffic to b goes to the 4th band holding netem, the priomap of
+	// prio only sends the rest to the first 3
+	var apply strings.Builder
+	apply.WriteString("set -e\nfor dev in $(ls /sys/class/net); do\n[ \"$dev\" = lo ] && continue\n")
+	apply.WriteString("tc qdisc add dev $dev root handle 1: prio bands 4\n")
+	apply.WriteString("tc qdisc add dev $dev parent 1:4 handle 40: netem " + args + "\n")
+	for _, ip := range ips {
+		apply.WriteString("tc filter add dev $dev protocol ip parent 1:0 prio 4 u32 match ip dst " + ip + "/32 flowid 1:4\n")
+	}
+	apply.WriteString("done\n")
+	heal := "rc=0\nfor dev in $(ls /sys/class/net); do\n[ \"$dev\" = lo ] || tc qdisc del dev $dev root || rc=1\ndone\nexit $rc\n"
+	return inject(ctx, newConfig(opts), a, "degradation", apply.String(), heal)
+}
+
+// Latency delays the packets a sends to b by delay, see Degrade
+func Latency(ctx context.Context, a, b testcontainers.Container, delay, jitter time.Duration, opts ...Option) (*Fault, error) {
+	return Degrade(ctx, a, b, Netem{Delay: delay, Jitter: jitter}, opts...)
+}
+
+// PacketLoss drops percent of the packets a sends to b, see Degrade
+func PacketLoss(ctx context.Context, a, b testcontainers.Container, percent float64, opts ...Option) (*Fault, error) {
+	return Degrade(ctx, a, b, Netem{Loss: percent}, opts...)
+}
+
+func inject(ctx context.Context, cfg *config, target testcontainers.Container, name, apply, heal string) (*Fault, error) {
+	if err := runSidecar(ctx, cfg, target, apply); err != nil {
+		// remove what the script applied before failing
+		_ = runSidecar(context.WithoutCancel(ctx), cfg, target, heal)
+		return nil, fmt.Errorf("failed to inject %s: %w", name, err)
+	}
+	return &Fault{target: target, cfg: cfg, name: name, heal: heal}, nil
+}
+
+// runSidecar runs the script in the network namespace of the target with the
+// NET_ADMIN capability, it fails with the output unless the script exits
+// with code 0
+func runSidecar(ctx context.Context, cfg *config, target testcontainers.Container, script string) error {
+	req := testcontainers.ContainerRequest{
+		Image:      cfg.image,
+		Entrypoint: []string{"sh", "-c", script},
+		HostConfigModifier: func(hc *dcontainer.HostConfig) {
+			hc.NetworkMode = dcontainer.NetworkMode("container:" + target.GetContainerID())
+			hc.CapAdd = append(hc.CapAdd, "NET_ADMIN")
+		},
+		WaitingFor: wait.ForExit().WithExitTimeout(sidecarTimeout),
+	}
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if ctr != nil {
+		defer func() { _ = ctr.Terminate(context.WithoutCancel(ctx)) }()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run sidecar container: %w", err)
+	}
+
+	state, err := ctr.State(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sidecar container state: %w", err)
+	}
+	if state.ExitCode != 0 {
+		var output []byte
+		if logs, err := ctr.Logs(ctx); err == nil {
+			output, _ = io.ReadAll(logs)
+			_ = logs.Close()
+		}
+		return fmt.Errorf("sidecar exited with code %d: %s", state.ExitCode, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
+	"strings"
+)
+
+// PerconaToolkitImage is the image pt-online-schema-change runs from
+const PerconaToolkitImage = "percona/percona-toolkit:3.6.0"
+
+// OnlineSchemaChangeTool is a tool altering a table while it serves traffic
+type OnlineSchemaChangeTool string
+
+const (
+	PtOnlineSchemaChange OnlineSchemaChangeTool = "pt-online-schema-change"
+	GhOst                OnlineSchemaChangeTool = "gh-ost"
+)
+
+// OnlineSchemaChange is a run of RunOnlineSchemaChange
+type OnlineSchemaChange struct {
+	Tool OnlineSchemaChangeTool
+	// Image runs the tool, PerconaToolkitImage by default for
+	// pt-online-schema-change. gh-ost has no official image, set one with the
+	// gh-ost binary on its PATH.
+	Image string
+	// Table is the table of the database of the container to alter
+	Table string
+	// Alter is the change without ALTER TABLE, e.g. "ADD COLUMN c INT"
+	Alter string
+	// Args are extra flags of the tool, e.g. "--chunk-size=100"
+	Args []string
+}
+
+// OnlineSchemaChangeResult is the outcome of RunOnlineSchemaChange
+type OnlineSchemaChangeResult struct {
+	// Output is the log of the tool
+	Output string
+	// RowsBefore and RowsAfter count the rows of the table around the change
+	RowsBefore int64
+	RowsAfter  int64
+}
+
+// RunOnlineSchemaChange runs pt-online-schema-change or gh-ost against the
+// table, e.g. seeded by the init scripts, from a container sharing the
+// network of the MySQL container, so migration playbooks are validated in CI
+// instead of rehearsed in staging. The change fails with the output of the
+// tool when it exits with an error, and when the table lost or gained rows.
+// gh-ost runs on the primary and needs the binlog in ROW format, see
+// WithBinlog.
+func (c *MySQLContainer) RunOnlineSchemaChange(ctx context.Context, change OnlineSchemaChange) (*OnlineSchemaChangeResult, error) {
+	connStr, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mysql connection string: %w", err)
+	}
+	cfg, err := gomysql.ParseDSN(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mysql connection string: %w", err)
+	}
+
+	var cmd []string
+	img := change.Image
+	switch change.Tool {
+	case PtOnlineSchemaChange:
+		img = orDefault(img, PerconaToolkitImage)
+		cmd = []string{"pt-online-schema-change", "--alter", change.Alter, "--execute",
+			// the container has no replicas to find
+			"--recursion-method=none",
+			fmt.Sprintf("h=127.0.0.1,P=3306,u=%s,p=%s,D=%s,t=%s", cfg.User, cfg.Passwd, cfg.DBName, change.Table)}
+	case GhOst:
+		if img == "" {
+			return nil, fmt.Errorf("gh-ost has no official image, set OnlineSchemaChange.Image")
+		}
+		cmd = []string{"gh-ost", "--host=127.0.0.1", "--port=3306",
+			"--user=" + cfg.User, "--password=" + cfg.Passwd,
+			"--database=" + cfg.DBName, "--table=" + change.Table, "--alter=" + change.Alter,
+			"--allow-on-master", "--initially-drop-ghost-table", "--initially-drop-old-table", "--ok-to-drop-table",
+			"--execute"}
+	default:
+		return nil, fmt.Errorf("unknown online schema change tool '%s'", change.Tool)
+	}
+	cmd = append(cmd, change.Args...)
+
+	res := &OnlineSchemaChangeResult{}
+	count := "SELECT COUNT(*) FROM `" + strings.ReplaceAll(change.Table, "`", "``") + "`"
+	if err = c.Db.GetContext(ctx, &res.RowsBefore, count); err != nil {
+		return nil, fmt.Errorf("failed to count rows of %s: %w", change.Table, err)
+	}
+
+	id := c.GetContainerID()
+	res.Output, err = runToolContainer(ctx, string(change.Tool), testcontainers.ContainerRequest{
+		Image:      img,
+		Entrypoint: cmd[:1],
+		Cmd:        cmd[1:],
+		// 127.0.0.1 of the tool is the one of the MySQL container
+		HostConfigModifier: func(hc *dockercontainer.HostConfig) {
+			hc.NetworkMode = dockercontainer.NetworkMode("container:" + id)
+		},
+	})
+	if err != nil {
+		return res, err
+	}
+
+	if err = c.Db.GetContext(ctx, &res.RowsAfter, count); err != nil {
+		return res, fmt.Errorf("failed to count rows of %s: %w", change.Table, err)
+	}
+	if res.RowsAfter != res.RowsBefore {
+		return res, fmt.Errorf("%s changed the rows of %s from %d to %d", change.Tool, change.Table, res.RowsBefore, res.RowsAfter)
+	}
+	return res, nil
+}
@@ -0,0 +1,224 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Option configures the container helpers.
+type Option func(*options)
+
+type options struct {
+	timeouts    map[Phase]time.Duration
+	startup     time.Duration
+	progress    io.Writer
+	progressInt time.Duration
+	logger      func(format string, args ...any)
+	customizers []testcontainers.ContainerCustomizer
+	ipv6        bool
+	ipv6Subnet  string
+	// ipv6Net is the network of WithIPv6, shared by the containers of the
+	// options
+	ipv6Net ipv6Network
+
+	image       string
+	archImages  map[string]string
+	user        string
+	password    string
+	database    string
+	configFile  string
+	initScripts []string
+	connParams  []string
+	noPreflight bool
+	reuseName   string
+	migrations  *migrations
+	// reused is set when the container of WithReuse already existed
+	reused bool
+
+	aclUsers   []ACLUser
+	luaScripts []luaScriptSource
+
+	binlogFormat BinlogFormat
+
+	proxySQL      bool
+	proxySQLRules []ProxySQLRule
+
+	mongo  mongoOptions
+	redis  redisOptions
+	rabbit rabbitOptions
+	kafka  kafkaOptions
+	es     esOptions
+
+	postgres postgresOptions
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		timeouts: make(map[Phase]time.Duration),
+		mongo:    defaultMongoOptions(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPhaseTimeout sets the timeout of a single startup phase,
+// the phase still respects the deadline of the parent context.
+// A zero timeout means the phase is only bounded by the parent context.
+func WithPhaseTimeout(phase Phase, timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeouts[phase] = timeout
+	}
+}
+
+// WithStartupTimeout bounds the whole startup of a helper, from the image pull
+// to the client connect. Unlike WithPhaseTimeout the error tells how far the
+// startup got, e.g. "pull phase, pulled 3/5 layers (2m0s)".
+func WithStartupTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.startup = timeout
+	}
+}
+
+// WithProgress writes a line about the running phase to w every interval, e.g.
+// "redis:6.2.6: wait phase, waiting for log line \"Ready to accept connections\" (42s)",
+// so a slow startup can be told apart from a hanging one. A zero interval
+// reports every 10 seconds.
+func WithProgress(w io.Writer, interval time.Duration) Option {
+	return func(o *options) {
+		o.progress = w
+		o.progressInt = interval
+	}
+}
+
+// WithLogf routes the messages of the helper, e.g. why a startup failed, to
+// logf instead of stderr. The *T constructors pass t.Logf.
+func WithLogf(logf func(format string, args ...any)) Option {
+	return func(o *options) {
+		o.logger = logf
+	}
+}
+
+// logf writes a message of the helper to the configured logger
+func (o *options) logf(format string, args ...any) {
+	if o.logger != nil {
+		o.logger(format, args...)
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// WithCustomizers passes module specific customizers (e.g. doris.WithSQLScripts)
+// through to the module the helper runs.
+func WithCustomizers(customizers ...testcontainers.ContainerCustomizer) Option {
+	return func(o *options) {
+		o.customizers = append(o.customizers, customizers...)
+	}
+}
+
+// containerCustomizers returns the module specific customizers followed by
+// the ones shared by all helpers, attaching the IPv6 network if requested.
+func (o *options) containerCustomizers(ctx context.Context, moduleOpts ...testcontainers.ContainerCustomizer) ([]testcontainers.ContainerCustomizer, error) {
+	customizers := append(moduleOpts, o.customizers...)
+	if o.reuseEnabled() {
+		customizers = append(customizers, o.reuseCustomizer())
+	}
+	if o.ipv6 {
+		customizers = append(customizers, o.ipv6Customizer(ctx))
+	}
+	return customizers, nil
+}
+
+// ipv6Customizer attaches the container to the network of WithIPv6, created
+// once per options and removed when the last container attached to it is
+// terminated. A reused container is already attached to its network.
+func (o *options) ipv6Customizer(ctx context.Context) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		if o.reused {
+			return nil
+		}
+		nw, err := o.ipv6Net.get(ctx, o.ipv6Subnet)
+		if err != nil {
+			return err
+		}
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostCreates: []testcontainers.ContainerHook{
+				func(context.Context, testcontainers.Container) error {
+					o.ipv6Net.attach()
+					return nil
+				},
+			},
+			PostTerminates: []testcontainers.ContainerHook{
+				func(ctx context.Context, _ testcontainers.Container) error {
+					return o.ipv6Net.detach(ctx)
+				},
+			},
+		})
+		return network.WithNetwork(nil, nw)(req)
+	}
+}
+
+// removeIPv6Network removes the network of WithIPv6 if no container was
+// created on it, e.g. when the startup failed before
+func (o *options) removeIPv6Network(ctx context.Context) {
+	if err := o.ipv6Net.removeUnused(ctx); err != nil {
+		o.logf("failed to remove ipv6 network: %v", err)
+	}
+}
+
+// ipv6Network counts the containers attached to the network of WithIPv6
+type ipv6Network struct {
+	mu   sync.Mutex
+	nw   *testcontainers.DockerNetwork
+	refs int
+}
+
+// get returns the network, creating it on the first call
+func (n *ipv6Network) get(ctx context.Context, subnet string) (*testcontainers.DockerNetwork, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.nw == nil {
+		nw, err := NewIPv6Network(ctx, subnet)
+		if err != nil {
+			return nil, err
+		}
+		n.nw = nw
+	}
+	return n.nw, nil
+}
+
+func (n *ipv6Network) attach() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.refs++
+}
+
+// detach removes the network once the last container left it
+func (n *ipv6Network) detach(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.refs--
+	return n.removeLocked(ctx)
+}
+
+func (n *ipv6Network) removeUnused(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.removeLocked(ctx)
+}
+
+func (n *ipv6Network) removeLocked(ctx context.Context) error {
+	if n.nw == nil || n.refs > 0 {
+		return nil
+	}
+	err := n.nw.Remove(ctx)
+	n.nw = nil
+	return err
+}
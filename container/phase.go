@@ -0,0 +1,218 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/errdefs"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"sync"
+	"time"
+)
+
+// Phase names a step of starting a container helper.
+type Phase string
+
+const (
+	PhasePull          Phase = "pull"
+	PhaseStart         Phase = "start"
+	PhaseWait          Phase = "wait"
+	PhaseClientConnect Phase = "client-connect"
+	PhaseInitScripts   Phase = "init-scripts"
+)
+
+// PhaseError is returned by the helpers when a startup phase fails,
+// it names the failing phase and the timeout configured for it.
+type PhaseError struct {
+	Phase   Phase
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *PhaseError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("%s phase failed (timeout %s): %v", e.Phase, e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("%s phase failed: %v", e.Phase, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// phaseTimeoutError is the cancel cause of a phase running out of time.
+type phaseTimeoutError struct {
+	phase   Phase
+	timeout time.Duration
+}
+
+func (e *phaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s phase timed out after %s", e.phase, e.timeout)
+}
+
+// phaseRunner runs the startup phases of a helper. Every phase gets its own
+// timeout on top of the parent context, and errors are tagged with the phase
+// that was running when they happened.
+type phaseRunner struct {
+	timeouts map[Phase]time.Duration
+	startup  time.Duration
+	progress *progressReporter
+
+	mu      sync.Mutex
+	phase   Phase
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	started time.Time
+	// status describes what the running phase is doing, e.g. "pulled 3/5 layers"
+	status string
+	since  time.Time
+}
+
+func newPhaseRunner(o *options) *phaseRunner {
+	p := &phaseRunner{timeouts: o.timeouts, startup: o.startup}
+	if o.progress != nil {
+		p.progress = &progressReporter{w: o.progress, interval: o.progressInt}
+	}
+	return p
+}
+
+// run executes fn as the given phase. fn may switch to following phases
+// with enter, e.g. a container start moves on to wait once it is running.
+func (p *phaseRunner) run(ctx context.Context, phase Phase, fn func(ctx context.Context) error) error {
+	p.mu.Lock()
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
+	started := p.started
+	p.mu.Unlock()
+
+	if p.startup > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadlineCause(ctx, started.Add(p.startup), &startupTimeoutError{timeout: p.startup})
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
+	p.enter(phase)
+
+	if p.progress != nil {
+		stop := p.progress.start(p)
+		defer stop()
+	}
+
+	err := fn(ctx)
+
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	failed := p.phase
+	p.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+	var timeoutErr *phaseTimeoutError
+	var startupErr *startupTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		err = fmt.Errorf("%w: %w", timeoutErr, err)
+	} else if errors.As(context.Cause(ctx), &startupErr) {
+		err = fmt.Errorf("%w, got to %s: %w", startupErr, p.describe(), err)
+	}
+	return &PhaseError{Phase: failed, Timeout: p.timeouts[failed], Err: err}
+}
+
+// enter switches the running phase and arms its timeout.
+func (p *phaseRunner) enter(phase Phase) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.phase = phase
+	p.status = ""
+	p.since = time.Now()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if timeout := p.timeouts[phase]; timeout > 0 && p.cancel != nil {
+		cancel := p.cancel
+		p.timer = time.AfterFunc(timeout, func() {
+			cancel(&phaseTimeoutError{phase: phase, timeout: timeout})
+		})
+	}
+}
+
+// setStatus describes what the running phase is doing
+func (p *phaseRunner) setStatus(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = fmt.Sprintf(format, args...)
+}
+
+// describe reports the running phase, its status and for how long it's been running
+func (p *phaseRunner) describe() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.since).Round(time.Second)
+	if p.status == "" {
+		return fmt.Sprintf("%s phase (%s)", p.phase, elapsed)
+	}
+	return fmt.Sprintf("%s phase, %s (%s)", p.phase, p.status, elapsed)
+}
+
+// pull pulls the image if it is not present locally yet,
+// so a slow registry shows up as the pull phase instead of the start phase.
+func (p *phaseRunner) pull(ctx context.Context, img string) error {
+	if p.progress != nil {
+		p.progress.name = img
+	}
+	return p.run(ctx, PhasePull, func(ctx context.Context) error {
+		provider, err := testcontainers.NewDockerProvider()
+		if err != nil {
+			// the daemon is unreachable, tell why instead of the bare client error
+			return fmt.Errorf("%w\n%s", err, Doctor(ctx))
+		}
+		defer func() { _ = provider.Close() }()
+
+		if _, err = provider.Client().ImageInspect(ctx, img); err == nil {
+			return nil
+		} else if !errdefs.IsNotFound(err) {
+			return err
+		}
+		return p.pullImage(ctx, provider, img)
+	})
+}
+
+// customizer wraps the wait strategy of the container request so that
+// the runner switches to the wait phase once the container is running,
+// and to the init-scripts phase once it is ready (post-ready commands).
+// It must be the last customizer passed to the module.
+func (p *phaseRunner) customizer() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.WaitingFor = &phaseWaitStrategy{runner: p, strategy: req.WaitingFor}
+		return nil
+	}
+}
+
+type phaseWaitStrategy struct {
+	runner   *phaseRunner
+	strategy wait.Strategy
+}
+
+func (s *phaseWaitStrategy) WaitUntilReady(ctx context.Context, target wait.StrategyTarget) error {
+	s.runner.enter(PhaseWait)
+	if s.strategy != nil {
+		s.runner.setStatus("waiting for %s", describeStrategy(s.strategy))
+		if err := s.strategy.WaitUntilReady(ctx, target); err != nil {
+			return err
+		}
+	}
+	s.runner.enter(PhaseInitScripts)
+	return nil
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	_ "github.com/lib/pq"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	port = "5432/tcp"
+
+	defaultUser         = "postgres"
+	defaultPassword     = "password"
+	defaultDatabaseName = "test"
+)
+
+// Container represents the PostgreSQL container type used in the module
+type Container struct {
+	testcontainers.Container
+	user     string
+	password string
+	database string
+}
+
+// Run creates an instance of the PostgreSQL container type
+func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	req := testcontainers.ContainerRequest{
+		Image: img,
+		Env: map[string]string{
+			"POSTGRES_USER":     defaultUser,
+			"POSTGRES_PASSWORD": defaultPassword,
+			"POSTGRES_DB":       defaultDatabaseName,
+		},
+		ExposedPorts: []string{port},
+		// the entrypoint restarts the server once the init scripts ran
+		WaitingFor: wait.ForAll(
+			wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+			wait.ForListeningPort(port),
+		).WithDeadline(2 * time.Minute),
+	}
+
+	genericContainerReq := testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	}
+
+	for _, opt := range opts {
+		if err := opt.Customize(&genericContainerReq); err != nil {
+			return nil, err
+		}
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, genericContainerReq)
+	var c *Container
+	if container != nil {
+		c = &Container{
+			Container: container,
+			user:      genericContainerReq.Env["POSTGRES_USER"],
+			password:  genericContainerReq.Env["POSTGRES_PASSWORD"],
+			database:  genericContainerReq.Env["POSTGRES_DB"],
+		}
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("generic container: %w", err)
+	}
+
+	return c, nil
+}
+
+// Database returns the database the connection strings point at.
+func (c *Container) Database() string {
+	return c.database
+}
+
+// ConnectionString returns the URL of the database for lib/pq and pgx, TLS
+// is disabled unless args set sslmode.
+func (c *Container) ConnectionString(ctx context.Context, args ...string) (string, error) {
+	containerPort, err := c.MappedPort(ctx, port)
+	if err != nil {
+		return "", err
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return dsn.NewPostgres(host, containerPort.Int()).Credentials(c.user, c.password).Database(c.database).
+		Param("sslmode", "disable").Params(args...).String(), nil
+}
+
+// WithUsername sets the superuser the container creates, "postgres" by default
+func WithUsername(user string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Env["POSTGRES_USER"] = user
+
+		return nil
+	}
+}
+
+// WithPassword sets the password of the superuser
+func WithPassword(password string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Env["POSTGRES_PASSWORD"] = password
+
+		return nil
+	}
+}
+
+// WithDatabase sets the database the container creates, "test" by default
+func WithDatabase(database string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Env["POSTGRES_DB"] = database
+
+		return nil
+	}
+}
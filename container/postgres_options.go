@@ -0,0 +1,104 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	"strings"
+)
+
+const (
+	// PostgresImage is the image of the Postgres helper
+	PostgresImage = "postgres:16.9"
+	// PgvectorImage is the image of the Postgres helper when WithExtensions
+	// asks for pgvector, which the official image doesn't ship
+	PgvectorImage = "pgvector/pgvector:0.8.0-pg16"
+)
+
+// postgresOptions holds the extensions of the Postgres helper
+type postgresOptions struct {
+	extensions []string
+}
+
+// extensionAliases maps the project names users know the extensions by to
+// the names CREATE EXTENSION takes
+var extensionAliases = map[string]string{
+	"pgvector": "vector",
+}
+
+// WithExtensions creates the extensions in the database of the Postgres
+// helper before its init scripts run, e.g. WithExtensions("pgvector",
+// "uuid-ossp"), failing the startup when the image doesn't ship one.
+// pgvector runs PgvectorImage unless WithImage sets another one.
+func WithExtensions(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			if alias, ok := extensionAliases[name]; ok {
+				name = alias
+			}
+			o.postgres.extensions = append(o.postgres.extensions, name)
+		}
+	}
+}
+
+// image returns the default image able to create the extensions
+func (p postgresOptions) image() string {
+	for _, ext := range p.extensions {
+		if ext == "vector" {
+			return PgvectorImage
+		}
+	}
+	return PostgresImage
+}
+
+// extensionsScript mounts the script creating the extensions, named to run
+// before the init scripts of WithInitScripts
+func (p postgresOptions) extensionsScript() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		if len(p.extensions) == 0 {
+			return nil
+		}
+		var sb strings.Builder
+		for _, ext := range p.extensions {
+			lit := quotePostgresLiteral(ext)
+			// the entrypoint stops at the first error, explain it in the logs
+			_, _ = fmt.Fprintf(&sb, "DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = %s) THEN "+
+				"RAISE EXCEPTION 'extension %%s is not available in the image', %s; END IF; END $$;\n", lit, lit)
+			_, _ = fmt.Fprintf(&sb, "CREATE EXTENSION IF NOT EXISTS %s;\n", quotePostgresIdent(ext))
+		}
+		req.Files = append(req.Files, testcontainers.ContainerFile{
+			Reader:            strings.NewReader(sb.String()),
+			ContainerFilePath: "/docker-entrypoint-initdb.d/00-mtest-extensions.sql",
+			FileMode:          0o644,
+		})
+		return nil
+	}
+}
+
+// createExtensions checks the extensions are available and creates the
+// missing ones, e.g. in a reused container
+func (p postgresOptions) createExtensions(ctx context.Context, db *sqlx.DB, img string) error {
+	for _, ext := range p.extensions {
+		var available bool
+		err := db.GetContext(ctx, &available, "SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = $1)", ext)
+		if err != nil {
+			return fmt.Errorf("failed to check extension %s: %w", ext, err)
+		}
+		if !available {
+			return fmt.Errorf("extension %s is not available in image %s", ext, img)
+		}
+		if _, err = db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS "+quotePostgresIdent(ext)); err != nil {
+			return fmt.Errorf("failed to create extension %s: %w", ext, err)
+		}
+	}
+	return nil
+}
+
+func quotePostgresIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quotePostgresLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
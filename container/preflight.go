@@ -0,0 +1,112 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Resources is the memory and CPU containers can use
+type Resources struct {
+	// Memory in bytes
+	Memory int64
+	CPUs   float64
+}
+
+func (r Resources) String() string {
+	return fmt.Sprintf("%s of memory and %.1f CPUs", formatBytes(r.Memory), r.CPUs)
+}
+
+// resourceNeed is what a helper needs to start without being OOM-killed
+type resourceNeed struct {
+	service           Service
+	minMemory         int64
+	recommendedMemory int64
+	minCPUs           float64
+	// adjust adapts the container to have memory below the recommended
+	adjust func(memory int64) testcontainers.ContainerCustomizer
+}
+
+var dorisNeed = resourceNeed{
+	service:           ServiceDoris,
+	minMemory:         4 << 30,
+	recommendedMemory: 8 << 30,
+	minCPUs:           2,
+	// The BE sizes its memory pool by the cgroup limit of its container, so
+	// a limit below the daemon memory keeps it from claiming what it can't get.
+	adjust: func(memory int64) testcontainers.ContainerCustomizer {
+		return testcontainers.WithHostConfigModifier(func(hc *dockercontainer.HostConfig) {
+			hc.Memory = memory
+		})
+	},
+}
+
+// PreflightError is returned by the helpers when the machine has less memory
+// than the service needs, instead of starting a container bound to be OOM-killed
+type PreflightError struct {
+	Service   Service
+	MinMemory int64
+	Available Resources
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("%s needs at least %s of memory, containers get %s: "+
+		"raise the memory of the docker VM (Docker Desktop, colima or podman machine settings), "+
+		"use a larger CI runner, skip the test with MTEST_SKIP=%s or disable the check with WithoutPreflight",
+		e.Service, formatBytes(e.MinMemory), e.Available, e.Service)
+}
+
+// WithoutPreflight disables the resource check of the helpers of heavy
+// services, e.g. when the daemon reports less memory than it really has.
+func WithoutPreflight() Option {
+	return func(o *options) {
+		o.noPreflight = true
+	}
+}
+
+// DetectResources returns the memory and CPUs of the docker daemon. The
+// limits of the test process don't bound the containers, which run under
+// the daemon, e.g. on the host of a docker-in-docker CI job.
+func DetectResources(ctx context.Context) (Resources, error) {
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return Resources{}, fmt.Errorf("%w\n%s", err, Doctor(ctx))
+	}
+	defer func() { _ = provider.Close() }()
+
+	info, err := provider.Client().Info(ctx)
+	if err != nil {
+		return Resources{}, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	return Resources{Memory: info.MemTotal, CPUs: float64(info.NCPU)}, nil
+}
+
+// preflight checks the resources against the need of the helper, returning
+// the customizer adapting the container to them, nil if none is needed.
+func (o *options) preflight(ctx context.Context, need resourceNeed) (testcontainers.ContainerCustomizer, error) {
+	if o.noPreflight {
+		return nil, nil
+	}
+	res, err := DetectResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Memory < need.minMemory {
+		return nil, &PreflightError{Service: need.service, MinMemory: need.minMemory, Available: res}
+	}
+	if res.CPUs < need.minCPUs {
+		o.logf("%s needs %.0f CPUs, containers get %s, startup will be slow", need.service, need.minCPUs, res)
+	}
+	if res.Memory < need.recommendedMemory && need.adjust != nil {
+		o.logf("%s recommends %s of memory, containers get %s, limiting the container to it",
+			need.service, formatBytes(need.recommendedMemory), res)
+		return need.adjust(res.Memory), nil
+	}
+	return nil, nil
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+}
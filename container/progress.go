@@ -0,0 +1,168 @@
+package container
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultProgressInterval = 10 * time.Second
+
+// startupTimeoutError is the cancel cause of a startup running out of time
+type startupTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *startupTimeoutError) Error() string {
+	return fmt.Sprintf("startup timed out after %s", e.timeout)
+}
+
+// progressReporter periodically writes the state of a phase runner
+type progressReporter struct {
+	w        io.Writer
+	interval time.Duration
+	// name is the image of the helper, it prefixes every line
+	name string
+
+	mu sync.Mutex
+}
+
+func (r *progressReporter) printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line := fmt.Sprintf(format, args...)
+	if r.name != "" {
+		line = r.name + ": " + line
+	}
+	_, _ = fmt.Fprintln(r.w, line)
+}
+
+// start reports the state of the runner every interval until stop is called
+func (r *progressReporter) start(p *phaseRunner) (stop func()) {
+	interval := r.interval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.printf("%s", p.describe())
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// pullImage pulls the image and keeps the runner status up to date with
+// the number of layers pulled so far.
+func (p *phaseRunner) pullImage(ctx context.Context, provider *testcontainers.DockerProvider, img string) error {
+	var opts image.PullOptions
+	// like testcontainers, fall back to an anonymous pull without credentials
+	if _, auth, err := testcontainers.DockerImageAuth(ctx, img); err == nil {
+		if encoded, err := json.Marshal(auth); err == nil {
+			opts.RegistryAuth = base64.URLEncoding.EncodeToString(encoded)
+		}
+	}
+
+	started := time.Now()
+	p.setStatus("pulling")
+	rc, err := provider.Client().ImagePull(ctx, img, opts)
+	if err != nil {
+		return fmt.Errorf("failed to pull image '%s': %w", img, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	// layers maps the layer ids to whether they are pulled
+	layers := make(map[string]bool)
+	pulled := 0
+	dec := json.NewDecoder(rc)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err = dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read pull progress of image '%s': %w", img, err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("failed to pull image '%s': %w", img, msg.Error)
+		}
+
+		switch msg.Status {
+		case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum", "Download complete", "Extracting":
+			if _, ok := layers[msg.ID]; !ok {
+				layers[msg.ID] = false
+			}
+		case "Pull complete", "Already exists":
+			if !layers[msg.ID] {
+				layers[msg.ID] = true
+				pulled++
+			}
+		default:
+			continue
+		}
+		p.setStatus("pulled %d/%d layers", pulled, len(layers))
+	}
+
+	if p.progress != nil {
+		p.progress.printf("pulled %d/%d layers in %s", pulled, len(layers), time.Since(started).Round(time.Second))
+	}
+	return nil
+}
+
+// describeStrategy describes what a wait strategy is waiting for,
+// e.g. `log line "ready for connections" and port 3306/tcp`.
+func describeStrategy(strategy wait.Strategy) string {
+	switch s := strategy.(type) {
+	case *wait.LogStrategy:
+		if s.Occurrence > 1 {
+			return fmt.Sprintf("log line %q x%d", s.Log, s.Occurrence)
+		}
+		return fmt.Sprintf("log line %q", s.Log)
+	case *wait.HostPortStrategy:
+		return "port " + string(s.Port)
+	case *wait.HTTPStrategy:
+		return fmt.Sprintf("http %s on port %s", s.Path, s.Port)
+	case *wait.HealthStrategy:
+		return "healthy container"
+	case *wait.ExecStrategy:
+		return "exec command"
+	case *wait.MultiStrategy:
+		var parts []string
+		for _, sub := range s.Strategies {
+			if sub == nil || reflect.ValueOf(sub).IsNil() {
+				continue
+			}
+			parts = append(parts, describeStrategy(sub))
+		}
+		return strings.Join(parts, " and ")
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", strategy), "*wait.") + " strategy"
+	}
+}
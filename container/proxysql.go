@@ -0,0 +1,248 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/docker/go-connections/nat"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ProxySQLImage is the image of the ProxySQL container
+const ProxySQLImage = "proxysql/proxysql:2.7.1"
+
+const (
+	proxySQLPort      = "6033/tcp"
+	proxySQLAdminPort = "6032/tcp"
+)
+
+// ProxySQLRule is a ProxySQL query rule, see mysql_query_rules in the ProxySQL docs.
+// Rules are applied in the given order, their ids start at 1.
+type ProxySQLRule struct {
+	// MatchDigest is a regexp matched against the query digest, e.g. "^SELECT .* FOR UPDATE"
+	MatchDigest string
+	// MatchPattern is a regexp matched against the query text
+	MatchPattern string
+	// ReplacePattern rewrites the text matched by MatchPattern
+	ReplacePattern string
+	// ErrorMsg makes the proxy fail matching queries with the message
+	ErrorMsg string
+	// CacheTTL caches the result of matching queries for the milliseconds
+	CacheTTL int
+	// Delay delays matching queries by the milliseconds
+	Delay int
+	// Apply stops evaluating the rules after this one
+	Apply bool
+}
+
+// ProxySQLContainer is a ProxySQL container in front of a MySQL container
+type ProxySQLContainer struct {
+	testcontainers.Container
+	// DSN connects through the proxy to the database of the MySQL container
+	DSN string
+	Db  *sqlx.DB
+}
+
+// WithProxySQL starts a ProxySQL container with the query rules in front of
+// the MySQL container, available as MySQLContainer.Proxy.
+func WithProxySQL(rules ...ProxySQLRule) Option {
+	return func(o *options) {
+		o.proxySQL = true
+		o.proxySQLRules = append(o.proxySQLRules, rules...)
+	}
+}
+
+var proxySQLConfigTpl = template.Must(template.New("proxysql.cnf").Funcs(template.FuncMap{
+	"inc":    func(i int) int { return i + 1 },
+	"escape": escapeProxySQLString,
+}).Parse(`datadir="/var/lib/proxysql"
+
+admin_variables=
+{
+	admin_credentials="admin:admin;radmin:radmin"
+	mysql_ifaces="0.0.0.0:6032"
+}
+
+mysql_variables=
+{
+	threads=2
+	max_connections=2048
+	interfaces="0.0.0.0:6033"
+	server_version="8.4.5"
+	monitor_username="{{.User}}"
+	monitor_password="{{.Password}}"
+}
+
+mysql_servers=
+(
+	{ address="{{.Host}}", port=3306, hostgroup=0, max_connections=200 }
+)
+
+mysql_users=
+(
+	{ username="{{.User}}", password="{{.Password}}", default_hostgroup=0, default_schema="{{.Database}}" }
+)
+
+mysql_query_rules=
+(
+{{- range $i, $r := .Rules}}{{if $i}},{{end}}
+	{
+		rule_id={{inc $i}}
+		active=1
+{{- with $r.MatchDigest}}
+		match_digest="{{escape .}}"{{end}}
+{{- with $r.MatchPattern}}
+		match_pattern="{{escape .}}"{{end}}
+{{- with $r.ReplacePattern}}
+		replace_pattern="{{escape .}}"{{end}}
+{{- with $r.ErrorMsg}}
+		error_msg="{{escape .}}"{{end}}
+{{- with $r.CacheTTL}}
+		cache_ttl={{.}}{{end}}
+{{- with $r.Delay}}
+		delay={{.}}{{end}}
+		destination_hostgroup=0
+		apply={{if $r.Apply}}1{{else}}0{{end}}
+	}
+{{- end}}
+)
+`))
+
+// StartProxySQL starts a ProxySQL container with the query rules in front of
+// the MySQL container, on the docker network of the MySQL container. The
+// proxy uses the credentials of the MySQL container for both its frontend
+// and the backend.
+func (c *MySQLContainer) StartProxySQL(ctx context.Context, rules ...ProxySQLRule) (*ProxySQLContainer, error) {
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mysql connection string: %w", err)
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mysql connection string: %w", err)
+	}
+
+	// ProxySQL reaches MySQL by its IP on the first of its networks
+	inspect, err := c.Inspect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect mysql container: %w", err)
+	}
+	var networks []string
+	for name := range inspect.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("mysql container is not attached to any network")
+	}
+	sort.Strings(networks)
+	host := inspect.NetworkSettings.Networks[networks[0]].IPAddress
+
+	var conf bytes.Buffer
+	err = proxySQLConfigTpl.Execute(&conf, map[string]any{
+		"Host":     host,
+		"User":     cfg.User,
+		"Password": cfg.Passwd,
+		"Database": cfg.DBName,
+		"Rules":    rules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render proxysql config: %w", err)
+	}
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        ProxySQLImage,
+			ExposedPorts: []string{proxySQLPort, proxySQLAdminPort},
+			Networks:     []string{networks[0]},
+			Files: []testcontainers.ContainerFile{{
+				Reader:            bytes.NewReader(conf.Bytes()),
+				ContainerFilePath: "/etc/proxysql.cnf",
+				FileMode:          0o644,
+			}},
+			WaitingFor: wait.ForListeningPort(proxySQLPort),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start proxysql container: %w", err)
+	}
+
+	endpoint, err := ctr.PortEndpoint(ctx, nat.Port(proxySQLPort), "")
+	if err != nil {
+		_ = ctr.Terminate(ctx)
+		return nil, fmt.Errorf("failed to get proxysql endpoint: %w", err)
+	}
+	proxyCfg := cfg.Clone()
+	proxyCfg.Addr = endpoint
+
+	p := &ProxySQLContainer{Container: ctr, DSN: proxyCfg.FormatDSN()}
+	if p.Db, err = sqlx.ConnectContext(ctx, "mysql", p.DSN); err != nil {
+		_ = ctr.Terminate(ctx)
+		return nil, fmt.Errorf("failed to connect through proxysql: %w", err)
+	}
+	return p, nil
+}
+
+// Admin connects to the admin interface of ProxySQL, e.g. to change the
+// query rules at runtime or read the stats tables.
+func (p *ProxySQLContainer) Admin(ctx context.Context) (*sqlx.DB, error) {
+	endpoint, err := p.PortEndpoint(ctx, nat.Port(proxySQLAdminPort), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxysql admin endpoint: %w", err)
+	}
+	cfg := gomysql.NewConfig()
+	cfg.User = "radmin"
+	cfg.Passwd = "radmin"
+	cfg.Net = "tcp"
+	cfg.Addr = endpoint
+	db, err := sqlx.ConnectContext(ctx, "mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect proxysql admin: %w", err)
+	}
+	return db, nil
+}
+
+// RuleHits returns how many queries matched each query rule, keyed by rule id
+func (p *ProxySQLContainer) RuleHits(ctx context.Context) (map[int]int64, error) {
+	admin, err := p.Admin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = admin.Close() }()
+
+	rows, err := admin.QueryContext(ctx, "SELECT rule_id, hits FROM stats_mysql_query_rules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule hits: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	hits := make(map[int]int64)
+	for rows.Next() {
+		var id int
+		var n int64
+		if err = rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		hits[id] = n
+	}
+	return hits, rows.Err()
+}
+
+// Terminate closes the client and terminates the proxy container
+func (p *ProxySQLContainer) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if p.Db != nil {
+		_ = p.Db.Close()
+	}
+	return p.Container.Terminate(ctx, opts...)
+}
+
+// escapeProxySQLString escapes a string value of the ProxySQL config file
+func escapeProxySQLString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,51 @@
+package container
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DelayedMessageImage is a RabbitMQ image shipping the delayed message exchange plugin
+const DelayedMessageImage = "heidiks/rabbitmq-delayed-message-exchange:3.13.0-management"
+
+// rabbitOptions holds the image and topology settings of the RabbitMQ helper
+type rabbitOptions struct {
+	delayedImage string
+	topology     []func(ch *amqp.Channel) error
+}
+
+// WithDelayedMessagePlugin runs an image with the delayed message exchange
+// plugin and enables it, DelayedMessageImage if img is empty.
+func WithDelayedMessagePlugin(img string) Option {
+	return func(o *options) {
+		if img == "" {
+			img = DelayedMessageImage
+		}
+		o.rabbit.delayedImage = img
+	}
+}
+
+// WithDeadLetter declares the durable queue with the dead letter exchange dlx,
+// and the queue DeadLetterQueue(queue) bound to dlx, so rejected and expired
+// messages of queue land there. Declaring queue with other arguments
+// afterwards fails with PRECONDITION_FAILED.
+func WithDeadLetter(queue, dlx string) Option {
+	return func(o *options) {
+		o.rabbit.topology = append(o.rabbit.topology, func(ch *amqp.Channel) error {
+			return declareDeadLetter(ch, queue, dlx)
+		})
+	}
+}
+
+// WithDelayedExchange declares a durable delayed message exchange routing
+// like kind (e.g. "direct" or "topic") once a message's x-delay header in
+// milliseconds elapsed. It implies WithDelayedMessagePlugin("").
+func WithDelayedExchange(name, kind string) Option {
+	return func(o *options) {
+		if o.rabbit.delayedImage == "" {
+			o.rabbit.delayedImage = DelayedMessageImage
+		}
+		o.rabbit.topology = append(o.rabbit.topology, func(ch *amqp.Channel) error {
+			return declareDelayedExchange(ch, name, kind)
+		})
+	}
+}
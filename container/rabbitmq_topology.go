@@ -0,0 +1,138 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"testing"
+	"time"
+)
+
+// DeadLetterQueue returns the name of the queue WithDeadLetter binds to the dead letter exchange of queue
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+func declareDeadLetter(ch *amqp.Channel, queue, dlx string) error {
+	if err := ch.ExchangeDeclare(dlx, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead letter exchange '%s': %w", dlx, err)
+	}
+	dlq := DeadLetterQueue(queue)
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead letter queue '%s': %w", dlq, err)
+	}
+	if err := ch.QueueBind(dlq, "", dlx, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead letter queue '%s': %w", dlq, err)
+	}
+	args := amqp.Table{"x-dead-letter-exchange": dlx}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare queue '%s': %w", queue, err)
+	}
+	return nil
+}
+
+func declareDelayedExchange(ch *amqp.Channel, name, kind string) error {
+	args := amqp.Table{"x-delayed-type": kind}
+	if err := ch.ExchangeDeclare(name, "x-delayed-message", true, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare delayed exchange '%s': %w", name, err)
+	}
+	return nil
+}
+
+// PublishDelayed publishes the message to a delayed exchange, it is routed once delay elapsed
+func (c *RabbitMQContainer) PublishDelayed(ctx context.Context, exchange, key string, msg amqp.Publishing, delay time.Duration) error {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Headers["x-delay"] = delay.Milliseconds()
+	if err := c.Channel.PublishWithContext(ctx, exchange, key, false, false, msg); err != nil {
+		return fmt.Errorf("failed to publish delayed message to '%s': %w", exchange, err)
+	}
+	return nil
+}
+
+// DeadLetter is a message consumed from a dead letter queue
+type DeadLetter struct {
+	Body    []byte
+	Headers amqp.Table
+	// Queue is the queue the message was dead-lettered from
+	Queue string
+	// Reason is why it was dead-lettered, e.g. "rejected", "expired" or "maxlen"
+	Reason string
+	// Count is how many times it was dead-lettered from Queue for Reason
+	Count int64
+}
+
+func newDeadLetter(d amqp.Delivery) DeadLetter {
+	letter := DeadLetter{Body: d.Body, Headers: d.Headers}
+	// the most recent death comes first
+	if deaths, ok := d.Headers["x-death"].([]any); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp.Table); ok {
+			letter.Queue, _ = death["queue"].(string)
+			letter.Reason, _ = death["reason"].(string)
+			letter.Count, _ = death["count"].(int64)
+		}
+	}
+	return letter
+}
+
+// WaitDeadLetters consumes n messages from the dead letter queue, waiting at
+// most timeout for them to land. The messages consumed so far are returned
+// with the error on timeout.
+func (c *RabbitMQContainer) WaitDeadLetters(ctx context.Context, dlq string, n int, timeout time.Duration) ([]DeadLetter, error) {
+	ch, err := c.Conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer func() { _ = ch.Close() }()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var letters []DeadLetter
+	for len(letters) < n {
+		d, ok, err := ch.Get(dlq, true)
+		if err != nil {
+			return letters, fmt.Errorf("failed to get message from '%s': %w", dlq, err)
+		}
+		if ok {
+			letters = append(letters, newDeadLetter(d))
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return letters, fmt.Errorf("got %d of %d dead letters from '%s' within %s", len(letters), n, dlq, timeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return letters, nil
+}
+
+// AssertDeadLettered asserts that exactly n messages land in the dead letter
+// queue within timeout, consuming them.
+func (c *RabbitMQContainer) AssertDeadLettered(t testing.TB, dlq string, n int, timeout time.Duration) bool {
+	t.Helper()
+
+	letters, err := c.WaitDeadLetters(context.Background(), dlq, n, timeout)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+
+	ch, err := c.Conn.Channel()
+	if err != nil {
+		t.Errorf("failed to open channel: %v", err)
+		return false
+	}
+	defer func() { _ = ch.Close() }()
+	queue, err := ch.QueueDeclarePassive(dlq, true, false, false, false, nil)
+	if err != nil {
+		t.Errorf("failed to inspect '%s': %v", dlq, err)
+		return false
+	}
+	if queue.Messages > 0 {
+		t.Errorf("'%s' has %d more dead letters than the %d expected, consumed: %+v", dlq, queue.Messages, n, letters)
+		return false
+	}
+	return true
+}
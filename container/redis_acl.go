@@ -0,0 +1,87 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	"sync"
+)
+
+// ACLUser is a Redis ACL user created by WithACLUsers or CreateACLUser
+type ACLUser struct {
+	Name     string
+	Password string
+	// Commands are the command rules, e.g. "+@read", "+set" or "-flushall";
+	// a user without rules can't run any command
+	Commands []string
+	// Keys are the key patterns the user can access, e.g. "app:*"
+	Keys []string
+	// Channels are the pub/sub channel patterns the user can access
+	Channels []string
+}
+
+// rules returns the ACL SETUSER arguments of the user
+func (u ACLUser) rules() []any {
+	args := []any{"ACL", "SETUSER", u.Name, "reset", "on", ">" + u.Password}
+	for _, key := range u.Keys {
+		args = append(args, "~"+key)
+	}
+	for _, channel := range u.Channels {
+		args = append(args, "&"+channel)
+	}
+	for _, command := range u.Commands {
+		args = append(args, command)
+	}
+	return args
+}
+
+// WithACLUsers creates the ACL users once the Redis container is ready,
+// get their clients with RedisContainer.UserClient.
+func WithACLUsers(users ...ACLUser) Option {
+	return func(o *options) {
+		o.aclUsers = append(o.aclUsers, users...)
+	}
+}
+
+// aclClients holds the clients of the ACL users of a RedisContainer
+type aclClients struct {
+	mu      sync.Mutex
+	clients map[string]*r.Client
+}
+
+// CreateACLUser creates the ACL user, replacing the rules of an existing one,
+// and returns a client authenticated as the user.
+func (c *RedisContainer) CreateACLUser(ctx context.Context, user ACLUser) (*r.Client, error) {
+	if err := c.RedisCli.Do(ctx, user.rules()...).Err(); err != nil {
+		return nil, fmt.Errorf("failed to create acl user '%s': %w", user.Name, err)
+	}
+
+	opts := *c.RedisCli.Options()
+	opts.Username = user.Name
+	opts.Password = user.Password
+	cli := r.NewClient(&opts)
+	if err := cli.Ping(ctx).Err(); err != nil {
+		// PING needs no permission, a failure means the credentials are wrong
+		_ = cli.Close()
+		return nil, fmt.Errorf("failed to authenticate acl user '%s': %w", user.Name, err)
+	}
+
+	c.acl.mu.Lock()
+	defer c.acl.mu.Unlock()
+	if c.acl.clients == nil {
+		c.acl.clients = make(map[string]*r.Client)
+	}
+	if old, ok := c.acl.clients[user.Name]; ok {
+		_ = old.Close()
+	}
+	c.acl.clients[user.Name] = cli
+	return cli, nil
+}
+
+// UserClient returns the client of an ACL user created by WithACLUsers or
+// CreateACLUser, nil if there is no such user.
+func (c *RedisContainer) UserClient(name string) *r.Client {
+	c.acl.mu.Lock()
+	defer c.acl.mu.Unlock()
+	return c.acl.clients[name]
+}
@@ -0,0 +1,262 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	r "github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisClusterImage is the image of CreateRedisCluster, hostname endpoints
+// need Redis 7
+const RedisClusterImage = "redis:7.2.5"
+
+// RedisCluster is a Redis cluster whose nodes run in a single container
+type RedisCluster struct {
+	testcontainers.Container
+	Client *r.ClusterClient
+	// Nodes are the addresses of the nodes reachable from the host
+	Nodes []string
+
+	password string
+}
+
+func (c *RedisCluster) Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error {
+	if c.Client != nil {
+		_ = c.Client.Close()
+	}
+	return terminate(ctx, c, c.Container, opts...)
+}
+
+// CreateRedisCluster starts a cluster of masters, at least 3, each with the
+// replicas of WithClusterReplicas, and returns once all slots are served.
+// RedisCluster.FailoverNode needs replicas.
+// Every node listens on the same port in the container and on the host and
+// announces the docker host, so the cluster client follows the redirections
+// from the host; the docker daemon must run on this host.
+func CreateRedisCluster(ctx context.Context, masters int, opts ...Option) (*RedisCluster, error) {
+	if masters < 3 {
+		return nil, fmt.Errorf("a redis cluster needs at least 3 masters, got %d", masters)
+	}
+	o := newOptions(opts...)
+	img := o.imageOr(RedisClusterImage)
+	if o.reuseName != "" {
+		o.logf("clusters can't be reused, starting a new one")
+		o.reuseName = ""
+	}
+	runner := newPhaseRunner(o)
+	if err := runner.pull(ctx, img); err != nil {
+		o.logf("failed to pull image: %v", err)
+		return nil, err
+	}
+
+	n := masters * (1 + o.redis.clusterReplicas)
+	ports := make([]string, n)
+	nodes := make([]string, n)
+	var (
+		c     testcontainers.Container
+		hc    *RedisCluster
+		ready bool
+	)
+	defer func() {
+		if ready {
+			return
+		}
+		if hc != nil && hc.Client != nil {
+			_ = hc.Client.Close()
+		}
+		o.abort(ctx, c)
+	}()
+	err := runner.run(ctx, PhaseStart, func(ctx context.Context) error {
+		host, err := daemonHost(ctx)
+		if err != nil {
+			return err
+		}
+		// the nodes announce their ports, so the host ports are the ones of
+		// the container and picked here; another process may take one before
+		// docker binds it, the ports are picked again then
+		for attempt := 1; ; attempt++ {
+			for i := range ports {
+				port, err := freePort()
+				if err != nil {
+					return err
+				}
+				ports[i] = strconv.Itoa(port)
+				nodes[i] = net.JoinHostPort(host, ports[i])
+			}
+			if c, err = startRedisCluster(ctx, o, runner, img, ports, host); err == nil || attempt == 3 || !isPortConflict(err) {
+				return err
+			}
+			o.logf("host port taken, picking others: %v", err)
+			_ = testcontainers.TerminateContainer(c, testcontainers.StopContext(context.WithoutCancel(ctx)))
+			c = nil
+		}
+	})
+	if err != nil {
+		o.logf("failed to start container: %v", err)
+		return nil, err
+	}
+	hc = &RedisCluster{Container: c, Nodes: nodes, password: o.password}
+	if err = runHooks(ctx, EventStart, hc); err != nil {
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseInitScripts, func(ctx context.Context) error {
+		// the nodes meet on the loopback of the container
+		cmd := []string{"redis-cli", "--cluster", "create"}
+		for _, port := range ports {
+			cmd = append(cmd, "127.0.0.1:"+port)
+		}
+		cmd = append(cmd, "--cluster-replicas", strconv.Itoa(o.redis.clusterReplicas), "--cluster-yes")
+		if o.password != "" {
+			cmd = append(cmd, "-a", o.password, "--no-auth-warning")
+		}
+		code, out, err := c.Exec(ctx, cmd, tcexec.Multiplexed())
+		if err != nil {
+			return fmt.Errorf("failed to create cluster: %w", err)
+		}
+		if code != 0 {
+			output, _ := io.ReadAll(out)
+			return fmt.Errorf("failed to create cluster, exit code %d: %s", code, output)
+		}
+		return nil
+	})
+	if err != nil {
+		o.logf("failed to create redis cluster: %v", err)
+		return nil, err
+	}
+
+	err = runner.run(ctx, PhaseClientConnect, func(ctx context.Context) error {
+		hc.Client = r.NewClusterClient(&r.ClusterOptions{Addrs: nodes, Password: o.password})
+		return hc.waitClusterOK(ctx)
+	})
+	if err != nil {
+		o.logf("Unable to connect to Redis cluster: %v", err)
+		return nil, err
+	}
+
+	if err = runHooks(ctx, EventReady, hc); err != nil {
+		return nil, err
+	}
+	ready = true
+	return hc, nil
+}
+
+// redisClusterScript returns the script starting a cluster node on each
+// port, announcing host as its endpoint
+func redisClusterScript(ports []string, host, password string) string {
+	var sb strings.Builder
+	for _, port := range ports {
+		args := []string{"redis-server", "--port", port, "--cluster-enabled", "yes",
+			"--cluster-config-file", "nodes-" + port + ".conf", "--cluster-node-timeout", strconv.FormatInt(redisClusterNodeTimeout.Milliseconds(), 10),
+			"--cluster-announce-hostname", host, "--cluster-preferred-endpoint-type", "hostname",
+			"--save", "''", "--appendonly", "no"}
+		if password != "" {
+			args = append(args, "--requirepass", shellQuote(password), "--masterauth", shellQuote(password))
+		}
+		sb.WriteString(strings.Join(args, " ") + " &\n")
+	}
+	sb.WriteString("wait\n")
+	return sb.String()
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// waitClusterOK waits until every node reports the cluster state ok
+func (c *RedisCluster) waitClusterOK(ctx context.Context) error {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		err := c.Client.ForEachShard(ctx, func(ctx context.Context, node *r.Client) error {
+			info, err := node.ClusterInfo(ctx).Result()
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(info, "cluster_state:ok") {
+				return fmt.Errorf("cluster state of %s is not ok", node.Options().Addr)
+			}
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the redis cluster: %w (%v)", ctx.Err(), err)
+		case <-ticker.C:
+			// the slots learned before the cluster was ready are stale
+			c.Client.ReloadState(ctx)
+		}
+	}
+}
+
+// startRedisCluster starts the container of the nodes on the ports
+func startRedisCluster(ctx context.Context, o *options, runner *phaseRunner, img string, ports []string, host string) (testcontainers.Container, error) {
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        img,
+			Entrypoint:   []string{"sh", "-c", redisClusterScript(ports, host, o.password)},
+			ExposedPorts: make([]string, len(ports)),
+			HostConfigModifier: func(hc *dockercontainer.HostConfig) {
+				hc.PortBindings = nat.PortMap{}
+				for _, port := range ports {
+					hc.PortBindings[nat.Port(port+"/tcp")] = []nat.PortBinding{{HostPort: port}}
+				}
+			},
+			WaitingFor: wait.ForLog("Ready to accept connections").WithOccurrence(len(ports)).WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	}
+	for i, port := range ports {
+		req.ExposedPorts[i] = port + "/tcp"
+	}
+	customizers, err := o.containerCustomizers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, customizer := range append(customizers, runner.customizer()) {
+		if err = customizer.Customize(&req); err != nil {
+			return nil, err
+		}
+	}
+	return testcontainers.GenericContainer(ctx, req)
+}
+
+// isPortConflict reports whether docker failed to bind a host port
+func isPortConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "port is already allocated") || strings.Contains(msg, "address already in use")
+}
+
+// daemonHost returns the host the ports published by the docker daemon are reachable on
+func daemonHost(ctx context.Context) (string, error) {
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return "", fmt.Errorf("%w\n%s", err, Doctor(ctx))
+	}
+	defer func() { _ = provider.Close() }()
+	return provider.DaemonHost(ctx)
+}
+
+// freePort returns a port free on this host
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,285 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisClusterNodeTimeout is the cluster-node-timeout of the nodes, a node
+// unreachable for longer is failed over
+const redisClusterNodeTimeout = 5 * time.Second
+
+// RedisClusterNode is a node of the cluster as listed by CLUSTER NODES
+type RedisClusterNode struct {
+	ID string
+	// Port is the port of the node, the same in the container and on the host
+	Port   string
+	Master bool
+	// MasterID is the master of a replica
+	MasterID string
+	// Slots is the number of slots a master serves
+	Slots int
+	// Failed reports whether the cluster considers the node failed
+	Failed bool
+}
+
+// ClusterNodes returns the nodes of the cluster, e.g. to pick the ids of
+// FailoverNode, ReshardSlots and PauseNode
+func (c *RedisCluster) ClusterNodes(ctx context.Context) ([]RedisClusterNode, error) {
+	out, err := c.Client.ClusterNodes(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	return parseClusterNodes(out), nil
+}
+
+// parseClusterNodes parses the lines of CLUSTER NODES, e.g.
+// "<id> 127.0.0.1:7000@17000,host myself,master - 0 0 1 connected 0-5460"
+func parseClusterNodes(out string) []RedisClusterNode {
+	var nodes []RedisClusterNode
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		addr, _, _ := strings.Cut(fields[1], "@")
+		_, port, _ := strings.Cut(addr, ":")
+		flags := "," + fields[2] + ","
+		node := RedisClusterNode{
+			ID:     fields[0],
+			Port:   port,
+			Master: strings.Contains(flags, ",master,"),
+			Failed: strings.Contains(flags, ",fail,") || strings.Contains(flags, ",fail?,"),
+		}
+		if fields[3] != "-" {
+			node.MasterID = fields[3]
+		}
+		for _, slots := range fields[8:] {
+			// [slot->-id] and [slot-<-id] are migrating and importing slots
+			if strings.HasPrefix(slots, "[") {
+				continue
+			}
+			from, to, ok := strings.Cut(slots, "-")
+			if !ok {
+				node.Slots++
+				continue
+			}
+			first, _ := strconv.Atoi(from)
+			last, _ := strconv.Atoi(to)
+			node.Slots += last - first + 1
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func findClusterNode(nodes []RedisClusterNode, id string) (RedisClusterNode, error) {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n, nil
+		}
+	}
+	return RedisClusterNode{}, fmt.Errorf("no cluster node %s", id)
+}
+
+// nodeClient connects to the node of the port from the host
+func (c *RedisCluster) nodeClient(port string) (*r.Client, error) {
+	for _, addr := range c.Nodes {
+		if strings.HasSuffix(addr, ":"+port) {
+			return r.NewClient(&r.Options{Addr: addr, Password: c.password}), nil
+		}
+	}
+	return nil, fmt.Errorf("no cluster node on port %s", port)
+}
+
+// FailoverNode promotes a replica of the master id, or the replica id
+// itself, to master and waits until the cluster serves all slots again, so
+// the clients get MOVED redirections to the new master. A failed master,
+// e.g. paused by PauseNode, is failed over without its agreement. The
+// master needs a replica, see WithClusterReplicas.
+func (c *RedisCluster) FailoverNode(ctx context.Context, id string) error {
+	nodes, err := c.ClusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := findClusterNode(nodes, id)
+	if err != nil {
+		return err
+	}
+	replica, master := target, RedisClusterNode{}
+	if target.Master {
+		master = target
+		replica = RedisClusterNode{}
+		for _, n := range nodes {
+			if n.MasterID == id && !n.Failed {
+				replica = n
+				break
+			}
+		}
+		if replica.ID == "" {
+			return fmt.Errorf("master %s has no replica to fail over to, see WithClusterReplicas", id)
+		}
+	} else if master, err = findClusterNode(nodes, target.MasterID); err != nil {
+		return err
+	}
+
+	cli, err := c.nodeClient(replica.Port)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cli.Close() }()
+	cmd := []any{"CLUSTER", "FAILOVER"}
+	if master.Failed {
+		cmd = append(cmd, "FORCE")
+	}
+	if err = cli.Do(ctx, cmd...).Err(); err != nil {
+		return fmt.Errorf("failed to fail over to %s: %w", replica.ID, err)
+	}
+
+	err = c.waitClusterNodes(ctx, func(nodes []RedisClusterNode) error {
+		n, err := findClusterNode(nodes, replica.ID)
+		if err != nil {
+			return err
+		}
+		if !n.Master {
+			return fmt.Errorf("replica %s is not promoted yet", replica.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.waitClusterOK(ctx)
+}
+
+// ReshardSlots moves n slots and their keys from the master from to the
+// master to, so the clients get ASK redirections while the slots migrate
+// and MOVED ones after. It returns once all slots are served again.
+func (c *RedisCluster) ReshardSlots(ctx context.Context, from, to string, n int) error {
+	nodes, err := c.ClusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	source, err := findClusterNode(nodes, from)
+	if err != nil {
+		return err
+	}
+	target, err := findClusterNode(nodes, to)
+	if err != nil {
+		return err
+	}
+	if !source.Master || !target.Master {
+		return fmt.Errorf("slots move between masters, %s and %s aren't both masters", from, to)
+	}
+	if n < 1 || n > source.Slots {
+		return fmt.Errorf("can't move %d slots, master %s serves %d", n, from, source.Slots)
+	}
+
+	cmd := []string{"redis-cli", "--cluster", "reshard", "127.0.0.1:" + source.Port,
+		"--cluster-from", from, "--cluster-to", to, "--cluster-slots", strconv.Itoa(n), "--cluster-yes"}
+	if c.password != "" {
+		cmd = append(cmd, "-a", c.password, "--no-auth-warning")
+	}
+	if err = c.execCluster(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to reshard %d slots from %s to %s: %w", n, from, to, err)
+	}
+	return c.waitClusterOK(ctx)
+}
+
+// PauseNode freezes the process of the node id for d, like a long GC pause
+// or a stalled host: it neither answers the clients nor the cluster bus.
+// It blocks until the node resumes, run the workload concurrently. A pause
+// longer than the node timeout of 5s makes the cluster fail a master over
+// to one of its replicas.
+func (c *RedisCluster) PauseNode(ctx context.Context, id string, d time.Duration) error {
+	nodes, err := c.ClusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	node, err := findClusterNode(nodes, id)
+	if err != nil {
+		return err
+	}
+	cli, err := c.nodeClient(node.Port)
+	if err != nil {
+		return err
+	}
+	info, err := cli.Info(ctx, "server").Result()
+	_ = cli.Close()
+	if err != nil {
+		return fmt.Errorf("failed to get the process of %s: %w", id, err)
+	}
+	pid := infoField(info, "process_id")
+	if pid == "" {
+		return fmt.Errorf("no process_id in the info of %s", id)
+	}
+
+	if err = c.execCluster(ctx, []string{"kill", "-STOP", pid}); err != nil {
+		return fmt.Errorf("failed to pause %s: %w", id, err)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	// resume even if the context is done, a frozen node breaks the cluster
+	if err = c.execCluster(context.WithoutCancel(ctx), []string{"kill", "-CONT", pid}); err != nil {
+		return fmt.Errorf("failed to resume %s: %w", id, err)
+	}
+	return ctx.Err()
+}
+
+// infoField returns the value of the field of an INFO reply
+func infoField(info, field string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), field+":"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// execCluster runs the command in the container, it fails with the output
+// unless the command exits with code 0
+func (c *RedisCluster) execCluster(ctx context.Context, cmd []string) error {
+	code, out, err := c.Exec(ctx, cmd, tcexec.Multiplexed())
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		output, _ := io.ReadAll(out)
+		return fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// waitClusterNodes polls the cluster nodes until check passes, for up to
+// a few node timeouts
+func (c *RedisCluster) waitClusterNodes(ctx context.Context, check func([]RedisClusterNode) error) error {
+	timeout := 6 * redisClusterNodeTimeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		nodes, err := c.ClusterNodes(ctx)
+		if err == nil {
+			if err = check(nodes); err == nil {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cluster not ready within %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
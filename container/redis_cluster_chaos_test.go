@@ -0,0 +1,35 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClusterNodes(t *testing.T) {
+	out := `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@40004,host replica e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@40002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@40003 master - 0 1426238318243 3 connected 10923-16383 [10924->-67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1]
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@40005 slave,fail 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 disconnected
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@40001 myself,master - 0 0 1 connected 0-5459 5460 [5461-<-292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f]
+a1b2c3 127.0.0.1:30006@40006 master,fail? - 0 0 6 connected
+truncated line
+`
+	want := []RedisClusterNode{
+		{ID: "07c37dfeb235213a872192d90877d0cd55635b91", Port: "30004", MasterID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"},
+		{ID: "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1", Port: "30002", Master: true, Slots: 5462},
+		{ID: "292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f", Port: "30003", Master: true, Slots: 5461},
+		{ID: "6ec23923021cf3ffec47632106199cb7f496ce01", Port: "30005", MasterID: "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1", Failed: true},
+		{ID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", Port: "30001", Master: true, Slots: 5461},
+		{ID: "a1b2c3", Port: "30006", Master: true, Failed: true},
+	}
+	got := parseClusterNodes(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseClusterNodes() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseClusterNodesEmpty(t *testing.T) {
+	if got := parseClusterNodes(""); len(got) != 0 {
+		t.Errorf("parseClusterNodes(\"\") = %+v, want none", got)
+	}
+}
@@ -0,0 +1,35 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	"strconv"
+	"strings"
+)
+
+// CollectKeyEvents collects the keyspace notifications of the keys matching
+// the glob pattern in the database of the client, e.g. "session:*", so
+// workflows driven by expiries or evictions can be asserted end to end. The
+// channel of a message is "__keyspace@<db>__:<key>" and its payload the
+// event, e.g. "expired", see KeyEvent. The server only notifies the events
+// of WithKeyspaceNotifications or of the config file.
+func (c *RedisContainer) CollectKeyEvents(ctx context.Context, pattern string) (*MessageCollector, error) {
+	events, err := c.RedisCli.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notify-keyspace-events: %w", err)
+	}
+	// K is the keyspace class, A or any other class selects the events
+	if flags := events["notify-keyspace-events"]; !strings.Contains(flags, "K") || flags == "K" {
+		return nil, fmt.Errorf("keyspace notifications are disabled (notify-keyspace-events is '%s'), see WithKeyspaceNotifications", flags)
+	}
+	channel := "__keyspace@" + strconv.Itoa(c.RedisCli.Options().DB) + "__:" + pattern
+	return collectPubSub(ctx, c.RedisCli.PSubscribe(ctx, channel), channel)
+}
+
+// KeyEvent returns the key and the event, e.g. "expired", of a message of
+// CollectKeyEvents
+func KeyEvent(msg *r.Message) (key, event string) {
+	_, key, _ = strings.Cut(msg.Channel, "__:")
+	return key, msg.Payload
+}
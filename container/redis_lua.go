@@ -0,0 +1,133 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// luaScriptSource is a set of scripts added by WithLuaScripts
+type luaScriptSource struct {
+	fsys fs.FS
+	glob string
+}
+
+// WithLuaScripts loads the Lua scripts of fsys matching glob with SCRIPT LOAD
+// once the Redis container is ready, so code calling EVALSHA runs unchanged.
+// A script is named after its file without extension, e.g. "rate_limit" for
+// "scripts/rate_limit.lua", get its SHA with RedisContainer.ScriptSHA.
+func WithLuaScripts(fsys fs.FS, glob string) Option {
+	return func(o *options) {
+		o.luaScripts = append(o.luaScripts, luaScriptSource{fsys: fsys, glob: glob})
+	}
+}
+
+// luaScripts holds the scripts loaded into a RedisContainer
+type luaScripts struct {
+	mu     sync.Mutex
+	bodies map[string]string
+	shas   map[string]string
+}
+
+// LoadScripts loads the Lua scripts of fsys matching glob with SCRIPT LOAD,
+// replacing scripts of the same name.
+func (c *RedisContainer) LoadScripts(ctx context.Context, fsys fs.FS, glob string) error {
+	files, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return fmt.Errorf("invalid lua script glob '%s': %w", glob, err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no lua script matches '%s'", glob)
+	}
+
+	for _, file := range files {
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read lua script '%s': %w", file, err)
+		}
+		name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+		if err = c.loadScript(ctx, name, string(body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisContainer) loadScript(ctx context.Context, name, body string) error {
+	sha, err := c.RedisCli.ScriptLoad(ctx, body).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load lua script '%s': %w", name, err)
+	}
+
+	c.scripts.mu.Lock()
+	defer c.scripts.mu.Unlock()
+	if c.scripts.shas == nil {
+		c.scripts.bodies = make(map[string]string)
+		c.scripts.shas = make(map[string]string)
+	}
+	c.scripts.bodies[name] = body
+	c.scripts.shas[name] = sha
+	return nil
+}
+
+// ScriptSHA returns the SHA of a loaded script by name, false if there is none
+func (c *RedisContainer) ScriptSHA(name string) (string, bool) {
+	c.scripts.mu.Lock()
+	defer c.scripts.mu.Unlock()
+	sha, ok := c.scripts.shas[name]
+	return sha, ok
+}
+
+// ScriptSHAs returns the SHAs of all loaded scripts keyed by name
+func (c *RedisContainer) ScriptSHAs() map[string]string {
+	c.scripts.mu.Lock()
+	defer c.scripts.mu.Unlock()
+	shas := make(map[string]string, len(c.scripts.shas))
+	for name, sha := range c.scripts.shas {
+		shas[name] = sha
+	}
+	return shas
+}
+
+// Script returns a go-redis script of a loaded script by name, nil if there
+// is none. Its Run falls back from EVALSHA to EVAL on NOSCRIPT.
+func (c *RedisContainer) Script(name string) *r.Script {
+	c.scripts.mu.Lock()
+	defer c.scripts.mu.Unlock()
+	body, ok := c.scripts.bodies[name]
+	if !ok {
+		return nil
+	}
+	return r.NewScript(body)
+}
+
+// FlushScripts removes all scripts from the server's script cache, so
+// EVALSHA fails with NOSCRIPT like after a restart or failover. The SHAs
+// stay known, ReloadScripts loads the scripts again.
+func (c *RedisContainer) FlushScripts(ctx context.Context) error {
+	if err := c.RedisCli.ScriptFlush(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to flush lua scripts: %w", err)
+	}
+	return nil
+}
+
+// ReloadScripts loads all known scripts again, e.g. after FlushScripts
+func (c *RedisContainer) ReloadScripts(ctx context.Context) error {
+	c.scripts.mu.Lock()
+	bodies := make(map[string]string, len(c.scripts.bodies))
+	for name, body := range c.scripts.bodies {
+		bodies[name] = body
+	}
+	c.scripts.mu.Unlock()
+
+	for name, body := range bodies {
+		if err := c.loadScript(ctx, name, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
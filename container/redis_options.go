@@ -0,0 +1,104 @@
+package container
+
+import (
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/redis"
+	"strings"
+)
+
+// RedisStackImage is the Redis Stack image of WithRedisStack, bundling the
+// JSON, Search, TimeSeries and Bloom modules
+const RedisStackImage = "redis/redis-stack-server:7.4.0-v1"
+
+// redisOptions holds the image and cluster settings of the Redis helpers
+type redisOptions struct {
+	clusterReplicas int
+
+	protocol         int
+	tracking         bool
+	trackingPrefixes []string
+
+	keyspaceEvents string
+}
+
+// WithRedisStack runs Redis Stack of the version instead of Redis, e.g. to
+// test JSON.SET or FT.SEARCH, RedisStackImage if version is empty
+func WithRedisStack(version string) Option {
+	return func(o *options) {
+		o.image = RedisStackImage
+		if version != "" {
+			o.image = "redis/redis-stack-server:" + version
+		}
+	}
+}
+
+// WithClusterReplicas gives every master of CreateRedisCluster n replicas, none by default
+func WithClusterReplicas(n int) Option {
+	return func(o *options) {
+		o.redis.clusterReplicas = n
+	}
+}
+
+// WithRedisProtocol sets the RESP version the client of the Redis helper
+// speaks, 2 or 3. go-redis speaks RESP3 by default, and falls back to RESP2
+// against servers older than Redis 6; 2 forces RESP2, e.g. to test the code
+// against the replies of proxies or servers that don't speak RESP3.
+func WithRedisProtocol(protocol int) Option {
+	return func(o *options) {
+		o.redis.protocol = protocol
+	}
+}
+
+// WithClientTracking turns on client side caching tracking on every
+// connection of the client of the Redis helper, so the server notifies it when
+// a key it read is modified; assert the invalidations with
+// RedisContainer.AssertInvalidated. With prefixes, the broadcasting mode
+// notifies the modifications of all keys of the prefixes instead.
+func WithClientTracking(prefixes ...string) Option {
+	return func(o *options) {
+		o.redis.tracking = true
+		o.redis.trackingPrefixes = prefixes
+	}
+}
+
+// WithKeyspaceNotifications sets notify-keyspace-events of the Redis helper
+// to events, e.g. "Kx" for the expired events only, "KEA" for all the events
+// if empty, so RedisContainer.CollectKeyEvents receives them
+func WithKeyspaceNotifications(events string) Option {
+	return func(o *options) {
+		o.redis.keyspaceEvents = orDefault(events, "KEA")
+	}
+}
+
+// isRedisStack reports whether the image is a Redis Stack one
+func isRedisStack(img string) bool {
+	return strings.Contains(img, "redis-stack")
+}
+
+// redisModuleOpts returns the customizers of the config file and password of
+// WithConfigFile and WithCredentials. The entrypoint of Redis Stack ignores
+// the command, it takes the server arguments from REDIS_ARGS.
+func (o *options) redisModuleOpts(img string) []testcontainers.ContainerCustomizer {
+	var opts []testcontainers.ContainerCustomizer
+	if !isRedisStack(img) {
+		if o.configFile != "" {
+			opts = append(opts, redis.WithConfigFile(o.configFile))
+		}
+		if o.password != "" {
+			opts = append(opts, testcontainers.WithCmdArgs("--requirepass", o.password))
+		}
+		return opts
+	}
+
+	if o.configFile != "" {
+		opts = append(opts, testcontainers.WithFiles(testcontainers.ContainerFile{
+			HostFilePath:      o.configFile,
+			ContainerFilePath: "/redis-stack.conf",
+			FileMode:          0o644,
+		}))
+	}
+	if o.password != "" {
+		opts = append(opts, testcontainers.WithEnv(map[string]string{"REDIS_ARGS": "--requirepass " + o.password}))
+	}
+	return opts
+}
@@ -0,0 +1,108 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// MessageCollector subscribes to a redis channel and collects every message
+// published to it, so tests can assert on pub/sub traffic directly.
+type MessageCollector struct {
+	pubsub *r.PubSub
+	mu     sync.Mutex
+	msgs   []*r.Message
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// SubscribeCollect subscribes to the given channel and starts collecting messages.
+// The subscription is removed when ctx is done or Close is called.
+func (c *RedisContainer) SubscribeCollect(ctx context.Context, channel string) (*MessageCollector, error) {
+	return collectPubSub(ctx, c.RedisCli.Subscribe(ctx, channel), channel)
+}
+
+// collectPubSub collects the messages of the subscription until ctx is done
+// or the collector is closed
+func collectPubSub(ctx context.Context, pubsub *r.PubSub, channel string) (*MessageCollector, error) {
+	// Wait for the subscription confirmation, otherwise messages published
+	// right after this call may be lost.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe channel '%s': %w", channel, err)
+	}
+
+	mc := &MessageCollector{
+		pubsub: pubsub,
+		msgs:   make([]*r.Message, 0),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	go mc.collect(pubsub.Channel())
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = mc.Close()
+		case <-mc.done:
+		}
+	}()
+
+	return mc, nil
+}
+
+func (mc *MessageCollector) collect(ch <-chan *r.Message) {
+	for msg := range ch {
+		mc.mu.Lock()
+		mc.msgs = append(mc.msgs, msg)
+		mc.mu.Unlock()
+
+		select {
+		case mc.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Messages returns a snapshot of the messages collected so far.
+func (mc *MessageCollector) Messages() []*r.Message {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	msgs := make([]*r.Message, len(mc.msgs))
+	copy(msgs, mc.msgs)
+	return msgs
+}
+
+// WaitForN blocks until at least n messages are collected or the timeout expires.
+func (mc *MessageCollector) WaitForN(n int, timeout time.Duration) ([]*r.Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		msgs := mc.Messages()
+		if len(msgs) >= n {
+			return msgs, nil
+		}
+
+		select {
+		case <-mc.notify:
+		case <-mc.done:
+			return msgs, fmt.Errorf("collector closed, got %d of %d messages", len(msgs), n)
+		case <-timer.C:
+			return msgs, fmt.Errorf("timeout after %s waiting for messages, got %d of %d", timeout, len(msgs), n)
+		}
+	}
+}
+
+// Close unsubscribes from the channel and stops collecting messages.
+func (mc *MessageCollector) Close() error {
+	var err error
+	mc.once.Do(func() {
+		close(mc.done)
+		err = mc.pubsub.Close()
+	})
+	return err
+}
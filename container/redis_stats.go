@@ -0,0 +1,184 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// RedisStats holds the parsed output of INFO ALL, keyed by lower-cased
+// section name ("memory", "keyspace", "commandstats", ...) and then by field.
+type RedisStats struct {
+	Sections map[string]map[string]string
+}
+
+// KeyspaceStats is a parsed line of the keyspace section, e.g.
+// "db0:keys=1,expires=0,avg_ttl=0".
+type KeyspaceStats struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  int64
+}
+
+// CommandStats is a parsed line of the commandstats section, e.g.
+// "cmdstat_get:calls=2,usec=15,usec_per_call=7.50".
+type CommandStats struct {
+	Calls       int64
+	Usec        int64
+	UsecPerCall float64
+}
+
+// Stats runs INFO ALL and parses all of its sections.
+func (c *RedisContainer) Stats(ctx context.Context) (*RedisStats, error) {
+	info, err := c.RedisCli.Info(ctx, "all").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redis info: %w", err)
+	}
+	return parseRedisInfo(info), nil
+}
+
+// Get returns the field of the section, or "" if it does not exist.
+func (s *RedisStats) Get(section, field string) string {
+	return s.Sections[strings.ToLower(section)][field]
+}
+
+// Int returns the field of the section as an integer.
+func (s *RedisStats) Int(section, field string) (int64, error) {
+	v := s.Get(section, field)
+	if v == "" {
+		return 0, fmt.Errorf("redis info field '%s.%s' not found", section, field)
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("redis info field '%s.%s' is not an integer: %w", section, field, err)
+	}
+	return n, nil
+}
+
+// UsedMemory returns the used_memory field of the memory section in bytes.
+func (s *RedisStats) UsedMemory() (uint64, error) {
+	n, err := s.Int("memory", "used_memory")
+	return uint64(n), err
+}
+
+// EvictedKeys returns the evicted_keys field of the stats section.
+func (s *RedisStats) EvictedKeys() (int64, error) {
+	return s.Int("stats", "evicted_keys")
+}
+
+// Keyspace returns the keyspace stats of the logical database db,
+// a database without any key is reported with zero values.
+func (s *RedisStats) Keyspace(db int) KeyspaceStats {
+	var ks KeyspaceStats
+	for k, v := range parseInfoFields(s.Get("keyspace", "db"+strconv.Itoa(db))) {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		switch k {
+		case "keys":
+			ks.Keys = n
+		case "expires":
+			ks.Expires = n
+		case "avg_ttl":
+			ks.AvgTTL = n
+		}
+	}
+	return ks
+}
+
+// Command returns the stats of the command (e.g. "get"), and false if the
+// command has not been called since the stats were last reset.
+func (s *RedisStats) Command(name string) (CommandStats, bool) {
+	v := s.Get("commandstats", "cmdstat_"+strings.ToLower(name))
+	if v == "" {
+		return CommandStats{}, false
+	}
+	var cs CommandStats
+	for k, v := range parseInfoFields(v) {
+		switch k {
+		case "calls":
+			cs.Calls, _ = strconv.ParseInt(v, 10, 64)
+		case "usec":
+			cs.Usec, _ = strconv.ParseInt(v, 10, 64)
+		case "usec_per_call":
+			cs.UsecPerCall, _ = strconv.ParseFloat(v, 64)
+		}
+	}
+	return cs, true
+}
+
+// AssertMaxMemoryUnder asserts that the used memory of redis is under the given bytes.
+func (c *RedisContainer) AssertMaxMemoryUnder(t testing.TB, bytes uint64) bool {
+	t.Helper()
+
+	stats, err := c.Stats(context.Background())
+	if err != nil {
+		t.Errorf("failed to get redis stats: %v", err)
+		return false
+	}
+	used, err := stats.UsedMemory()
+	if err != nil {
+		t.Errorf("failed to get redis used memory: %v", err)
+		return false
+	}
+	if used >= bytes {
+		t.Errorf("redis used memory is %d bytes (%s), want under %d bytes",
+			used, stats.Get("memory", "used_memory_human"), bytes)
+		return false
+	}
+	return true
+}
+
+// AssertKeyCount asserts that the logical database db holds exactly n keys.
+func (c *RedisContainer) AssertKeyCount(t testing.TB, db int, n int64) bool {
+	t.Helper()
+
+	stats, err := c.Stats(context.Background())
+	if err != nil {
+		t.Errorf("failed to get redis stats: %v", err)
+		return false
+	}
+	if keys := stats.Keyspace(db).Keys; keys != n {
+		t.Errorf("redis db%d has %d keys, want %d", db, keys, n)
+		return false
+	}
+	return true
+}
+
+// parseRedisInfo parses the "# Section" headed "field:value" lines of INFO.
+func parseRedisInfo(info string) *RedisStats {
+	stats := &RedisStats{Sections: make(map[string]map[string]string)}
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			section = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if stats.Sections[section] == nil {
+			stats.Sections[section] = make(map[string]string)
+		}
+		stats.Sections[section][k] = v
+	}
+	return stats
+}
+
+// parseInfoFields parses "k1=v1,k2=v2" values of INFO.
+func parseInfoFields(v string) map[string]string {
+	fields := make(map[string]string)
+	for _, kv := range strings.Split(v, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
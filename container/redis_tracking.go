@@ -0,0 +1,163 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	r "github.com/redis/go-redis/v9"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// invalidateChannel is the channel the server publishes invalidations to on
+// the connection tracking is redirected to
+const invalidateChannel = "__redis__:invalidate"
+
+// redisTracking receives the invalidations of the connections of the client
+// of the Redis helper, which redirect them to its connection
+type redisTracking struct {
+	client        *r.Client
+	invalidations *MessageCollector
+}
+
+// newRedisTracking subscribes a connection to the invalidations and makes
+// opts, the options of the client of the helper, redirect the tracking of
+// every connection to it
+func newRedisTracking(ctx context.Context, opts *r.Options, prefixes []string) (*redisTracking, error) {
+	// a RESP3 connection gets invalidate pushes, which PubSub doesn't read,
+	// a RESP2 one gets them as messages of the invalidate channel
+	subOpts := *opts
+	subOpts.Protocol = 2
+	subOpts.PoolSize = 1
+	var id atomic.Int64
+	subOpts.OnConnect = func(ctx context.Context, cn *r.Conn) error {
+		clientID, err := cn.ClientID(ctx).Result()
+		id.Store(clientID)
+		return err
+	}
+
+	t := &redisTracking{client: r.NewClient(&subOpts)}
+	var err error
+	t.invalidations, err = collectPubSub(context.WithoutCancel(ctx), t.client.Subscribe(ctx, invalidateChannel), invalidateChannel)
+	if err != nil {
+		_ = t.client.Close()
+		return nil, err
+	}
+
+	var bcast []any
+	if len(prefixes) > 0 {
+		bcast = append(bcast, "BCAST")
+		for _, prefix := range prefixes {
+			bcast = append(bcast, "PREFIX", prefix)
+		}
+	}
+	onConnect := opts.OnConnect
+	opts.OnConnect = func(ctx context.Context, cn *r.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		// the subscribed connection gets a new id when it reconnects
+		args := append([]any{"CLIENT", "TRACKING", "ON", "REDIRECT", id.Load()}, bcast...)
+		if err := cn.Do(ctx, args...).Err(); err != nil {
+			return fmt.Errorf("failed to turn on client tracking: %w", err)
+		}
+		return nil
+	}
+	return t, nil
+}
+
+func (t *redisTracking) close() error {
+	_ = t.invalidations.Close()
+	return t.client.Close()
+}
+
+// Invalidations returns the collector of the invalidation messages of
+// WithClientTracking, one per notification, whose PayloadSlice holds the
+// invalidated keys. A FLUSHALL invalidates all keys with an empty payload.
+// It is nil without WithClientTracking.
+func (c *RedisContainer) Invalidations() *MessageCollector {
+	if c.tracking == nil {
+		return nil
+	}
+	return c.tracking.invalidations
+}
+
+// WaitInvalidated waits until the server notified the invalidation of every
+// key, or of all keys by a flush
+func (c *RedisContainer) WaitInvalidated(keys []string, timeout time.Duration) error {
+	if c.tracking == nil {
+		return fmt.Errorf("client tracking is off, create the container WithClientTracking")
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		missing := c.notInvalidated(keys)
+		if len(missing) == 0 {
+			return nil
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return fmt.Errorf("keys %v not invalidated within %s", missing, timeout)
+		}
+		// wait for one more message than collected so far
+		n := len(c.tracking.invalidations.Messages()) + 1
+		if _, err := c.tracking.invalidations.WaitForN(n, left); err != nil {
+			if missing = c.notInvalidated(keys); len(missing) > 0 {
+				return fmt.Errorf("keys %v not invalidated within %s", missing, timeout)
+			}
+			return nil
+		}
+	}
+}
+
+// notInvalidated returns the keys no invalidation message names so far
+func (c *RedisContainer) notInvalidated(keys []string) []string {
+	invalidated := make(map[string]bool)
+	for _, msg := range c.tracking.invalidations.Messages() {
+		if len(msg.PayloadSlice) == 0 && msg.Payload == "" {
+			// flushed
+			return nil
+		}
+		for _, key := range msg.PayloadSlice {
+			invalidated[key] = true
+		}
+	}
+	var missing []string
+	for _, key := range keys {
+		if !invalidated[key] {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// AssertInvalidated asserts the server notifies the invalidation of the keys within the timeout
+func (c *RedisContainer) AssertInvalidated(t testing.TB, timeout time.Duration, keys ...string) bool {
+	t.Helper()
+	if err := c.WaitInvalidated(keys, timeout); err != nil {
+		t.Errorf("invalidation: %v", err)
+		return false
+	}
+	return true
+}
+
+// AssertNotInvalidated asserts the server notifies no invalidation of the
+// keys during the wait, e.g. for keys the client never read
+func (c *RedisContainer) AssertNotInvalidated(t testing.TB, wait time.Duration, keys ...string) bool {
+	t.Helper()
+	if c.tracking == nil {
+		t.Errorf("client tracking is off, create the container WithClientTracking")
+		return false
+	}
+	time.Sleep(wait)
+	missing := c.notInvalidated(keys)
+	for _, key := range keys {
+		if !slices.Contains(missing, key) {
+			t.Errorf("key '%s' was invalidated", key)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/testcontainers/testcontainers-go"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceReport is the setup result of a service of an Environment, for CI
+// dashboards to track the flakiness of the infrastructure apart from the
+// failures of the tests
+type ServiceReport struct {
+	Service Service `json:"service"`
+	// Image is the image the container runs, empty if it failed to start
+	Image string `json:"image,omitempty"`
+	// Digest is the repo digest of the image, its ID for local images
+	Digest   string        `json:"digest,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Reused is set when the container of WithReuse already existed
+	Reused bool `json:"reused"`
+	// Error is the failure of the start, empty on success
+	Error string `json:"error,omitempty"`
+}
+
+// WithReportFile writes the setup report of the services, see Report, to
+// path after every start, whether it succeeds or fails, so the report of a
+// failed NewEnvironment is written too. A path ending with .xml gets a JUnit
+// report with a test case per service, any other a JSON array. A failed
+// write is logged to stderr and doesn't fail the environment.
+func WithReportFile(path string) EnvOption {
+	return func(o *envOptions) {
+		o.reportPath = path
+	}
+}
+
+// Report returns the setup results of the services started so far, in
+// start order, failed starts included
+func (e *Environment) Report() []ServiceReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ServiceReport(nil), e.reports...)
+}
+
+// WriteReportJSON writes the report of Report as a JSON array
+func (e *Environment) WriteReportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e.Report())
+}
+
+// junitSuite is the JUnit XML of a report
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteReportJUnit writes the report of Report as a JUnit test suite named
+// "mtest infrastructure", a test case per service failing when it failed to
+// start, with its image, digest and whether it was reused as output
+func (e *Environment) WriteReportJUnit(w io.Writer) error {
+	suite := junitSuite{Name: "mtest infrastructure"}
+	for _, r := range e.Report() {
+		c := junitCase{
+			Name:      string(r.Service),
+			Classname: "infrastructure",
+			Time:      r.Duration.Seconds(),
+			SystemOut: fmt.Sprintf("image=%s digest=%s reused=%t", r.Image, r.Digest, r.Reused),
+		}
+		if r.Error != "" {
+			c.Failure = &junitFailure{Message: firstLine(r.Error), Text: r.Error}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Time += c.Time
+		suite.Cases = append(suite.Cases, c)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// record adds the result of a start to the report and rewrites the file of
+// WithReportFile. A failed write is logged, the report must not fail the
+// services.
+func (e *Environment) record(r ServiceReport) {
+	e.mu.Lock()
+	e.reports = append(e.reports, r)
+	e.mu.Unlock()
+	if e.reportPath == "" {
+		return
+	}
+	if err := e.writeReportFile(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// writeReportFile writes the report to the file of WithReportFile
+func (e *Environment) writeReportFile() error {
+	// the starts run in parallel, the last write holds all of them
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+	f, err := os.Create(e.reportPath)
+	if err != nil {
+		return fmt.Errorf("failed to create report file '%s': %w", e.reportPath, err)
+	}
+	if strings.EqualFold(filepath.Ext(e.reportPath), ".xml") {
+		err = e.WriteReportJUnit(f)
+	} else {
+		err = e.WriteReportJSON(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report file '%s': %w", e.reportPath, err)
+	}
+	return nil
+}
+
+// describeService fills the image, digest and reuse of the report from the
+// container of the handle, started at start
+func describeService(ctx context.Context, h envHandle, start time.Time, r *ServiceReport) {
+	c, ok := h.(testcontainers.Container)
+	if !ok {
+		return
+	}
+	inspect, err := c.Inspect(ctx)
+	if err != nil || inspect.Config == nil {
+		return
+	}
+	r.Image = inspect.Config.Image
+	r.Digest = inspect.Image
+	if created, err := time.Parse(time.RFC3339Nano, inspect.Created); err == nil {
+		r.Reused = isReused(ctx, c) && created.Before(start)
+	}
+
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return
+	}
+	defer func() { _ = provider.Close() }()
+	if img, err := provider.Client().ImageInspect(ctx, inspect.Image); err == nil && len(img.RepoDigests) > 0 {
+		r.Digest = img.RepoDigests[0]
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,223 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvReuse set to 0, false or off disables WithReuse, e.g. for a CI job
+// that wants fresh containers
+const EnvReuse = "MTEST_REUSE"
+
+// reuseLabel is the label of the containers started with WithReuse
+const reuseLabel = "mtest.reuse"
+
+// WithReuse keeps the container running after Terminate and reuses it, by
+// name and image, in the next helpers and go test invocations created with
+// the same name and options. The state of a reused container is reset to
+// the one of a fresh container when it is acquired: the MySQL and Doris
+// database is recreated and the MongoDB databases are dropped, then the init
+// scripts and migrations run again; Redis is flushed and other services are
+// not reset. Packages run in parallel by go test share the container, give them distinct names or run
+// them with -p 1. Reused containers survive the session only when Ryuk is
+// disabled with TESTCONTAINERS_RYUK_DISABLED=true; remove them with docker rm.
+func WithReuse(name string) Option {
+	return func(o *options) {
+		o.reuseName = name
+	}
+}
+
+// reuseEnabled reports whether the container is reused, see WithReuse
+func (o *options) reuseEnabled() bool {
+	if o.reuseName == "" {
+		return false
+	}
+	switch strings.ToLower(os.Getenv(EnvReuse)) {
+	case "0", "false", "off":
+		return false
+	}
+	return true
+}
+
+// reuseCustomizer names and labels the container of WithReuse, and records
+// in o.reused whether it already exists
+func (o *options) reuseCustomizer() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		// the image tells apart the containers of the helpers sharing the options
+		base := req.Image[strings.LastIndex(req.Image, "/")+1:]
+		base, _, _ = strings.Cut(base, ":")
+		name := "mtest-" + o.reuseName + "-" + base
+		req.Reuse = true
+		req.Name = name
+		if req.Labels == nil {
+			req.Labels = make(map[string]string)
+		}
+		req.Labels[reuseLabel] = o.reuseName
+
+		provider, err := testcontainers.NewDockerProvider()
+		if err != nil {
+			return fmt.Errorf("%w\n%s", err, Doctor(context.Background()))
+		}
+		defer func() { _ = provider.Close() }()
+		list, err := provider.Client().ContainerList(context.Background(), dockercontainer.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("name", "^/"+name+"$")),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to look up container %s: %w", name, err)
+		}
+		o.reused = len(list) > 0
+		if o.reused {
+			o.logf("reusing container %s", name)
+		} else if os.Getenv("TESTCONTAINERS_RYUK_DISABLED") != "true" {
+			o.logf("container %s is removed by Ryuk when the session ends, set TESTCONTAINERS_RYUK_DISABLED=true to reuse it across go test runs", name)
+		}
+		return nil
+	}
+}
+
+// isReused reports whether the container was started with WithReuse, in
+// which case terminate leaves it running
+func isReused(ctx context.Context, c testcontainers.Container) bool {
+	inspect, err := c.Inspect(ctx)
+	if err != nil || inspect.Config == nil {
+		return false
+	}
+	_, ok := inspect.Config.Labels[reuseLabel]
+	return ok
+}
+
+// resetReused resets the state of a reused container, see WithReuse
+func (o *options) resetReused(ctx context.Context, handle any) error {
+	if !o.reused {
+		return nil
+	}
+	var err error
+	switch c := handle.(type) {
+	case *MySQLContainer:
+		if err = o.rerunMySQLInitScripts(ctx, c); err == nil {
+			reopenConns(c.Db)
+		}
+	case *DorisContainer:
+		// ResetDatabase runs the init scripts of WithSQLScripts again
+		if err = c.ResetDatabase(ctx); err == nil {
+			reopenConns(c.Db)
+		}
+	case *RedisContainer:
+		err = c.RedisCli.FlushAll(ctx).Err()
+	case *MongoDBContainer:
+		if err = dropMongoDatabases(ctx, c); err == nil {
+			err = o.rerunMongoInitScripts(ctx, c)
+		}
+	default:
+		o.logf("the state of a reused %T is not reset", handle)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reset reused container: %w", err)
+	}
+	o.logf("reset the state of reused %T %s", handle, o.reuseName)
+	return nil
+}
+
+// rerunMySQLInitScripts recreates the database as root and runs the init
+// scripts in it again like the entrypoint does on a fresh container, the
+// user keeps its grants on the database
+func (o *options) rerunMySQLInitScripts(ctx context.Context, c *MySQLContainer) error {
+	database := orDefault(o.database, defaultMySQLDatabase)
+	quoted := "`" + strings.ReplaceAll(database, "`", "``") + "`"
+	var sb strings.Builder
+	sb.WriteString("set -e\n")
+	sb.WriteString(`mysql_root() { mysql --user=root --password="$MYSQL_ROOT_PASSWORD" "$@"; }` + "\n")
+	sb.WriteString("mysql_root -e " + shellQuote("DROP DATABASE IF EXISTS "+quoted+"; CREATE DATABASE "+quoted) + "\n")
+	for i, script := range o.initScripts {
+		path := shellQuote("/docker-entrypoint-initdb.d/" + indexedName(i, filepath.Base(script)))
+		db := shellQuote(database)
+		switch {
+		case strings.HasSuffix(script, ".sh"):
+			sb.WriteString(path + "\n")
+		case strings.HasSuffix(script, ".sql"):
+			sb.WriteString("mysql_root " + db + " < " + path + "\n")
+		case strings.HasSuffix(script, ".sql.gz"):
+			sb.WriteString("gunzip -c " + path + " | mysql_root " + db + "\n")
+		case strings.HasSuffix(script, ".sql.xz"):
+			sb.WriteString("xzcat " + path + " | mysql_root " + db + "\n")
+		case strings.HasSuffix(script, ".sql.zst"):
+			sb.WriteString("zstd -dc " + path + " | mysql_root " + db + "\n")
+		}
+	}
+	return execScript(ctx, c, sb.String())
+}
+
+// rerunMongoInitScripts runs the init scripts again like the entrypoint does
+// on a fresh container
+func (o *options) rerunMongoInitScripts(ctx context.Context, c *MongoDBContainer) error {
+	for i, script := range o.initScripts {
+		var err error
+		switch {
+		case strings.HasSuffix(script, ".js"):
+			err = c.runMongoScript(ctx, script)
+		case strings.HasSuffix(script, ".sh"):
+			err = execScript(ctx, c, shellQuote("/docker-entrypoint-initdb.d/"+indexedName(i, filepath.Base(script))))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to run init script %s: %w", script, err)
+		}
+	}
+	return nil
+}
+
+// execScript runs the sh script in the container, it fails with the output
+// unless the script exits with code 0
+func execScript(ctx context.Context, c testcontainers.Container, script string) error {
+	code, out, err := c.Exec(ctx, []string{"sh", "-c", script}, tcexec.Multiplexed())
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		output, _ := io.ReadAll(out)
+		return fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// reopenConns closes the idle connections of the pool, they still use the
+// dropped database
+func reopenConns(db *sqlx.DB) {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(2)
+}
+
+// dropMongoDatabases drops the databases but the system ones
+func dropMongoDatabases(ctx context.Context, c *MongoDBContainer) error {
+	var list struct {
+		Databases []struct {
+			Name string `bson:"name"`
+		} `bson:"databases"`
+	}
+	cmd := bson.D{{Key: "listDatabases", Value: 1}, {Key: "nameOnly", Value: true}}
+	if err := c.MongoCli.Database("admin").RunCommand(ctx, cmd).Decode(&list); err != nil {
+		return fmt.Errorf("failed to list databases: %w", err)
+	}
+	for _, db := range list.Databases {
+		name := db.Name
+		switch name {
+		case "admin", "config", "local":
+			continue
+		}
+		if err := c.MongoCli.Database(name).DropDatabase(ctx); err != nil {
+			return fmt.Errorf("failed to drop database '%s': %w", name, err)
+		}
+	}
+	return nil
+}
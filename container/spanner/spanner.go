@@ -0,0 +1,287 @@
+package spanner
+
+import (
+	gspanner "cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"context"
+	"fmt"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"os"
+	"strings"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	grpcPort = "9010/tcp"
+
+	defaultProject  = "test-project"
+	defaultInstance = "test-instance"
+	defaultDatabase = "test-db"
+)
+
+// Container represents the Cloud Spanner emulator container type used in the module
+type Container struct {
+	testcontainers.Container
+	project  string
+	instance string
+	database string
+}
+
+type options struct {
+	project  string
+	instance string
+	database string
+	ddlFiles []string
+}
+
+// Option configures the emulator instance and database, it is not a container customizer.
+type Option func(*options)
+
+// Customize implements testcontainers.ContainerCustomizer, so options can be passed to Run
+func (o Option) Customize(*testcontainers.GenericContainerRequest) error {
+	return nil
+}
+
+// WithProject sets the project id, "test-project" by default
+func WithProject(project string) Option {
+	return func(o *options) {
+		o.project = project
+	}
+}
+
+// WithInstance sets the instance id, "test-instance" by default
+func WithInstance(instance string) Option {
+	return func(o *options) {
+		o.instance = instance
+	}
+}
+
+// WithDatabase sets the database id, "test-db" by default
+func WithDatabase(database string) Option {
+	return func(o *options) {
+		o.database = database
+	}
+}
+
+// WithDDLFiles adds DDL files applied when creating the database, in the given order
+func WithDDLFiles(files ...string) Option {
+	return func(o *options) {
+		o.ddlFiles = append(o.ddlFiles, files...)
+	}
+}
+
+// Run creates an instance of the Spanner emulator container type,
+// and creates the configured instance and database in it
+func Run(ctx context.Context, img string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        img,
+		ExposedPorts: []string{grpcPort},
+		WaitingFor: wait.ForAll(
+			wait.ForLog("gRPC server listening"),
+			wait.ForListeningPort(grpcPort),
+		),
+	}
+
+	genericContainerReq := testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	}
+
+	settings := options{
+		project:  defaultProject,
+		instance: defaultInstance,
+		database: defaultDatabase,
+	}
+	for _, opt := range opts {
+		if opt, ok := opt.(Option); ok {
+			opt(&settings)
+			continue
+		}
+		if err := opt.Customize(&genericContainerReq); err != nil {
+			return nil, err
+		}
+	}
+
+	var ddl []string
+	for _, file := range settings.ddlFiles {
+		stmts, err := readDDLFile(file)
+		if err != nil {
+			return nil, err
+		}
+		ddl = append(ddl, stmts...)
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, genericContainerReq)
+	var c *Container
+	if container != nil {
+		c = &Container{
+			Container: container,
+			project:   settings.project,
+			instance:  settings.instance,
+			database:  settings.database,
+		}
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("generic container: %w", err)
+	}
+
+	if err = c.createDatabase(ctx, ddl); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// GRPCEndpoint returns the host:port of the emulator gRPC endpoint
+func (c *Container) GRPCEndpoint(ctx context.Context) (string, error) {
+	containerPort, err := c.MappedPort(ctx, grpcPort)
+	if err != nil {
+		return "", err
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%s", host, containerPort.Port()), nil
+}
+
+// InstanceName returns the full name of the instance, projects/<p>/instances/<i>
+func (c *Container) InstanceName() string {
+	return fmt.Sprintf("projects/%s/instances/%s", c.project, c.instance)
+}
+
+// DatabaseName returns the full name of the database, projects/<p>/instances/<i>/databases/<d>
+func (c *Container) DatabaseName() string {
+	return fmt.Sprintf("%s/databases/%s", c.InstanceName(), c.database)
+}
+
+// ClientOptions returns the client options pointing at the emulator, so no
+// SPANNER_EMULATOR_HOST environment variable is needed.
+func (c *Container) ClientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	endpoint, err := c.GRPCEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{
+		option.WithEndpoint(endpoint),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}, nil
+}
+
+// Client creates a Spanner client of the database
+func (c *Container) Client(ctx context.Context) (*gspanner.Client, error) {
+	opts, err := c.ClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gspanner.NewClient(ctx, c.DatabaseName(), opts...)
+}
+
+// UpdateDDL applies DDL statements to the database
+func (c *Container) UpdateDDL(ctx context.Context, stmts ...string) error {
+	opts, err := c.ClientOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	admin, err := database.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create database admin client: %w", err)
+	}
+	defer func() { _ = admin.Close() }()
+
+	op, err := admin.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   c.DatabaseName(),
+		Statements: stmts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update ddl: %w", err)
+	}
+	if err = op.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to update ddl: %w", err)
+	}
+	return nil
+}
+
+func (c *Container) createDatabase(ctx context.Context, ddl []string) error {
+	opts, err := c.ClientOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create instance admin client: %w", err)
+	}
+	defer func() { _ = instanceAdmin.Close() }()
+
+	instanceOp, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + c.project,
+		InstanceId: c.instance,
+		Instance: &instancepb.Instance{
+			Config:      fmt.Sprintf("projects/%s/instanceConfigs/emulator-config", c.project),
+			DisplayName: c.instance,
+			NodeCount:   1,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create instance '%s': %w", c.instance, err)
+	}
+	if _, err = instanceOp.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to create instance '%s': %w", c.instance, err)
+	}
+
+	databaseAdmin, err := database.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create database admin client: %w", err)
+	}
+	defer func() { _ = databaseAdmin.Close() }()
+
+	databaseOp, err := databaseAdmin.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+		Parent:          c.InstanceName(),
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", c.database),
+		ExtraStatements: ddl,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create database '%s': %w", c.database, err)
+	}
+	if _, err = databaseOp.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to create database '%s': %w", c.database, err)
+	}
+	return nil
+}
+
+// readDDLFile reads a DDL file and splits it into statements, dropping "--" comments.
+func readDDLFile(file string) ([]string, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ddl file '%s': %w", file, err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(bs), "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+
+	var stmts []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts, nil
+}
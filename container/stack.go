@@ -0,0 +1,51 @@
+package container
+
+import (
+	"context"
+)
+
+// StandardStack is the MySQL, Redis and MongoDB containers most services
+// depend on, started by StartStandardStack
+type StandardStack struct {
+	*Environment
+	MySQL   *MySQLContainer
+	Redis   *RedisContainer
+	MongoDB *MongoDBContainer
+}
+
+// StackOptions are the options of each service of StartStandardStack, the
+// services don't share them since e.g. WithImage or WithInitScripts only
+// fit one of them
+type StackOptions struct {
+	MySQL   []Option
+	Redis   []Option
+	MongoDB []Option
+}
+
+// StartStandardStack starts MySQL, Redis and MongoDB in parallel as an
+// Environment, with the defaults or the options of opts, e.g.
+// StartStandardStack(ctx, StackOptions{MySQL: []Option{WithInitScripts(...)}}).
+// If one fails, the others are terminated.
+func StartStandardStack(ctx context.Context, opts ...StackOptions) (*StandardStack, error) {
+	var merged StackOptions
+	for _, o := range opts {
+		merged.MySQL = append(merged.MySQL, o.MySQL...)
+		merged.Redis = append(merged.Redis, o.Redis...)
+		merged.MongoDB = append(merged.MongoDB, o.MongoDB...)
+	}
+	env, err := NewEnvironment(ctx, WithMySQL(merged.MySQL...), WithRedis(merged.Redis...), WithMongo(merged.MongoDB...))
+	if err != nil {
+		return nil, err
+	}
+	s := &StandardStack{Environment: env}
+	if s.MySQL, err = env.MySQL(ctx); err == nil {
+		if s.Redis, err = env.Redis(ctx); err == nil {
+			s.MongoDB, err = env.Mongo(ctx)
+		}
+	}
+	if err != nil {
+		_ = env.Terminate(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return s, nil
+}
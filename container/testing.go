@@ -0,0 +1,133 @@
+package container
+
+import (
+	"context"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+)
+
+// terminator is a helper container, terminated at the end of the test
+type terminator interface {
+	Terminate(ctx context.Context, opts ...testcontainers.TerminateOption) error
+}
+
+// createT creates a helper container for the test: its messages go to
+// t.Logf, a startup error fails the test and the container is terminated
+// by t.Cleanup.
+func createT[C terminator](t testing.TB, name string, create func(context.Context, ...Option) (C, error), opts []Option) C {
+	t.Helper()
+	c, err := create(context.Background(), append([]Option{WithLogf(t.Logf)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create %s container: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if err := c.Terminate(context.Background()); err != nil {
+			t.Logf("failed to terminate %s container: %v", name, err)
+		}
+	})
+	return c
+}
+
+// CreateRedisContainerT is CreateRedisContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateRedisContainerT(t testing.TB, opts ...Option) *RedisContainer {
+	t.Helper()
+	return createT(t, "redis", CreateRedisContainer, opts)
+}
+
+// CreateRedisClusterT is CreateRedisCluster terminating the container when
+// the test ends and failing the test if it can't start.
+func CreateRedisClusterT(t testing.TB, masters int, opts ...Option) *RedisCluster {
+	t.Helper()
+	return createT(t, "redis cluster", func(ctx context.Context, opts ...Option) (*RedisCluster, error) {
+		return CreateRedisCluster(ctx, masters, opts...)
+	}, opts)
+}
+
+// CreateMySQLContainerT is CreateMySQLContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateMySQLContainerT(t testing.TB, opts ...Option) *MySQLContainer {
+	t.Helper()
+	return createT(t, "mysql", CreateMySQLContainer, opts)
+}
+
+// CreateMongoDBContainerT is CreateMongoDBContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateMongoDBContainerT(t testing.TB, opts ...Option) *MongoDBContainer {
+	t.Helper()
+	return createT(t, "mongodb", CreateMongoDBContainer, opts)
+}
+
+// CreateDorisContainerT is CreateDorisContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateDorisContainerT(t testing.TB, opts ...Option) *DorisContainer {
+	t.Helper()
+	return createT(t, "doris", CreateDorisContainer, opts)
+}
+
+// CreateGreptimeDBContainerT is CreateGreptimeDBContainer terminating the
+// container when the test ends and failing the test if it can't start.
+func CreateGreptimeDBContainerT(t testing.TB, opts ...Option) *GreptimeDBContainer {
+	t.Helper()
+	return createT(t, "greptimedb", CreateGreptimeDBContainer, opts)
+}
+
+// CreateSpannerContainerT is CreateSpannerContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateSpannerContainerT(t testing.TB, opts ...Option) *SpannerContainer {
+	t.Helper()
+	return createT(t, "spanner", CreateSpannerContainer, opts)
+}
+
+// CreateRabbitMQContainerT is CreateRabbitMQContainer terminating the
+// container when the test ends and failing the test if it can't start.
+func CreateRabbitMQContainerT(t testing.TB, opts ...Option) *RabbitMQContainer {
+	t.Helper()
+	return createT(t, "rabbitmq", CreateRabbitMQContainer, opts)
+}
+
+// CreateKafkaContainerT is CreateKafkaContainer terminating the container
+// when the test ends and failing the test if it can't start.
+func CreateKafkaContainerT(t testing.TB, opts ...Option) *KafkaContainer {
+	t.Helper()
+	return createT(t, "kafka", CreateKafkaContainer, opts)
+}
+
+// CreateKafkaClusterT is CreateKafkaCluster terminating the brokers when the
+// test ends and failing the test if they can't start.
+func CreateKafkaClusterT(t testing.TB, brokers int, opts ...Option) *KafkaCluster {
+	t.Helper()
+	return createT(t, "kafka cluster", func(ctx context.Context, opts ...Option) (*KafkaCluster, error) {
+		return CreateKafkaCluster(ctx, brokers, opts...)
+	}, opts)
+}
+
+// CreateElasticsearchContainerT is CreateElasticsearchContainer terminating
+// the container when the test ends and failing the test if it can't start.
+func CreateElasticsearchContainerT(t testing.TB, opts ...Option) *ElasticsearchContainer {
+	t.Helper()
+	return createT(t, "elasticsearch", CreateElasticsearchContainer, opts)
+}
+
+// CreatePostgresContainerT is CreatePostgresContainer terminating the
+// container when the test ends and failing the test if it can't start.
+func CreatePostgresContainerT(t testing.TB, opts ...Option) *PostgresContainer {
+	t.Helper()
+	return createT(t, "postgres", CreatePostgresContainer, opts)
+}
+
+// NewEnvironmentT is NewEnvironment terminating all services when the test
+// ends and failing the test if one can't start.
+func NewEnvironmentT(t testing.TB, opts ...EnvOption) *Environment {
+	t.Helper()
+	e, err := NewEnvironment(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("failed to create environment: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := e.Terminate(context.Background()); err != nil {
+			t.Logf("failed to terminate environment: %v", err)
+		}
+	})
+	return e
+}
@@ -0,0 +1,205 @@
+package datagen
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"strings"
+	"time"
+)
+
+// baseTime is the value of generated date and time columns
+var baseTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type column struct {
+	Table     string         `db:"table_name"`
+	Name      string         `db:"column_name"`
+	DataType  string         `db:"data_type"`
+	Type      string         `db:"column_type"`
+	Nullable  string         `db:"is_nullable"`
+	Default   sql.NullString `db:"column_default"`
+	Key       string         `db:"column_key"`
+	Extra     string         `db:"extra"`
+	MaxLength sql.NullInt64  `db:"character_maximum_length"`
+}
+
+// required reports whether an insert must set the column
+func (c column) required() bool {
+	return c.Nullable == "NO" && !c.Default.Valid && !strings.Contains(strings.ToLower(c.Extra), "auto_increment") &&
+		!strings.Contains(strings.ToLower(c.Extra), "generated")
+}
+
+// generate returns a value of the column type for the nth row
+func (c column) generate(n int) any {
+	switch strings.ToLower(c.DataType) {
+	case "tinyint", "bit", "bool", "boolean":
+		return n % 2
+	case "smallint", "mediumint", "int", "integer", "bigint", "decimal", "numeric", "float", "double", "real", "year":
+		return n
+	case "date":
+		return baseTime.AddDate(0, 0, n).Format("2006-01-02")
+	case "datetime", "timestamp":
+		return baseTime.Add(time.Duration(n) * time.Second).Format("2006-01-02 15:04:05")
+	case "time":
+		return "00:00:00"
+	case "json":
+		return "{}"
+	case "enum", "set":
+		// the first value of enum('a','b')
+		if start := strings.Index(c.Type, "'"); start >= 0 {
+			if end := strings.Index(c.Type[start+1:], "'"); end >= 0 {
+				return c.Type[start+1 : start+1+end]
+			}
+		}
+		return ""
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return []byte(fmt.Sprintf("%s-%d", c.Name, n))
+	default:
+		s := fmt.Sprintf("%s-%d", c.Name, n)
+		if c.MaxLength.Valid && int64(len(s)) > c.MaxLength.Int64 {
+			s = s[int64(len(s))-c.MaxLength.Int64:]
+		}
+		return s
+	}
+}
+
+type table struct {
+	// name is the name as the database has it, the schema keys it lower case
+	name    string
+	columns []column
+}
+
+// autoIncrement returns the auto increment column, empty if none
+func (t *table) autoIncrement() string {
+	for _, c := range t.columns {
+		if strings.Contains(strings.ToLower(c.Extra), "auto_increment") {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// foreignKey is a foreign key, its table names are lower case
+type foreignKey struct {
+	name          string
+	table         string
+	columns       []string
+	parent        string
+	parentColumns []string
+}
+
+// same reports whether both keys reference the same columns
+func (fk foreignKey) same(other foreignKey) bool {
+	return fk.table == other.table && fk.parent == other.parent &&
+		sameColumns(fk.columns, other.columns) && sameColumns(fk.parentColumns, other.parentColumns)
+}
+
+type schema struct {
+	// tables are keyed by lower case name
+	tables      map[string]*table
+	foreignKeys []foreignKey
+}
+
+// addForeignKeys adds the keys the schema doesn't have yet
+func (s *schema) addForeignKeys(keys ...foreignKey) {
+	for _, key := range keys {
+		if !slices.ContainsFunc(s.foreignKeys, key.same) {
+			s.foreignKeys = append(s.foreignKeys, key)
+		}
+	}
+}
+
+// reference returns the foreign key of the table referencing the parent
+// table, the one of the via columns if given
+func (s *schema) reference(tableName, parent string, via []string) (*foreignKey, error) {
+	var found []*foreignKey
+	for i := range s.foreignKeys {
+		fk := &s.foreignKeys[i]
+		if fk.table != strings.ToLower(tableName) || fk.parent != strings.ToLower(parent) {
+			continue
+		}
+		if len(via) > 0 && !sameColumns(fk.columns, via) {
+			continue
+		}
+		found = append(found, fk)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no foreign key of '%s' references '%s', declare it with ForeignKey", tableName, parent)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("'%s' references '%s' more than once, pick the columns with Via", tableName, parent)
+	}
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// introspect reads the tables, columns and foreign keys of the current database
+func introspect(ctx context.Context, db *sqlx.DB) (*schema, error) {
+	var columns []column
+	err := db.SelectContext(ctx, &columns, "SELECT table_name AS table_name, column_name AS column_name, "+
+		"data_type AS data_type, column_type AS column_type, is_nullable AS is_nullable, "+
+		"column_default AS column_default, column_key AS column_key, extra AS extra, "+
+		"character_maximum_length AS character_maximum_length FROM information_schema.columns "+
+		"WHERE table_schema = DATABASE() ORDER BY table_name, ordinal_position")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+
+	var keys []struct {
+		Name             string `db:"constraint_name"`
+		Table            string `db:"table_name"`
+		Column           string `db:"column_name"`
+		ReferencedTable  string `db:"referenced_table_name"`
+		ReferencedColumn string `db:"referenced_column_name"`
+	}
+	err = db.SelectContext(ctx, &keys, "SELECT constraint_name AS constraint_name, table_name AS table_name, "+
+		"column_name AS column_name, referenced_table_name AS referenced_table_name, "+
+		"referenced_column_name AS referenced_column_name FROM information_schema.key_column_usage "+
+		"WHERE table_schema = DATABASE() AND referenced_table_name IS NOT NULL "+
+		"ORDER BY table_name, constraint_name, ordinal_position")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+
+	s := &schema{tables: make(map[string]*table)}
+	for _, c := range columns {
+		name := strings.ToLower(c.Table)
+		t, ok := s.tables[name]
+		if !ok {
+			t = &table{name: c.Table}
+			s.tables[name] = t
+		}
+		t.columns = append(t.columns, c)
+	}
+	for _, key := range keys {
+		tableName, parent := strings.ToLower(key.Table), strings.ToLower(key.ReferencedTable)
+		if n := len(s.foreignKeys); n > 0 && s.foreignKeys[n-1].table == tableName && s.foreignKeys[n-1].name == key.Name {
+			fk := &s.foreignKeys[n-1]
+			fk.columns = append(fk.columns, key.Column)
+			fk.parentColumns = append(fk.parentColumns, key.ReferencedColumn)
+			continue
+		}
+		s.foreignKeys = append(s.foreignKeys, foreignKey{
+			name:          key.Name,
+			table:         tableName,
+			columns:       []string{key.Column},
+			parent:        parent,
+			parentColumns: []string{key.ReferencedColumn},
+		})
+	}
+	return s, nil
+}
@@ -0,0 +1,109 @@
+// Package dsn builds the connection strings of the databases the helpers
+// start, escaping parameters and the credentials of the URL forms, instead
+// of formatting them by hand. The MySQL driver reads the user and the
+// password verbatim, so the MySQL DSN holds them unescaped:
+//
+//	dsn.NewMySQL("127.0.0.1", 3306).Credentials("root", "p@ss").Database("app").Param("parseTime", "true").String()
+//	// root:p@ss@tcp(127.0.0.1:3306)/app?parseTime=true
+//
+// The Parse functions read a connection string back, e.g. to add
+// parameters to the one a container returns.
+package dsn
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// param is a connection parameter, kept in the order it was added
+type param struct {
+	key   string
+	value string
+}
+
+// params is an ordered list of connection parameters
+type params []param
+
+// set sets the value of key, replacing a former value
+func (p params) set(key, value string) params {
+	for i := range p {
+		if p[i].key == key {
+			p[i].value = value
+			return p
+		}
+	}
+	return append(p, param{key: key, value: value})
+}
+
+// setPairs sets the parameters of "key=value" pairs, escaped like in a
+// connection string, e.g. "loc=Asia%2FShanghai", so they are encoded back
+// as they are. A part that isn't a valid escape is taken verbatim.
+func (p params) setPairs(pairs []string) params {
+	for _, pair := range pairs {
+		k, v, _ := strings.Cut(pair, "=")
+		p = p.set(unescape(k), unescape(v))
+	}
+	return p
+}
+
+// unescape query unescapes s, or returns it if it isn't escaped validly
+func unescape(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+func (p params) get(key string) string {
+	for _, param := range p {
+		if param.key == key {
+			return param.value
+		}
+	}
+	return ""
+}
+
+func (p params) encode() string {
+	if len(p) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(p))
+	for i, param := range p {
+		pairs[i] = escape(param.key) + "=" + escape(param.value)
+	}
+	return "?" + strings.Join(pairs, "&")
+}
+
+// escape query escapes s but its commas, the MySQL driver reads lists like
+// charset=utf8mb4,utf8 without unescaping them
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "%2C", ",")
+}
+
+func parseParams(query string) (params, error) {
+	var p params
+	if query == "" {
+		return p, nil
+	}
+	for _, pair := range strings.Split(query, "&") {
+		k, v, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter '%s': %w", pair, err)
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter '%s': %w", pair, err)
+		}
+		p = p.set(key, value)
+	}
+	return p, nil
+}
+
+// hostPort joins a host and a port, bracketing IPv6 hosts
+func hostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,147 @@
+package dsn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DorisPort is the MySQL protocol port of the Doris and StarRocks FE
+const DorisPort = 9030
+
+// MySQL builds a go-sql-driver/mysql DSN,
+// user:password@tcp(host:port,...)/database?params
+type MySQL struct {
+	user     string
+	password string
+	network  string
+	addrs    []string
+	database string
+	params   params
+}
+
+// NewMySQL returns a builder of a DSN connecting to host:port
+func NewMySQL(host string, port int) *MySQL {
+	return &MySQL{network: "tcp", addrs: []string{hostPort(host, port)}}
+}
+
+// NewDoris returns a builder of a DSN connecting to the FE at host:port as
+// root, the user the Doris and StarRocks containers start with.
+func NewDoris(host string, port int) *MySQL {
+	return NewMySQL(host, port).Credentials("root", "")
+}
+
+// ParseMySQL parses a go-sql-driver/mysql DSN, over tcp or a network
+// registered with mysql.RegisterDialContext
+func ParseMySQL(s string) (*MySQL, error) {
+	slash := strings.LastIndex(s, "/")
+	if slash < 0 {
+		return nil, fmt.Errorf("invalid dsn '%s', expected user:password@tcp(host:port)/db", s)
+	}
+	m := &MySQL{}
+
+	prefix := s[:slash]
+	if at := strings.LastIndex(prefix, "@"); at >= 0 {
+		m.user, m.password, _ = strings.Cut(prefix[:at], ":")
+		prefix = prefix[at+1:]
+	}
+	network, addrs, ok := strings.Cut(prefix, "(")
+	if !ok || network == "" || !strings.HasSuffix(addrs, ")") {
+		return nil, fmt.Errorf("invalid dsn '%s', expected user:password@tcp(host:port)/db", s)
+	}
+	m.network = network
+	m.addrs = strings.Split(strings.TrimSuffix(addrs, ")"), ",")
+
+	database, query, _ := strings.Cut(s[slash+1:], "?")
+	m.database = database
+	var err error
+	if m.params, err = parseParams(query); err != nil {
+		return nil, fmt.Errorf("invalid dsn '%s': %w", s, err)
+	}
+	return m, nil
+}
+
+// Credentials sets the user and the password, an empty password for none
+func (m *MySQL) Credentials(user, password string) *MySQL {
+	m.user = user
+	m.password = password
+	return m
+}
+
+// Database sets the default database
+func (m *MySQL) Database(database string) *MySQL {
+	m.database = database
+	return m
+}
+
+// Addresses replaces the host:port addresses. go-sql-driver/mysql dials a
+// single address, so pass several ones only to proxies splitting the list.
+func (m *MySQL) Addresses(addrs ...string) *MySQL {
+	m.addrs = addrs
+	return m
+}
+
+// Network sets the network of the addresses, tcp by default, e.g. the name
+// of a dial function registered with mysql.RegisterDialContext
+func (m *MySQL) Network(network string) *MySQL {
+	m.network = network
+	return m
+}
+
+// Param sets a parameter, e.g. Param("parseTime", "true")
+func (m *MySQL) Param(key, value string) *MySQL {
+	m.params = m.params.set(key, value)
+	return m
+}
+
+// Params sets the parameters of "key=value" pairs, the form the
+// ConnectionString methods of the containers take, escaped like in the
+// connection string, e.g. "loc=Asia%2FShanghai"
+func (m *MySQL) Params(pairs ...string) *MySQL {
+	m.params = m.params.setPairs(pairs)
+	return m
+}
+
+// TLS sets the tls parameter, "true", "skip-verify", "preferred" or the
+// name of a config registered with mysql.RegisterTLSConfig
+func (m *MySQL) TLS(config string) *MySQL {
+	return m.Param("tls", config)
+}
+
+// User returns the user
+func (m *MySQL) User() string {
+	return m.user
+}
+
+// Password returns the password
+func (m *MySQL) Password() string {
+	return m.password
+}
+
+// Addrs returns the host:port addresses
+func (m *MySQL) Addrs() []string {
+	return m.addrs
+}
+
+// DatabaseName returns the default database
+func (m *MySQL) DatabaseName() string {
+	return m.database
+}
+
+// Get returns the value of a parameter, empty if it is not set
+func (m *MySQL) Get(key string) string {
+	return m.params.get(key)
+}
+
+func (m *MySQL) String() string {
+	var sb strings.Builder
+	if m.user != "" {
+		sb.WriteString(m.user)
+		if m.password != "" {
+			sb.WriteString(":" + m.password)
+		}
+		sb.WriteString("@")
+	}
+	sb.WriteString(m.network + "(" + strings.Join(m.addrs, ",") + ")/" + m.database)
+	sb.WriteString(m.params.encode())
+	return sb.String()
+}
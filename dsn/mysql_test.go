@@ -0,0 +1,166 @@
+package dsn
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMySQLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsn      string
+		user     string
+		password string
+		addrs    []string
+		database string
+		params   map[string]string
+	}{
+		{
+			name:     "credentials and database",
+			dsn:      "root:test@tcp(127.0.0.1:3306)/app",
+			user:     "root",
+			password: "test",
+			addrs:    []string{"127.0.0.1:3306"},
+			database: "app",
+		},
+		{
+			name:  "empty credentials",
+			dsn:   "tcp(127.0.0.1:3306)/",
+			addrs: []string{"127.0.0.1:3306"},
+		},
+		{
+			name:     "user without password",
+			dsn:      "root@tcp(127.0.0.1:9030)/test",
+			user:     "root",
+			addrs:    []string{"127.0.0.1:9030"},
+			database: "test",
+		},
+		{
+			name:     "password with separators",
+			dsn:      "root:p@ss:w/rd@tcp(127.0.0.1:3306)/app",
+			user:     "root",
+			password: "p@ss:w/rd",
+			addrs:    []string{"127.0.0.1:3306"},
+			database: "app",
+		},
+		{
+			name:     "multiple addresses",
+			dsn:      "root:pw@tcp(h1:9030,h2:9030,[::1]:9030)/db",
+			user:     "root",
+			password: "pw",
+			addrs:    []string{"h1:9030", "h2:9030", "[::1]:9030"},
+			database: "db",
+		},
+		{
+			name:     "escaped params",
+			dsn:      "root:test@tcp(127.0.0.1:3306)/app?parseTime=true&loc=Asia%2FShanghai&charset=utf8mb4,utf8&time_zone=%27%2B08%3A00%27",
+			user:     "root",
+			password: "test",
+			addrs:    []string{"127.0.0.1:3306"},
+			database: "app",
+			params: map[string]string{
+				"parseTime": "true",
+				"loc":       "Asia/Shanghai",
+				"charset":   "utf8mb4,utf8",
+				"time_zone": "'+08:00'",
+			},
+		},
+		{
+			name:     "registered network",
+			dsn:      "root@mock(app)/app",
+			user:     "root",
+			addrs:    []string{"app"},
+			database: "app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMySQL(tt.dsn)
+			if err != nil {
+				t.Fatalf("ParseMySQL(%q) failed: %v", tt.dsn, err)
+			}
+			if m.User() != tt.user || m.Password() != tt.password {
+				t.Errorf("credentials = %q, %q, want %q, %q", m.User(), m.Password(), tt.user, tt.password)
+			}
+			if !slices.Equal(m.Addrs(), tt.addrs) {
+				t.Errorf("Addrs() = %q, want %q", m.Addrs(), tt.addrs)
+			}
+			if m.DatabaseName() != tt.database {
+				t.Errorf("DatabaseName() = %q, want %q", m.DatabaseName(), tt.database)
+			}
+			for key, want := range tt.params {
+				if got := m.Get(key); got != want {
+					t.Errorf("Get(%q) = %q, want %q", key, got, want)
+				}
+			}
+			if got := m.String(); got != tt.dsn {
+				t.Errorf("String() = %q, want %q", got, tt.dsn)
+			}
+		})
+	}
+}
+
+func TestParseMySQLInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"root:test@127.0.0.1:3306",
+		"root:test@127.0.0.1:3306/app",
+		"root:test@tcp(127.0.0.1:3306/app",
+		"root:test@tcp(127.0.0.1:3306)/app?loc=%zz",
+	} {
+		if _, err := ParseMySQL(s); err == nil {
+			t.Errorf("ParseMySQL(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestMySQLParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{
+			name:  "escaped pair kept verbatim",
+			pairs: []string{"loc=Asia%2FShanghai"},
+			want:  "root:test@tcp(127.0.0.1:3306)/app?loc=Asia%2FShanghai",
+		},
+		{
+			name:  "unescaped pair escaped",
+			pairs: []string{"loc=Asia/Shanghai"},
+			want:  "root:test@tcp(127.0.0.1:3306)/app?loc=Asia%2FShanghai",
+		},
+		{
+			name:  "invalid escape taken verbatim",
+			pairs: []string{"comment=100%"},
+			want:  "root:test@tcp(127.0.0.1:3306)/app?comment=100%25",
+		},
+		{
+			name:  "later pair replaces former one",
+			pairs: []string{"parseTime=false", "charset=utf8mb4,utf8", "parseTime=true"},
+			want:  "root:test@tcp(127.0.0.1:3306)/app?parseTime=true&charset=utf8mb4,utf8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMySQL("127.0.0.1", 3306).Credentials("root", "test").Database("app").Params(tt.pairs...).String()
+			if got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			m, err := ParseMySQL(got)
+			if err != nil {
+				t.Fatalf("ParseMySQL(%q) failed: %v", got, err)
+			}
+			if again := m.String(); again != got {
+				t.Errorf("String() after ParseMySQL = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestNewMySQLIPv6(t *testing.T) {
+	want := "root@tcp([::1]:9030)/"
+	if got := NewDoris("::1", DorisPort).String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+// Package fixtures loads structured test data from YAML, JSON and CSV files
+// keyed by table name into the mock MySQL server and the MySQL and Doris
+// containers alike, so both kinds of tests can share one fixture format.
+//
+// YAML and JSON files map table names to lists of rows, tables are loaded in
+// file order:
+//
+//	users:
+//	  - {id: 1, name: alice}
+//	  - {id: 2, name: bob, tags: [admin]}
+//	orders:
+//	  - {id: 10, user_id: 1}
+//
+// CSV files hold one table named after the file, e.g. users.csv, with the
+// column names in the header row and NULL written as "NULL".
+//
+// Use Files as an init source of the mock, mysql.Builder().InitFrom(fixtures.Files(...)),
+// and Load for a container, fixtures.Load(ctx, c.Db, files). LoadMongo loads
+// YAML and JSON files into a Mongo database, resolving references between
+// the documents.
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"path/filepath"
+	"strings"
+)
+
+// Null is how NULL values are written in CSV fixtures
+const Null = "NULL"
+
+const defaultBatchSize = 500
+
+// Table holds the rows of a table, a row lacking a column leaves it to its default
+type Table struct {
+	Name string
+	Rows []map[string]any
+	// Columns are the column names in the order first seen
+	Columns []string
+}
+
+type config struct {
+	truncate  bool
+	batchSize int
+}
+
+// Option configures the loading of fixtures
+type Option func(*config)
+
+// Truncate empties every table of the fixtures before loading its rows
+func Truncate() Option {
+	return func(c *config) {
+		c.truncate = true
+	}
+}
+
+// BatchSize sets how many rows one INSERT statement holds, 500 by default
+func BatchSize(n int) Option {
+	return func(c *config) {
+		c.batchSize = n
+	}
+}
+
+// Source is the fixture files as an init source of the mock server, see
+// mysql.MockBuilder.InitFrom
+type Source struct {
+	files []string
+	cfg   config
+}
+
+// Files returns the fixture files as an init source of the mock server,
+// loaded in the given order
+func Files(files []string, opts ...Option) *Source {
+	cfg := config{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Source{files: files, cfg: cfg}
+}
+
+// Statements returns the TRUNCATE and INSERT statements loading the fixtures
+func (s *Source) Statements(ctx context.Context) ([]string, error) {
+	var stmts []string
+	for _, file := range s.files {
+		tables, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			stmts = append(stmts, table.statements(s.cfg)...)
+		}
+	}
+	return stmts, nil
+}
+
+// Load loads the fixture files into the database, in the given order
+func Load(ctx context.Context, db *sqlx.DB, files []string, opts ...Option) error {
+	stmts, err := Files(files, opts...).Statements(ctx)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to load fixtures: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFile parses a fixture file by its extension: .yaml, .yml, .json or .csv
+func LoadFile(file string) ([]*Table, error) {
+	var (
+		tables []*Table
+		err    error
+	)
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		tables, err = parseYAML(file)
+	case ".json":
+		tables, err = parseJSON(file)
+	case ".csv":
+		tables, err = parseCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file '%s', want .yaml, .yml, .json or .csv", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture file '%s': %w", file, err)
+	}
+	return tables, nil
+}
+
+func (t *Table) addRow(row map[string]any) {
+	for column := range row {
+		if !contains(t.Columns, column) {
+			t.Columns = append(t.Columns, column)
+		}
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// statements returns the INSERT statements of the table, consecutive rows
+// having the same columns share a statement
+func (t *Table) statements(cfg config) []string {
+	var stmts []string
+	if cfg.truncate {
+		stmts = append(stmts, fmt.Sprintf("TRUNCATE TABLE %s", quoteIdent(t.Name)))
+	}
+
+	var columns []string
+	var values []string
+	flush := func() {
+		if len(values) == 0 {
+			return
+		}
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = quoteIdent(column)
+		}
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+			quoteIdent(t.Name), strings.Join(quoted, ", "), strings.Join(values, ", ")))
+		values = nil
+	}
+
+	for _, row := range t.Rows {
+		rowColumns := make([]string, 0, len(row))
+		for _, column := range t.Columns {
+			if _, ok := row[column]; ok {
+				rowColumns = append(rowColumns, column)
+			}
+		}
+		if strings.Join(rowColumns, "\x00") != strings.Join(columns, "\x00") || len(values) >= cfg.batchSize {
+			flush()
+			columns = rowColumns
+		}
+		literals := make([]string, len(columns))
+		for i, column := range columns {
+			literals[i] = literal(row[column])
+		}
+		values = append(values, "("+strings.Join(literals, ", ")+")")
+	}
+	flush()
+	return stmts
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package fixtures
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+)
+
+const (
+	// KeyField names a document of a Mongo fixture, so other documents can
+	// reference it; it is not stored
+	KeyField = "_key"
+	// RefPrefix marks a string value as a reference, "$oid:users.alice" is
+	// replaced by the _id of the users document whose _key is alice
+	RefPrefix = "$oid:"
+)
+
+// ObjectID returns the ObjectID of a reference like "users.alice", the same
+// in every run, e.g. to look up a fixture document from the test
+func ObjectID(ref string) primitive.ObjectID {
+	sum := sha256.Sum256([]byte("mtest:" + ref))
+	var id primitive.ObjectID
+	copy(id[:], sum[:len(id)])
+	return id
+}
+
+// LoadMongo loads the fixture files into the Mongo database, mapping the
+// collection names to lists of documents, in the given order:
+//
+//	users:
+//	  - {_key: alice, name: Alice}
+//	orders:
+//	  - {user_id: "$oid:users.alice", total: 42}
+//
+// A document without _id gets ObjectID("collection.key"), or one derived
+// from its position without _key, so related documents stay consistent
+// without hard coded ids. A reference to a key no file defines fails. The
+// documents of a collection in several files are loaded together, in file
+// order. Truncate removes all documents of the collections first, BatchSize
+// is ignored. It returns the ids of the keyed documents by reference.
+func LoadMongo(ctx context.Context, db *qmgo.Database, files []string, opts ...Option) (map[string]primitive.ObjectID, error) {
+	cfg := config{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// a collection of several files is loaded once with the documents of
+	// all of them, so Truncate and the positional ids span the files
+	var collections []*Table
+	byName := make(map[string]*Table)
+	for _, file := range files {
+		tables, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tables {
+			coll, ok := byName[t.Name]
+			if !ok {
+				coll = &Table{Name: t.Name}
+				byName[t.Name] = coll
+				collections = append(collections, coll)
+			}
+			coll.Rows = append(coll.Rows, t.Rows...)
+		}
+	}
+
+	// assign the ids first, documents may reference later ones
+	ids := make(map[string]primitive.ObjectID)
+	for _, coll := range collections {
+		for _, doc := range coll.Rows {
+			key, ok := doc[KeyField]
+			if !ok {
+				continue
+			}
+			ref := coll.Name + "." + fmt.Sprint(key)
+			if _, dup := ids[ref]; dup {
+				return nil, fmt.Errorf("duplicate fixture key '%s'", ref)
+			}
+			ids[ref] = ObjectID(ref)
+			if id, ok := doc["_id"]; ok {
+				resolved, err := resolveRefs(id, nil)
+				if err != nil {
+					return nil, err
+				}
+				oid, ok := resolved.(primitive.ObjectID)
+				if !ok {
+					return nil, fmt.Errorf("_id of the keyed document '%s' must be an ObjectID reference", ref)
+				}
+				ids[ref] = oid
+			}
+		}
+	}
+
+	for _, coll := range collections {
+		c := db.Collection(coll.Name)
+		if cfg.truncate {
+			if _, err := c.RemoveAll(ctx, bson.M{}); err != nil {
+				return nil, fmt.Errorf("failed to truncate collection '%s': %w", coll.Name, err)
+			}
+		}
+		if len(coll.Rows) == 0 {
+			continue
+		}
+
+		docs := make([]any, 0, len(coll.Rows))
+		for i, row := range coll.Rows {
+			doc := make(bson.M, len(row))
+			for field, value := range row {
+				if field == KeyField {
+					continue
+				}
+				resolved, err := resolveRefs(value, ids)
+				if err != nil {
+					return nil, fmt.Errorf("collection '%s': %w", coll.Name, err)
+				}
+				doc[field] = resolved
+			}
+			if _, ok := doc["_id"]; !ok {
+				if key, ok := row[KeyField]; ok {
+					doc["_id"] = ids[coll.Name+"."+fmt.Sprint(key)]
+				} else {
+					doc["_id"] = ObjectID(fmt.Sprintf("%s#%d", coll.Name, i))
+				}
+			}
+			docs = append(docs, doc)
+		}
+		if _, err := c.InsertMany(ctx, docs); err != nil {
+			return nil, fmt.Errorf("failed to insert into collection '%s': %w", coll.Name, err)
+		}
+	}
+	return ids, nil
+}
+
+// resolveRefs replaces the references of a value by the ids, a nil ids
+// map resolves any reference, and converts JSON numbers for BSON
+func resolveRefs(v any, ids map[string]primitive.ObjectID) (any, error) {
+	switch v := v.(type) {
+	case string:
+		ref, ok := strings.CutPrefix(v, RefPrefix)
+		if !ok {
+			return v, nil
+		}
+		if ids == nil {
+			return ObjectID(ref), nil
+		}
+		id, ok := ids[ref]
+		if !ok {
+			return nil, fmt.Errorf("unknown reference '%s', known are %s", ref, strings.Join(refNames(ids), ", "))
+		}
+		return id, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case map[string]any:
+		doc := make(bson.M, len(v))
+		for field, value := range v {
+			resolved, err := resolveRefs(value, ids)
+			if err != nil {
+				return nil, err
+			}
+			doc[field] = resolved
+		}
+		return doc, nil
+	case []any:
+		list := make(bson.A, len(v))
+		for i, value := range v {
+			resolved, err := resolveRefs(value, ids)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	default:
+		return v, nil
+	}
+}
+
+func refNames(ids map[string]primitive.ObjectID) []string {
+	names := make([]string, 0, len(ids))
+	for name := range ids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
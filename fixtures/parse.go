@@ -0,0 +1,166 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func parseYAML(file string) ([]*Table, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("want a mapping of table names to rows")
+	}
+
+	// decode the mapping by hand, a map would lose the table order
+	var tables []*Table
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		table := &Table{Name: root.Content[i].Value}
+		var rows []map[string]any
+		if err = root.Content[i+1].Decode(&rows); err != nil {
+			return nil, fmt.Errorf("table '%s': %w", table.Name, err)
+		}
+		for _, row := range rows {
+			table.addRow(row)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func parseJSON(file string) ([]*Table, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// read the object token by token, a map would lose the table order
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("want an object of table names to rows")
+	}
+	var tables []*Table
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		table := &Table{Name: tok.(string)}
+		var rows []map[string]any
+		if err = dec.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("table '%s': %w", table.Name, err)
+		}
+		for _, row := range rows {
+			table.addRow(row)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func parseCSV(file string) ([]*Table, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := csv.NewReader(f)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	table := &Table{Name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), Columns: header}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(header))
+		for i, column := range header {
+			if record[i] == Null {
+				row[column] = nil
+			} else {
+				row[column] = record[i]
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return []*Table{table}, nil
+}
+
+// literal renders a fixture value as a SQL literal, nested objects and lists
+// become JSON strings for JSON columns
+func literal(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		return v.String()
+	case string:
+		return quoteString(v)
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quoteString(v.Format("2006-01-02 15:04:05.999999"))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return quoteString(fmt.Sprint(v))
+		}
+		return quoteString(string(data))
+	}
+}
+
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		"\x00", `\0`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\x1a", `\Z`,
+	).Replace(s) + "'"
+}
@@ -0,0 +1,120 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "null", value: nil, want: "NULL"},
+		{name: "true", value: true, want: "TRUE"},
+		{name: "false", value: false, want: "FALSE"},
+		{name: "int", value: -42, want: "-42"},
+		{name: "int64", value: int64(1) << 40, want: "1099511627776"},
+		{name: "uint64", value: uint64(1) << 63, want: "9223372036854775808"},
+		{name: "float", value: 1.5, want: "1.5"},
+		{name: "json number", value: json.Number("12345678901234567890"), want: "12345678901234567890"},
+		{name: "string", value: "it's", want: `'it\'s'`},
+		{name: "escapes", value: "a\\b\nc\r\x00\x1a", want: `'a\\b\nc\r\0\Z'`},
+		{name: "bytes", value: []byte{0xca, 0xfe}, want: "X'cafe'"},
+		{name: "time", value: time.Date(2024, 1, 2, 3, 4, 5, 120000000, time.UTC), want: "'2024-01-02 03:04:05.12'"},
+		{name: "time without fraction", value: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), want: "'2024-01-02 03:04:05'"},
+		{name: "object", value: map[string]any{"tags": []any{"a", "b"}}, want: `'{"tags":["a","b"]}'`},
+		{name: "list", value: []any{1, "x'y"}, want: `'[1,"x\'y"]'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := literal(tt.value); got != tt.want {
+				t.Errorf("literal(%v) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	file := writeFixture(t, "users.yaml", `
+users:
+  - id: 1
+    name: alice
+    score: 1.5
+    active: true
+    profile: {city: Berlin}
+  - id: 2
+    name: bob
+    email: null
+orders: []
+`)
+	tables, err := parseYAML(file)
+	if err != nil {
+		t.Fatalf("parseYAML() failed: %v", err)
+	}
+	if len(tables) != 2 || tables[0].Name != "users" || tables[1].Name != "orders" {
+		t.Fatalf("got tables %v, want users and orders in file order", tableNames(tables))
+	}
+
+	users := tables[0]
+	wantRows := []map[string]any{
+		{"id": 1, "name": "alice", "score": 1.5, "active": true, "profile": map[string]any{"city": "Berlin"}},
+		{"id": 2, "name": "bob", "email": nil},
+	}
+	if !reflect.DeepEqual(users.Rows, wantRows) {
+		t.Errorf("rows = %v, want %v", users.Rows, wantRows)
+	}
+	columns := slices.Sorted(slices.Values(users.Columns))
+	if want := []string{"active", "email", "id", "name", "profile", "score"}; !slices.Equal(columns, want) {
+		t.Errorf("columns = %v, want %v", columns, want)
+	}
+	if len(tables[1].Rows) != 0 {
+		t.Errorf("orders has %d rows, want none", len(tables[1].Rows))
+	}
+}
+
+func TestParseYAMLInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not a mapping":     "- id: 1\n",
+		"rows not a list":   "users: {id: 1}\n",
+		"row not an object": "users: [1, 2]\n",
+		"syntax":            "users: [\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseYAML(writeFixture(t, "bad.yaml", content)); err == nil {
+				t.Errorf("parseYAML() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseYAMLEmpty(t *testing.T) {
+	tables, err := parseYAML(writeFixture(t, "empty.yaml", ""))
+	if err != nil || len(tables) != 0 {
+		t.Errorf("parseYAML() = %v, %v, want no tables", tableNames(tables), err)
+	}
+}
+
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func tableNames(tables []*Table) []string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return names
+}
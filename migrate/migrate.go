@@ -0,0 +1,211 @@
+// Package migrate runs the SQL migrations of golang-migrate and goose
+// directories against MySQL compatible databases, the containers of the
+// container package and the mock server of the mysql package, recording them
+// in the version table of the tool so the tool itself sees them applied. The
+// version table is created in the SQL dialect of the database, StarRocks and
+// Doris reject the MySQL DDL.
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tool is the migration tool whose file layout and version table are used
+type Tool string
+
+const (
+	// GolangMigrate reads {version}_{title}.up.sql and .down.sql files and
+	// records the version in schema_migrations
+	GolangMigrate Tool = "golang-migrate"
+	// Goose reads {version}_{name}.sql files with -- +goose Up and Down
+	// sections and records the versions in goose_db_version
+	Goose Tool = "goose"
+)
+
+// Dialect is the SQL dialect the version table is created in
+type Dialect string
+
+const (
+	// MySQL is MySQL and the mock server of the mysql package
+	MySQL Dialect = "mysql"
+	// StarRocks keys the version table with a primary key table
+	StarRocks Dialect = "starrocks"
+	// Doris keys the version table with a unique key table
+	Doris Dialect = "doris"
+)
+
+// Migration is a version of the schema
+type Migration struct {
+	Version int64
+	Name    string
+	// Up and Down are the statements applying and reverting the migration
+	Up   []string
+	Down []string
+}
+
+// Load reads the migrations of dir, sorted by version
+func Load(dir string, tool Tool) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		prefix, rest, ok := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s, expected {version}_{name}", name)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		path := filepath.Join(dir, name)
+		switch tool {
+		case GolangMigrate:
+			var stmts *[]string
+			switch {
+			case strings.HasSuffix(rest, ".up.sql"):
+				m.Name, stmts = strings.TrimSuffix(rest, ".up.sql"), &m.Up
+			case strings.HasSuffix(rest, ".down.sql"):
+				m.Name, stmts = strings.TrimSuffix(rest, ".down.sql"), &m.Down
+			default:
+				return nil, fmt.Errorf("invalid migration file name %s, expected .up.sql or .down.sql", name)
+			}
+			if len(*stmts) > 0 {
+				return nil, fmt.Errorf("duplicate migration version %d", version)
+			}
+			if *stmts, err = parseFile(path); err != nil {
+				return nil, err
+			}
+		case Goose:
+			if m.Name != "" {
+				return nil, fmt.Errorf("duplicate migration version %d", version)
+			}
+			m.Name = strings.TrimSuffix(rest, ".sql")
+			if m.Up, m.Down, err = parseGoose(path); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown migration tool '%s'", tool)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseFile splits a golang-migrate file into statements
+func parseFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration: %w", err)
+	}
+	var s splitter
+	for _, line := range strings.Split(string(data), "\n") {
+		s.line(line)
+	}
+	return s.done(), nil
+}
+
+// parseGoose splits the Up and Down sections of a goose file into statements
+func parseGoose(path string) (up, down []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read migration: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var (
+		section *[]string
+		s       splitter
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if !ok {
+			if section != nil {
+				s.line(line)
+			}
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(annotation)) {
+		case "up", "down":
+			if section != nil {
+				*section = append(*section, s.done()...)
+			}
+			section = &up
+			if strings.EqualFold(strings.TrimSpace(annotation), "down") {
+				section = &down
+			}
+		case "statementbegin":
+			s.block = true
+		case "statementend":
+			s.block = false
+			s.flush()
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read migration %s: %w", filepath.Base(path), err)
+	}
+	if section == nil {
+		return nil, nil, fmt.Errorf("migration %s has no -- +goose Up section", filepath.Base(path))
+	}
+	*section = append(*section, s.done()...)
+	return up, down, nil
+}
+
+// splitter splits SQL into statements the way goose does: a statement ends
+// with the line ending with a semicolon, except within a StatementBegin and
+// StatementEnd block which is a single statement
+type splitter struct {
+	buf   strings.Builder
+	stmts []string
+	block bool
+}
+
+func (s *splitter) line(line string) {
+	trimmed := strings.TrimSpace(line)
+	if s.buf.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "#")) {
+		return
+	}
+	s.buf.WriteString(line + "\n")
+	if !s.block && strings.HasSuffix(trimmed, ";") {
+		s.flush()
+	}
+}
+
+func (s *splitter) flush() {
+	stmt := strings.TrimSpace(s.buf.String())
+	stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+	if stmt != "" {
+		s.stmts = append(s.stmts, stmt)
+	}
+	s.buf.Reset()
+}
+
+func (s *splitter) done() []string {
+	s.flush()
+	stmts := s.stmts
+	s.stmts = nil
+	return stmts
+}
@@ -0,0 +1,228 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// versionTable is the version bookkeeping of a tool, in the schema of the tool
+type versionTable struct {
+	create string
+	// current returns the version applied last, none if no row
+	current string
+	// applied returns the statements recording the version as applied
+	applied func(version int64) []string
+	// reverted returns the statements recording the version as reverted,
+	// previous being the version before it, 0 if none
+	reverted func(version, previous int64) []string
+}
+
+var versionTables = map[Tool]versionTable{
+	GolangMigrate: {
+		create:   "CREATE TABLE IF NOT EXISTS `schema_migrations` (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)",
+		current:  "SELECT version FROM `schema_migrations` LIMIT 1",
+		applied:  migrateApplied,
+		reverted: migrateReverted,
+	},
+	Goose: {
+		create:  "CREATE TABLE IF NOT EXISTS `goose_db_version` (id serial NOT NULL, version_id bigint NOT NULL, is_applied boolean NOT NULL, tstamp timestamp NULL DEFAULT now(), PRIMARY KEY (id))",
+		current: gooseCurrent,
+		applied: func(version int64) []string {
+			return []string{"INSERT INTO `goose_db_version` (version_id, is_applied) VALUES (" + strconv.FormatInt(version, 10) + ", true)"}
+		},
+		reverted: gooseReverted,
+	},
+}
+
+// olapVersionTables are the version tables of StarRocks and Doris, keyed by
+// a single bucket table with the key model of keys, e.g. "PRIMARY KEY". They
+// have no auto increment or column defaults, goose's id and tstamp are set
+// by the insert.
+func olapVersionTables(keys string) map[Tool]versionTable {
+	const props = " DISTRIBUTED BY HASH(%s) BUCKETS 1 PROPERTIES (\"replication_num\" = \"1\")"
+	return map[Tool]versionTable{
+		GolangMigrate: {
+			create: "CREATE TABLE IF NOT EXISTS `schema_migrations` (version bigint NOT NULL, dirty boolean NOT NULL) " +
+				keys + " (version)" + fmt.Sprintf(props, "version"),
+			current:  "SELECT version FROM `schema_migrations` LIMIT 1",
+			applied:  migrateApplied,
+			reverted: migrateReverted,
+		},
+		Goose: {
+			create: "CREATE TABLE IF NOT EXISTS `goose_db_version` (id bigint NOT NULL, version_id bigint NOT NULL, is_applied boolean NOT NULL, tstamp datetime NULL) " +
+				keys + " (id)" + fmt.Sprintf(props, "id"),
+			current: gooseCurrent,
+			applied: func(version int64) []string {
+				return []string{"INSERT INTO `goose_db_version` (id, version_id, is_applied, tstamp) SELECT COALESCE(MAX(id), 0) + 1, " +
+					strconv.FormatInt(version, 10) + ", true, now() FROM `goose_db_version`"}
+			},
+			reverted: gooseReverted,
+		},
+	}
+}
+
+var dialectVersionTables = map[Dialect]map[Tool]versionTable{
+	MySQL:     versionTables,
+	StarRocks: olapVersionTables("PRIMARY KEY"),
+	Doris:     olapVersionTables("UNIQUE KEY"),
+}
+
+const gooseCurrent = "SELECT version_id FROM `goose_db_version` WHERE is_applied ORDER BY id DESC LIMIT 1"
+
+func migrateApplied(version int64) []string {
+	return []string{"TRUNCATE TABLE `schema_migrations`",
+		"INSERT INTO `schema_migrations` (version, dirty) VALUES (" + strconv.FormatInt(version, 10) + ", false)"}
+}
+
+func migrateReverted(_, previous int64) []string {
+	stmts := []string{"TRUNCATE TABLE `schema_migrations`"}
+	if previous > 0 {
+		stmts = append(stmts, "INSERT INTO `schema_migrations` (version, dirty) VALUES ("+strconv.FormatInt(previous, 10)+", false)")
+	}
+	return stmts
+}
+
+func gooseReverted(version, _ int64) []string {
+	return []string{"DELETE FROM `goose_db_version` WHERE version_id = " + strconv.FormatInt(version, 10)}
+}
+
+// lookupVersionTable returns the version table of the tool in the dialect
+func lookupVersionTable(tool Tool, dialect Dialect) (versionTable, error) {
+	tables, ok := dialectVersionTables[dialect]
+	if !ok {
+		return versionTable{}, fmt.Errorf("unknown sql dialect '%s'", dialect)
+	}
+	table, ok := tables[tool]
+	if !ok {
+		return versionTable{}, fmt.Errorf("unknown migration tool '%s'", tool)
+	}
+	return table, nil
+}
+
+// Statements returns the statements creating the version table and applying
+// all the migrations of dir to an empty database, e.g. to run them as init
+// statements
+func Statements(dir string, tool Tool, dialect Dialect) ([]string, error) {
+	table, err := lookupVersionTable(tool, dialect)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(dir, tool)
+	if err != nil {
+		return nil, err
+	}
+	stmts := []string{table.create}
+	for _, m := range migrations {
+		stmts = append(stmts, m.Up...)
+		stmts = append(stmts, table.applied(m.Version)...)
+	}
+	return stmts, nil
+}
+
+// Version returns the version the database is migrated to, 0 if none
+func Version(ctx context.Context, db *sql.DB, tool Tool, dialect Dialect) (int64, error) {
+	table, err := lookupVersionTable(tool, dialect)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = db.ExecContext(ctx, table.create); err != nil {
+		return 0, fmt.Errorf("failed to create version table: %w", err)
+	}
+	var version int64
+	err = db.QueryRowContext(ctx, table.current).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read version: %w", err)
+	}
+	return version, nil
+}
+
+// Up applies the migrations of dir newer than the version of the database,
+// recording them in the version table of the tool in the dialect
+func Up(ctx context.Context, db *sql.DB, dir string, tool Tool, dialect Dialect) error {
+	migrations, err := Load(dir, tool)
+	if err != nil {
+		return err
+	}
+	current, err := Version(ctx, db, tool, dialect)
+	if err != nil {
+		return err
+	}
+	// one connection, so the session state of a migration holds for its statements
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	table, _ := lookupVersionTable(tool, dialect)
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err = exec(ctx, conn, m.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if err = exec(ctx, conn, table.applied(m.Version)); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts the last steps migrations applied to the database, all of
+// them if steps is 0 or less
+func Down(ctx context.Context, db *sql.DB, dir string, tool Tool, dialect Dialect, steps int) error {
+	migrations, err := Load(dir, tool)
+	if err != nil {
+		return err
+	}
+	current, err := Version(ctx, db, tool, dialect)
+	if err != nil {
+		return err
+	}
+	if steps <= 0 {
+		steps = len(migrations)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	table, _ := lookupVersionTable(tool, dialect)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current {
+			continue
+		}
+		if steps == 0 {
+			break
+		}
+		steps--
+
+		if err = exec(ctx, conn, m.Down); err != nil {
+			return fmt.Errorf("failed to revert migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		var previous int64
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+		if err = exec(ctx, conn, table.reverted(m.Version, previous)); err != nil {
+			return fmt.Errorf("failed to record revert of migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+func exec(ctx context.Context, conn *sql.Conn, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w, statement: %s", err, stmt)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package mtest
+
+import (
+	"github.com/dennis2006/mtest/container"
+	"github.com/dennis2006/mtest/mysql"
+	"github.com/jmoiron/sqlx"
+	"testing"
+)
+
+// MySQLMock returns a client of a database of the test on the process wide
+// mock MySQL server, with the init sources run in it, e.g.
+// mysql.Files("schema.sql"). The database is dropped when the test ends. It
+// needs no docker, see mysql.Builder for a server of the test's own.
+func MySQLMock(t testing.TB, sources ...mysql.InitSource) *sqlx.DB {
+	t.Helper()
+	return mysql.Global().Database(t, sources...)
+}
+
+// MySQL starts a MySQL container for the test, terminated when it ends. The
+// test is skipped like Requires does without docker.
+func MySQL(t testing.TB, opts ...container.Option) *container.MySQLContainer {
+	t.Helper()
+	requiresService(t, NeedMySQL)
+	return container.CreateMySQLContainerT(t, opts...)
+}
+
+// Redis starts a Redis container for the test, terminated when it ends. The
+// test is skipped like Requires does without docker.
+func Redis(t testing.TB, opts ...container.Option) *container.RedisContainer {
+	t.Helper()
+	requiresService(t, NeedRedis)
+	return container.CreateRedisContainerT(t, opts...)
+}
+
+// Mongo starts a MongoDB container for the test, terminated when it ends.
+// The test is skipped like Requires does without docker.
+func Mongo(t testing.TB, opts ...container.Option) *container.MongoDBContainer {
+	t.Helper()
+	requiresService(t, NeedMongoDB)
+	return container.CreateMongoDBContainerT(t, opts...)
+}
+
+// Doris starts a Doris container for the test, terminated when it ends. The
+// test is skipped like Requires does without docker or its memory.
+func Doris(t testing.TB, opts ...container.Option) *container.DorisContainer {
+	t.Helper()
+	requiresService(t, NeedDoris)
+	return container.CreateDorisContainerT(t, opts...)
+}
+
+// requiresService is Requires without the image of the requirement, the
+// options may run another one
+func requiresService(t testing.TB, req Requirement) {
+	t.Helper()
+	req.Image = ""
+	Requires(t, req)
+}
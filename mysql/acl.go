@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"github.com/dolthub/go-mysql-server/server"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	querypb "github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"regexp"
+	"strings"
+)
+
+// erTableAccessDenied is ER_TABLEACCESS_DENIED_ERROR, which vitess lacks
+const erTableAccessDenied = 1142
+
+var (
+	// the privilege errors of the engine, all sent as error 1105
+	commandDeniedRe  = regexp.MustCompile(`^command denied to user (.+)$`)
+	tableDeniedRe    = regexp.MustCompile(`^Access denied for user (.+) to table '(.+)'$`)
+	databaseDeniedRe = regexp.MustCompile(`^Access denied for user (.+) to database '(.+)'$`)
+)
+
+// aclInterceptor turns the privilege errors of the engine into the errors
+// MySQL returns, 1142 "command denied" naming the command and table and
+// 1044 "access denied" naming the database, for code checking the codes.
+type aclInterceptor struct {
+	grants []mockGrant
+	// currentDB returns the database of the connection, the server is
+	// created after the interceptor
+	currentDB func(c *vmysql.Conn) string
+}
+
+var _ server.Interceptor = (*aclInterceptor)(nil)
+
+func newACLInterceptor(grants []mockGrant) *aclInterceptor {
+	return &aclInterceptor{grants: grants, currentDB: func(*vmysql.Conn) string { return "" }}
+}
+
+func (a *aclInterceptor) Priority() int {
+	return 0
+}
+
+func (a *aclInterceptor) Query(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) error {
+	return a.rewrite(c, query, chain.ComQuery(ctx, c, query, callback))
+}
+
+func (a *aclInterceptor) ParsedQuery(chain server.Chain, c *vmysql.Conn, query string, parsed sqlparser.Statement, callback func(res *sqltypes.Result, more bool) error) error {
+	return a.rewrite(c, query, chain.ComQuery(context.Background(), c, query, callback))
+}
+
+func (a *aclInterceptor) MultiQuery(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) (string, error) {
+	rest, err := chain.ComMultiQuery(ctx, c, query, callback)
+	return rest, a.rewrite(c, query, err)
+}
+
+func (a *aclInterceptor) Prepare(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, prepare *vmysql.PrepareData) ([]*querypb.Field, error) {
+	fields, err := chain.ComPrepare(ctx, c, query, prepare)
+	return fields, a.rewrite(c, query, err)
+}
+
+func (a *aclInterceptor) StmtExecute(ctx context.Context, chain server.Chain, c *vmysql.Conn, prepare *vmysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	return a.rewrite(c, prepare.PrepareStmt, chain.ComStmtExecute(ctx, c, prepare, callback))
+}
+
+// rewrite returns the MySQL error for a privilege error of the query
+func (a *aclInterceptor) rewrite(c *vmysql.Conn, query string, err error) error {
+	var sqlErr *vmysql.SQLError
+	if err == nil || !errors.As(err, &sqlErr) || sqlErr.Number() != vmysql.ERUnknownError {
+		return err
+	}
+	msg := sqlErr.Message
+	if m := databaseDeniedRe.FindStringSubmatch(msg); m != nil {
+		return vmysql.NewSQLError(vmysql.ERDBAccessDenied, vmysql.SSClientError, "Access denied for user %s to database '%s'", m[1], m[2])
+	}
+
+	var account, table string
+	if m := tableDeniedRe.FindStringSubmatch(msg); m != nil {
+		account, table = m[1], m[2]
+	} else if m = commandDeniedRe.FindStringSubmatch(msg); m != nil {
+		account = m[1]
+	} else {
+		return err
+	}
+	command := statementCommand(query)
+	if table == "" {
+		if table = a.deniedTable(c, query, command); table == "" {
+			return err
+		}
+	}
+	return vmysql.NewSQLError(erTableAccessDenied, vmysql.SSClientError, "%s command denied to user %s for table '%s'", command, account, table)
+}
+
+// deniedTable returns the first table of the query the user lacks the
+// privilege for by the grants of the builder, else the first table, since
+// the engine doesn't name it
+func (a *aclInterceptor) deniedTable(c *vmysql.Conn, query, command string) string {
+	parsed, err := sqlparser.Parse(query)
+	if err != nil {
+		return ""
+	}
+	var tables []sqlparser.TableName
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if name, ok := node.(sqlparser.TableName); ok && !name.IsEmpty() {
+			tables = append(tables, name)
+		}
+		return true, nil
+	}, parsed)
+	if len(tables) == 0 {
+		return ""
+	}
+	for _, table := range tables {
+		db := table.DbQualifier.String()
+		if db == "" {
+			db = a.currentDB(c)
+		}
+		if !a.granted(c.User, db, table.Name.String(), command) {
+			return table.Name.String()
+		}
+	}
+	return tables[0].Name.String()
+}
+
+// granted reports whether a grant of the builder gives the user the
+// privilege on the table
+func (a *aclInterceptor) granted(user, db, table, privilege string) bool {
+	for _, g := range a.grants {
+		if g.user != user || (g.db != "*" && !strings.EqualFold(g.db, db)) || (g.table != "" && !strings.EqualFold(g.table, table)) {
+			continue
+		}
+		for _, p := range g.privileges {
+			p = strings.ToUpper(strings.TrimSpace(p))
+			if p == privilege || p == "ALL" || p == "ALL PRIVILEGES" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// statementCommand returns the command MySQL names in its errors for the
+// statement, its privilege
+func statementCommand(query string) string {
+	query = strings.TrimSpace(strings.TrimPrefix(query, internalQueryPrefix))
+	keyword, _, _ := strings.Cut(query, " ")
+	switch keyword = strings.ToUpper(strings.TrimRight(keyword, "(;")); keyword {
+	case "WITH", "TABLE":
+		return "SELECT"
+	case "REPLACE":
+		return "INSERT"
+	case "TRUNCATE":
+		return "DROP"
+	case "RENAME":
+		return "ALTER"
+	}
+	return keyword
+}
@@ -0,0 +1,233 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// IndexAdvice is a table fully scanned by a query, while filtering or
+// joining on columns that have no index starting with them.
+type IndexAdvice struct {
+	Query   string
+	Table   string
+	Columns []string
+	Plan    string
+}
+
+func (a IndexAdvice) String() string {
+	return fmt.Sprintf("full scan of table '%s' filtered on (%s), consider an index: %s",
+		a.Table, strings.Join(a.Columns, ", "), a.Query)
+}
+
+// IndexAdvisor records the executed statements, so IndexAdvice can
+// explain them and report the full table scans an index would avoid.
+func (b *MockBuilder) IndexAdvisor() *MockBuilder {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	b.recorder.logQueries = true
+	return b
+}
+
+// IndexAdvice explains every distinct SELECT, UPDATE and DELETE statement
+// executed so far and returns the full table scans filtered or joined
+// on columns without an index.
+func (b *MockBuilder) IndexAdvice(ctx context.Context) ([]IndexAdvice, error) {
+	b.recorder.mu.Lock()
+	if !b.recorder.logQueries {
+		b.recorder.mu.Unlock()
+		return nil, fmt.Errorf("index advisor is not enabled")
+	}
+	queries := append([]RecordedQuery(nil), b.recorder.queries...)
+	b.recorder.mu.Unlock()
+
+	indexed, err := b.leadingIndexColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var advice []IndexAdvice
+	seen := make(map[string]bool)
+	for _, q := range queries {
+		query, ok := advisableQuery(q)
+		if !ok || seen[query] {
+			continue
+		}
+		seen[query] = true
+
+		var plan strings.Builder
+		rows, err := b.sqlDB.QueryContext(ctx, internalQueryPrefix+"EXPLAIN PLAN "+query)
+		if err != nil {
+			// e.g. the tables have been dropped since
+			continue
+		}
+		for rows.Next() {
+			var line string
+			if err = rows.Scan(&line); err != nil {
+				break
+			}
+			plan.WriteString(line + "\n")
+		}
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to explain '%s': %w", query, err)
+		}
+
+		for _, scan := range fullScans(plan.String()) {
+			var columns []string
+			for _, column := range scan.columns {
+				if !indexed[scan.table+"."+column] {
+					columns = append(columns, column)
+				}
+			}
+			if len(columns) > 0 {
+				advice = append(advice, IndexAdvice{Query: query, Table: scan.table, Columns: columns, Plan: plan.String()})
+			}
+		}
+	}
+	return advice, nil
+}
+
+// LogIndexAdvice logs the index advice as a soft assertion that does not fail the test.
+func (b *MockBuilder) LogIndexAdvice(t testing.TB) {
+	t.Helper()
+
+	advice, err := b.IndexAdvice(context.Background())
+	if err != nil {
+		t.Logf("failed to get index advice: %v", err)
+		return
+	}
+	for _, a := range advice {
+		t.Logf("index advice: %s", a)
+	}
+}
+
+// AssertNoFullScans asserts that no statement executed so far fully scanned
+// a table filtered or joined on columns without an index.
+func (b *MockBuilder) AssertNoFullScans(t testing.TB) bool {
+	t.Helper()
+
+	advice, err := b.IndexAdvice(context.Background())
+	if err != nil {
+		t.Errorf("failed to get index advice: %v", err)
+		return false
+	}
+	for _, a := range advice {
+		t.Errorf("%s\n%s", a, a.Plan)
+	}
+	return len(advice) == 0
+}
+
+// advisableQuery returns the query to explain with the parameter values
+// of a prepared statement inlined, since the plan depends on them.
+func advisableQuery(q RecordedQuery) (string, bool) {
+	stmt, err := sqlparser.Parse(q.Query)
+	if err != nil {
+		return "", false
+	}
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return "", false
+	}
+	if len(q.bindVars) == 0 {
+		return q.Query, true
+	}
+	query, err := sqlparser.NewParsedQuery(stmt).GenerateQuery(q.bindVars, nil)
+	if err != nil {
+		return "", false
+	}
+	return query, true
+}
+
+// leadingIndexColumns returns the "table.column" set of the columns
+// an index of the current database starts with.
+func (b *MockBuilder) leadingIndexColumns(ctx context.Context) (map[string]bool, error) {
+	rows, err := b.sqlDB.QueryContext(ctx, internalQueryPrefix+
+		"SELECT table_name, column_name FROM information_schema.statistics "+
+		"WHERE table_schema = DATABASE() AND seq_in_index = 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	indexed := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err = rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan indexes: %w", err)
+		}
+		indexed[strings.ToLower(table+"."+column)] = true
+	}
+	return indexed, rows.Err()
+}
+
+type fullScan struct {
+	table   string
+	columns []string
+}
+
+var (
+	planNodeRegex    = regexp.MustCompile(`^[\s│├└─]*`)
+	planTableRegex   = regexp.MustCompile(`^name: (\S+)$`)
+	planAliasRegex   = regexp.MustCompile(`^TableAlias\((\S+)\)$`)
+	planColumnsRegex = regexp.MustCompile("`?([A-Za-z_][\\w$]*)`?\\.`?([A-Za-z_][\\w$]*)`?")
+)
+
+// fullScans parses a plan of EXPLAIN PLAN and returns the fully scanned tables
+// (Table nodes, as opposed to IndexedTableAccess) with the columns the filter
+// and join predicates of the plan compare on.
+func fullScans(plan string) []fullScan {
+	lines := strings.Split(strings.TrimSpace(plan), "\n")
+
+	// "alias or table name" -> table name of the scanned tables
+	scanned := make(map[string]string)
+	var predicates []string
+	for i, line := range lines {
+		node := planNodeRegex.ReplaceAllString(line, "")
+		switch {
+		case node == "Table" && i+1 < len(lines):
+			m := planTableRegex.FindStringSubmatch(planNodeRegex.ReplaceAllString(lines[i+1], ""))
+			if m == nil {
+				continue
+			}
+			table := strings.ToLower(m[1])
+			name := table
+			if i > 0 {
+				if alias := planAliasRegex.FindStringSubmatch(planNodeRegex.ReplaceAllString(lines[i-1], "")); alias != nil {
+					name = strings.ToLower(alias[1])
+				}
+			}
+			scanned[name] = table
+		case strings.HasPrefix(node, "("):
+			predicates = append(predicates, node)
+		}
+	}
+
+	columns := make(map[string]map[string]struct{})
+	for _, predicate := range predicates {
+		for _, m := range planColumnsRegex.FindAllStringSubmatch(predicate, -1) {
+			table, ok := scanned[strings.ToLower(m[1])]
+			if !ok {
+				continue
+			}
+			if columns[table] == nil {
+				columns[table] = make(map[string]struct{})
+			}
+			columns[table][strings.ToLower(m[2])] = struct{}{}
+		}
+	}
+
+	scans := make([]fullScan, 0, len(columns))
+	for table, set := range columns {
+		scans = append(scans, fullScan{table: table, columns: sortedKeys(set)})
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].table < scans[j].table
+	})
+	return scans
+}
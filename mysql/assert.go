@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"testing"
+)
+
+// AssertTableExists asserts that the table exists in the current database of db.
+// It works against both the mock server and real MySQL/Doris containers.
+func AssertTableExists(t testing.TB, db *sqlx.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+		name,
+	).Scan(&count)
+	if err != nil {
+		t.Errorf("failed to query table '%s': %v", name, err)
+		return false
+	}
+	if count == 0 {
+		t.Errorf("table '%s' does not exist, tables: %v", name, showTables(db))
+		return false
+	}
+	return true
+}
+
+// AssertColumn asserts that the column exists in the table and has the given type.
+// typ is compared case-insensitively with either the full column type (e.g. "varchar(255)")
+// or the bare data type (e.g. "varchar"); an empty typ only checks the column exists.
+func AssertColumn(t testing.TB, db *sqlx.DB, table, column, typ string) bool {
+	t.Helper()
+
+	var columnType, dataType string
+	err := db.QueryRow(
+		"SELECT column_type, data_type FROM information_schema.columns "+
+			"WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+		table, column,
+	).Scan(&columnType, &dataType)
+	if errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("column '%s.%s' does not exist", table, column)
+		return false
+	}
+	if err != nil {
+		t.Errorf("failed to query column '%s.%s': %v", table, column, err)
+		return false
+	}
+
+	if typ != "" && !strings.EqualFold(typ, columnType) && !strings.EqualFold(typ, dataType) {
+		t.Errorf("column '%s.%s' has type '%s', want '%s'", table, column, columnType, typ)
+		return false
+	}
+	return true
+}
+
+// showTables lists the tables of the current database for failure messages.
+func showTables(db *sqlx.DB) []string {
+	var tables []string
+	_ = db.Select(&tables, "SHOW TABLES")
+	return tables
+}
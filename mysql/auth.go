@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/dennis2006/mtest/dsn"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"strings"
+)
+
+type mockUser struct {
+	name     string
+	password string
+}
+
+type mockGrant struct {
+	user string
+	db   string
+	// table is empty for the grants on the whole database
+	table      string
+	privileges []string
+}
+
+// WithUser adds a user account with the password, see WithGrants for its
+// privileges and DSN for connecting as it. Adding users turns on the
+// authentication of the server; root keeps all privileges.
+func (b *MockBuilder) WithUser(name, password string) *MockBuilder {
+	if strings.EqualFold(name, "root") {
+		b.err = fmt.Errorf("user 'root' is built in, set its password with RequireAuth")
+		return b
+	}
+	b.users = append(b.users, mockUser{name: name, password: password})
+	return b
+}
+
+// WithGrants grants the privileges on the database to a user of WithUser,
+// e.g. WithGrants("app", "shop", "SELECT", "INSERT"). A db of "*" grants
+// them on all databases, privileges default to ALL.
+func (b *MockBuilder) WithGrants(user, db string, privileges ...string) *MockBuilder {
+	if len(privileges) == 0 {
+		privileges = []string{"ALL"}
+	}
+	b.grants = append(b.grants, mockGrant{user: user, db: db, privileges: privileges})
+	return b
+}
+
+// WithTableGrants grants the privileges on a table of the database to a user
+// of WithUser, e.g. WithTableGrants("app", "shop", "orders", "SELECT"), so
+// the user may only access the tables granted to it. Statements on other
+// tables fail like on MySQL, with error 1142 "SELECT command denied to user
+// 'app'@'%' for table 'customers'". Privileges default to ALL.
+func (b *MockBuilder) WithTableGrants(user, db, table string, privileges ...string) *MockBuilder {
+	if db == "*" || table == "*" || table == "" {
+		b.err = fmt.Errorf("table grants need a database and table, use WithGrants for whole databases")
+		return b
+	}
+	if len(privileges) == 0 {
+		privileges = []string{"ALL"}
+	}
+	b.grants = append(b.grants, mockGrant{user: user, db: db, table: table, privileges: privileges})
+	return b
+}
+
+// RequireAuth turns on the authentication of the server and sets the
+// password of root, instead of the open root account accepting any user.
+// The handles returned by Build connect as root with the password.
+func (b *MockBuilder) RequireAuth(rootPassword string) *MockBuilder {
+	b.requireAuth = true
+	b.rootPassword = rootPassword
+	return b
+}
+
+// DSN returns the DSN connecting to the database of the started server as
+// the user, root or one added with WithUser. When served InProcess, it only
+// connects from this process.
+func (b *MockBuilder) DSN(user string) (string, error) {
+	if !b.started.Load() {
+		return "", fmt.Errorf("mysql server not started")
+	}
+	password, ok := b.rootPassword, strings.EqualFold(user, "root")
+	for _, u := range b.users {
+		if u.name == user {
+			password, ok = u.password, true
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown user '%s'", user)
+	}
+	return b.mockDSN(user, password, b.dbName), nil
+}
+
+// mockDSN returns the DSN of the server for the user and database
+func (b *MockBuilder) mockDSN(user, password string, dbName string) string {
+	d := dsn.NewMySQL("127.0.0.1", b.port).Credentials(user, password).Database(dbName)
+	if b.inProcess {
+		d.Network(b.network)
+	}
+	return d.String()
+}
+
+// authEnabled reports whether the server checks credentials and privileges
+func (b *MockBuilder) authEnabled() bool {
+	return b.requireAuth || len(b.users) > 0
+}
+
+// enableAuth adds the root account to the server, which turns on the
+// authentication
+func (b *MockBuilder) enableAuth() {
+	mysqlDb := b.server.Engine.Analyzer.Catalog.MySQLDb
+	// the accounts live as long as the server
+	mysqlDb.SetPersister(&mysql_db.NoopPersister{})
+	ed := mysqlDb.Editor()
+	defer ed.Close()
+	mysqlDb.AddSuperUser(ed, "root", "localhost", b.rootPassword)
+}
+
+// createUsers creates the users of WithUser and grants their privileges
+func (b *MockBuilder) createUsers() error {
+	for _, u := range b.users {
+		query := fmt.Sprintf("CREATE USER %s IDENTIFIED BY %s", accountName(u.name), quoteString(u.password))
+		if _, err := b.sqlxDB.Exec(internalQueryPrefix + query); err != nil {
+			return fmt.Errorf("failed to create user '%s': %w", u.name, err)
+		}
+	}
+	for _, g := range b.grants {
+		on := "*.*"
+		switch {
+		case g.table != "":
+			on = quoteIdent(g.db) + "." + quoteIdent(g.table)
+		case g.db != "*":
+			on = quoteIdent(g.db) + ".*"
+		}
+		query := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(g.privileges, ", "), on, accountName(g.user))
+		if _, err := b.sqlxDB.Exec(internalQueryPrefix + query); err != nil {
+			return fmt.Errorf("failed to grant privileges to '%s': %w", g.user, err)
+		}
+	}
+	return nil
+}
+
+func accountName(user string) string {
+	return quoteString(user) + "@'%'"
+}
@@ -1,59 +1,182 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/dennis2006/mtest/migrate"
 	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"log"
+	"io"
 	"net"
 	"os"
-	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"testing"
+	"time"
 )
 
 // MockBuilder struct for building and managing the mock MySQL server
 type MockBuilder struct {
-	dbName  string
-	port    int
-	server  *server.Server
-	sqlDB   *sql.DB
-	sqlxDB  *sqlx.DB
-	err     error
-	started atomic.Bool
+	dbName   string
+	extraDBs []string
+	port     int
+	server   *server.Server
+	provider gmssql.DatabaseProvider
+	sqlDB    *sql.DB
+	sqlxDB   *sqlx.DB
+	err      error
+	started  atomic.Bool
 
 	sqlStmts []string
 	sqlFiles []string
+	sources  []InitSource
+
+	templateVars map[string]any
+
+	codecs *Codecs
+	seeds  []seedRows
+	masks  map[string]map[string]MaskFunc
+
+	maxRowsPerTable uint64
+	maxMemory       uint64
+
+	lint      bool
+	lintRules []LintRule
+
+	isolation *isolationInterceptor
+	recorder  *recorder
+	faults    *faultInterceptor
+	clock     *mockClock
+
+	functions   []gmssql.Function
+	compatShims bool
+	logger      func(format string, args ...any)
+
+	users        []mockUser
+	grants       []mockGrant
+	requireAuth  bool
+	rootPassword string
+
+	snapshotMu sync.Mutex
+	snapshot   []snapshotTable
+
+	lifecycle    *lifecycle
+	drainTimeout time.Duration
+
+	persistDir         string
+	persistFingerprint string
+
+	inProcess bool
+	network   string
+
+	migrationsDir  string
+	migrationsTool migrate.Tool
 }
 
 // Builder initializes a new MockBuilder instance with db name,
 // if db name is not provided, gmm would generate a random db name.
+// Further names add databases like AddDatabase.
 func Builder(db ...string) *MockBuilder {
 	b := &MockBuilder{
 		sqlStmts: make([]string, 0),
 		sqlFiles: make([]string, 0),
 		started:  atomic.Bool{},
+		recorder: newRecorder(),
+		faults:   newFaultInterceptor(),
+		clock:    newMockClock(),
+		codecs:   NewCodecs(),
 	}
 	dbName := "test-db-" + uuid.NewString()[:6]
 	if len(db) > 0 {
 		dbName = db[0]
+		b.extraDBs = db[1:]
 	}
 	b.dbName = dbName
 	return b
 }
 
+// AddDatabase adds databases next to the one of the builder, e.g. to run
+// queries joining across schemas. Scope init statements to them with
+// SQLStmtsIn and SQLFilesIn, or qualify the table names.
+func (b *MockBuilder) AddDatabase(names ...string) *MockBuilder {
+	b.extraDBs = append(b.extraDBs, names...)
+	return b
+}
+
+// databases returns the database of the builder and the ones of AddDatabase
+func (b *MockBuilder) databases() []string {
+	return append([]string{b.dbName}, b.extraDBs...)
+}
+
+// schemaFilter returns the condition on table_schema of information_schema
+// matching the databases of the builder, and its args
+func (b *MockBuilder) schemaFilter() (string, []any) {
+	dbs := b.databases()
+	args := make([]any, len(dbs))
+	for i, db := range dbs {
+		args[i] = db
+	}
+	return "table_schema IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(dbs)), ", ") + ")", args
+}
+
+// qualifiedName returns the name of the table in the database, qualified
+// unless it is the database of the builder
+func (b *MockBuilder) qualifiedName(db, table string) string {
+	if db == "" || strings.EqualFold(db, b.dbName) {
+		return table
+	}
+	return db + "." + table
+}
+
 // Port sets the port for the MySQL server,
-// if not set, gmm would generate a port start from 19527
+// if not set or 0, the OS chooses a free port when the server is bound
 func (b *MockBuilder) Port(port int) *MockBuilder {
 	b.port = port
 	return b
 }
 
+// MaxRowsPerTable sets the max rows a single table may hold,
+// statements that make any table exceed the limit fail with an error.
+func (b *MockBuilder) MaxRowsPerTable(rows uint64) *MockBuilder {
+	b.maxRowsPerTable = rows
+	return b
+}
+
+// MaxMemory sets the max total data size in bytes of all tables, estimated
+// by the engine the same way as information_schema.tables.data_length.
+// Statements that make the data size exceed the limit fail with an error.
+func (b *MockBuilder) MaxMemory(bytes uint64) *MockBuilder {
+	b.maxMemory = bytes
+	return b
+}
+
+// Logf routes the messages of the builder to logf instead of the logger of
+// SetLogger, e.g. t.Logf; BuildT does it for the test.
+func (b *MockBuilder) Logf(logf func(format string, args ...any)) *MockBuilder {
+	b.logger = logf
+	return b
+}
+
+func (b *MockBuilder) logf(format string, args ...any) {
+	if b.logger != nil {
+		b.logger(format, args...)
+		return
+	}
+	logDefault(format, args...)
+}
+
 // GetPort returns the port of the MySQL server,
 // if not set, gmm would return the port of the server.
+// It is 0 when the server is served in process.
 func (b *MockBuilder) GetPort() int {
+	if b.inProcess {
+		return 0
+	}
 	if b.port == 0 {
 		return b.server.Listener.Addr().(*net.TCPAddr).Port
 	}
@@ -61,7 +184,12 @@ func (b *MockBuilder) GetPort() int {
 }
 
 // Build initializes and starts the MySQL server, returns handles to SQL and Gorm DB
+// and a func shutting the server down, see BuildContext.
 func (b *MockBuilder) Build() (*sqlx.DB, *sql.DB, func(), error) {
+	return b.build(context.Background())
+}
+
+func (b *MockBuilder) build(ctx context.Context) (*sqlx.DB, *sql.DB, func(), error) {
 	if b.err != nil {
 		return nil, nil, nil, b.err
 	}
@@ -70,64 +198,128 @@ func (b *MockBuilder) Build() (*sqlx.DB, *sql.DB, func(), error) {
 		return nil, nil, nil, errors.New("mysql server already started")
 	}
 
-	// If not specify port, get an unused one form local machine.
-	//
-	// NOTE: The `getFreePort` method indeed has the limitation
-	// that it cannot guarantee the port will remain available.
-	// While it can find a currently unused port,
-	// there is a possibility that the port might be occupied
-	// by another process between the time the port number is
-	// retrieved and the moment it is actually used.
-	if b.port == 0 {
-		var listener net.Listener
-		listener, b.port, b.err = getFreePort()
+	// Bind the listener before creating the server and read the actual port
+	// back from it, so a port chosen by the OS can't be taken by another
+	// process in between.
+	var listener net.Listener
+	if !b.inProcess {
+		port := b.port
+		listener, b.port, b.err = listenPort(port)
+		if b.err != nil && port == 0 && bindDenied(b.err) {
+			b.logf("binding a port is denied (%v), serving in process", b.err)
+			b.inProcess, b.err = true, nil
+		}
 		if b.err != nil {
 			return nil, nil, nil, b.err
 		}
-		_ = listener.Close()
 	}
+	if b.inProcess {
+		pl := listenInProcess()
+		listener, b.network = pl, pl.network
+	}
+	b.lifecycle = newLifecycle(listener)
 
 	// Init mysql server
-	b.initServer()
+	b.initServer(b.lifecycle.listener)
 	if b.err != nil {
+		_ = listener.Close()
 		return nil, nil, nil, b.err
 	}
 
 	// Start mysql server
-	log.Print("start go mysql mocker server, listening at 127.0.0.1:" + strconv.Itoa(b.port))
-	go func() {
-		if err := b.server.Start(); err != nil {
-			panic(err)
-		}
-	}()
+	if b.inProcess {
+		b.logf("start go mysql mocker server in process, network %s", b.network)
+	} else {
+		b.logf("start go mysql mocker server, listening at 127.0.0.1:%d", b.port)
+	}
+	go b.serve()
 
 	shutdown := func() {
-		_ = b.server.Close()
+		if err := b.shutdown(); err != nil {
+			b.logf("failed to shut down mysql server: %v", err)
+		}
+	}
+	fail := func(err error) (*sqlx.DB, *sql.DB, func(), error) {
+		select {
+		case serveErr := <-b.lifecycle.serveErr:
+			err = fmt.Errorf("%w (mysql server stopped: %v)", err, serveErr)
+		default:
+		}
+		_ = b.shutdown()
+		b.err = err
+		return nil, nil, nil, err
 	}
 
 	// Create client and connect to server
 	var err error
-	b.sqlxDB, b.sqlDB, err = createMySQLClient(b.port, b.dbName)
+	b.sqlxDB, b.sqlDB, err = createMySQLClient(b.mockDSN("root", b.rootPassword, b.dbName))
 	if err != nil {
-		b.err = fmt.Errorf("failed to create sql client: %w", err)
-		return nil, nil, nil, b.err
+		return fail(fmt.Errorf("failed to create sql client: %w", err))
+	}
+	if err = b.createUsers(); err != nil {
+		return fail(err)
 	}
 
-	b.initWithStmts()
-	b.initWithFiles()
+	b.initWithSources(ctx)
 	if b.err != nil {
-		return nil, nil, nil, b.err
+		return fail(b.err)
 	}
+	b.recorder.mu.Lock()
+	b.recorder.initialized = true
+	b.recorder.mu.Unlock()
 
 	return b.sqlxDB, b.sqlDB, shutdown, nil
 }
 
+// BuildT is Build for a test: messages go to t.Logf, a startup error fails
+// the test and the server is shut down by t.Cleanup.
+func (b *MockBuilder) BuildT(t testing.TB) (*sqlx.DB, *sql.DB) {
+	t.Helper()
+	if b.logger == nil {
+		b.logger = t.Logf
+	}
+	sqlxDB, sqlDB, shutdown, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build mysql mock server: %v", err)
+	}
+	t.Cleanup(shutdown)
+	return sqlxDB, sqlDB
+}
+
 // initServer initializes the mock MySQL server
-func (b *MockBuilder) initServer() *MockBuilder {
+func (b *MockBuilder) initServer(listener net.Listener) *MockBuilder {
 	if b.err != nil {
 		return b
 	}
-	b.server, b.err = createMySQLServer(b.dbName, b.port)
+	if b.provider == nil {
+		b.provider = createMySQLProvider(b.databases()...)
+	} else {
+		for _, name := range b.databases() {
+			if b.err = ensureDatabase(b.provider, name); b.err != nil {
+				return b
+			}
+		}
+	}
+
+	interceptors := []server.Interceptor{b.recorder, b.faults}
+	if b.maxRowsPerTable > 0 || b.maxMemory > 0 {
+		interceptors = append(interceptors, newGuardrailInterceptor(b.provider, b.maxRowsPerTable, b.maxMemory))
+	}
+	if b.isolation != nil {
+		interceptors = append(interceptors, b.isolation)
+	}
+	var acl *aclInterceptor
+	if b.authEnabled() {
+		// innermost, so the other interceptors see the MySQL errors
+		acl = newACLInterceptor(b.grants)
+		interceptors = append(interceptors, acl)
+	}
+	sessionBuilder := b.recorder.sessionBuilder(newSessionBuilder(b.provider))
+	b.server, b.err = createMySQLServer(b.provider, b.dbName, listener, b.functions, b.clock.contextFactory, sessionBuilder, interceptors...)
+	if b.err == nil && b.authEnabled() {
+		b.enableAuth()
+		acl.currentDB = b.server.SessionManager().GetCurrentDB
+	}
 	return b
 }
 
@@ -150,47 +342,138 @@ func (b *MockBuilder) SQLFiles(files ...string) *MockBuilder {
 	return b
 }
 
-func (b *MockBuilder) initWithStmts() {
-	if b.err != nil || len(b.sqlStmts) == 0 {
+// SQLStmtsIn adds SQL statements to be executed in the database db upon
+// initialization, after the ones added by SQLStmts and SQLFiles
+func (b *MockBuilder) SQLStmtsIn(db string, stmts ...string) *MockBuilder {
+	b.sources = append(b.sources, InDatabase(db, Stmts(stmts...)))
+	return b
+}
+
+// SQLFilesIn adds SQL files to be executed in the database db upon
+// initialization, after the ones added by SQLStmts and SQLFiles
+func (b *MockBuilder) SQLFilesIn(db string, files ...string) *MockBuilder {
+	for _, file := range files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			b.err = fmt.Errorf("sql file %s not exist", file)
+			return b
+		}
+	}
+
+	b.sources = append(b.sources, InDatabase(db, Files(files...)))
+	return b
+}
+
+// SQLReader adds the SQL read from r to be executed upon initialization,
+// name identifies it in failure messages (see Reader)
+func (b *MockBuilder) SQLReader(name string, r io.Reader) *MockBuilder {
+	b.sources = append(b.sources, Reader(name, r))
+	return b
+}
+
+// SQLNamed adds a SQL string to be executed upon initialization,
+// name identifies it in failure messages, e.g. the test case it belongs to
+func (b *MockBuilder) SQLNamed(name, sql string) *MockBuilder {
+	return b.SQLReader(name, strings.NewReader(sql))
+}
+
+// InitFrom adds init sources whose statements are to be executed upon initialization,
+// after the ones added by SQLStmts and SQLFiles
+func (b *MockBuilder) InitFrom(sources ...InitSource) *MockBuilder {
+	b.sources = append(b.sources, sources...)
+	return b
+}
+
+func (b *MockBuilder) initWithSources(ctx context.Context) {
+	if b.err != nil {
+		return
+	}
+
+	var sources []InitSource
+	if len(b.sqlStmts) > 0 {
+		sources = append(sources, Stmts(b.sqlStmts...))
+	}
+	if len(b.sqlFiles) > 0 {
+		sources = append(sources, Files(b.sqlFiles...))
+	}
+	sources = append(sources, b.sources...)
+	if len(sources) == 0 {
+		if !b.restorePersisted(ctx, nil) && b.err == nil {
+			b.seedRows(ctx)
+		}
 		return
 	}
-	log.Print("start to init data with sql stmts, count = " + strconv.Itoa(len(b.sqlStmts)))
-	for _, stmt := range b.sqlStmts {
-		stmts, err := splitSQLStatements(stmt)
+
+	b.logf("start to init data with init sources, count = %d", len(sources))
+	loaded := make([][]string, len(sources))
+	for i, source := range sources {
+		stmts, err := source.Statements(ctx)
 		if err != nil {
-			b.err = err
+			b.err = fmt.Errorf("failed to load init source %s: %w", sourceName(source, i), err)
 			return
 		}
-		if err = b.executeSQLStatements(stmts); err != nil {
-			b.err = err
+		if stmts, err = b.renderStatements(stmts); err != nil {
+			b.err = fmt.Errorf("failed to load init source %s: %w", sourceName(source, i), err)
+			return
+		}
+		loaded[i] = stmts
+	}
+
+	if b.lint {
+		if b.err = b.lintSources(sources, loaded); b.err != nil {
 			return
 		}
 	}
-	log.Print("init data with sql stmts successfully, count = " + strconv.Itoa(len(b.sqlStmts)))
-}
 
-func (b *MockBuilder) initWithFiles() {
-	if b.err != nil || len(b.sqlFiles) == 0 {
+	if b.restorePersisted(ctx, loaded) || b.err != nil {
 		return
 	}
-	log.Print("start to init data with sql files, count = " + strconv.Itoa(len(b.sqlFiles)))
-	for _, file := range b.sqlFiles {
-		stmts, err := splitSQLFile(file)
-		if err != nil {
-			b.err = fmt.Errorf("failed to split sql file '%s': %w", file, err)
+
+	for i, stmts := range loaded {
+		if err := b.executeSQLStatements(stmts); err != nil {
+			if _, named := sources[i].(*readerSource); named {
+				err = fmt.Errorf("init source %s: %w", sourceName(sources[i], i), err)
+			}
+			b.err = err
 			return
 		}
-		if err = b.executeSQLStatements(stmts); err != nil {
+	}
+	b.logf("init data with init sources successfully, count = %d", len(sources))
+	b.seedRows(ctx)
+}
+
+func (b *MockBuilder) seedRows(ctx context.Context) {
+	for _, seed := range b.seeds {
+		rows := make([]map[string]any, len(seed.rows))
+		for i, row := range seed.rows {
+			rows[i] = b.maskRow(seed.table, row)
+		}
+		if err := b.codecs.Insert(ctx, b.sqlxDB, seed.table, rows...); err != nil {
 			b.err = err
 			return
 		}
 	}
-	log.Print("init data with sql files successfully, count = " + strconv.Itoa(len(b.sqlFiles)))
 }
 
+// executeSQLStatements executes the statements of a source on one connection,
+// so a USE or SET of the source holds for its following statements
 func (b *MockBuilder) executeSQLStatements(stmts []string) error {
+	ctx := context.Background()
+	conn, err := b.sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() {
+		// back to the database of the builder before the pool reuses it
+		_, _ = conn.ExecContext(ctx, internalQueryPrefix+"USE "+quoteIdent(b.dbName))
+		_ = conn.Close()
+	}()
+
 	for _, stmt := range stmts {
-		_, err := b.sqlDB.Exec(stmt)
+		stmt, err := b.maskStatement(ctx, conn, stmt)
+		if err != nil {
+			return err
+		}
+		_, err = conn.ExecContext(ctx, stmt)
 		if err != nil {
 			return fmt.Errorf("failed to exec sql stmt '%s': %w", stmt, err)
 		}
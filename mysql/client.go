@@ -6,8 +6,7 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-func createMySQLClient(port int, dbName string) (*sqlx.DB, *sql.DB, error) {
-	dsn := fmt.Sprintf("root:@tcp(127.0.0.1:%d)/%s", port, dbName)
+func createMySQLClient(dsn string) (*sqlx.DB, *sql.DB, error) {
 	sqlxDB, err := sqlx.Connect("mysql", dsn)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to connect sqlx client: %w", err)
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"context"
+	"github.com/dolthub/go-mysql-server/sql"
+	"sync"
+	"time"
+)
+
+// mockClock is the time the mock server runs queries at, the wall clock
+// unless it was fixed
+type mockClock struct {
+	mu    sync.Mutex
+	fixed bool
+	now   time.Time
+}
+
+func newMockClock() *mockClock {
+	return &mockClock{}
+}
+
+// fixedTime returns the fixed time, false if the clock follows the wall clock
+func (c *mockClock) fixedTime() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now, c.fixed
+}
+
+// contextFactory creates the query contexts of the server, their query time
+// is what NOW(), CURRENT_TIMESTAMP and column defaults evaluate to.
+func (c *mockClock) contextFactory(ctx context.Context, opts ...sql.ContextOption) *sql.Context {
+	sqlCtx := sql.NewContext(ctx, opts...)
+	if now, ok := c.fixedTime(); ok {
+		sqlCtx.SetQueryTime(now)
+	}
+	return sqlCtx
+}
+
+// WithFixedTime makes NOW(), CURRENT_TIMESTAMP, UTC_TIMESTAMP and the like,
+// including DEFAULT CURRENT_TIMESTAMP columns, return t instead of the wall
+// clock, also once the server is running. SYSDATE() keeps the wall clock.
+func (b *MockBuilder) WithFixedTime(t time.Time) *MockBuilder {
+	b.clock.mu.Lock()
+	defer b.clock.mu.Unlock()
+	b.clock.fixed = true
+	b.clock.now = t
+	return b
+}
+
+// AdvanceTime moves the fixed time forward by d, e.g. to expire rows by their
+// created_at. Without WithFixedTime the clock is fixed at the current time first.
+func (b *MockBuilder) AdvanceTime(d time.Duration) *MockBuilder {
+	b.clock.mu.Lock()
+	defer b.clock.mu.Unlock()
+	if !b.clock.fixed {
+		b.clock.fixed = true
+		b.clock.now = time.Now()
+	}
+	b.clock.now = b.clock.now.Add(d)
+	return b
+}
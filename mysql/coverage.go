@@ -0,0 +1,397 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// SchemaCoverage is how much of the schema the statements executed since
+// TrackSchemaCoverage read and wrote, like a code coverage report for the
+// tables and columns.
+type SchemaCoverage struct {
+	Tables []TableCoverage
+}
+
+// TableCoverage counts the statements reading and writing a table
+type TableCoverage struct {
+	Table   string
+	Reads   int
+	Writes  int
+	Columns []ColumnCoverage
+}
+
+// ColumnCoverage counts the statements reading and writing a column
+type ColumnCoverage struct {
+	Column string
+	Reads  int
+	Writes int
+}
+
+// Covered reports whether a statement read or wrote the column
+func (c ColumnCoverage) Covered() bool {
+	return c.Reads > 0 || c.Writes > 0
+}
+
+// Covered reports whether a statement read or wrote the table
+func (t TableCoverage) Covered() bool {
+	return t.Reads > 0 || t.Writes > 0
+}
+
+// Percent returns the percentage of the columns of the table read or written
+func (t TableCoverage) Percent() float64 {
+	covered, _ := t.count()
+	return percent(covered, len(t.Columns))
+}
+
+func (t TableCoverage) count() (covered, total int) {
+	for _, c := range t.Columns {
+		if c.Covered() {
+			covered++
+		}
+	}
+	return covered, len(t.Columns)
+}
+
+// Percent returns the percentage of the columns of all tables read or written
+func (c *SchemaCoverage) Percent() float64 {
+	var covered, total int
+	for _, t := range c.Tables {
+		n, m := t.count()
+		covered, total = covered+n, total+m
+	}
+	return percent(covered, total)
+}
+
+// Uncovered returns the tables no statement read or wrote, and the
+// "table.column" columns of the other tables no statement read or wrote:
+// dead schema or paths no test exercises
+func (c *SchemaCoverage) Uncovered() []string {
+	var uncovered []string
+	for _, t := range c.Tables {
+		if !t.Covered() {
+			uncovered = append(uncovered, t.Table)
+			continue
+		}
+		for _, col := range t.Columns {
+			if !col.Covered() {
+				uncovered = append(uncovered, t.Table+"."+col.Column)
+			}
+		}
+	}
+	return uncovered
+}
+
+// String formats the coverage like go tool cover -func, a line per table
+// with its statement counts and uncovered columns, then the total
+func (c *SchemaCoverage) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, t := range c.Tables {
+		var uncovered []string
+		for _, col := range t.Columns {
+			if !col.Covered() {
+				uncovered = append(uncovered, col.Column)
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\treads %d\twrites %d\t%.1f%%\t%s\n",
+			t.Table, t.Reads, t.Writes, t.Percent(), strings.Join(uncovered, ", "))
+	}
+	_, _ = fmt.Fprintf(w, "total\t\t\t%.1f%%\n", c.Percent())
+	_ = w.Flush()
+	return sb.String()
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// TrackSchemaCoverage records the tables and columns each statement reads
+// and writes from now on, not counting the init statements, see
+// SchemaCoverage. Share the builder across the tests of a package, e.g.
+// with Global, to find the schema no test exercises.
+func (b *MockBuilder) TrackSchemaCoverage() *MockBuilder {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	if b.recorder.coverage == nil {
+		b.recorder.coverage = newCoverageTracker()
+	}
+	return b
+}
+
+// SchemaCoverage returns the coverage of the tables of the databases of the
+// builder by the statements executed since TrackSchemaCoverage. The tables of
+// AddDatabase are named after their database, e.g. "audit.events"; the
+// statements are counted for the tables of their name in every database.
+func (b *MockBuilder) SchemaCoverage(ctx context.Context) (*SchemaCoverage, error) {
+	b.recorder.mu.Lock()
+	if b.recorder.coverage == nil {
+		b.recorder.mu.Unlock()
+		return nil, fmt.Errorf("schema coverage is not tracked, see TrackSchemaCoverage")
+	}
+	tracker := b.recorder.coverage.clone()
+	b.recorder.mu.Unlock()
+
+	filter, args := b.schemaFilter()
+	rows, err := b.sqlDB.QueryContext(ctx, internalQueryPrefix+
+		"SELECT table_schema, table_name, column_name FROM information_schema.columns "+
+		"WHERE "+filter+" ORDER BY table_schema, table_name, ordinal_position", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	cov := &SchemaCoverage{}
+	// lower case "schema.table" and "schema.table.column" -> the coverage
+	tables := make(map[string]*TableCoverage)
+	columns := make(map[string]*ColumnCoverage)
+	// lower case table name of the statements -> its "schema.table" keys
+	keys := make(map[string][]string)
+	var order []string
+	for rows.Next() {
+		var schema, table, column string
+		if err = rows.Scan(&schema, &table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan columns: %w", err)
+		}
+		name := strings.ToLower(table)
+		key := strings.ToLower(schema) + "." + name
+		t, ok := tables[key]
+		if !ok {
+			use := tracker.tables[name]
+			t = &TableCoverage{Table: b.qualifiedName(schema, table), Reads: use.reads, Writes: use.writes}
+			tables[key] = t
+			keys[name] = append(keys[name], key)
+			order = append(order, key)
+		}
+		t.Columns = append(t.Columns, ColumnCoverage{Column: column})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, key := range order {
+		t := tables[key]
+		for i := range t.Columns {
+			columns[key+"."+strings.ToLower(t.Columns[i].Column)] = &t.Columns[i]
+		}
+	}
+
+	for ref, use := range tracker.columns {
+		for _, table := range strings.Split(ref.tables, ",") {
+			if ref.column == "*" {
+				for _, key := range keys[table] {
+					t := tables[key]
+					for i := range t.Columns {
+						t.Columns[i].Reads += use.reads
+						t.Columns[i].Writes += use.writes
+					}
+				}
+				continue
+			}
+			// an unqualified column belongs to the first table having it
+			var found bool
+			for _, key := range keys[table] {
+				if c, ok := columns[key+"."+ref.column]; ok {
+					c.Reads += use.reads
+					c.Writes += use.writes
+					found = true
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+	for _, key := range order {
+		cov.Tables = append(cov.Tables, *tables[key])
+	}
+	return cov, nil
+}
+
+// LogSchemaCoverage logs the schema coverage report as a soft assertion that
+// does not fail the test
+func (b *MockBuilder) LogSchemaCoverage(t testing.TB) {
+	t.Helper()
+
+	cov, err := b.SchemaCoverage(context.Background())
+	if err != nil {
+		t.Logf("failed to get schema coverage: %v", err)
+		return
+	}
+	t.Logf("schema coverage:\n%s", cov)
+}
+
+// coverageTracker counts the statements using each table and column
+type coverageTracker struct {
+	// lower case table name -> statements
+	tables map[string]coverageUse
+	// columns of unresolved tables, resolved against the schema of the report
+	columns map[columnRef]coverageUse
+}
+
+type coverageUse struct {
+	reads, writes int
+}
+
+// columnRef is a column of a statement, of the first of the comma
+// separated tables having it
+type columnRef struct {
+	tables string
+	column string
+}
+
+func newCoverageTracker() *coverageTracker {
+	return &coverageTracker{
+		tables:  make(map[string]coverageUse),
+		columns: make(map[columnRef]coverageUse),
+	}
+}
+
+func (ct *coverageTracker) clone() *coverageTracker {
+	c := newCoverageTracker()
+	for k, v := range ct.tables {
+		c.tables[k] = v
+	}
+	for k, v := range ct.columns {
+		c.columns[k] = v
+	}
+	return c
+}
+
+// track counts the tables and columns the statement reads and writes
+func (ct *coverageTracker) track(query string) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return
+	}
+
+	// alias or name -> lower case table name of the statement
+	names := make(map[string]string)
+	var tables []string
+	addTable := func(name sqlparser.TableName, alias string) {
+		table := strings.ToLower(name.Name.String())
+		if table == "" {
+			return
+		}
+		names[strings.ToLower(name.Name.String())] = table
+		if alias != "" {
+			names[strings.ToLower(alias)] = table
+		}
+		for _, t := range tables {
+			if t == table {
+				return
+			}
+		}
+		tables = append(tables, table)
+	}
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if t, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			if name, ok := t.Expr.(sqlparser.TableName); ok {
+				addTable(name, t.As.String())
+			}
+		}
+		return true, nil
+	}, stmt)
+
+	var (
+		written  = make(map[string]bool)
+		assigned = make(map[*sqlparser.ColName]bool)
+	)
+	writeColumn := func(col *sqlparser.ColName) {
+		assigned[col] = true
+		ref := ct.ref(col.Qualifier.Name.String(), col.Name.String(), names, tables)
+		ct.addColumn(ref, coverageUse{writes: 1})
+		for _, table := range strings.Split(ref.tables, ",") {
+			written[table] = true
+		}
+	}
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select, *sqlparser.SetOp:
+	case *sqlparser.Insert:
+		addTable(stmt.Table, "")
+		table := strings.ToLower(stmt.Table.Name.String())
+		written[table] = true
+		if len(stmt.Columns) == 0 {
+			ct.addColumn(columnRef{tables: table, column: "*"}, coverageUse{writes: 1})
+		}
+		for _, col := range stmt.Columns {
+			ct.addColumn(columnRef{tables: table, column: col.Lowered()}, coverageUse{writes: 1})
+		}
+		for _, expr := range stmt.OnDup {
+			writeColumn(expr.Name)
+		}
+	case *sqlparser.Update:
+		for _, expr := range stmt.Exprs {
+			writeColumn(expr.Name)
+		}
+	case *sqlparser.Delete:
+		for _, target := range stmt.Targets {
+			written[names[strings.ToLower(target.Name.String())]] = true
+		}
+		if len(stmt.Targets) == 0 && len(tables) > 0 {
+			written[tables[0]] = true
+		}
+	default:
+		return
+	}
+
+	for _, table := range tables {
+		use := ct.tables[table]
+		if written[table] {
+			use.writes++
+		} else {
+			use.reads++
+		}
+		ct.tables[table] = use
+	}
+
+	// the star of COUNT(*) reads no column
+	countStars := make(map[*sqlparser.StarExpr]bool)
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.FuncExpr:
+			for _, expr := range node.Exprs {
+				if star, ok := expr.(*sqlparser.StarExpr); ok {
+					countStars[star] = true
+				}
+			}
+		case *sqlparser.ColName:
+			if !assigned[node] {
+				ct.addColumn(ct.ref(node.Qualifier.Name.String(), node.Name.String(), names, tables), coverageUse{reads: 1})
+			}
+		case *sqlparser.StarExpr:
+			if !countStars[node] {
+				ct.addColumn(ct.ref(node.TableName.Name.String(), "*", names, tables), coverageUse{reads: 1})
+			}
+		}
+		return true, nil
+	}, stmt)
+}
+
+// ref returns the reference of a column of the statement, to the table of
+// its qualifier if any, else to the tables of the statement
+func (ct *coverageTracker) ref(qualifier, column string, names map[string]string, tables []string) columnRef {
+	ref := columnRef{tables: strings.Join(tables, ","), column: strings.ToLower(column)}
+	if qualifier != "" {
+		ref.tables = names[strings.ToLower(qualifier)]
+		if ref.tables == "" {
+			ref.tables = strings.ToLower(qualifier)
+		}
+	}
+	return ref
+}
+
+func (ct *coverageTracker) addColumn(ref columnRef, use coverageUse) {
+	if ref.tables == "" {
+		return
+	}
+	sum := ct.columns[ref]
+	sum.reads += use.reads
+	sum.writes += use.writes
+	ct.columns[ref] = sum
+}
@@ -0,0 +1,190 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/go-mysql-server/server"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	querypb "github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault makes the statements matching a pattern fail with a MySQL error
+// instead of running, e.g. to test retry-on-deadlock logic.
+type Fault struct {
+	pattern  string
+	re       *regexp.Regexp
+	code     int
+	state    string
+	message  string
+	wait     time.Duration
+	rollback bool
+
+	mu    sync.Mutex
+	times int
+	hits  int
+}
+
+// Deadlock returns a fault failing the statements matching the regexp with
+// error 1213, rolling back the transaction like InnoDB does.
+func Deadlock(pattern string) *Fault {
+	return &Fault{
+		pattern:  pattern,
+		code:     vmysql.ERLockDeadlock,
+		state:    vmysql.SSLockDeadlock,
+		message:  "Deadlock found when trying to get lock; try restarting transaction",
+		rollback: true,
+	}
+}
+
+// LockWaitTimeout returns a fault failing the statements matching the regexp
+// with error 1205 after blocking for wait. Only the statement is rolled back,
+// the transaction stays open like with innodb_rollback_on_timeout=OFF.
+func LockWaitTimeout(pattern string, wait time.Duration) *Fault {
+	return &Fault{
+		pattern: pattern,
+		code:    vmysql.ERLockWaitTimeout,
+		state:   vmysql.SSUnknownSQLState,
+		message: "Lock wait timeout exceeded; try restarting transaction",
+		wait:    wait,
+	}
+}
+
+// Times limits the fault to the first n matching statements, so a retry
+// succeeds; by default every matching statement fails.
+func (f *Fault) Times(n int) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.times = n
+	return f
+}
+
+// Hits returns how many statements the fault failed
+func (f *Fault) Hits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits
+}
+
+// hit reports whether the fault fails the query, and counts it
+func (f *Fault) hit(query string) bool {
+	if !f.re.MatchString(query) {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.times > 0 && f.hits >= f.times {
+		return false
+	}
+	f.hits++
+	return true
+}
+
+// faultInterceptor fails the statements matching an injected fault
+type faultInterceptor struct {
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+var _ server.Interceptor = (*faultInterceptor)(nil)
+
+func newFaultInterceptor() *faultInterceptor {
+	return &faultInterceptor{}
+}
+
+func (f *faultInterceptor) Priority() int {
+	return 0
+}
+
+func (f *faultInterceptor) Query(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) error {
+	if err := f.inject(ctx, chain, c, query); err != nil {
+		return err
+	}
+	return chain.ComQuery(ctx, c, query, callback)
+}
+
+func (f *faultInterceptor) ParsedQuery(chain server.Chain, c *vmysql.Conn, query string, parsed sqlparser.Statement, callback func(res *sqltypes.Result, more bool) error) error {
+	if err := f.inject(context.Background(), chain, c, query); err != nil {
+		return err
+	}
+	return chain.ComQuery(context.Background(), c, query, callback)
+}
+
+func (f *faultInterceptor) MultiQuery(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) (string, error) {
+	if err := f.inject(ctx, chain, c, query); err != nil {
+		return "", err
+	}
+	return chain.ComMultiQuery(ctx, c, query, callback)
+}
+
+func (f *faultInterceptor) Prepare(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, prepare *vmysql.PrepareData) ([]*querypb.Field, error) {
+	return chain.ComPrepare(ctx, c, query, prepare)
+}
+
+func (f *faultInterceptor) StmtExecute(ctx context.Context, chain server.Chain, c *vmysql.Conn, prepare *vmysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	if err := f.inject(ctx, chain, c, prepare.PrepareStmt); err != nil {
+		return err
+	}
+	return chain.ComStmtExecute(ctx, c, prepare, callback)
+}
+
+// inject returns the error of the first fault matching the query
+func (f *faultInterceptor) inject(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string) error {
+	if strings.HasPrefix(query, internalQueryPrefix) {
+		return nil
+	}
+
+	f.mu.Lock()
+	faults := f.faults
+	f.mu.Unlock()
+
+	for _, fault := range faults {
+		if !fault.hit(query) {
+			continue
+		}
+		if fault.wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(fault.wait):
+			}
+		}
+		if fault.rollback {
+			err := chain.ComQuery(ctx, c, internalQueryPrefix+"ROLLBACK", func(*sqltypes.Result, bool) error { return nil })
+			if err != nil {
+				return fmt.Errorf("failed to roll back the transaction of the injected fault: %w", err)
+			}
+		}
+		return vmysql.NewSQLError(fault.code, fault.state, "%s", fault.message)
+	}
+	return nil
+}
+
+// InjectFault makes the statements matching the fault fail, also once the
+// server is running. Keep the fault to check its Hits.
+func (b *MockBuilder) InjectFault(fault *Fault) *MockBuilder {
+	re, err := regexp.Compile(fault.pattern)
+	if err != nil {
+		b.err = fmt.Errorf("invalid fault pattern '%s': %w", fault.pattern, err)
+		return b
+	}
+	fault.re = re
+
+	b.faults.mu.Lock()
+	defer b.faults.mu.Unlock()
+	// copy on write, inject reads the slice without holding the lock
+	b.faults.faults = append(append([]*Fault(nil), b.faults.faults...), fault)
+	return b
+}
+
+// ClearFaults removes all injected faults
+func (b *MockBuilder) ClearFaults() *MockBuilder {
+	b.faults.mu.Lock()
+	defer b.faults.mu.Unlock()
+	b.faults.faults = nil
+	return b
+}
@@ -0,0 +1,100 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// GlobalServer is a mock server shared by the whole test binary,
+// each caller gets its own database on it.
+type GlobalServer struct {
+	builder *MockBuilder
+	err     error
+	seq     atomic.Uint64
+}
+
+var global struct {
+	once   sync.Once
+	server *GlobalServer
+}
+
+// Global returns the process-wide mock server, started on first use and
+// never shut down, so packages with many small tests pay the startup cost once.
+// Use Database to get an isolated database on it.
+func Global() *GlobalServer {
+	global.once.Do(func() {
+		g := &GlobalServer{builder: Builder("mtest_global")}
+		_, _, _, g.err = g.builder.Build()
+		global.server = g
+	})
+	return global.server
+}
+
+// Builder returns the builder of the global server, e.g. to read its warnings
+func (g *GlobalServer) Builder() *MockBuilder {
+	return g.builder
+}
+
+var dbNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Database creates a database named after the test on the global server,
+// runs the init sources in it, and returns a client connected to it.
+// The database is dropped when the test finishes.
+func (g *GlobalServer) Database(t testing.TB, sources ...InitSource) *sqlx.DB {
+	t.Helper()
+
+	if g.err != nil {
+		t.Fatalf("failed to start global mysql server: %v", g.err)
+	}
+	return testDatabase(t, g.builder, &g.seq, sources)
+}
+
+// testDatabase creates a database named after the test on the server of
+// the builder, runs the init sources in it, and returns a client connected
+// to it. The database is dropped when the test finishes.
+func testDatabase(t testing.TB, b *MockBuilder, seq *atomic.Uint64, sources []InitSource) *sqlx.DB {
+	t.Helper()
+
+	// a unique name is needed since the same test may run several times, e.g. with -count
+	suffix := "_" + strconv.FormatUint(seq.Add(1), 10)
+	name := "t_" + strings.ToLower(dbNameInvalidChars.ReplaceAllString(t.Name(), "_"))
+	if len(name)+len(suffix) > 64 {
+		name = name[:64-len(suffix)]
+	}
+	name += suffix
+
+	if _, err := b.sqlDB.Exec(fmt.Sprintf("CREATE DATABASE `%s`", name)); err != nil {
+		t.Fatalf("failed to create database '%s': %v", name, err)
+	}
+	db, sqlDB, err := createMySQLClient(b.mockDSN("root", b.rootPassword, name))
+	if err != nil {
+		t.Fatalf("failed to connect database '%s': %v", name, err)
+	}
+	// createMySQLClient opens a second pool for the builder, it's not needed here
+	_ = sqlDB.Close()
+
+	t.Cleanup(func() {
+		_ = db.Close()
+		_, _ = b.sqlDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", name))
+	})
+
+	for i, source := range sources {
+		stmts, err := source.Statements(context.Background())
+		if err != nil {
+			t.Fatalf("failed to load init source #%d: %v", i, err)
+		}
+		for _, stmt := range stmts {
+			if _, err = db.Exec(stmt); err != nil {
+				t.Fatalf("failed to exec sql stmt '%s': %v", stmt, err)
+			}
+		}
+	}
+	return db
+}
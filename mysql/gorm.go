@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+type gormConfig struct {
+	logLevel logger.LogLevel
+	naming   schema.Namer
+}
+
+// GormOption configures the *gorm.DB of GormDB and BuildGorm
+type GormOption func(*gormConfig)
+
+// GormLogLevel sets the level of the gorm logger, logger.Warn by default,
+// logger.Info logs every statement.
+func GormLogLevel(level logger.LogLevel) GormOption {
+	return func(c *gormConfig) {
+		c.logLevel = level
+	}
+}
+
+// GormNamingStrategy sets how gorm maps structs and fields to tables and
+// columns, e.g. schema.NamingStrategy{SingularTable: true}.
+func GormNamingStrategy(naming schema.Namer) GormOption {
+	return func(c *gormConfig) {
+		c.naming = naming
+	}
+}
+
+// GormDB returns a *gorm.DB sharing the connection pool of the started server
+func (b *MockBuilder) GormDB(opts ...GormOption) (*gorm.DB, error) {
+	if b.sqlDB == nil {
+		return nil, errors.New("mysql server not started")
+	}
+
+	cfg := &gormConfig{logLevel: logger.Warn, naming: schema.NamingStrategy{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	db, err := gorm.Open(gormmysql.New(gormmysql.Config{
+		Conn:                      b.sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:         logger.Default.LogMode(cfg.logLevel),
+		NamingStrategy: cfg.naming,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gorm db: %w", err)
+	}
+	return db, nil
+}
+
+// BuildGorm is Build for services using gorm, it starts the server and
+// returns a *gorm.DB pointed at it instead of the sqlx and sql handles.
+func (b *MockBuilder) BuildGorm(opts ...GormOption) (*gorm.DB, func(), error) {
+	var shutdown func()
+	var err error
+	if _, _, shutdown, err = b.Build(); err != nil {
+		return nil, nil, err
+	}
+	db, err := b.GormDB(opts...)
+	if err != nil {
+		shutdown()
+		return nil, nil, err
+	}
+	return db, shutdown, nil
+}
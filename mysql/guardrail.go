@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	querypb "github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"strings"
+)
+
+// guardrailInterceptor checks the data size limits after every statement
+// that may add data, and fails the statement once a limit is exceeded.
+type guardrailInterceptor struct {
+	pro             sql.DatabaseProvider
+	maxRowsPerTable uint64
+	maxMemory       uint64
+}
+
+var _ server.Interceptor = (*guardrailInterceptor)(nil)
+
+func newGuardrailInterceptor(pro sql.DatabaseProvider, maxRowsPerTable, maxMemory uint64) *guardrailInterceptor {
+	return &guardrailInterceptor{
+		pro:             pro,
+		maxRowsPerTable: maxRowsPerTable,
+		maxMemory:       maxMemory,
+	}
+}
+
+func (g *guardrailInterceptor) Priority() int {
+	return 0
+}
+
+func (g *guardrailInterceptor) Query(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) error {
+	return chain.ComQuery(ctx, c, query, g.wrapCallback(query, callback))
+}
+
+func (g *guardrailInterceptor) ParsedQuery(chain server.Chain, c *vmysql.Conn, query string, parsed sqlparser.Statement, callback func(res *sqltypes.Result, more bool) error) error {
+	return chain.ComQuery(context.Background(), c, query, g.wrapCallback(query, callback))
+}
+
+func (g *guardrailInterceptor) MultiQuery(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) (string, error) {
+	return chain.ComMultiQuery(ctx, c, query, g.wrapCallback(query, callback))
+}
+
+func (g *guardrailInterceptor) Prepare(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, prepare *vmysql.PrepareData) ([]*querypb.Field, error) {
+	return chain.ComPrepare(ctx, c, query, prepare)
+}
+
+func (g *guardrailInterceptor) StmtExecute(ctx context.Context, chain server.Chain, c *vmysql.Conn, prepare *vmysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	wrapped := g.wrapCallback(prepare.PrepareStmt, func(res *sqltypes.Result, _ bool) error {
+		return callback(res)
+	})
+	return chain.ComStmtExecute(ctx, c, prepare, func(res *sqltypes.Result) error {
+		return wrapped(res, false)
+	})
+}
+
+// wrapCallback checks the limits before the result of a data modifying statement
+// is sent, the statement has been executed by then but the client still gets
+// the error instead of the OK packet.
+func (g *guardrailInterceptor) wrapCallback(query string, callback func(res *sqltypes.Result, more bool) error) func(res *sqltypes.Result, more bool) error {
+	if !isDataModifyingStmt(query) {
+		return callback
+	}
+	checked := false
+	return func(res *sqltypes.Result, more bool) error {
+		if !checked {
+			checked = true
+			if err := g.check(); err != nil {
+				return err
+			}
+		}
+		return callback(res, more)
+	}
+}
+
+// check walks all tables of the provider and compares row counts and
+// the estimated data length against the configured limits.
+func (g *guardrailInterceptor) check() error {
+	ctx := sql.NewContext(context.Background(), sql.WithSession(memory.NewSession(sql.NewBaseSession(), g.pro)))
+
+	var totalMemory uint64
+	for _, db := range g.pro.AllDatabases(ctx) {
+		names, err := db.GetTableNames(ctx)
+		if err != nil {
+			return fmt.Errorf("guardrail: failed to list tables of '%s': %w", db.Name(), err)
+		}
+		for _, name := range names {
+			tbl, ok, err := db.GetTableInsensitive(ctx, name)
+			if err != nil || !ok {
+				continue
+			}
+			st, ok := tbl.(sql.StatisticsTable)
+			if !ok {
+				continue
+			}
+
+			rows, _, err := st.RowCount(ctx)
+			if err != nil {
+				return fmt.Errorf("guardrail: failed to count rows of '%s.%s': %w", db.Name(), name, err)
+			}
+			if g.maxRowsPerTable > 0 && rows > g.maxRowsPerTable {
+				return fmt.Errorf("guardrail exceeded: table '%s.%s' has %d rows, max rows per table is %d",
+					db.Name(), name, rows, g.maxRowsPerTable)
+			}
+
+			length, err := st.DataLength(ctx)
+			if err != nil {
+				return fmt.Errorf("guardrail: failed to get data length of '%s.%s': %w", db.Name(), name, err)
+			}
+			totalMemory += length
+		}
+	}
+
+	if g.maxMemory > 0 && totalMemory > g.maxMemory {
+		return fmt.Errorf("guardrail exceeded: estimated data size is %d bytes, max memory is %d bytes",
+			totalMemory, g.maxMemory)
+	}
+	return nil
+}
+
+// isDataModifyingStmt reports whether the statement may add rows to a table.
+func isDataModifyingStmt(query string) bool {
+	fields := strings.Fields(removeSQLComments(query))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "REPLACE", "UPDATE", "LOAD", "CREATE", "ALTER", "CALL":
+		return true
+	}
+	return false
+}
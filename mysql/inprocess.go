@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// InProcess serves the mock database in memory without binding any port:
+// clients connect through a dial function of the go-sql-driver registered
+// under a network named after the builder, so the DSN only works within the
+// test binary. It suits sandboxes that forbid listening on sockets; Build
+// switches to it by itself when binding a port is denied and no Port is set.
+func (b *MockBuilder) InProcess() *MockBuilder {
+	b.inProcess = true
+	return b
+}
+
+// IsInProcess reports whether the server is served in memory, see InProcess
+func (b *MockBuilder) IsInProcess() bool {
+	return b.inProcess
+}
+
+// bindDenied reports whether err is the sandbox denying to bind a socket
+func bindDenied(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)
+}
+
+// pipeAddr is the address of both ends of in-process connections, the
+// loopback one so the host checks of the accounts match as over TCP
+var pipeAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// pipeListener is a listener of in-process connections, which the dial
+// function registered under network creates
+type pipeListener struct {
+	network string
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+// listenInProcess creates a pipe listener and registers its dial function
+// with the go-sql-driver. Registrations can't be removed, dialing a closed
+// listener fails.
+func listenInProcess() *pipeListener {
+	l := &pipeListener{
+		network: "mtest-" + uuid.NewString(),
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+	}
+	gomysql.RegisterDialContext(l.network, func(ctx context.Context, _ string) (net.Conn, error) {
+		return l.dial(ctx)
+	})
+	return l
+}
+
+func (l *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- &pipeConn{Conn: server}:
+		return &pipeConn{Conn: client}, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr
+}
+
+// pipeConn is an end of an in-process connection
+type pipeConn struct {
+	net.Conn
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return pipeAddr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return pipeAddr
+}
@@ -0,0 +1,226 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/go-mysql-server/server"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	querypb "github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SessionLeak is the session state left on a connection of the pool,
+// which the next user of the connection silently inherits.
+type SessionLeak struct {
+	ConnectionID  uint32
+	TempTables    []string
+	Variables     []string
+	InTransaction bool
+}
+
+func (l SessionLeak) String() string {
+	var parts []string
+	if len(l.TempTables) > 0 {
+		parts = append(parts, "temporary tables "+strings.Join(l.TempTables, ", "))
+	}
+	if len(l.Variables) > 0 {
+		parts = append(parts, "session variables "+strings.Join(l.Variables, ", "))
+	}
+	if l.InTransaction {
+		parts = append(parts, "an open transaction")
+	}
+	return fmt.Sprintf("connection %d has %s", l.ConnectionID, strings.Join(parts, " and "))
+}
+
+type sessionState struct {
+	conn       *vmysql.Conn
+	tempTables map[string]struct{}
+	vars       map[string]struct{}
+	inTx       bool
+}
+
+func (s *sessionState) empty() bool {
+	return len(s.tempTables) == 0 && len(s.vars) == 0 && !s.inTx
+}
+
+// isolationInterceptor tracks the session state each statement leaves
+// on its connection: temporary tables, session variables and transactions.
+type isolationInterceptor struct {
+	mu    sync.Mutex
+	conns map[uint32]*sessionState
+}
+
+var _ server.Interceptor = (*isolationInterceptor)(nil)
+
+func newIsolationInterceptor() *isolationInterceptor {
+	return &isolationInterceptor{conns: make(map[uint32]*sessionState)}
+}
+
+func (i *isolationInterceptor) Priority() int {
+	return 0
+}
+
+func (i *isolationInterceptor) Query(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) error {
+	if err := chain.ComQuery(ctx, c, query, callback); err != nil {
+		return err
+	}
+	i.track(c, query)
+	return nil
+}
+
+func (i *isolationInterceptor) ParsedQuery(chain server.Chain, c *vmysql.Conn, query string, parsed sqlparser.Statement, callback func(res *sqltypes.Result, more bool) error) error {
+	if err := chain.ComQuery(context.Background(), c, query, callback); err != nil {
+		return err
+	}
+	i.track(c, query)
+	return nil
+}
+
+func (i *isolationInterceptor) MultiQuery(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) (string, error) {
+	remainder, err := chain.ComMultiQuery(ctx, c, query, callback)
+	if err != nil {
+		return remainder, err
+	}
+	i.track(c, strings.TrimSuffix(query, remainder))
+	return remainder, nil
+}
+
+func (i *isolationInterceptor) Prepare(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, prepare *vmysql.PrepareData) ([]*querypb.Field, error) {
+	return chain.ComPrepare(ctx, c, query, prepare)
+}
+
+func (i *isolationInterceptor) StmtExecute(ctx context.Context, chain server.Chain, c *vmysql.Conn, prepare *vmysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	return chain.ComStmtExecute(ctx, c, prepare, callback)
+}
+
+// track records the session state changed by the successfully executed query
+func (i *isolationInterceptor) track(c *vmysql.Conn, query string) {
+	parsed, err := sqlparser.Parse(query)
+	if err != nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	state, ok := i.conns[c.ConnectionID]
+	if !ok {
+		state = &sessionState{conn: c, tempTables: make(map[string]struct{}), vars: make(map[string]struct{})}
+		i.conns[c.ConnectionID] = state
+	}
+
+	switch stmt := parsed.(type) {
+	case *sqlparser.DDL:
+		switch {
+		case stmt.Action == sqlparser.CreateStr && stmt.Temporary:
+			state.tempTables[stmt.Table.Name.String()] = struct{}{}
+		case stmt.Action == sqlparser.DropStr:
+			for _, tbl := range stmt.FromTables {
+				delete(state.tempTables, tbl.Name.String())
+			}
+		}
+	case *sqlparser.Set:
+		for _, expr := range stmt.Exprs {
+			// the parser sets the scope of @x and @@session.x itself,
+			// names like @@x are only resolved here
+			name, scope := expr.Name, expr.Scope
+			if scope == sqlparser.SetScope_None {
+				var err error
+				if name, scope, _, err = sqlparser.VarScopeForColName(expr.Name); err != nil {
+					continue
+				}
+			}
+			switch scope {
+			case sqlparser.SetScope_User:
+				state.vars["@"+name.Name.String()] = struct{}{}
+			case sqlparser.SetScope_None, sqlparser.SetScope_Session:
+				// character set statements are sent by drivers on connect
+				lower := strings.ToLower(name.Name.String())
+				if lower == "names" || lower == "charset" || strings.HasPrefix(lower, "character_set_") {
+					continue
+				}
+				state.vars["@@"+lower] = struct{}{}
+			}
+		}
+	case *sqlparser.Begin:
+		state.inTx = true
+	case *sqlparser.Commit, *sqlparser.Rollback:
+		state.inTx = false
+	}
+}
+
+// leaks returns the open connections holding session state
+func (i *isolationInterceptor) leaks() []SessionLeak {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var leaks []SessionLeak
+	for id, state := range i.conns {
+		if state.conn.IsClosed() {
+			delete(i.conns, id)
+			continue
+		}
+		if state.empty() {
+			continue
+		}
+		leaks = append(leaks, SessionLeak{
+			ConnectionID:  id,
+			TempTables:    sortedKeys(state.tempTables),
+			Variables:     sortedKeys(state.vars),
+			InTransaction: state.inTx,
+		})
+	}
+	sort.Slice(leaks, func(a, b int) bool {
+		return leaks[a].ConnectionID < leaks[b].ConnectionID
+	})
+	return leaks
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// VerifySessionIsolation enables tracking of the session state (temporary tables,
+// session and user variables, open transactions) each connection holds, so tests
+// can check that no state leaks to the next user of a pooled connection.
+// Leaks left at shutdown are logged.
+func (b *MockBuilder) VerifySessionIsolation() *MockBuilder {
+	b.isolation = newIsolationInterceptor()
+	return b
+}
+
+// SessionLeaks returns the open connections holding session state.
+// Call it while the test holds no connection or transaction itself,
+// since the state of connections in use is reported as well.
+func (b *MockBuilder) SessionLeaks() ([]SessionLeak, error) {
+	if b.isolation == nil {
+		return nil, fmt.Errorf("session isolation verification is not enabled")
+	}
+	return b.isolation.leaks(), nil
+}
+
+// AssertNoSessionLeaks asserts that no open connection holds session state,
+// see SessionLeaks.
+func (b *MockBuilder) AssertNoSessionLeaks(t testing.TB) bool {
+	t.Helper()
+
+	leaks, err := b.SessionLeaks()
+	if err != nil {
+		t.Errorf("failed to get session leaks: %v", err)
+		return false
+	}
+	for _, leak := range leaks {
+		t.Errorf("session state leaked to the connection pool: %s", leak)
+	}
+	return len(leaks) == 0
+}
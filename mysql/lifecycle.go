@@ -0,0 +1,194 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultDrainTimeout is how long shutdown waits for client connections to close
+const defaultDrainTimeout = 5 * time.Second
+
+// DrainTimeout sets how long shutting the server down waits for the client
+// connections to close before closing them, 5s by default.
+func (b *MockBuilder) DrainTimeout(d time.Duration) *MockBuilder {
+	b.drainTimeout = d
+	return b
+}
+
+// BuildContext is Build binding the server to ctx: cancelling ctx shuts the
+// server down like calling the returned func, which may be called as well.
+func (b *MockBuilder) BuildContext(ctx context.Context) (*sqlx.DB, *sql.DB, func(), error) {
+	sqlxDB, sqlDB, shutdown, err := b.build(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdown()
+		case <-b.lifecycle.closed:
+		}
+	}()
+	return sqlxDB, sqlDB, shutdown, nil
+}
+
+// ServeErr returns a channel receiving the error the server stopped
+// serving with, it is closed once the server is shut down and nil before
+// the server is started.
+func (b *MockBuilder) ServeErr() <-chan error {
+	if b.lifecycle == nil {
+		return nil
+	}
+	return b.lifecycle.serveErr
+}
+
+// lifecycle holds the state of a started server
+type lifecycle struct {
+	listener *trackingListener
+	serveErr chan error
+	closed   chan struct{}
+	once     sync.Once
+	err      error
+}
+
+func newLifecycle(listener net.Listener) *lifecycle {
+	return &lifecycle{
+		listener: &trackingListener{Listener: listener, conns: make(map[net.Conn]struct{})},
+		serveErr: make(chan error, 1),
+		closed:   make(chan struct{}),
+	}
+}
+
+// serve runs the server until it is closed, sending an error it stops with
+func (b *MockBuilder) serve() {
+	err := b.server.Start()
+	select {
+	case <-b.lifecycle.closed:
+	default:
+		if err == nil {
+			err = errors.New("mysql server stopped serving")
+		}
+		b.lifecycle.serveErr <- err
+	}
+}
+
+// shutdown stops accepting connections, closes the clients of the builder
+// and waits for the other client connections to close, up to the drain
+// timeout. Only the first call shuts down, the others return its error.
+func (b *MockBuilder) shutdown() error {
+	l := b.lifecycle
+	l.once.Do(func() {
+		close(l.closed)
+		if b.isolation != nil {
+			for _, leak := range b.isolation.leaks() {
+				b.logf("session state leaked to the connection pool: %s", leak)
+			}
+		}
+
+		var errs []error
+		if err := b.persist(context.Background()); err != nil {
+			errs = append(errs, fmt.Errorf("failed to persist mock database: %w", err))
+		}
+		if err := b.server.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close mysql server: %w", err))
+		}
+		if b.sqlxDB != nil {
+			if err := b.sqlxDB.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close sqlx client: %w", err))
+			}
+		}
+		if b.sqlDB != nil {
+			if err := b.sqlDB.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close sql client: %w", err))
+			}
+		}
+
+		timeout := b.drainTimeout
+		if timeout == 0 {
+			timeout = defaultDrainTimeout
+		}
+		if n := l.listener.drain(timeout); n > 0 {
+			b.logf("closed %d client connections still open after %s", n, timeout)
+		}
+		close(l.serveErr)
+		l.err = errors.Join(errs...)
+	})
+	return l.err
+}
+
+// trackingListener tracks the accepted connections, so shutdown can wait
+// for them to close
+type trackingListener struct {
+	net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	idle  chan struct{}
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns[conn] = struct{}{}
+	return &trackedConn{Conn: conn, listener: l}, nil
+}
+
+func (l *trackingListener) remove(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, conn)
+	if len(l.conns) == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// drain waits for the connections to close up to timeout, then closes the
+// remaining ones and returns their number
+func (l *trackingListener) drain(timeout time.Duration) int {
+	l.mu.Lock()
+	if len(l.conns) == 0 {
+		l.mu.Unlock()
+		return 0
+	}
+	idle := make(chan struct{})
+	l.idle = idle
+	l.mu.Unlock()
+
+	select {
+	case <-idle:
+		return 0
+	case <-time.After(timeout):
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.idle = nil
+	n := len(l.conns)
+	for conn := range l.conns {
+		_ = conn.Close()
+	}
+	return n
+}
+
+type trackedConn struct {
+	net.Conn
+	listener *trackingListener
+	once     sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.listener.remove(c.Conn) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,199 @@
+package mysql
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LintRule flags statements matching Pattern as unsupported or behaving
+// differently in the in-memory go-mysql-server engine.
+type LintRule struct {
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// LintFinding is a statement flagged by a lint rule.
+type LintFinding struct {
+	// Location is "file:line" for files, "stmts[i]:line" for SQLStmts,
+	// and "source #i, statement #j" for other init sources.
+	Location  string
+	Statement string
+	Message   string
+}
+
+func (f LintFinding) String() string {
+	stmt := f.Statement
+	if len(stmt) > 80 {
+		stmt = stmt[:77] + "..."
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Location, f.Message, stmt)
+}
+
+// LintError reports all the findings of the lint pass at once.
+type LintError struct {
+	Findings []LintFinding
+}
+
+func (e *LintError) Error() string {
+	lines := make([]string, 0, len(e.Findings)+1)
+	lines = append(lines, fmt.Sprintf("sql lint found %d unsupported statement(s):", len(e.Findings)))
+	for _, f := range e.Findings {
+		lines = append(lines, "  "+f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// defaultLintRules are constructs known to be unsupported or to behave
+// differently in go-mysql-server compared to MySQL.
+var defaultLintRules = []LintRule{
+	{
+		Pattern: regexp.MustCompile(`(?i)\bFULLTEXT\b|\bMATCH\s*\(.*\)\s*AGAINST\b`),
+		Message: "FULLTEXT indexes and MATCH ... AGAINST are only partially supported",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)\bSPATIAL\s+(INDEX|KEY)\b`),
+		Message: "SPATIAL indexes are not supported by in-memory tables",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)\bPARTITION\s+BY\b|^\s*ALTER\s+TABLE\b.*\b(ADD|DROP|TRUNCATE|COALESCE|REORGANIZE|EXCHANGE)\s+PARTITION\b`),
+		Message: "table partitioning is not supported",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\b.*\b(ALGORITHM|LOCK)\s*=`),
+		Message: "online DDL ALGORITHM/LOCK clauses have no effect",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\b.*\b(DISCARD|IMPORT)\s+TABLESPACE\b|^\s*ALTER\s+TABLE\b.*\bORDER\s+BY\b`),
+		Message: "ALTER TABLE option is not supported",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)\bENGINE\s*=\s*(MyISAM|MEMORY|ARCHIVE|CSV|BLACKHOLE|MERGE)\b`),
+		Message: "storage engine is ignored, the table behaves like a transactional in-memory table",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)^\s*DELIMITER\b`),
+		Message: "DELIMITER is a client command and is not understood by the init loader",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)^\s*CREATE\s+(DEFINER\s*=\s*\S+\s+)?(PROCEDURE|FUNCTION|TRIGGER|EVENT)\b.*\bBEGIN\b`),
+		Message: "BEGIN ... END bodies are split on ';' by the init loader",
+	},
+}
+
+// Lint enables a lint pass run over all init statements before any of them is
+// executed. All findings are reported at once with their location. Extra rules
+// are checked in addition to the default ones.
+func (b *MockBuilder) Lint(extra ...LintRule) *MockBuilder {
+	b.lint = true
+	b.lintRules = append(b.lintRules, extra...)
+	return b
+}
+
+// lintSources lints the statements of the sources, loaded holds the statements
+// already loaded from each source.
+func (b *MockBuilder) lintSources(sources []InitSource, loaded [][]string) error {
+	rules := append(defaultLintRules[:len(defaultLintRules):len(defaultLintRules)], b.lintRules...)
+
+	var findings []LintFinding
+	check := func(location, stmt string) {
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(stmt) {
+				findings = append(findings, LintFinding{Location: location, Statement: stmt, Message: rule.Message})
+			}
+		}
+	}
+
+	for i, source := range sources {
+		switch src := source.(type) {
+		case stmtsSource:
+			for j, content := range src {
+				for _, stmt := range splitSQLWithLines(content) {
+					check(fmt.Sprintf("stmts[%d]:%d", j, stmt.line), stmt.text)
+				}
+			}
+		case filesSource:
+			for _, file := range src {
+				bs, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				for _, stmt := range splitSQLWithLines(string(bs)) {
+					check(fmt.Sprintf("%s:%d", file, stmt.line), stmt.text)
+				}
+			}
+		case *readerSource:
+			content, err := src.read()
+			if err != nil {
+				return err
+			}
+			for _, stmt := range splitSQLWithLines(content) {
+				check(fmt.Sprintf("%s:%d", src.name, stmt.line), stmt.text)
+			}
+		default:
+			for j, stmt := range loaded[i] {
+				check(fmt.Sprintf("source #%d, statement #%d", i, j), stmt)
+			}
+		}
+	}
+
+	if len(findings) > 0 {
+		return &LintError{Findings: findings}
+	}
+	return nil
+}
+
+type lineStmt struct {
+	text string
+	line int
+}
+
+var blockCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// splitSQLWithLines splits the content like splitSQLStatements does, but keeps
+// the line each statement starts at.
+func splitSQLWithLines(content string) []lineStmt {
+	content = cleanString(content)
+	// blank out comments, keeping the newlines so line numbers stay valid
+	content = blockCommentRegex.ReplaceAllStringFunc(content, func(c string) string {
+		return strings.Repeat("\n", strings.Count(c, "\n"))
+	})
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") {
+			lines[i] = ""
+		} else if idx := strings.Index(line, "--"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+
+	var (
+		stmts   []lineStmt
+		current strings.Builder
+		start   int
+	)
+	for i, line := range lines {
+		for _, part := range strings.SplitAfter(line, ";") {
+			if current.Len() == 0 && strings.TrimSpace(part) != "" {
+				start = i + 1
+			}
+			if strings.HasSuffix(part, ";") {
+				current.WriteString(strings.TrimSuffix(part, ";"))
+				if text := strings.Join(strings.Fields(current.String()), " "); text != "" {
+					stmts = append(stmts, lineStmt{text: text, line: start})
+				}
+				current.Reset()
+				continue
+			}
+			if current.Len() > 0 || strings.TrimSpace(part) != "" {
+				current.WriteString(part)
+				current.WriteString(" ")
+			}
+		}
+	}
+	if text := strings.Join(strings.Fields(current.String()), " "); text != "" {
+		stmts = append(stmts, lineStmt{text: text, line: start})
+	}
+	return stmts
+}
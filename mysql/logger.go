@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"log"
+	"sync"
+)
+
+// Logger receives the log messages of the mock servers
+type Logger func(format string, args ...any)
+
+var defaultLogger struct {
+	mu     sync.RWMutex
+	logger Logger
+	set    bool
+}
+
+// engineLogger is the logger of the engine sessions, e.g. of the errors of
+// the queries, it writes to the logger of SetLogger
+var engineLogger = &logrus.Logger{
+	Out:       &logWriter{logger: logDefault},
+	Formatter: new(logrus.TextFormatter),
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+}
+
+// SetLogger routes the messages of the builders without Logf, and the
+// session logs of the engine, which has no per-server logger, to logger
+// instead of the standard logger. A nil logger drops them, e.g. for parallel
+// suites whose output the engine logs of dozens of servers would drown. Set
+// it before starting servers, e.g. in TestMain. The standard logrus logger,
+// which the engine logs the connections to, is left alone.
+func SetLogger(logger Logger) {
+	defaultLogger.mu.Lock()
+	defaultLogger.logger, defaultLogger.set = logger, true
+	defaultLogger.mu.Unlock()
+}
+
+// logDefault logs the message to the logger of SetLogger, the standard
+// logger if none was set
+func logDefault(format string, args ...any) {
+	defaultLogger.mu.RLock()
+	logger, set := defaultLogger.logger, defaultLogger.set
+	defaultLogger.mu.RUnlock()
+
+	switch {
+	case !set:
+		log.Printf(format, args...)
+	case logger != nil:
+		logger(format, args...)
+	}
+}
+
+// logWriter logs every line written by the engine
+type logWriter struct {
+	logger Logger
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		w.logger("%s", line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,184 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"strings"
+)
+
+// MaskFunc replaces a column value while it is loaded, e.g. to keep the PII
+// of a production dump out of tests and their logs
+type MaskFunc func(value string) string
+
+// MaskEmail replaces emails by "user-<hash>@example.com", the same email
+// always gets the same replacement so unique keys and joins still hold.
+func MaskEmail() MaskFunc {
+	return func(value string) string {
+		return "user-" + maskHash(value)[:12] + "@example.com"
+	}
+}
+
+// MaskPhone replaces the digits of phone numbers keeping their format, e.g.
+// "+1 (555) 123-4567" becomes another "+d (ddd) ddd-dddd" number. The same
+// number always gets the same replacement.
+func MaskPhone() MaskFunc {
+	return func(value string) string {
+		hash := maskHash(value)
+		var sb strings.Builder
+		var i int
+		for _, r := range value {
+			if r >= '0' && r <= '9' {
+				r = rune('0' + hash[i%len(hash)]%10)
+				i++
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	}
+}
+
+// MaskConstant replaces every value by replacement
+func MaskConstant(replacement string) MaskFunc {
+	return func(string) string {
+		return replacement
+	}
+}
+
+func maskHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Mask registers a masking function applied to the column of the table while
+// the init data is loaded: the values of INSERT and REPLACE statements of
+// SQL files, dumps and fixtures, and the rows of SeedRows. NULL is kept.
+func (b *MockBuilder) Mask(table, column string, fn MaskFunc) *MockBuilder {
+	if b.masks == nil {
+		b.masks = make(map[string]map[string]MaskFunc)
+	}
+	table = strings.ToLower(table)
+	if b.masks[table] == nil {
+		b.masks[table] = make(map[string]MaskFunc)
+	}
+	b.masks[table][strings.ToLower(column)] = fn
+	return b
+}
+
+// maskStatement returns the statement with the masked columns replaced,
+// statements inserting into tables without masks are returned unchanged.
+// conn is the connection running the statement, its database is the one of
+// unqualified tables.
+func (b *MockBuilder) maskStatement(ctx context.Context, conn *sql.Conn, stmt string) (string, error) {
+	if len(b.masks) == 0 {
+		return stmt, nil
+	}
+	// cheap check first, most statements of a dump are not inserts
+	head := strings.ToLower(strings.TrimSpace(stmt))
+	if !strings.HasPrefix(head, "insert") && !strings.HasPrefix(head, "replace") {
+		return stmt, nil
+	}
+
+	parsed, err := sqlparser.Parse(stmt)
+	if err != nil {
+		// leave it to the server to report the syntax error
+		return stmt, nil
+	}
+	ins, ok := parsed.(*sqlparser.Insert)
+	if !ok {
+		return stmt, nil
+	}
+	table := ins.Table.Name.String()
+	masks := b.masks[strings.ToLower(table)]
+	if len(masks) == 0 {
+		return stmt, nil
+	}
+	var values sqlparser.Values
+	switch rows := ins.Rows.(type) {
+	case sqlparser.Values:
+		values = rows
+	case *sqlparser.AliasedValues:
+		values = rows.Values
+	default:
+		// INSERT ... SELECT has no literal values to mask
+		return stmt, nil
+	}
+
+	columns := make([]string, len(ins.Columns))
+	for i, column := range ins.Columns {
+		columns[i] = column.String()
+	}
+	if len(columns) == 0 {
+		// dumps insert without a column list, in table column order
+		if columns, err = tableColumns(ctx, conn, ins.Table.DbQualifier.String(), table); err != nil {
+			return "", fmt.Errorf("failed to query columns of table '%s' to mask: %w", table, err)
+		}
+	}
+
+	for _, tuple := range values {
+		for i, expr := range tuple {
+			if i >= len(columns) {
+				break
+			}
+			mask, ok := masks[strings.ToLower(columns[i])]
+			if !ok {
+				continue
+			}
+			if val, ok := expr.(*sqlparser.SQLVal); ok {
+				tuple[i] = sqlparser.NewStrVal([]byte(mask(string(val.Val))))
+			}
+		}
+	}
+	return sqlparser.String(ins), nil
+}
+
+// tableColumns returns the columns of the table of the database db, of the
+// current database of conn if db is empty, in table column order
+func tableColumns(ctx context.Context, conn *sql.Conn, db, table string) ([]string, error) {
+	schema, args := "DATABASE()", []any{table}
+	if db != "" {
+		schema, args = "?", []any{db, table}
+	}
+	rows, err := conn.QueryContext(ctx, internalQueryPrefix+"SELECT column_name FROM information_schema.columns "+
+		"WHERE table_schema = "+schema+" AND table_name = ? ORDER BY ordinal_position", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// maskRow returns a copy of the seed row with the masked columns replaced
+func (b *MockBuilder) maskRow(table string, row map[string]any) map[string]any {
+	masks := b.masks[strings.ToLower(table)]
+	if len(masks) == 0 {
+		return row
+	}
+	masked := make(map[string]any, len(row))
+	for column, value := range row {
+		if mask, ok := masks[strings.ToLower(column)]; ok && value != nil {
+			switch v := value.(type) {
+			case string:
+				value = mask(v)
+			case []byte:
+				value = mask(string(v))
+			default:
+				value = mask(fmt.Sprint(v))
+			}
+		}
+		masked[column] = value
+	}
+	return masked
+}
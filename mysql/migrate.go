@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dennis2006/mtest/migrate"
+	"os"
+)
+
+// Migrations applies the migrations of dir, laid out for the tool, upon
+// initialization after the ones added by SQLStmts and SQLFiles. The version
+// table of the tool records them, MigrateDown reverts them.
+func (b *MockBuilder) Migrations(dir string, tool migrate.Tool) *MockBuilder {
+	if _, err := os.Stat(dir); err != nil {
+		b.err = fmt.Errorf("migrations dir %s: %w", dir, err)
+		return b
+	}
+	b.migrationsDir, b.migrationsTool = dir, tool
+	b.sources = append(b.sources, InitSourceFunc(func(ctx context.Context) ([]string, error) {
+		return migrate.Statements(dir, tool, migrate.MySQL)
+	}))
+	return b
+}
+
+// MigrateDown reverts the last steps migrations of Migrations, all of them
+// if steps is 0 or less, e.g. to test the down migrations
+func (b *MockBuilder) MigrateDown(ctx context.Context, steps int) error {
+	if !b.started.Load() {
+		return fmt.Errorf("mysql server not started")
+	}
+	if b.migrationsDir == "" {
+		return fmt.Errorf("no migrations, add them with Migrations")
+	}
+	return migrate.Down(ctx, b.sqlDB, b.migrationsDir, b.migrationsTool, migrate.MySQL, steps)
+}
@@ -0,0 +1,200 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvCI is set by most CI systems, PersistTo does nothing when it is set
+const EnvCI = "CI"
+
+// persistedState is the file PersistTo writes, one per database
+type persistedState struct {
+	// Fingerprint identifies the inputs the state was built from
+	Fingerprint string           `json:"fingerprint"`
+	Tables      []persistedTable `json:"tables"`
+}
+
+type persistedTable struct {
+	// Schema is the database of the table, the one of the builder if empty
+	Schema  string   `json:"schema,omitempty"`
+	Name    string   `json:"name"`
+	DDL     string   `json:"ddl"`
+	Columns []string `json:"columns"`
+	// Rows hold the values as MySQL returns them in text, nil for NULL
+	Rows [][][]byte `json:"rows"`
+}
+
+// PersistTo saves the tables and rows of the mock database and the ones of
+// AddDatabase to a file in dir on shutdown, and loads them on the next Build
+// instead of running the init statements, so a local database keeps its
+// state across go test runs.
+// The state is only loaded while the databases, the init statements and the
+// rows of SeedRows, as masked before their codecs encode them, are unchanged, and
+// the file is named after the database, so give the builder a fixed name.
+// When CI is set, it does nothing.
+func (b *MockBuilder) PersistTo(dir string) *MockBuilder {
+	if os.Getenv(EnvCI) != "" {
+		return b
+	}
+	b.persistDir = dir
+	return b
+}
+
+func (b *MockBuilder) persistPath() string {
+	return filepath.Join(b.persistDir, b.dbName+".json")
+}
+
+// fingerprint hashes the inputs the database is built from: the databases,
+// the init statements and the seeded rows as masked. The values are hashed
+// before the codecs encode them, an encoding like AESGCMCodec differs each time.
+func (b *MockBuilder) fingerprint(loaded [][]string) string {
+	h := sha256.New()
+	for _, name := range b.databases() {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+	for _, stmts := range loaded {
+		for _, stmt := range stmts {
+			h.Write([]byte(stmt))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	for _, seed := range b.seeds {
+		h.Write([]byte(seed.table))
+		h.Write([]byte{0})
+		for _, row := range seed.rows {
+			row = b.maskRow(seed.table, row)
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+			for _, column := range columns {
+				value := row[column]
+				h.Write([]byte(column))
+				h.Write([]byte{0})
+				// NULL differs from an empty value
+				if value == nil {
+					h.Write([]byte{2})
+				} else {
+					h.Write(valueBytes(value))
+				}
+				h.Write([]byte{0})
+			}
+			h.Write([]byte{1})
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// restorePersisted loads the state saved by PersistTo if it was built from
+// the same inputs, and reports whether it did
+func (b *MockBuilder) restorePersisted(ctx context.Context, loaded [][]string) bool {
+	if b.persistDir == "" {
+		return false
+	}
+	b.persistFingerprint = b.fingerprint(loaded)
+
+	data, err := os.ReadFile(b.persistPath())
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		b.err = fmt.Errorf("failed to read persisted state: %w", err)
+		return false
+	}
+	var state persistedState
+	if err = json.Unmarshal(data, &state); err != nil {
+		b.err = fmt.Errorf("failed to parse persisted state %s, delete it to start over: %w", b.persistPath(), err)
+		return false
+	}
+	if state.Fingerprint != b.persistFingerprint {
+		b.logf("databases, init statements or seeded rows changed since the state was persisted, initializing from scratch")
+		return false
+	}
+
+	tables := make([]snapshotTable, len(state.Tables))
+	for i, t := range state.Tables {
+		schema := t.Schema
+		if schema == "" {
+			schema = b.dbName
+		}
+		tables[i] = snapshotTable{schema: schema, name: t.Name, ddl: t.DDL, columns: t.Columns, rows: make([][]any, len(t.Rows))}
+		for j, row := range t.Rows {
+			values := make([]any, len(row))
+			for k, v := range row {
+				if v != nil {
+					values[k] = v
+				}
+			}
+			tables[i].rows[j] = values
+		}
+	}
+	if err = b.restoreTables(ctx, tables); err != nil {
+		b.err = fmt.Errorf("failed to restore persisted state %s, delete it to start over: %w", b.persistPath(), err)
+		return false
+	}
+	b.logf("restored %d tables persisted in %s", len(tables), b.persistPath())
+	return true
+}
+
+// persist saves the tables and rows of the mock database for restorePersisted
+func (b *MockBuilder) persist(ctx context.Context) error {
+	if b.persistDir == "" || b.persistFingerprint == "" {
+		return nil
+	}
+	tables, err := b.readTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := persistedState{Fingerprint: b.persistFingerprint, Tables: make([]persistedTable, len(tables))}
+	for i, t := range tables {
+		schema := t.schema
+		if strings.EqualFold(schema, b.dbName) {
+			schema = ""
+		}
+		state.Tables[i] = persistedTable{Schema: schema, Name: t.name, DDL: t.ddl, Columns: t.columns, Rows: make([][][]byte, len(t.rows))}
+		for j, row := range t.rows {
+			values := make([][]byte, len(row))
+			for k, v := range row {
+				switch v := v.(type) {
+				case nil:
+				case []byte:
+					values[k] = v
+				default:
+					values[k] = []byte(fmt.Sprint(v))
+				}
+			}
+			state.Tables[i].Rows[j] = values
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err = os.MkdirAll(b.persistDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create persist dir: %w", err)
+	}
+	// write then rename, so an interrupted write doesn't leave a broken file
+	tmp := b.persistPath() + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err = os.Rename(tmp, b.persistPath()); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Pool is a fixed set of mock servers started upfront for suites of parallel
+// tests, which would otherwise start a server per test. Acquire lends a
+// server to one test at a time, so server wide state like faults, warnings
+// and the query log only ever belongs to that test.
+type Pool struct {
+	builders []*MockBuilder
+	free     chan *MockBuilder
+	seq      atomic.Uint64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewPool starts size mock servers, each configured by configure if not
+// nil, e.g. to add functions or users. Their messages go to the logger of
+// SetLogger unless configure sets Logf. Close shuts them down.
+func NewPool(size int, configure func(b *MockBuilder)) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", size)
+	}
+	p := &Pool{
+		free:   make(chan *MockBuilder, size),
+		closed: make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		b := Builder(fmt.Sprintf("mtest_pool_%d", i))
+		if configure != nil {
+			configure(b)
+		}
+		if _, _, _, err := b.Build(); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to start pooled mysql server #%d: %w", i, err)
+		}
+		p.builders = append(p.builders, b)
+		p.free <- b
+	}
+	return p, nil
+}
+
+// Acquire waits for a free server of the pool, creates a database named
+// after the test on it, runs the init sources in it and returns a client
+// connected to it. The database is dropped and the server returned to the
+// pool when the test finishes.
+func (p *Pool) Acquire(t testing.TB, sources ...InitSource) *sqlx.DB {
+	t.Helper()
+	_, db := p.AcquireServer(t, sources...)
+	return db
+}
+
+// AcquireServer is Acquire also returning the builder of the server, e.g.
+// to inject faults or assert the executed queries while the test holds it.
+// Its warnings and query log start empty, the faults of the test are
+// removed when it finishes.
+func (p *Pool) AcquireServer(t testing.TB, sources ...InitSource) (*MockBuilder, *sqlx.DB) {
+	t.Helper()
+
+	var b *MockBuilder
+	select {
+	case b = <-p.free:
+	case <-p.closed:
+		t.Fatalf("mysql pool is closed")
+	}
+	t.Cleanup(func() { p.free <- b })
+	db := testDatabase(t, b, &p.seq, sources)
+	// cleanups run last in first out, the faults must not fail dropping the database
+	t.Cleanup(func() { b.ClearFaults() })
+	b.ResetWarnings()
+	b.ResetQueries()
+	return b, db
+}
+
+// Size returns the number of servers of the pool
+func (p *Pool) Size() int {
+	return len(p.builders)
+}
+
+// Close shuts down the servers of the pool, including the ones tests still hold
+func (p *Pool) Close() error {
+	var errs []error
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		for _, b := range p.builders {
+			if err := b.shutdown(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
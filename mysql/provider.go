@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// WithDatabaseProvider serves the databases of the provider instead of a new
+// in-memory one, e.g. to share databases across builders or to add custom
+// databases and tables. The database of the builder is created in the
+// provider if it is missing and the provider is mutable.
+func (b *MockBuilder) WithDatabaseProvider(pro sql.DatabaseProvider) *MockBuilder {
+	b.provider = pro
+	return b
+}
+
+// WithMemoryDatabase serves the pre-built in-memory database, which becomes
+// the database of the builder. The same database may be passed to several
+// builders, they see each other's changes.
+func (b *MockBuilder) WithMemoryDatabase(db *memory.Database) *MockBuilder {
+	b.provider = memory.NewDBProvider(db)
+	b.dbName = db.Name()
+	return b
+}
+
+// ensureDatabase checks the provider holds the database of the builder,
+// creating it if the provider allows to
+func ensureDatabase(pro sql.DatabaseProvider, dbName string) error {
+	ctx := sql.NewContext(context.Background(), sql.WithSession(memory.NewSession(sql.NewBaseSession(), pro)))
+	if pro.HasDatabase(ctx, dbName) {
+		return nil
+	}
+	mutable, ok := pro.(sql.MutableDatabaseProvider)
+	if !ok {
+		return fmt.Errorf("database '%s' not found in the provider", dbName)
+	}
+	if err := mutable.CreateDatabase(ctx, dbName); err != nil {
+		return fmt.Errorf("failed to create database '%s': %w", dbName, err)
+	}
+	return nil
+}
+
+// newSessionBuilder is memory.NewSessionBuilder for any database provider
+func newSessionBuilder(pro sql.DatabaseProvider) server.SessionBuilder {
+	return func(ctx context.Context, conn *vmysql.Conn, addr string) (sql.Session, error) {
+		client := sql.Client{Capabilities: conn.Capabilities}
+		if user, ok := conn.UserData.(sql.MysqlConnectionUser); ok {
+			client.Address = user.Host
+			client.User = user.User
+		}
+		baseSession := sql.NewBaseSessionWithClientServer(addr, client, conn.ConnectionID)
+		baseSession.SetLogger(logrus.NewEntry(engineLogger))
+		return memory.NewSession(baseSession, pro), nil
+	}
+}
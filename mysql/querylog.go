@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// EnableQueryLog records every statement the server executes from now on,
+// including the init statements if enabled before Build, see Queries.
+func (b *MockBuilder) EnableQueryLog() *MockBuilder {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	b.recorder.logQueries = true
+	return b
+}
+
+// Queries returns the statements executed since the query log was enabled,
+// in execution order, with the parameter values of prepared statements.
+func (b *MockBuilder) Queries() []RecordedQuery {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	return append([]RecordedQuery(nil), b.recorder.queries...)
+}
+
+// ResetQueries forgets the statements logged so far, e.g. the init statements
+func (b *MockBuilder) ResetQueries() {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	b.recorder.queries = nil
+}
+
+// AssertExecuted asserts that a logged statement matches the regexp pattern,
+// and if args are given, was executed with these parameter values. Statements
+// are matched with their whitespace collapsed to single spaces.
+func (b *MockBuilder) AssertExecuted(t testing.TB, pattern string, args ...any) bool {
+	t.Helper()
+
+	matched, ok := b.matchQueries(t, pattern)
+	if !ok {
+		return false
+	}
+	if len(matched) == 0 {
+		t.Errorf("no statement matching '%s' was executed, executed:\n%s", pattern, b.formatQueries())
+		return false
+	}
+	if len(args) == 0 {
+		return true
+	}
+	for _, q := range matched {
+		if sameArgs(q.Args, args) {
+			return true
+		}
+	}
+	t.Errorf("no statement matching '%s' was executed with args %v, matching:\n%s", pattern, args, formatQueries(matched))
+	return false
+}
+
+// AssertNotExecuted asserts that no logged statement matches the regexp pattern
+func (b *MockBuilder) AssertNotExecuted(t testing.TB, pattern string) bool {
+	t.Helper()
+
+	matched, ok := b.matchQueries(t, pattern)
+	if !ok {
+		return false
+	}
+	if len(matched) > 0 {
+		t.Errorf("statements matching '%s' were executed:\n%s", pattern, formatQueries(matched))
+		return false
+	}
+	return true
+}
+
+// AssertExecutedTimes asserts that exactly n logged statements match the
+// regexp pattern, e.g. to catch N+1 queries.
+func (b *MockBuilder) AssertExecutedTimes(t testing.TB, pattern string, n int) bool {
+	t.Helper()
+
+	matched, ok := b.matchQueries(t, pattern)
+	if !ok {
+		return false
+	}
+	if len(matched) != n {
+		t.Errorf("%d statements matching '%s' were executed, want %d:\n%s", len(matched), pattern, n, formatQueries(matched))
+		return false
+	}
+	return true
+}
+
+// AssertExecutedInOrder asserts that statements matching the regexp patterns
+// were executed in the given order, other statements may run in between.
+func (b *MockBuilder) AssertExecutedInOrder(t testing.TB, patterns ...string) bool {
+	t.Helper()
+
+	queries := b.Queries()
+	next := 0
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			t.Errorf("invalid query pattern '%s': %v", pattern, err)
+			return false
+		}
+		for next < len(queries) && !re.MatchString(normalizeQuery(queries[next].Query)) {
+			next++
+		}
+		if next == len(queries) {
+			t.Errorf("no statement matching '%s' was executed after the ones matching the previous patterns, executed:\n%s",
+				pattern, formatQueries(queries))
+			return false
+		}
+		next++
+	}
+	return true
+}
+
+func (b *MockBuilder) matchQueries(t testing.TB, pattern string) ([]RecordedQuery, bool) {
+	t.Helper()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Errorf("invalid query pattern '%s': %v", pattern, err)
+		return nil, false
+	}
+	b.recorder.mu.Lock()
+	logging := b.recorder.logQueries
+	b.recorder.mu.Unlock()
+	if !logging {
+		t.Errorf("query log is not enabled, call EnableQueryLog")
+		return nil, false
+	}
+
+	var matched []RecordedQuery
+	for _, q := range b.Queries() {
+		if re.MatchString(normalizeQuery(q.Query)) {
+			matched = append(matched, q)
+		}
+	}
+	return matched, true
+}
+
+func (b *MockBuilder) formatQueries() string {
+	return formatQueries(b.Queries())
+}
+
+func formatQueries(queries []RecordedQuery) string {
+	var sb strings.Builder
+	for _, q := range queries {
+		sb.WriteString("  " + normalizeQuery(q.Query))
+		if len(q.Args) > 0 {
+			fmt.Fprintf(&sb, " %v", q.Args)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// normalizeQuery collapses the whitespace of a statement to single spaces
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// sameArgs compares parameter values by their printed form, so an int
+// argument matches the int64 the server received
+func sameArgs(got, want []any) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if fmt.Sprint(got[i]) != fmt.Sprint(want[i]) {
+			return false
+		}
+	}
+	return true
+}
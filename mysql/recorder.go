@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	vmysql "github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	querypb "github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Warning is a warning raised by the engine, as listed by SHOW WARNINGS
+type Warning struct {
+	Level   string
+	Code    int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %d: %s", w.Level, w.Code, w.Message)
+}
+
+// RecordedQuery is a statement executed by the mock server
+type RecordedQuery struct {
+	ConnectionID uint32
+	Query        string
+	// Args are the parameter values of a prepared statement
+	Args     []any
+	Warnings []Warning
+
+	bindVars map[string]*querypb.BindVariable
+}
+
+// internalQueryPrefix marks the statements run by the builder itself,
+// e.g. the EXPLAINs of the index advisor, which are not recorded.
+const internalQueryPrefix = "/* mtest:internal */ "
+
+// recorder records the statements executed by the server. It wraps the
+// session builder as well, to read the warnings of each statement from
+// the session of its connection.
+type recorder struct {
+	mu       sync.Mutex
+	sessions map[uint32]sql.Session
+	warnings []RecordedQuery
+
+	// logQueries records all statements, not only the ones with warnings
+	logQueries bool
+	queries    []RecordedQuery
+
+	// coverage tracks the schema used by the statements once initialized
+	// is set, after the init statements
+	coverage    *coverageTracker
+	initialized bool
+
+	// strict flags the statements with literal values once initialized
+	strict *strictParameters
+}
+
+var _ server.Interceptor = (*recorder)(nil)
+
+func newRecorder() *recorder {
+	return &recorder{sessions: make(map[uint32]sql.Session)}
+}
+
+func (r *recorder) sessionBuilder(sb server.SessionBuilder) server.SessionBuilder {
+	return func(ctx context.Context, conn *vmysql.Conn, addr string) (sql.Session, error) {
+		sess, err := sb(ctx, conn, addr)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		r.sessions[conn.ConnectionID] = sess
+		r.mu.Unlock()
+		return sess, nil
+	}
+}
+
+func (r *recorder) Priority() int {
+	return 0
+}
+
+func (r *recorder) Query(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) error {
+	err := chain.ComQuery(ctx, c, query, callback)
+	r.record(c, query, nil, err)
+	return err
+}
+
+func (r *recorder) ParsedQuery(chain server.Chain, c *vmysql.Conn, query string, parsed sqlparser.Statement, callback func(res *sqltypes.Result, more bool) error) error {
+	err := chain.ComQuery(context.Background(), c, query, callback)
+	r.record(c, query, nil, err)
+	return err
+}
+
+func (r *recorder) MultiQuery(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, callback func(res *sqltypes.Result, more bool) error) (string, error) {
+	remainder, err := chain.ComMultiQuery(ctx, c, query, callback)
+	r.record(c, strings.TrimSuffix(query, remainder), nil, err)
+	return remainder, err
+}
+
+func (r *recorder) Prepare(ctx context.Context, chain server.Chain, c *vmysql.Conn, query string, prepare *vmysql.PrepareData) ([]*querypb.Field, error) {
+	return chain.ComPrepare(ctx, c, query, prepare)
+}
+
+func (r *recorder) StmtExecute(ctx context.Context, chain server.Chain, c *vmysql.Conn, prepare *vmysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	err := chain.ComStmtExecute(ctx, c, prepare, callback)
+	r.record(c, prepare.PrepareStmt, prepare.BindVars, err)
+	return err
+}
+
+// record records the statement just executed on the connection. The statement
+// has completed by then, and the next one of the connection is not read before
+// the interceptor returns, so the session holds the warnings of this statement.
+// err is the error of the statement, which then counts for no schema coverage.
+func (r *recorder) record(c *vmysql.Conn, query string, bindVars map[string]*querypb.BindVariable, err error) {
+	if strings.HasPrefix(query, internalQueryPrefix) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.coverage != nil && r.initialized && err == nil {
+		r.coverage.track(query)
+	}
+	if r.strict != nil && r.initialized {
+		r.strict.check(RecordedQuery{ConnectionID: c.ConnectionID, Query: query, Args: bindVarArgs(bindVars)})
+	}
+
+	var warnings []Warning
+	// SHOW WARNINGS lists the warnings of the previous statement
+	if sess, ok := r.sessions[c.ConnectionID]; ok && sess.WarningCount() > 0 &&
+		!strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SHOW WARNINGS") {
+		// the session returns the latest warning first
+		sessWarnings := sess.Warnings()
+		warnings = make([]Warning, 0, len(sessWarnings))
+		for i := len(sessWarnings) - 1; i >= 0; i-- {
+			w := sessWarnings[i]
+			warnings = append(warnings, Warning{Level: w.Level, Code: w.Code, Message: w.Message})
+		}
+	}
+	if !r.logQueries && len(warnings) == 0 {
+		return
+	}
+
+	q := RecordedQuery{
+		ConnectionID: c.ConnectionID,
+		Query:        query,
+		Args:         bindVarArgs(bindVars),
+		Warnings:     warnings,
+		bindVars:     maps.Clone(bindVars),
+	}
+	if r.logQueries {
+		r.queries = append(r.queries, q)
+	}
+	if len(warnings) > 0 {
+		r.warnings = append(r.warnings, q)
+	}
+}
+
+// bindVarArgs converts the bind variables v1..vn of a prepared statement
+// to the Go values of its parameters.
+func bindVarArgs(bindVars map[string]*querypb.BindVariable) []any {
+	if len(bindVars) == 0 {
+		return nil
+	}
+	args := make([]any, len(bindVars))
+	for i := range args {
+		bv, ok := bindVars["v"+strconv.Itoa(i+1)]
+		if !ok {
+			continue
+		}
+		v, err := sqltypes.BindVariableToValue(bv)
+		if err != nil || v.IsNull() {
+			continue
+		}
+		switch {
+		case v.IsSigned():
+			args[i], _ = strconv.ParseInt(v.ToString(), 10, 64)
+		case v.IsIntegral():
+			args[i], _ = strconv.ParseUint(v.ToString(), 10, 64)
+		case v.IsFloat():
+			args[i], _ = strconv.ParseFloat(v.ToString(), 64)
+		default:
+			args[i] = v.ToString()
+		}
+	}
+	return args
+}
+
+// Warnings returns the statements executed so far that raised warnings,
+// e.g. on silent truncation or implicit conversion, in execution order.
+func (b *MockBuilder) Warnings() []RecordedQuery {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	return append([]RecordedQuery(nil), b.recorder.warnings...)
+}
+
+// ResetWarnings forgets the warnings recorded so far, e.g. the ones
+// raised by the init statements.
+func (b *MockBuilder) ResetWarnings() {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	b.recorder.warnings = nil
+}
+
+// AssertNoWarnings asserts that no statement executed so far raised a warning.
+func (b *MockBuilder) AssertNoWarnings(t testing.TB) bool {
+	t.Helper()
+
+	warned := b.Warnings()
+	for _, q := range warned {
+		for _, w := range q.Warnings {
+			t.Errorf("statement '%s' raised warning: %s", q.Query, w)
+		}
+	}
+	return len(warned) == 0
+}
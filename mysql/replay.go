@@ -0,0 +1,237 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayResult is the outcome of ReplayGeneralLog
+type ReplayResult struct {
+	// Statements is the number of statements replayed, failed ones included
+	Statements int
+	Failures   []ReplayFailure
+	// Elapsed is the duration of the replay
+	Elapsed time.Duration
+}
+
+// ReplayFailure is a statement of the log the mock failed to execute
+type ReplayFailure struct {
+	// Line is the line of the statement in the log
+	Line      int
+	Thread    int64
+	Statement string
+	Err       error
+}
+
+func (f ReplayFailure) String() string {
+	return fmt.Sprintf("line %d, thread %d: %s: %v", f.Line, f.Thread, f.Statement, f.Err)
+}
+
+// generalLogEntry is an entry of a general query log
+type generalLogEntry struct {
+	line     int
+	time     time.Time
+	thread   int64
+	command  string
+	argument string
+}
+
+// generalLogLine matches the first line of an entry: the time of MySQL 5.7
+// and later, or of 5.6 and earlier which only logs it once per second, the
+// thread id, the command and its argument
+var generalLogLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+|\d{6}\s+\d{1,2}:\d{2}:\d{2})?\s+(\d+)\s([A-Z][a-z]*(?: [A-Za-z]+)?)(?:\t(.*))?$`)
+
+// generalLogCommands are the commands of the entries, the lines not starting
+// with one of them continue the argument of the previous entry
+var generalLogCommands = map[string]bool{
+	"Connect": true, "Quit": true, "Init DB": true, "Query": true, "Field List": true,
+	"Prepare": true, "Execute": true, "Close stmt": true, "Reset stmt": true, "Fetch": true,
+	"Long Data": true, "Statistics": true, "Ping": true, "Change user": true, "Set option": true,
+	"Refresh": true, "Shutdown": true, "Processlist": true, "Kill": true, "Debug": true,
+	"Binlog Dump": true, "Register Slave": true, "Daemon": true, "Reset Connection": true,
+}
+
+// parseGeneralLog calls fn with the entries of the general query log in
+// order, skipping the headers written when the log is opened
+func parseGeneralLog(file string, fn func(generalLogEntry) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open general log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var (
+		entry   *generalLogEntry
+		last    time.Time
+		lineNum int
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if isGeneralLogHeader(line) {
+			continue
+		}
+		m := generalLogLine.FindStringSubmatch(line)
+		if m == nil || !generalLogCommands[m[3]] {
+			if entry != nil {
+				entry.argument += "\n" + line
+			}
+			continue
+		}
+
+		if entry != nil {
+			if err = fn(*entry); err != nil {
+				return err
+			}
+		}
+		if m[1] != "" {
+			if last, err = parseGeneralLogTime(m[1]); err != nil {
+				return fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		}
+		thread, _ := strconv.ParseInt(m[2], 10, 64)
+		entry = &generalLogEntry{line: lineNum, time: last, thread: thread, command: m[3], argument: m[4]}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read general log: %w", err)
+	}
+	if entry != nil {
+		return fn(*entry)
+	}
+	return nil
+}
+
+func isGeneralLogHeader(line string) bool {
+	return strings.Contains(line, ", Version: ") && strings.HasSuffix(line, "started with:") ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		strings.HasPrefix(line, "Time ") && strings.Contains(line, " Id Command ")
+}
+
+func parseGeneralLogTime(s string) (time.Time, error) {
+	if strings.Contains(s, "T") {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return time.Parse("060102 15:04:05", strings.Join(strings.Fields(s), " "))
+}
+
+// ReplayGeneralLog replays the statements of the MySQL general query log
+// file against the mock, e.g. to drive regression and performance tests
+// with a production shaped workload. Each thread of the log gets its own
+// connection, so its USE, SET and transactions hold for its following
+// statements, and the statements run one at a time in the order of the log.
+// The database of a Connect or Init DB is only switched to if the mock has
+// it, the connections start in the database of the builder.
+//
+// speed scales the time between the statements, 1 keeps the pace of the
+// log, 2 replays it twice as fast, 0 or less replays it as fast as possible.
+// The statements failing are returned in the result rather than stopping
+// the replay.
+func (b *MockBuilder) ReplayGeneralLog(file string, speed float64) (*ReplayResult, error) {
+	if b.sqlDB == nil {
+		return nil, errors.New("mysql server not started")
+	}
+	ctx := context.Background()
+	databases := make(map[string]bool)
+	for _, name := range b.databases() {
+		databases[strings.ToLower(name)] = true
+	}
+
+	conns := make(map[int64]*sql.Conn)
+	defer func() {
+		// discard the connections, their session state must not go back to the pool
+		for _, conn := range conns {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			_ = conn.Close()
+		}
+	}()
+	connFor := func(thread int64) (*sql.Conn, error) {
+		if conn, ok := conns[thread]; ok {
+			return conn, nil
+		}
+		conn, err := b.sqlDB.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection: %w", err)
+		}
+		if _, err = conn.ExecContext(ctx, internalQueryPrefix+"USE "+quoteIdent(b.dbName)); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to use database '%s': %w", b.dbName, err)
+		}
+		conns[thread] = conn
+		return conn, nil
+	}
+	use := func(conn *sql.Conn, db string) error {
+		if !databases[strings.ToLower(db)] {
+			return nil
+		}
+		_, err := conn.ExecContext(ctx, internalQueryPrefix+"USE "+quoteIdent(db))
+		return err
+	}
+
+	result := &ReplayResult{}
+	start := time.Now()
+	var first time.Time
+	err := parseGeneralLog(file, func(e generalLogEntry) error {
+		if speed > 0 && !e.time.IsZero() {
+			if first.IsZero() {
+				first = e.time
+			}
+			if wait := time.Duration(float64(e.time.Sub(first))/speed) - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		switch e.command {
+		case "Connect":
+			conn, err := connFor(e.thread)
+			if err != nil {
+				return err
+			}
+			// e.g. "root@localhost on shop using TCP/IP"
+			if _, rest, ok := strings.Cut(e.argument, " on "); ok {
+				if db, _, _ := strings.Cut(rest, " "); db != "" {
+					return use(conn, db)
+				}
+			}
+		case "Init DB":
+			conn, err := connFor(e.thread)
+			if err != nil {
+				return err
+			}
+			return use(conn, strings.TrimSpace(e.argument))
+		case "Quit":
+			if conn, ok := conns[e.thread]; ok {
+				_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+				_ = conn.Close()
+				delete(conns, e.thread)
+			}
+		case "Query", "Execute":
+			// Execute logs the prepared statement with its parameters
+			stmt := strings.TrimSpace(e.argument)
+			if stmt == "" {
+				return nil
+			}
+			conn, err := connFor(e.thread)
+			if err != nil {
+				return err
+			}
+			result.Statements++
+			if _, err = conn.ExecContext(ctx, stmt); err != nil {
+				result.Failures = append(result.Failures, ReplayFailure{Line: e.line, Thread: e.thread, Statement: stmt, Err: err})
+			}
+		}
+		return nil
+	})
+	result.Elapsed = time.Since(start)
+	return result, err
+}
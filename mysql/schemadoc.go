@@ -0,0 +1,201 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SchemaFormat is the output format of SchemaDoc
+type SchemaFormat string
+
+const (
+	// SchemaMarkdown renders a section with a column and an index table per table
+	SchemaMarkdown SchemaFormat = "markdown"
+	// SchemaDot renders a graphviz digraph, tables are nodes and foreign keys edges
+	SchemaDot SchemaFormat = "dot"
+)
+
+type schemaColumn struct {
+	Schema   string         `db:"table_schema"`
+	Table    string         `db:"table_name"`
+	Name     string         `db:"column_name"`
+	Type     string         `db:"column_type"`
+	Nullable string         `db:"is_nullable"`
+	Key      string         `db:"column_key"`
+	Default  sql.NullString `db:"column_default"`
+	Extra    string         `db:"extra"`
+}
+
+type schemaIndex struct {
+	Schema    string `db:"table_schema"`
+	Table     string `db:"table_name"`
+	Name      string `db:"index_name"`
+	NonUnique int    `db:"non_unique"`
+	Column    string `db:"column_name"`
+}
+
+type schemaForeignKey struct {
+	Schema           string `db:"table_schema"`
+	Table            string `db:"table_name"`
+	Column           string `db:"column_name"`
+	ReferencedSchema string `db:"referenced_table_schema"`
+	ReferencedTable  string `db:"referenced_table_name"`
+	ReferencedColumn string `db:"referenced_column_name"`
+}
+
+type schemaTable struct {
+	name    string
+	columns []schemaColumn
+	// indexes maps the index names to their columns, in indexNames order
+	indexes    map[string][]string
+	indexNames []string
+	unique     map[string]bool
+}
+
+// SchemaDoc writes a description of the tables, columns, indexes and foreign
+// keys currently in the mock database, e.g. to see why a query fails or to
+// document the schema a test runs against. The tables of AddDatabase are
+// named after their database, e.g. "audit.events".
+func (b *MockBuilder) SchemaDoc(w io.Writer, format SchemaFormat) error {
+	if b.sqlxDB == nil {
+		return errors.New("mysql server not started")
+	}
+
+	filter, args := b.schemaFilter()
+	var columns []schemaColumn
+	err := b.sqlxDB.Select(&columns, internalQueryPrefix+"SELECT table_schema AS table_schema, table_name AS table_name, "+
+		"column_name AS column_name, column_type AS column_type, is_nullable AS is_nullable, column_key AS column_key, "+
+		"column_default AS column_default, extra AS extra FROM information_schema.columns "+
+		"WHERE "+filter+" ORDER BY table_schema, table_name, ordinal_position", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query columns: %w", err)
+	}
+	var indexes []schemaIndex
+	err = b.sqlxDB.Select(&indexes, internalQueryPrefix+"SELECT table_schema AS table_schema, table_name AS table_name, "+
+		"index_name AS index_name, non_unique AS non_unique, column_name AS column_name "+
+		"FROM information_schema.statistics WHERE "+filter+" "+
+		"ORDER BY table_schema, table_name, index_name, seq_in_index", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query indexes: %w", err)
+	}
+	var fks []schemaForeignKey
+	err = b.sqlxDB.Select(&fks, internalQueryPrefix+"SELECT table_schema AS table_schema, table_name AS table_name, "+
+		"column_name AS column_name, referenced_table_schema AS referenced_table_schema, "+
+		"referenced_table_name AS referenced_table_name, referenced_column_name AS referenced_column_name "+
+		"FROM information_schema.key_column_usage "+
+		"WHERE "+filter+" AND referenced_table_name IS NOT NULL "+
+		"ORDER BY table_schema, table_name, column_name", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	for i := range columns {
+		columns[i].Table = b.qualifiedName(columns[i].Schema, columns[i].Table)
+	}
+	for i := range indexes {
+		indexes[i].Table = b.qualifiedName(indexes[i].Schema, indexes[i].Table)
+	}
+	for i := range fks {
+		fks[i].Table = b.qualifiedName(fks[i].Schema, fks[i].Table)
+		fks[i].ReferencedTable = b.qualifiedName(fks[i].ReferencedSchema, fks[i].ReferencedTable)
+	}
+
+	var tables []*schemaTable
+	byName := make(map[string]*schemaTable)
+	for _, column := range columns {
+		t, ok := byName[column.Table]
+		if !ok {
+			t = &schemaTable{name: column.Table, indexes: make(map[string][]string), unique: make(map[string]bool)}
+			byName[column.Table] = t
+			tables = append(tables, t)
+		}
+		t.columns = append(t.columns, column)
+	}
+	for _, index := range indexes {
+		t, ok := byName[index.Table]
+		if !ok {
+			continue
+		}
+		if _, ok = t.indexes[index.Name]; !ok {
+			t.indexNames = append(t.indexNames, index.Name)
+		}
+		t.indexes[index.Name] = append(t.indexes[index.Name], index.Column)
+		t.unique[index.Name] = index.NonUnique == 0
+	}
+
+	switch format {
+	case SchemaMarkdown:
+		return writeSchemaMarkdown(w, b.dbName, tables, fks)
+	case SchemaDot:
+		return writeSchemaDot(w, b.dbName, tables, fks)
+	default:
+		return fmt.Errorf("unknown schema format '%s'", format)
+	}
+}
+
+func writeSchemaMarkdown(w io.Writer, dbName string, tables []*schemaTable, fks []schemaForeignKey) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", dbName)
+	for _, t := range tables {
+		fmt.Fprintf(&sb, "\n## %s\n\n", t.name)
+		sb.WriteString("| column | type | nullable | key | default | extra |\n")
+		sb.WriteString("|---|---|---|---|---|---|\n")
+		for _, c := range t.columns {
+			def := ""
+			if c.Default.Valid {
+				def = c.Default.String
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s |\n", c.Name, c.Type, c.Nullable, c.Key, def, c.Extra)
+		}
+
+		if len(t.indexNames) > 0 {
+			sb.WriteString("\n| index | unique | columns |\n|---|---|---|\n")
+			for _, name := range t.indexNames {
+				fmt.Fprintf(&sb, "| %s | %t | %s |\n", name, t.unique[name], strings.Join(t.indexes[name], ", "))
+			}
+		}
+
+		var refs []string
+		for _, fk := range fks {
+			if fk.Table == t.name {
+				refs = append(refs, fmt.Sprintf("- %s → %s.%s\n", fk.Column, fk.ReferencedTable, fk.ReferencedColumn))
+			}
+		}
+		if len(refs) > 0 {
+			sb.WriteString("\nforeign keys:\n\n" + strings.Join(refs, ""))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeSchemaDot(w io.Writer, dbName string, tables []*schemaTable, fks []schemaForeignKey) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", dbName)
+	sb.WriteString("  node [shape=record];\n")
+	for _, t := range tables {
+		fields := make([]string, 0, len(t.columns))
+		for _, c := range t.columns {
+			field := fmt.Sprintf("<%s> %s %s", c.Name, c.Name, c.Type)
+			if c.Key == "PRI" {
+				field += " (PK)"
+			}
+			fields = append(fields, dotEscape(field))
+		}
+		fmt.Fprintf(&sb, "  %q [label=\"{%s|%s}\"];\n", t.name, dotEscape(t.name), strings.Join(fields, "|"))
+	}
+	for _, fk := range fks {
+		fmt.Fprintf(&sb, "  %q:%q -> %q:%q;\n", fk.Table, fk.Column, fk.ReferencedTable, fk.ReferencedColumn)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotEscape escapes the characters of record labels, keeping the port markers
+func dotEscape(s string) string {
+	r := strings.NewReplacer(`"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`)
+	return r.Replace(s)
+}
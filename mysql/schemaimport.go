@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"regexp"
+	"strings"
+)
+
+// ImportSchemaFrom loads the tables of the database of dsn, a real MySQL
+// like a shared dev database, into the mock upon initialization, all its
+// base tables if none are given, so the mock schema follows the real one
+// instead of a copy of its DDL going stale. The SHOW CREATE TABLE output is
+// adapted to the mock, see adaptCreateTable. The database is read when the
+// server is built, a failure fails Build.
+func (b *MockBuilder) ImportSchemaFrom(dsn string, tables ...string) *MockBuilder {
+	b.sources = append(b.sources, InitSourceFunc(func(ctx context.Context) ([]string, error) {
+		return importSchema(ctx, dsn, tables)
+	}))
+	return b
+}
+
+func importSchema(ctx context.Context, dsn string, tables []string) ([]string, error) {
+	db, err := sqlx.ConnectContext(ctx, "mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to schema source: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if len(tables) == 0 {
+		rows, err := db.QueryContext(ctx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var table, typ string
+			if err = rows.Scan(&table, &typ); err != nil {
+				return nil, fmt.Errorf("failed to scan tables: %w", err)
+			}
+			tables = append(tables, table)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the tables are created in the given order, whatever their foreign keys reference
+	stmts := []string{"SET FOREIGN_KEY_CHECKS = 0"}
+	for _, table := range tables {
+		var name, ddl string
+		if err = db.QueryRowxContext(ctx, "SHOW CREATE TABLE "+quoteIdent(table)).Scan(&name, &ddl); err != nil {
+			return nil, fmt.Errorf("failed to show create table %s: %w", table, err)
+		}
+		stmts = append(stmts, adaptCreateTable(ddl))
+	}
+	return append(stmts, "SET FOREIGN_KEY_CHECKS = 1"), nil
+}
+
+// tableOptionAdaptations rewrite the table options of SHOW CREATE TABLE,
+// which follow the closing parenthesis of the definitions, the mock can't
+// parse or shouldn't copy
+var tableOptionAdaptations = []*regexp.Regexp{
+	// partitioning is not supported, the table keeps its rows unpartitioned
+	regexp.MustCompile(`(?s)\s*/\*!\d+\s+PARTITION\s+BY\b.*?\*/`),
+	regexp.MustCompile(`(?is)\s*\bPARTITION\s+BY\b.*$`),
+	// the tables start empty, their ids from 1
+	regexp.MustCompile(`(?i)\s*\bAUTO_INCREMENT\s*=\s*\d+`),
+	regexp.MustCompile(`(?i)\s*/\*!\d+\s+DEFAULT\s+ENCRYPTION\s*=\s*'[^']*'\s*\*/`),
+	regexp.MustCompile(`(?i)\s*\b(ROW_FORMAT|KEY_BLOCK_SIZE|STATS_PERSISTENT|STATS_AUTO_RECALC|STATS_SAMPLE_PAGES|COMPRESSION|SECONDARY_ENGINE|TABLESPACE)\b\s*=?\s*('[^']*'|` + "`[^`]*`" + `|\w+)`),
+}
+
+// withParser is the parser of a full text index, e.g. ngram, which the mock lacks
+var withParser = regexp.MustCompile(`(?i)\s*/\*!\d+\s+WITH\s+PARSER\s+\S+\s*\*/`)
+
+// adaptCreateTable rewrites the DDL of SHOW CREATE TABLE for the mock:
+// partitioning, encryption, full text parsers and storage options are
+// dropped, and so is the auto increment counter, the mock tables start empty
+func adaptCreateTable(ddl string) string {
+	end := strings.LastIndex(ddl, "\n)")
+	if end < 0 {
+		return ddl
+	}
+	defs, options := ddl[:end], ddl[end:]
+	defs = withParser.ReplaceAllString(defs, "")
+	for _, re := range tableOptionAdaptations {
+		options = re.ReplaceAllString(options, "")
+	}
+	return defs + options
+}
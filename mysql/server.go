@@ -7,27 +7,53 @@ import (
 	"github.com/dolthub/go-mysql-server/memory"
 	"github.com/dolthub/go-mysql-server/server"
 	"github.com/dolthub/go-mysql-server/sql"
+	"net"
 )
 
-func createMySQLServer(dbName string, port int) (*server.Server, error) {
-	// create a new database
-	db := memory.NewDatabase(dbName)
-	db.BaseDatabase.EnablePrimaryKeyIndexes()
+// createMySQLProvider creates an in-memory database provider holding the given databases
+func createMySQLProvider(dbNames ...string) *memory.DbProvider {
+	dbs := make([]sql.Database, len(dbNames))
+	for i, name := range dbNames {
+		// create a new database
+		db := memory.NewDatabase(name)
+		db.BaseDatabase.EnablePrimaryKeyIndexes()
+		dbs[i] = db
+	}
+
+	return memory.NewDBProvider(dbs...)
+}
 
-	pro := memory.NewDBProvider(db)
+// createMySQLServer creates a server accepting connections on the given listener,
+// which is bound by the caller so the port is known before the server starts.
+// functions are registered on top of the built-in ones, ctxFactory creates
+// the context of every query.
+func createMySQLServer(pro sql.DatabaseProvider, dbName string, listener net.Listener, functions []sql.Function, ctxFactory sql.ContextFactory, sessionBuilder server.SessionBuilder, interceptors ...server.Interceptor) (*server.Server, error) {
 	session := memory.NewSession(sql.NewBaseSession(), pro)
 	ctx := sql.NewContext(context.Background(), sql.WithSession(session))
 	ctx.SetCurrentDatabase(dbName)
 
 	// create a new server engine
 	engine := sqle.NewDefault(pro)
+	if len(functions) > 0 {
+		engine.Analyzer.Catalog.RegisterFunction(ctx, functions...)
+	}
 	config := server.Config{
 		Protocol: "tcp",
-		Address:  fmt.Sprintf("127.0.0.1:%d", port),
+		Address:  listener.Addr().String(),
+		Listener: listener,
+	}
+
+	// wrap the handler with the given interceptors
+	if len(interceptors) > 0 {
+		var chain server.InterceptorChain
+		for _, interceptor := range interceptors {
+			chain.WithInterceptor(interceptor)
+		}
+		config.Options = append(config.Options, chain.Option())
 	}
 
 	// create a new server
-	s, err := server.NewServer(config, engine, sql.NewContext, memory.NewSessionBuilder(pro), nil)
+	s, err := server.NewServer(config, engine, ctxFactory, sessionBuilder, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
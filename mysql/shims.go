@@ -0,0 +1,293 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/types"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WithCompatShims registers shims of MySQL functions the mock server lacks, so
+// typical application SQL runs unchanged: TRUNCATE, SEC_TO_TIME, MAKETIME,
+// TO_SECONDS, MAKEDATE, PERIOD_ADD, PERIOD_DIFF, UTC_DATE, UTC_TIME,
+// UUID_SHORT, OCT and INSERT. Times are returned as "hh:mm:ss" strings.
+// UUID_TO_BIN/BIN_TO_UUID, JSON_TABLE and GROUP_CONCAT are supported natively.
+// Calling it again does nothing.
+func (b *MockBuilder) WithCompatShims() *MockBuilder {
+	if b.compatShims {
+		return b
+	}
+	b.compatShims = true
+	b.functions = append(b.functions, compatShims()...)
+	return b
+}
+
+// shimFunc is a function expression evaluating its arguments and passing
+// them to eval, a NULL argument makes the result NULL.
+type shimFunc struct {
+	name string
+	args []sql.Expression
+	typ  sql.Type
+	eval func(ctx *sql.Context, args []any) (any, error)
+}
+
+var _ sql.FunctionExpression = (*shimFunc)(nil)
+
+func (f *shimFunc) Resolved() bool {
+	for _, arg := range f.args {
+		if !arg.Resolved() {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *shimFunc) String() string {
+	args := make([]string, len(f.args))
+	for i, arg := range f.args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToUpper(f.name), strings.Join(args, ", "))
+}
+
+func (f *shimFunc) Type() sql.Type {
+	return f.typ
+}
+
+func (f *shimFunc) IsNullable() bool {
+	return true
+}
+
+func (f *shimFunc) Eval(ctx *sql.Context, row sql.Row) (any, error) {
+	values := make([]any, len(f.args))
+	for i, arg := range f.args {
+		v, err := arg.Eval(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+		values[i] = v
+	}
+	return f.eval(ctx, values)
+}
+
+func (f *shimFunc) Children() []sql.Expression {
+	return f.args
+}
+
+func (f *shimFunc) WithChildren(children ...sql.Expression) (sql.Expression, error) {
+	if len(children) != len(f.args) {
+		return nil, sql.ErrInvalidChildrenNumber.New(f, len(children), len(f.args))
+	}
+	nf := *f
+	nf.args = children
+	return &nf, nil
+}
+
+func (f *shimFunc) FunctionName() string {
+	return f.name
+}
+
+func (f *shimFunc) Description() string {
+	return "mtest compatibility shim of " + strings.ToUpper(f.name)
+}
+
+// newShim returns the function of a shim accepting the given argument counts
+func newShim(name string, typ sql.Type, arity []int, eval func(ctx *sql.Context, args []any) (any, error)) sql.Function {
+	return sql.FunctionN{
+		Name: name,
+		Fn: func(args ...sql.Expression) (sql.Expression, error) {
+			for _, n := range arity {
+				if len(args) == n {
+					return &shimFunc{name: name, args: args, typ: typ, eval: eval}, nil
+				}
+			}
+			return nil, sql.ErrInvalidArgumentNumber.New(name, arity, len(args))
+		},
+	}
+}
+
+var uuidShort atomic.Uint64
+
+func compatShims() []sql.Function {
+	return []sql.Function{
+		newShim("truncate", types.Float64, []int{2}, func(ctx *sql.Context, args []any) (any, error) {
+			x, err := shimFloat(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			d, err := shimInt(ctx, args[1])
+			if err != nil {
+				return nil, err
+			}
+			scale := math.Pow(10, float64(d))
+			return math.Trunc(x*scale) / scale, nil
+		}),
+		newShim("sec_to_time", types.LongText, []int{1}, func(ctx *sql.Context, args []any) (any, error) {
+			secs, err := shimInt(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return formatShimTime(secs), nil
+		}),
+		newShim("maketime", types.LongText, []int{3}, func(ctx *sql.Context, args []any) (any, error) {
+			var parts [3]int64
+			for i := range parts {
+				v, err := shimInt(ctx, args[i])
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = v
+			}
+			if parts[1] < 0 || parts[1] > 59 || parts[2] < 0 || parts[2] > 59 {
+				return nil, nil
+			}
+			secs := abs64(parts[0])*3600 + parts[1]*60 + parts[2]
+			if parts[0] < 0 {
+				secs = -secs
+			}
+			return formatShimTime(secs), nil
+		}),
+		newShim("to_seconds", types.Int64, []int{1}, func(ctx *sql.Context, args []any) (any, error) {
+			t, err := shimTime(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			// seconds since year 0, 62167219200 is the unix epoch
+			return t.Unix() + 62167219200, nil
+		}),
+		newShim("makedate", types.Date, []int{2}, func(ctx *sql.Context, args []any) (any, error) {
+			year, err := shimInt(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			day, err := shimInt(ctx, args[1])
+			if err != nil {
+				return nil, err
+			}
+			if day <= 0 {
+				return nil, nil
+			}
+			return time.Date(int(year), time.January, int(day), 0, 0, 0, 0, time.UTC), nil
+		}),
+		newShim("period_add", types.Int64, []int{2}, func(ctx *sql.Context, args []any) (any, error) {
+			period, err := shimInt(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			n, err := shimInt(ctx, args[1])
+			if err != nil {
+				return nil, err
+			}
+			months := periodMonths(period) + n
+			return (months/12)*100 + months%12 + 1, nil
+		}),
+		newShim("period_diff", types.Int64, []int{2}, func(ctx *sql.Context, args []any) (any, error) {
+			p1, err := shimInt(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			p2, err := shimInt(ctx, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return periodMonths(p1) - periodMonths(p2), nil
+		}),
+		newShim("utc_date", types.Date, []int{0}, func(ctx *sql.Context, args []any) (any, error) {
+			now := ctx.QueryTime().UTC()
+			return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+		}),
+		newShim("utc_time", types.LongText, []int{0, 1}, func(ctx *sql.Context, args []any) (any, error) {
+			return ctx.QueryTime().UTC().Format("15:04:05"), nil
+		}),
+		newShim("uuid_short", types.Uint64, []int{0}, func(ctx *sql.Context, args []any) (any, error) {
+			// like MySQL, a counter starting at the server start time
+			uuidShort.CompareAndSwap(0, uint64(time.Now().Unix())<<24)
+			return uuidShort.Add(1), nil
+		}),
+		newShim("oct", types.LongText, []int{1}, func(ctx *sql.Context, args []any) (any, error) {
+			n, err := shimInt(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return strconv.FormatUint(uint64(n), 8), nil
+		}),
+		newShim("insert", types.LongText, []int{4}, func(ctx *sql.Context, args []any) (any, error) {
+			s := []rune(fmt.Sprint(args[0]))
+			pos, err := shimInt(ctx, args[1])
+			if err != nil {
+				return nil, err
+			}
+			n, err := shimInt(ctx, args[2])
+			if err != nil {
+				return nil, err
+			}
+			if pos < 1 || pos > int64(len(s)) {
+				return string(s), nil
+			}
+			end := int64(len(s))
+			if n >= 0 && pos-1+n < end {
+				end = pos - 1 + n
+			}
+			return string(s[:pos-1]) + fmt.Sprint(args[3]) + string(s[end:]), nil
+		}),
+	}
+}
+
+func shimInt(ctx *sql.Context, v any) (int64, error) {
+	converted, _, err := types.Int64.Convert(ctx, v)
+	if err != nil {
+		return 0, err
+	}
+	return converted.(int64), nil
+}
+
+func shimFloat(ctx *sql.Context, v any) (float64, error) {
+	converted, _, err := types.Float64.Convert(ctx, v)
+	if err != nil {
+		return 0, err
+	}
+	return converted.(float64), nil
+}
+
+func shimTime(ctx *sql.Context, v any) (time.Time, error) {
+	converted, _, err := types.DatetimeMaxPrecision.Convert(ctx, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return converted.(time.Time), nil
+}
+
+// formatShimTime formats seconds as a TIME value, e.g. "-01:01:01"
+func formatShimTime(secs int64) string {
+	sign := ""
+	if secs < 0 {
+		sign = "-"
+		secs = -secs
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, secs/3600, secs/60%60, secs%60)
+}
+
+// periodMonths converts a YYMM or YYYYMM period to months since year 0
+func periodMonths(period int64) int64 {
+	year, month := period/100, period%100
+	if year < 70 {
+		year += 2000
+	} else if year < 100 {
+		year += 1900
+	}
+	return year*12 + month - 1
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"path/filepath"
+	"strings"
+)
+
+// SmokeTarget is a database a migration directory is applied to, e.g. the
+// mock server, a MySQL container or a Doris container.
+type SmokeTarget struct {
+	Name string
+	DB   *sqlx.DB
+}
+
+// SmokeResult is the outcome of one migration statement on every target,
+// keyed by target name; a nil error means the statement succeeded.
+type SmokeResult struct {
+	File      string
+	Statement string
+	Errors    map[string]error
+}
+
+// Compatible reports whether the statement succeeded on all targets
+func (r SmokeResult) Compatible() bool {
+	for _, err := range r.Errors {
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SmokeReport holds the results of all statements of a migration directory
+type SmokeReport struct {
+	Targets []string
+	Results []SmokeResult
+}
+
+// Incompatible returns the statements that failed on at least one target
+func (r *SmokeReport) Incompatible() []SmokeResult {
+	var failed []SmokeResult
+	for _, result := range r.Results {
+		if !result.Compatible() {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// String renders the report as a markdown table, one row per statement
+// and one column per target.
+func (r *SmokeReport) String() string {
+	var sb strings.Builder
+	sb.WriteString("| file | statement | " + strings.Join(r.Targets, " | ") + " |\n")
+	sb.WriteString("|---|---|" + strings.Repeat("---|", len(r.Targets)) + "\n")
+	for _, result := range r.Results {
+		stmt := result.Statement
+		if len(stmt) > 60 {
+			stmt = stmt[:57] + "..."
+		}
+		cells := []string{result.File, "`" + strings.ReplaceAll(stmt, "|", `\|`) + "`"}
+		for _, target := range r.Targets {
+			if err := result.Errors[target]; err != nil {
+				cells = append(cells, "FAIL: "+strings.ReplaceAll(err.Error(), "|", `\|`))
+			} else {
+				cells = append(cells, "ok")
+			}
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	fmt.Fprintf(&sb, "\n%d of %d statements compatible with all targets\n",
+		len(r.Results)-len(r.Incompatible()), len(r.Results))
+	return sb.String()
+}
+
+// SmokeMigrations applies the up migrations of dir (see MigrationsDir) to every
+// target, and records per statement whether each target accepted it. A failing
+// statement does not stop the run, so one run reports all dialect differences.
+func SmokeMigrations(ctx context.Context, dir string, targets ...SmokeTarget) (*SmokeReport, error) {
+	files, err := migrationFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SmokeReport{}
+	for _, target := range targets {
+		report.Targets = append(report.Targets, target.Name)
+	}
+
+	for _, file := range files {
+		stmts, err := splitSQLFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split sql file '%s': %w", file, err)
+		}
+		for _, stmt := range stmts {
+			result := SmokeResult{
+				File:      filepath.Base(file),
+				Statement: stmt,
+				Errors:    make(map[string]error, len(targets)),
+			}
+			for _, target := range targets {
+				_, err = target.DB.ExecContext(ctx, stmt)
+				result.Errors[target.Name] = err
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
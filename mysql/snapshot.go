@@ -0,0 +1,192 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// snapshotTable is a table saved by Snapshot
+type snapshotTable struct {
+	schema  string
+	name    string
+	ddl     string
+	columns []string
+	rows    [][]any
+}
+
+// Snapshot saves the tables and rows of the mock database and the ones of
+// AddDatabase, e.g. after the schema and seed data are loaded, so Reset can
+// restore them between tests sharing the server. A later Snapshot replaces
+// the saved one.
+func (b *MockBuilder) Snapshot() error {
+	if b.sqlxDB == nil {
+		return errors.New("mysql server not started")
+	}
+	tables, err := b.readTables(context.Background())
+	if err != nil {
+		return err
+	}
+
+	b.snapshotMu.Lock()
+	defer b.snapshotMu.Unlock()
+	b.snapshot = tables
+	return nil
+}
+
+// readTables reads the DDL and the rows of the tables of the databases of
+// the builder
+func (b *MockBuilder) readTables(ctx context.Context) ([]snapshotTable, error) {
+	var names []struct {
+		Schema string `db:"table_schema"`
+		Name   string `db:"table_name"`
+	}
+	filter, args := b.schemaFilter()
+	err := b.sqlxDB.SelectContext(ctx, &names, internalQueryPrefix+"SELECT table_schema AS table_schema, table_name AS table_name "+
+		"FROM information_schema.tables WHERE "+filter+" AND table_type = 'BASE TABLE' "+
+		"ORDER BY table_schema, table_name", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	tables := make([]snapshotTable, 0, len(names))
+	for _, n := range names {
+		t := snapshotTable{schema: n.Schema, name: n.Name}
+		name := b.qualifiedName(n.Schema, n.Name)
+		var ignored string
+		err = b.sqlxDB.QueryRowxContext(ctx, internalQueryPrefix+"SHOW CREATE TABLE "+t.quoted()).Scan(&ignored, &t.ddl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to show create table '%s': %w", name, err)
+		}
+
+		rows, err := b.sqlxDB.QueryxContext(ctx, internalQueryPrefix+"SELECT * FROM "+t.quoted())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table '%s': %w", name, err)
+		}
+		if t.columns, err = rows.Columns(); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to read table '%s': %w", name, err)
+		}
+		for rows.Next() {
+			row, err := rows.SliceScan()
+			if err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("failed to read table '%s': %w", name, err)
+			}
+			t.rows = append(t.rows, row)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table '%s': %w", name, err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// Reset restores the tables and rows saved by Snapshot: tables created since
+// are dropped, changed ones are recreated and refilled. Run it at the start
+// or the cleanup of every test sharing the server.
+func (b *MockBuilder) Reset() error {
+	b.snapshotMu.Lock()
+	defer b.snapshotMu.Unlock()
+	if b.snapshot == nil {
+		return errors.New("no snapshot to reset to, call Snapshot first")
+	}
+	return b.restoreTables(context.Background(), b.snapshot)
+}
+
+// restoreTables replaces the tables of the databases of the builder by the
+// given ones
+func (b *MockBuilder) restoreTables(ctx context.Context, tables []snapshotTable) error {
+	// foreign key checks are per session, keep them off on a single connection
+	conn, err := b.sqlxDB.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	exec := func(query string, args ...any) error {
+		_, err := conn.ExecContext(ctx, internalQueryPrefix+query, args...)
+		return err
+	}
+
+	if err = exec("SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return fmt.Errorf("failed to disable foreign key checks: %w", err)
+	}
+	defer func() { _ = exec("SET FOREIGN_KEY_CHECKS = 1") }()
+	// the DDL creates the table in the current database, back to the one of
+	// the builder before the pool reuses the connection
+	defer func() { _ = exec("USE " + quoteIdent(b.dbName)) }()
+
+	var current []snapshotTable
+	filter, args := b.schemaFilter()
+	rows, err := conn.QueryxContext(ctx, internalQueryPrefix+"SELECT table_schema, table_name "+
+		"FROM information_schema.tables WHERE "+filter+" AND table_type = 'BASE TABLE'", args...)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	for rows.Next() {
+		var t snapshotTable
+		if err = rows.Scan(&t.schema, &t.name); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to list tables: %w", err)
+		}
+		current = append(current, t)
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	for _, t := range current {
+		if err = exec("DROP TABLE " + t.quoted()); err != nil {
+			return fmt.Errorf("failed to drop table '%s': %w", b.qualifiedName(t.schema, t.name), err)
+		}
+	}
+
+	for _, t := range tables {
+		name := b.qualifiedName(t.schema, t.name)
+		if err = exec("USE " + quoteIdent(t.schema)); err != nil {
+			return fmt.Errorf("failed to recreate table '%s': %w", name, err)
+		}
+		if err = exec(t.ddl); err != nil {
+			return fmt.Errorf("failed to recreate table '%s': %w", name, err)
+		}
+		if len(t.rows) == 0 {
+			continue
+		}
+		columns := make([]string, len(t.columns))
+		for i, column := range t.columns {
+			columns[i] = quoteIdent(column)
+		}
+		placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+		for start := 0; start < len(t.rows); start += snapshotBatchSize {
+			end := min(start+snapshotBatchSize, len(t.rows))
+			values := make([]string, 0, end-start)
+			args := make([]any, 0, (end-start)*len(columns))
+			for _, row := range t.rows[start:end] {
+				values = append(values, placeholders)
+				args = append(args, row...)
+			}
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", t.quoted(),
+				strings.Join(columns, ", "), strings.Join(values, ", "))
+			if err = exec(query, args...); err != nil {
+				return fmt.Errorf("failed to restore rows of table '%s': %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+const snapshotBatchSize = 500
+
+// quoted returns the quoted name of the table qualified by its database
+func (t snapshotTable) quoted() string {
+	return quoteIdent(t.schema) + "." + quoteIdent(t.name)
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
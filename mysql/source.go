@@ -0,0 +1,223 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// InitSource provides SQL statements to be executed upon initialization.
+// Implement it to load init data from anywhere, and pass it to MockBuilder.InitFrom.
+type InitSource interface {
+	Statements(ctx context.Context) ([]string, error)
+}
+
+// InitSourceFunc adapts a function to an InitSource
+type InitSourceFunc func(ctx context.Context) ([]string, error)
+
+func (f InitSourceFunc) Statements(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// Stmts returns a source of raw SQL strings, each of them may hold several statements.
+func Stmts(stmts ...string) InitSource {
+	return stmtsSource(stmts)
+}
+
+type stmtsSource []string
+
+func (s stmtsSource) Statements(ctx context.Context) ([]string, error) {
+	var all []string
+	for _, stmt := range s {
+		split, err := splitSQLStatements(stmt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, split...)
+	}
+	return all, nil
+}
+
+// Files returns a source of SQL files, executed in the given order.
+func Files(files ...string) InitSource {
+	return filesSource(files)
+}
+
+type filesSource []string
+
+func (s filesSource) Statements(ctx context.Context) ([]string, error) {
+	var all []string
+	for _, file := range s {
+		stmts, err := splitSQLFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split sql file '%s': %w", file, err)
+		}
+		all = append(all, stmts...)
+	}
+	return all, nil
+}
+
+// InDatabase returns a source executing the statements of source in the
+// database db instead of the one of the builder
+func InDatabase(db string, source InitSource) InitSource {
+	return &dbSource{db: db, source: source}
+}
+
+type dbSource struct {
+	db     string
+	source InitSource
+}
+
+func (s *dbSource) Statements(ctx context.Context) ([]string, error) {
+	stmts, err := s.source.Statements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"USE " + quoteIdent(s.db)}, stmts...), nil
+}
+
+// Reader returns a source of the SQL read from r, e.g. a network response or
+// a generated buffer. name identifies the source in failure messages, r is
+// read once, on first use.
+func Reader(name string, r io.Reader) InitSource {
+	return &readerSource{name: name, r: r}
+}
+
+type readerSource struct {
+	name string
+	r    io.Reader
+
+	once    sync.Once
+	content string
+	err     error
+}
+
+func (s *readerSource) read() (string, error) {
+	s.once.Do(func() {
+		bs, err := io.ReadAll(s.r)
+		if err != nil {
+			s.err = fmt.Errorf("failed to read sql '%s': %w", s.name, err)
+			return
+		}
+		s.content = string(bs)
+	})
+	return s.content, s.err
+}
+
+func (s *readerSource) Statements(ctx context.Context) ([]string, error) {
+	content, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := splitSQLStatements(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split sql '%s': %w", s.name, err)
+	}
+	return stmts, nil
+}
+
+// sourceName names the source in failure messages
+func sourceName(source InitSource, i int) string {
+	if named, ok := source.(*readerSource); ok {
+		return fmt.Sprintf("'%s'", named.name)
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// FS returns a source of the SQL files in fsys (e.g. an embed.FS) matching
+// the glob patterns. Files of each pattern are executed in lexical order.
+func FS(fsys fs.FS, patterns ...string) InitSource {
+	return InitSourceFunc(func(ctx context.Context) ([]string, error) {
+		var all []string
+		for _, pattern := range patterns {
+			matches, err := fs.Glob(fsys, pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+			}
+			sort.Strings(matches)
+			for _, name := range matches {
+				bs, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read sql file '%s': %w", name, err)
+				}
+				stmts, err := splitSQLStatements(string(bs))
+				if err != nil {
+					return nil, fmt.Errorf("failed to split sql file '%s': %w", name, err)
+				}
+				all = append(all, stmts...)
+			}
+		}
+		return all, nil
+	})
+}
+
+// MigrationsDir returns a source of the up migrations in dir, named like
+// golang-migrate ("1_init.up.sql") or plain sql files, applied in version order.
+func MigrationsDir(dir string) InitSource {
+	return InitSourceFunc(func(ctx context.Context) ([]string, error) {
+		files, err := migrationFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		return Files(files...).Statements(ctx)
+	})
+}
+
+// migrationFiles returns the up migrations in dir in version order
+func migrationFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir '%s': %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return migrationVersion(files[i]) < migrationVersion(files[j])
+	})
+	return files, nil
+}
+
+// Dump returns a source of a mysqldump file, statements the mock
+// does not need (LOCK/UNLOCK TABLES) are skipped.
+func Dump(file string) InitSource {
+	return InitSourceFunc(func(ctx context.Context) ([]string, error) {
+		stmts, err := Files(file).Statements(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := make([]string, 0, len(stmts))
+		for _, stmt := range stmts {
+			upper := strings.ToUpper(stmt)
+			if strings.HasPrefix(upper, "LOCK TABLES") || strings.HasPrefix(upper, "UNLOCK TABLES") {
+				continue
+			}
+			filtered = append(filtered, stmt)
+		}
+		return filtered, nil
+	})
+}
+
+// migrationVersion returns the file name with its numeric version prefix
+// left padded, so that "10_x.up.sql" sorts after "9_x.up.sql".
+func migrationVersion(file string) string {
+	name := filepath.Base(file)
+	digits := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if digits <= 0 || digits >= 20 {
+		return name
+	}
+	return strings.Repeat("0", 20-digits) + name
+}
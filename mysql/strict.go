@@ -0,0 +1,118 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"regexp"
+	"testing"
+)
+
+// strictParameters flags the statements executed with literal values
+// instead of placeholders
+type strictParameters struct {
+	allow []*regexp.Regexp
+	found []RecordedQuery
+}
+
+// StrictParameters records the statements reading or writing tables that
+// the code under test executes with literal values instead of placeholders
+// from now on, not counting the init statements, e.g.
+// "SELECT * FROM users WHERE name = '" + name + "'", the string concatenated
+// SQL open to injections. LIMIT, ORDER BY and GROUP BY values are allowed,
+// and so are the statements matching the allow regexps, e.g. constant
+// filters like `status = 'active'`. Assert them with AssertParameterized.
+// Clients interpolating the parameters themselves, e.g. the go-sql-driver
+// with interpolateParams=true, send every statement with literals.
+func (b *MockBuilder) StrictParameters(allow ...string) *MockBuilder {
+	strict := &strictParameters{}
+	for _, pattern := range allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			b.err = fmt.Errorf("invalid allow pattern '%s': %w", pattern, err)
+			return b
+		}
+		strict.allow = append(strict.allow, re)
+	}
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	b.recorder.strict = strict
+	return b
+}
+
+// UnparameterizedQueries returns the statements flagged since
+// StrictParameters, in execution order
+func (b *MockBuilder) UnparameterizedQueries() []RecordedQuery {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+	if b.recorder.strict == nil {
+		return nil
+	}
+	return append([]RecordedQuery(nil), b.recorder.strict.found...)
+}
+
+// AssertParameterized asserts that no statement executed since
+// StrictParameters had literal values instead of placeholders
+func (b *MockBuilder) AssertParameterized(t testing.TB) bool {
+	t.Helper()
+
+	b.recorder.mu.Lock()
+	enabled := b.recorder.strict != nil
+	b.recorder.mu.Unlock()
+	if !enabled {
+		t.Errorf("unparameterized statements are not recorded, see StrictParameters")
+		return false
+	}
+	found := b.UnparameterizedQueries()
+	for _, q := range found {
+		t.Errorf("statement '%s' has the literal %s instead of a placeholder", q.Query, unparameterizedLiteral(q.Query))
+	}
+	return len(found) == 0
+}
+
+// check records the statement if it has literal values
+func (s *strictParameters) check(q RecordedQuery) {
+	if unparameterizedLiteral(q.Query) == "" {
+		return
+	}
+	normalized := normalizeQuery(q.Query)
+	for _, re := range s.allow {
+		if re.MatchString(normalized) {
+			return
+		}
+	}
+	s.found = append(s.found, q)
+}
+
+// unparameterizedLiteral returns the first literal value of a statement
+// reading or writing tables, empty if it has none
+func unparameterizedLiteral(query string) string {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return ""
+	}
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.SetOp, *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return ""
+	}
+
+	var tables bool
+	var literal string
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Limit, sqlparser.OrderBy, sqlparser.GroupBy:
+			return false, nil
+		case sqlparser.TableName:
+			tables = tables || !node.Name.IsEmpty()
+		case *sqlparser.SQLVal:
+			if node.Type != sqlparser.ValArg && literal == "" {
+				literal = sqlparser.String(node)
+			}
+		}
+		return true, nil
+	}, stmt)
+	if !tables {
+		return ""
+	}
+	return literal
+}
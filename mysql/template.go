@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the functions available to SQL fixture templates
+var templateFuncs = template.FuncMap{
+	// quote renders a value as a single quoted SQL string literal
+	"quote": func(v any) string {
+		return quoteString(fmt.Sprint(v))
+	},
+}
+
+// quoteString renders s as a single quoted SQL string literal
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `''`)
+	return "'" + s + "'"
+}
+
+// TemplateVars renders every init statement as a Go template with the given
+// variables before it is executed, e.g. "INSERT INTO t VALUES ({{.TenantID}}, {{quote .Name}})",
+// so one fixture set can serve many parameterized test cases. Referencing a
+// missing variable fails the build.
+func (b *MockBuilder) TemplateVars(vars map[string]any) *MockBuilder {
+	if b.templateVars == nil {
+		b.templateVars = make(map[string]any, len(vars))
+	}
+	for k, v := range vars {
+		b.templateVars[k] = v
+	}
+	return b
+}
+
+// renderStatements renders the statements with the template variables
+func (b *MockBuilder) renderStatements(stmts []string) ([]string, error) {
+	if b.templateVars == nil {
+		return stmts, nil
+	}
+
+	rendered := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		tpl, err := template.New("stmt").Funcs(templateFuncs).Option("missingkey=error").Parse(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sql template '%s': %w", stmt, err)
+		}
+		var buf bytes.Buffer
+		if err = tpl.Execute(&buf, b.templateVars); err != nil {
+			return nil, fmt.Errorf("failed to render sql template '%s': %w", stmt, err)
+		}
+		rendered = append(rendered, buf.String())
+	}
+	return rendered, nil
+}
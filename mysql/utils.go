@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -76,12 +77,12 @@ func cleanString(input string) string {
 	return cleaned
 }
 
-// getFreePort returns a free port on the local machine
-func getFreePort() (net.Listener, int, error) {
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+// listenPort binds the given port on the local machine and returns the bound port,
+// port 0 lets the OS choose a free one.
+func listenPort(port int) (net.Listener, int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
 	if err != nil {
 		return nil, 0, err
 	}
-	port := listener.Addr().(*net.TCPAddr).Port
-	return listener, port, nil
+	return listener, listener.Addr().(*net.TCPAddr).Port, nil
 }
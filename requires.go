@@ -0,0 +1,195 @@
+// Package mtest holds the entry points shared by the mock and container
+// helpers: the requirements of the tests, and the constructors of the most
+// used helpers for a test, e.g. MySQLMock or Redis.
+package mtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/dennis2006/mtest/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/testcontainers/testcontainers-go"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// EnvSkip lists the requirements whose tests are skipped, e.g. "doris,kafka",
+	// for CI pools that can't or shouldn't run them
+	EnvSkip = "MTEST_SKIP"
+	// EnvRequire lists the requirements whose tests fail instead of being
+	// skipped when unavailable, "all" for every requirement
+	EnvRequire = "MTEST_REQUIRE"
+)
+
+// Requirement is something a test needs from the machine it runs on
+type Requirement struct {
+	Name string
+	// Image must be present locally or pullable, empty for none
+	Image string
+	// MinMemory is the memory in bytes the docker daemon needs, 0 for none
+	MinMemory int64
+}
+
+// The requirements of the services, e.g. Requires(t, NeedKafka)
+var (
+	NeedDocker        = Requirement{Name: "docker"}
+	NeedMySQL         = Requirement{Name: "mysql", Image: container.MySQLImage}
+	NeedRedis         = Requirement{Name: "redis", Image: container.RedisImage}
+	NeedMongoDB       = Requirement{Name: "mongodb", Image: container.MongoDBImage}
+	NeedDoris         = Requirement{Name: "doris", Image: container.DorisImage, MinMemory: 4 << 30}
+	NeedGreptimeDB    = Requirement{Name: "greptimedb", Image: container.GreptimeDBImage}
+	NeedSpanner       = Requirement{Name: "spanner", Image: container.SpannerImage}
+	NeedRabbitMQ      = Requirement{Name: "rabbitmq", Image: container.RabbitMQImage}
+	NeedKafka         = Requirement{Name: "kafka", Image: container.KafkaImage, MinMemory: 1 << 30}
+	NeedElasticsearch = Requirement{Name: "elasticsearch", Image: container.ElasticsearchImage, MinMemory: 1 << 30}
+)
+
+// Unmet is a requirement that is not available, and why
+type Unmet struct {
+	Requirement string
+	Reason      string
+}
+
+func (u Unmet) String() string {
+	return fmt.Sprintf("%s: %s", u.Requirement, u.Reason)
+}
+
+// results caches the checks for the whole test binary
+var results struct {
+	mu     sync.Mutex
+	docker *dockerState
+	checks map[Requirement]string
+}
+
+type dockerState struct {
+	cli    *client.Client
+	memory int64
+	err    error
+}
+
+// Check returns the requirements that are not available. Every requirement
+// is checked once per process, the docker daemon is always required.
+func Check(ctx context.Context, reqs ...Requirement) []Unmet {
+	results.mu.Lock()
+	defer results.mu.Unlock()
+
+	if results.docker == nil {
+		results.docker = connectDocker(ctx)
+		results.checks = make(map[Requirement]string)
+	}
+
+	var unmet []Unmet
+	for _, req := range reqs {
+		reason, ok := results.checks[req]
+		if !ok {
+			reason = checkRequirement(ctx, results.docker, req)
+			results.checks[req] = reason
+		}
+		if reason != "" {
+			unmet = append(unmet, Unmet{Requirement: req.Name, Reason: reason})
+		}
+	}
+	return unmet
+}
+
+// Requires skips the test unless all the requirements are available, or
+// listed in MTEST_SKIP. Requirements listed in MTEST_REQUIRE fail the test
+// instead of skipping it, so a CI pool meant to run them can't silently
+// run nothing.
+func Requires(t testing.TB, reqs ...Requirement) {
+	t.Helper()
+
+	skip := envList(EnvSkip)
+	require := envList(EnvRequire)
+	for _, req := range reqs {
+		if skip[req.Name] {
+			t.Skipf("mtest: requirement %s skipped by %s", req.Name, EnvSkip)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	unmet := Check(ctx, reqs...)
+	if len(unmet) == 0 {
+		return
+	}
+
+	var reasons, required []string
+	for _, u := range unmet {
+		reasons = append(reasons, u.String())
+		if require["all"] || require[u.Requirement] {
+			required = append(required, u.String())
+		}
+	}
+	if len(required) > 0 {
+		t.Fatalf("mtest: requirements listed in %s are unavailable: %s", EnvRequire, strings.Join(required, "; "))
+	}
+	t.Skipf("mtest: unmet requirements: %s", strings.Join(reasons, "; "))
+}
+
+func envList(name string) map[string]bool {
+	list := make(map[string]bool)
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list[item] = true
+		}
+	}
+	return list
+}
+
+func connectDocker(ctx context.Context) *dockerState {
+	report := container.Doctor(ctx)
+	if !report.OK() {
+		return &dockerState{err: fmt.Errorf("no reachable docker daemon\n%s", report)}
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost(report.Selected.Host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return &dockerState{err: fmt.Errorf("failed to create docker client: %w", err)}
+	}
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return &dockerState{err: fmt.Errorf("failed to get docker info: %w", err)}
+	}
+	return &dockerState{cli: cli, memory: info.MemTotal}
+}
+
+// checkRequirement returns why the requirement is unmet, empty if it is met
+func checkRequirement(ctx context.Context, docker *dockerState, req Requirement) string {
+	if docker.err != nil {
+		return docker.err.Error()
+	}
+	if req.MinMemory > 0 && docker.memory < req.MinMemory {
+		return fmt.Sprintf("docker daemon has %s of memory, needs %s", formatBytes(docker.memory), formatBytes(req.MinMemory))
+	}
+	if req.Image == "" {
+		return ""
+	}
+
+	if _, err := docker.cli.ImageInspect(ctx, req.Image); err == nil {
+		return ""
+	} else if !errdefs.IsNotFound(err) {
+		return fmt.Sprintf("failed to inspect image %s: %v", req.Image, err)
+	}
+	// not present locally, check the registry serves it without pulling it
+	var auth string
+	if _, cfg, err := testcontainers.DockerImageAuth(ctx, req.Image); err == nil {
+		if encoded, err := json.Marshal(cfg); err == nil {
+			auth = base64.URLEncoding.EncodeToString(encoded)
+		}
+	}
+	if _, err := docker.cli.DistributionInspect(ctx, req.Image, auth); err != nil {
+		return fmt.Sprintf("image %s is neither present nor pullable: %v", req.Image, err)
+	}
+	return ""
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+}
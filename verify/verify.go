@@ -0,0 +1,43 @@
+// Package verify runs one test body against the mock MySQL server and a
+// MySQL container alike, so the mock stays honest about the SQL the tests
+// rely on without duplicating the tests.
+package verify
+
+import (
+	"github.com/jmoiron/sqlx"
+	"os"
+	"strings"
+	"testing"
+)
+
+// EnvMode selects the backends Both runs against: "mock", "container" or
+// "both", the default. CI pools without docker set it to "mock".
+const EnvMode = "MTEST_VERIFY"
+
+// Both runs fn as the subtests "mock" and "container", against the mock
+// and the container database. A nil containerDB skips the container
+// subtest, e.g. when the container is only started by some CI pools.
+// fn must leave the databases as it found them, or use fresh ones.
+func Both(t *testing.T, mockDB, containerDB *sqlx.DB, fn func(t *testing.T, db *sqlx.DB)) {
+	t.Helper()
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv(EnvMode)))
+
+	t.Run("mock", func(t *testing.T) {
+		if mode == "container" {
+			t.Skipf("verify: mock skipped by %s=%s", EnvMode, mode)
+		}
+		if mockDB == nil {
+			t.Fatal("verify: no mock database")
+		}
+		fn(t, mockDB)
+	})
+	t.Run("container", func(t *testing.T) {
+		if mode == "mock" {
+			t.Skipf("verify: container skipped by %s=%s", EnvMode, mode)
+		}
+		if containerDB == nil {
+			t.Skip("verify: no container database")
+		}
+		fn(t, containerDB)
+	})
+}